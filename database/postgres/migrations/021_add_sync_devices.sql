@@ -0,0 +1,26 @@
+-- Migration 021: Sync device registry
+-- Records each offline sync per device so the progress service can report
+-- real sync status and users can list and revoke their devices.
+
+CREATE TABLE IF NOT EXISTS sync_devices (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id VARCHAR(100) NOT NULL,
+    platform VARCHAR(20),               -- e.g. 'android', 'ios', 'web'
+    app_version VARCHAR(30),
+    last_sync_at TIMESTAMPTZ,
+    last_items_applied INTEGER NOT NULL DEFAULT 0,
+    last_items_failed INTEGER NOT NULL DEFAULT 0,
+    total_items_applied INTEGER NOT NULL DEFAULT 0,
+    total_items_failed INTEGER NOT NULL DEFAULT 0,
+    pending_items INTEGER NOT NULL DEFAULT 0,  -- client-reported items still queued on device
+    retry_items INTEGER NOT NULL DEFAULT 0,    -- items from last sync the client must retry
+    last_error TEXT,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_devices_user
+    ON sync_devices(user_id, last_sync_at DESC);
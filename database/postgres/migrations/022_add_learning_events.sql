@@ -0,0 +1,93 @@
+-- Migration 022: Learning event log
+-- Every learning action is appended to learning_events; user_progress,
+-- vocabulary_progress and lesson_rewards become projections of the log
+-- and can be rebuilt with `progress-service rebuild-projections`.
+
+CREATE TABLE IF NOT EXISTS learning_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    source_device VARCHAR(100),
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_learning_events_user
+    ON learning_events(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_learning_events_type
+    ON learning_events(event_type, occurred_at DESC);
+
+-- ================================================================
+-- TRIGGER: Events are immutable
+-- ================================================================
+-- Direct UPDATE/DELETE is rejected. Deletes cascading from users
+-- (trigger depth > 1) are allowed so accounts can still be removed.
+
+CREATE OR REPLACE FUNCTION prevent_learning_event_mutation()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'learning_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_learning_events_immutable ON learning_events;
+CREATE TRIGGER trigger_learning_events_immutable
+    BEFORE UPDATE OR DELETE ON learning_events
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_learning_event_mutation();
+
+-- ================================================================
+-- BACKFILL: Snapshot events for existing state
+-- ================================================================
+-- Rows are read through to_jsonb() so the backfill works with both the
+-- legacy (vocab_id, wrong_count, ...) and current column names.
+
+INSERT INTO learning_events (user_id, event_type, data, occurred_at)
+SELECT p.user_id, 'progress_imported',
+       jsonb_build_object(
+           'lesson_id', p.lesson_id,
+           'status', p.status,
+           'progress_percent', COALESCE(p.progress_percent, 0),
+           'quiz_score', p.quiz_score,
+           'time_spent_minutes', COALESCE(p.time_spent_minutes, 0),
+           'last_accessed_at', p.last_accessed_at::timestamptz,
+           'completed_at', p.completed_at::timestamptz
+       ),
+       COALESCE(p.created_at::timestamptz, NOW())
+FROM user_progress p
+WHERE NOT EXISTS (SELECT 1 FROM learning_events e WHERE e.event_type = 'progress_imported')
+ORDER BY p.user_id, p.id;
+
+INSERT INTO learning_events (user_id, event_type, data, occurred_at)
+SELECT (v.row->>'user_id')::int, 'vocabulary_imported',
+       jsonb_build_object(
+           'vocabulary_id', COALESCE(v.row->'vocabulary_id', v.row->'vocab_id'),
+           'mastery_level', COALESCE(v.row->'mastery_level', '0'),
+           'correct_count', COALESCE(v.row->'correct_count', '0'),
+           'incorrect_count', COALESCE(v.row->'incorrect_count', v.row->'wrong_count', '0'),
+           'last_reviewed_at', COALESCE(v.row->>'last_reviewed_at', v.row->>'last_reviewed', v.row->>'last_practiced')::timestamptz,
+           'next_review_at', COALESCE(v.row->>'next_review_at', v.row->>'next_review')::timestamptz,
+           'easiness_factor', COALESCE(v.row->'easiness_factor', v.row->'ease_factor', '2.5'),
+           'repetition_count', COALESCE(v.row->'repetition_count', '0'),
+           'interval_days', COALESCE(v.row->'interval_days', '1')
+       ),
+       COALESCE((v.row->>'created_at')::timestamptz, NOW())
+FROM (SELECT to_jsonb(vp) AS row FROM vocabulary_progress vp) v
+WHERE NOT EXISTS (SELECT 1 FROM learning_events e WHERE e.event_type = 'vocabulary_imported')
+ORDER BY (v.row->>'user_id')::int, (v.row->>'id')::int;
+
+INSERT INTO learning_events (user_id, event_type, data, occurred_at)
+SELECT r.user_id, 'lesson_reward_imported',
+       jsonb_build_object(
+           'lesson_id', r.lesson_id,
+           'lemons_earned', r.lemons_earned,
+           'best_quiz_score', r.best_quiz_score,
+           'earned_at', COALESCE(r.earned_at, NOW())
+       ),
+       COALESCE(r.earned_at, NOW())
+FROM lesson_rewards r
+WHERE NOT EXISTS (SELECT 1 FROM learning_events e WHERE e.event_type = 'lesson_reward_imported')
+ORDER BY r.user_id, r.id;
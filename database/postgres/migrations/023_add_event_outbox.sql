@@ -0,0 +1,28 @@
+-- Migration 023: Transactional outbox for progress domain events
+-- Rows are written in the same transaction as the change they describe
+-- and relayed to Redis Streams by the progress service (at-least-once).
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT,                    -- learning_events.id, when sourced from the event log
+    event_type VARCHAR(50) NOT NULL,
+    user_id INTEGER NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    published_at TIMESTAMPTZ,
+    dead_lettered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Pending messages in relay order
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending
+    ON event_outbox(next_attempt_at, id)
+    WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+
+-- Pruning of delivered messages
+CREATE INDEX IF NOT EXISTS idx_event_outbox_published
+    ON event_outbox(published_at)
+    WHERE published_at IS NOT NULL;
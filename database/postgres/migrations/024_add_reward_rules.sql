@@ -0,0 +1,49 @@
+-- Migration 024: Server-authoritative reward rules
+-- Lesson and boss quiz rewards are computed by the progress service from
+-- this table instead of trusting client-supplied amounts. Edits take
+-- effect within a minute (rules are cached in Redis), no redeploy needed.
+--
+-- Resolution:
+--   lesson: matching completion ('first' / 'replay' / 'any') with the highest
+--           min_score <= quiz score. Replays only credit the improvement over
+--           the best reward already earned for the lesson.
+--   boss:   most specific match (level + week > level > global), then the
+--           highest min_score <= score. No match = not passed.
+
+CREATE TABLE IF NOT EXISTS reward_rules (
+    id SERIAL PRIMARY KEY,
+    reward_type VARCHAR(20) NOT NULL CHECK (reward_type IN ('lesson', 'boss')),
+    completion VARCHAR(10) NOT NULL DEFAULT 'any' CHECK (completion IN ('first', 'replay', 'any')),
+    level INTEGER,
+    week INTEGER,
+    min_score INTEGER NOT NULL DEFAULT 0 CHECK (min_score BETWEEN 0 AND 100),
+    lemons INTEGER NOT NULL CHECK (lemons >= 0),
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    updated_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (week IS NULL OR level IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reward_rules_active
+    ON reward_rules(reward_type, min_score)
+    WHERE is_active = true;
+
+-- Rewards are no longer limited to the original 1-3 lemons per lesson
+ALTER TABLE lesson_rewards DROP CONSTRAINT IF EXISTS lesson_rewards_lemons_earned_check;
+ALTER TABLE lesson_rewards ADD CONSTRAINT lesson_rewards_lemons_earned_check
+    CHECK (lemons_earned >= 0);
+
+-- Seed from the existing gamification settings (migration 009)
+INSERT INTO reward_rules (reward_type, completion, min_score, lemons, description)
+SELECT v.reward_type, v.completion, v.min_score, v.lemons, v.description
+FROM gamification_settings s,
+LATERAL (VALUES
+    ('lesson', 'any', 0, 1, 'Lesson completed'),
+    ('lesson', 'any', COALESCE(s.lemon_2_threshold, 80), 2, 'Quiz score at 2-lemon threshold'),
+    ('lesson', 'any', COALESCE(s.lemon_3_threshold, 95), 3, 'Quiz score at 3-lemon threshold'),
+    ('boss', 'any', COALESCE(s.boss_quiz_pass_percent, 70), COALESCE(s.boss_quiz_bonus, 5), 'Boss quiz passed')
+) AS v(reward_type, completion, min_score, lemons, description)
+WHERE s.id = 1
+  AND NOT EXISTS (SELECT 1 FROM reward_rules);
@@ -0,0 +1,80 @@
+-- Migration 025: Double-entry lemon ledger
+-- Every lemon movement is a posting: a group of lemon_transactions rows
+-- (legs) sharing a posting_id whose amounts sum to zero. Each user account
+-- leg stores the balance after it was applied, and lemon_currency is kept
+-- in step with the ledger in the same transaction.
+--
+-- Accounts:
+--   wallet     lemon_currency.total_lemons (spendable balance)
+--   tree       lemon_currency.tree_lemons_available
+--   harvested  lemon_currency.tree_lemons_harvested
+--   rewards    system: source of earned lemons
+--   growth     system: source of lemons growing on the tree
+--   shop       system: sink for purchases
+--   opening    system: balances carried over from before the ledger
+--   adjustment system: reconciliation repairs
+--
+-- Postings:
+--   lesson/boss reward n   rewards -n, wallet +n, growth -n, tree +n
+--   harvest                tree -1, harvested +1
+--   purchase p             wallet -p, shop +p
+
+CREATE SEQUENCE IF NOT EXISTS lemon_posting_id_seq;
+
+ALTER TABLE lemon_transactions ADD COLUMN IF NOT EXISTS posting_id BIGINT;
+ALTER TABLE lemon_transactions ADD COLUMN IF NOT EXISTS account VARCHAR(20) NOT NULL DEFAULT 'wallet';
+ALTER TABLE lemon_transactions ADD COLUMN IF NOT EXISTS balance_after INTEGER;
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_account_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_account_check
+    CHECK (account IN ('wallet', 'tree', 'harvested', 'rewards', 'growth', 'shop', 'opening', 'adjustment'));
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'opening', 'adjustment'));
+
+CREATE INDEX IF NOT EXISTS idx_lemon_transactions_user_account
+    ON lemon_transactions(user_id, account, id);
+CREATE INDEX IF NOT EXISTS idx_lemon_transactions_posting
+    ON lemon_transactions(posting_id)
+    WHERE posting_id IS NOT NULL;
+
+-- Legacy harvest rows (+1) moved a lemon off the tree, not into the wallet
+UPDATE lemon_transactions
+SET account = 'harvested'
+WHERE type = 'harvest' AND posting_id IS NULL AND account = 'wallet';
+
+-- Opening postings: carry current balances into the ledger so that the sum
+-- of each account's legs (legacy rows included) equals lemon_currency
+WITH legacy AS (
+    SELECT user_id,
+           COALESCE(SUM(amount) FILTER (WHERE account = 'wallet'), 0) AS wallet,
+           COALESCE(SUM(amount) FILTER (WHERE account = 'harvested'), 0) AS harvested
+    FROM lemon_transactions
+    WHERE posting_id IS NULL
+    GROUP BY user_id
+),
+openings AS MATERIALIZED (
+    SELECT c.user_id,
+           nextval('lemon_posting_id_seq') AS posting_id,
+           c.total_lemons - COALESCE(l.wallet, 0) AS wallet,
+           c.tree_lemons_available AS tree,
+           c.tree_lemons_harvested - COALESCE(l.harvested, 0) AS harvested,
+           c.total_lemons, c.tree_lemons_available, c.tree_lemons_harvested
+    FROM lemon_currency c
+    LEFT JOIN legacy l ON l.user_id = c.user_id
+    WHERE NOT EXISTS (
+        SELECT 1 FROM lemon_transactions t
+        WHERE t.user_id = c.user_id AND t.type = 'opening'
+    )
+)
+INSERT INTO lemon_transactions (user_id, posting_id, account, amount, balance_after, type)
+SELECT o.user_id, o.posting_id, leg.account, leg.amount, leg.balance_after, 'opening'
+FROM openings o
+CROSS JOIN LATERAL (VALUES
+    ('wallet', o.wallet, o.total_lemons),
+    ('tree', o.tree, o.tree_lemons_available),
+    ('harvested', o.harvested, o.tree_lemons_harvested),
+    ('opening', -(o.wallet + o.tree + o.harvested), NULL)
+) AS leg(account, amount, balance_after)
+WHERE leg.amount <> 0;
@@ -0,0 +1,59 @@
+-- Migration 026: Server-side lemon tree simulation
+-- Each lemon on the tree is a row in lemon_tree_fruits. Lemons bud when the
+-- user earns rewards (up to max_tree_lemons), ripen after
+-- tree_ripen_minutes (studying shortens the wait for buds already on the
+-- tree), and ripe lemons wither one per tree_wither_interval_hours once the
+-- user has not studied for tree_wither_after_hours. Only ripe lemons can be
+-- harvested, at most once per tree_harvest_cooldown_seconds.
+--
+-- lemon_currency.tree_lemons_available (ledger account 'tree') always
+-- equals the number of fruits with status 'on_tree'.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS tree_ripen_minutes INTEGER DEFAULT 240;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS tree_study_boost_minutes INTEGER DEFAULT 30;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS tree_wither_after_hours INTEGER DEFAULT 72;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS tree_wither_interval_hours INTEGER DEFAULT 24;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS tree_harvest_cooldown_seconds INTEGER DEFAULT 60;
+
+CREATE TABLE IF NOT EXISTS lemon_trees (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_activity_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_harvest_at TIMESTAMPTZ,
+    next_wither_at TIMESTAMPTZ,         -- set once withering has started; cleared by study activity
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS lemon_tree_fruits (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'on_tree' CHECK (status IN ('on_tree', 'harvested', 'withered')),
+    grown_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ripens_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_lemon_tree_fruits_on_tree
+    ON lemon_tree_fruits(user_id, ripens_at)
+    WHERE status = 'on_tree';
+
+-- Ledger: withered lemons leave the tree to a system account
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_account_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_account_check
+    CHECK (account IN ('wallet', 'tree', 'harvested', 'rewards', 'growth', 'shop', 'withered', 'opening', 'adjustment'));
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'wither', 'opening', 'adjustment'));
+
+-- Existing tree lemons become ripe fruits; activity starts now so nothing
+-- withers before users have had a chance to study
+INSERT INTO lemon_trees (user_id)
+SELECT user_id FROM lemon_currency
+ON CONFLICT (user_id) DO NOTHING;
+
+INSERT INTO lemon_tree_fruits (user_id, grown_at, ripens_at)
+SELECT c.user_id, NOW(), NOW()
+FROM lemon_currency c
+CROSS JOIN LATERAL generate_series(1, c.tree_lemons_available)
+WHERE c.tree_lemons_available > 0
+  AND NOT EXISTS (SELECT 1 FROM lemon_tree_fruits f WHERE f.user_id = c.user_id);
@@ -0,0 +1,34 @@
+-- Migration 027: Verified ad rewards for lemon harvesting
+-- AdMob server-side verification callbacks are stored here (one row per
+-- transaction_id, so retried callbacks are idempotent). Each accepted
+-- callback is a single-use harvest grant that expires after
+-- ad_grant_ttl_minutes. Callbacks past ads_daily_reward_cap per user per
+-- UTC day are recorded as 'capped' and grant nothing.
+-- While ads_enabled is true, harvesting consumes one available grant.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS ads_daily_reward_cap INTEGER DEFAULT 10;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS ad_grant_ttl_minutes INTEGER DEFAULT 30;
+
+CREATE TABLE IF NOT EXISTS ad_reward_grants (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    transaction_id VARCHAR(128) NOT NULL UNIQUE,
+    ad_network VARCHAR(64),
+    ad_unit VARCHAR(64),
+    reward_item VARCHAR(64),
+    reward_amount INTEGER,
+    custom_data TEXT,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('available', 'consumed', 'capped')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    fruit_id BIGINT,                    -- lemon_tree_fruits.id harvested with this grant
+    rewarded_at TIMESTAMPTZ,            -- AdMob callback timestamp
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ad_reward_grants_available
+    ON ad_reward_grants(user_id, expires_at)
+    WHERE status = 'available';
+
+CREATE INDEX IF NOT EXISTS idx_ad_reward_grants_user_day
+    ON ad_reward_grants(user_id, created_at);
@@ -0,0 +1,69 @@
+-- Migration 028: Daily and weekly quests
+-- quest_templates is the pool of quests. Each UTC day (daily) and ISO week
+-- starting Monday (weekly) every user is assigned daily_quest_count /
+-- weekly_quest_count active templates, chosen by a per-user rotation, when
+-- they first study or open their quests in that period. user_quests keeps a
+-- snapshot of the template's target and reward so editing a template does
+-- not change quests already handed out.
+-- Progress comes from learning events and hangul practice; completed quests
+-- are claimed for lemons, posted to lemon_transactions as type 'quest'.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS daily_quest_count INTEGER DEFAULT 3;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS weekly_quest_count INTEGER DEFAULT 2;
+
+CREATE TABLE IF NOT EXISTS quest_templates (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    period VARCHAR(10) NOT NULL CHECK (period IN ('daily', 'weekly')),
+    metric VARCHAR(30) NOT NULL CHECK (metric IN (
+        'lessons_completed', 'vocabulary_reviewed', 'vocabulary_correct',
+        'hangul_practiced', 'hangul_correct'
+    )),
+    target INTEGER NOT NULL CHECK (target > 0),
+    reward_lemons INTEGER NOT NULL CHECK (reward_lemons > 0),
+    title VARCHAR(100) NOT NULL,
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO quest_templates (code, period, metric, target, reward_lemons, title, description) VALUES
+    ('daily_review_30_words',     'daily',  'vocabulary_reviewed', 30,  3,  '단어 30개 복습', 'Review 30 words'),
+    ('daily_correct_20_words',    'daily',  'vocabulary_correct',  20,  3,  '단어 20개 맞히기', 'Answer 20 words correctly'),
+    ('daily_finish_1_lesson',     'daily',  'lessons_completed',   1,   2,  '레슨 1개 완료', 'Finish 1 lesson'),
+    ('daily_finish_2_lessons',    'daily',  'lessons_completed',   2,   4,  '레슨 2개 완료', 'Finish 2 lessons'),
+    ('daily_perfect_5_hangul',    'daily',  'hangul_correct',      5,   2,  '한글 5자 완벽하게', 'Perfect 5 hangul characters'),
+    ('daily_practice_20_hangul',  'daily',  'hangul_practiced',    20,  2,  '한글 20자 연습', 'Practice 20 hangul characters'),
+    ('weekly_review_200_words',   'weekly', 'vocabulary_reviewed', 200, 15, '단어 200개 복습', 'Review 200 words this week'),
+    ('weekly_finish_10_lessons',  'weekly', 'lessons_completed',   10,  20, '레슨 10개 완료', 'Finish 10 lessons this week'),
+    ('weekly_perfect_50_hangul',  'weekly', 'hangul_correct',      50,  10, '한글 50자 완벽하게', 'Perfect 50 hangul characters this week')
+ON CONFLICT (code) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_quests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template_id INTEGER NOT NULL REFERENCES quest_templates(id) ON DELETE CASCADE,
+    period VARCHAR(10) NOT NULL CHECK (period IN ('daily', 'weekly')),
+    metric VARCHAR(30) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    progress INTEGER NOT NULL DEFAULT 0,
+    target INTEGER NOT NULL,
+    reward_lemons INTEGER NOT NULL,
+    completed_at TIMESTAMPTZ,
+    claimed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, template_id, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_quests_active
+    ON user_quests(user_id, metric, period_end)
+    WHERE completed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_quests_user_period
+    ON user_quests(user_id, period_start DESC);
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'wither', 'opening', 'adjustment', 'quest'));
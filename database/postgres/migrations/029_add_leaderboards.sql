@@ -0,0 +1,23 @@
+-- Migration 029: Leaderboards
+-- Live weekly and all-time leaderboards (lemons earned, reviews, study
+-- minutes) are Redis sorted sets maintained by the progress service.
+-- Finished weeks are archived here with their final ranks and removed from
+-- Redis. Users with leaderboard_opt_out are kept off every board.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS leaderboard_opt_out BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS leaderboard_archives (
+    week_start DATE NOT NULL,           -- Monday (UTC)
+    metric VARCHAR(20) NOT NULL CHECK (metric IN ('lemons', 'reviews', 'study_minutes')),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score BIGINT NOT NULL,
+    rank INTEGER NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (week_start, metric, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_leaderboard_archives_rank
+    ON leaderboard_archives(week_start, metric, rank);
+
+CREATE INDEX IF NOT EXISTS idx_leaderboard_archives_user
+    ON leaderboard_archives(user_id, week_start DESC);
@@ -0,0 +1,76 @@
+-- Migration 030: Weekly leagues
+-- Every user has a league tier (user_leagues, bronze by default). The first
+-- time a user earns lemons in a week (or opens the league screen) they join
+-- a cohort of up to league_cohort_size users in their tier for that week.
+-- Cohorts are ranked by the week's lemons earned (the weekly lemons
+-- leaderboard). After the week ends (Monday 00:00 UTC) a scheduled job in
+-- the progress service finalizes each cohort: the top promote_count move up
+-- a tier and are paid promotion_reward_lemons (lemon_transactions type
+-- 'league'), the bottom demote_count move down. Results are kept in
+-- league_members.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS league_cohort_size INTEGER DEFAULT 30;
+
+CREATE TABLE IF NOT EXISTS league_tiers (
+    tier INTEGER PRIMARY KEY,           -- 1 = lowest
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(50) NOT NULL,
+    promote_count INTEGER NOT NULL DEFAULT 0 CHECK (promote_count >= 0),
+    demote_count INTEGER NOT NULL DEFAULT 0 CHECK (demote_count >= 0),
+    promotion_reward_lemons INTEGER NOT NULL DEFAULT 0 CHECK (promotion_reward_lemons >= 0)
+);
+
+INSERT INTO league_tiers (tier, code, name, promote_count, demote_count, promotion_reward_lemons) VALUES
+    (1,  'bronze',   '브론즈',     10, 0, 5),
+    (2,  'silver',   '실버',       7,  5, 10),
+    (3,  'gold',     '골드',       7,  5, 15),
+    (4,  'sapphire', '사파이어',   5,  5, 20),
+    (5,  'ruby',     '루비',       5,  5, 25),
+    (6,  'emerald',  '에메랄드',   5,  5, 30),
+    (7,  'amethyst', '자수정',     5,  5, 35),
+    (8,  'pearl',    '진주',       5,  5, 40),
+    (9,  'obsidian', '흑요석',     5,  5, 50),
+    (10, 'diamond',  '다이아몬드', 0,  5, 0)
+ON CONFLICT (tier) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_leagues (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tier INTEGER NOT NULL DEFAULT 1 REFERENCES league_tiers(tier),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS league_cohorts (
+    id BIGSERIAL PRIMARY KEY,
+    week_start DATE NOT NULL,           -- Monday (UTC)
+    tier INTEGER NOT NULL REFERENCES league_tiers(tier),
+    member_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finalized_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_league_cohorts_open
+    ON league_cohorts(week_start, tier)
+    WHERE finalized_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS league_members (
+    id BIGSERIAL PRIMARY KEY,
+    cohort_id BIGINT NOT NULL REFERENCES league_cohorts(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_start DATE NOT NULL,
+    tier INTEGER NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set when the cohort is finalized
+    final_rank INTEGER,
+    weekly_lemons BIGINT,
+    result VARCHAR(10) CHECK (result IN ('promoted', 'stayed', 'demoted')),
+    new_tier INTEGER,
+    reward_lemons INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (user_id, week_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_league_members_cohort ON league_members(cohort_id);
+CREATE INDEX IF NOT EXISTS idx_league_members_user ON league_members(user_id, week_start DESC);
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'wither', 'opening', 'adjustment', 'quest', 'league'));
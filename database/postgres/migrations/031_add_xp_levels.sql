@@ -0,0 +1,36 @@
+-- Migration 031: Experience points and learner levels
+-- XP measures study effort and, unlike lemons, is never spent. It is
+-- awarded in the same transaction as the activity: a completed lesson, each
+-- vocabulary review, each hangul practice and each passed boss quiz, with
+-- the amounts below. xp_levels is the level curve (total XP needed to reach
+-- each level) and may name a character item that is added to the user's
+-- inventory on reaching that level. Every level-up is also written to the
+-- event outbox as a 'level_up' event.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS xp_per_lesson INTEGER DEFAULT 20;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS xp_per_review INTEGER DEFAULT 2;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS xp_per_hangul INTEGER DEFAULT 1;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS xp_per_boss_quiz INTEGER DEFAULT 50;
+
+CREATE TABLE IF NOT EXISTS xp_levels (
+    level INTEGER PRIMARY KEY CHECK (level >= 1),
+    xp_required BIGINT NOT NULL UNIQUE CHECK (xp_required >= 0),
+    unlock_item_id INTEGER REFERENCES character_items(id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (level > 1 OR xp_required = 0)
+);
+
+-- Default curve: reaching level n takes 50 * n * (n - 1) XP
+-- (100 for level 2, 300 for level 3, ... 122,500 for level 50)
+INSERT INTO xp_levels (level, xp_required)
+SELECT n, 50 * n * (n - 1) FROM generate_series(1, 50) AS n
+ON CONFLICT (level) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_xp (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    total_xp BIGINT NOT NULL DEFAULT 0 CHECK (total_xp >= 0),
+    level INTEGER NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_xp_total ON user_xp(total_xp DESC);
@@ -0,0 +1,59 @@
+-- Migration 032: Friends and friend activity feed
+-- Friendships are mutual and start with a friend request; they are stored
+-- in both directions (user_id, friend_id) so lists are a single lookup.
+-- Follows and blocks reuse the SNS tables (user_follows, user_blocks, with
+-- the users follower/following counters); blocking removes friendships,
+-- follows and pending requests in both directions.
+-- activity_feed holds notable progress events (lesson completions, streak
+-- milestones, level-ups, league promotions). Who may see a user's items is
+-- decided when the feed is read, from users.activity_privacy:
+--   public  - friends and followers
+--   friends - friends only (default)
+--   private - nobody
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS activity_privacy VARCHAR(10) DEFAULT 'friends'
+    CHECK (activity_privacy IN ('public', 'friends', 'private'));
+
+CREATE TABLE IF NOT EXISTS friend_requests (
+    id BIGSERIAL PRIMARY KEY,
+    requester_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    addressee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMPTZ,
+    CHECK (requester_id != addressee_id)
+);
+
+-- At most one pending request per pair, whichever side sent it
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friend_requests_pending_pair
+    ON friend_requests (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id))
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_friend_requests_addressee
+    ON friend_requests (addressee_id, created_at DESC) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_friend_requests_requester
+    ON friend_requests (requester_id, created_at DESC) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS friendships (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    friend_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, friend_id),
+    CHECK (user_id != friend_id)
+);
+
+CREATE TABLE IF NOT EXISTS activity_feed (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN (
+        'lesson_completed', 'streak_milestone', 'level_up', 'league_promoted'
+    )),
+    dedupe_key VARCHAR(100),
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_feed_user ON activity_feed (user_id, id DESC);
+-- Items that must only be recorded once (e.g. a streak milestone per day)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_feed_dedupe
+    ON activity_feed (user_id, kind, dedupe_key) WHERE dedupe_key IS NOT NULL;
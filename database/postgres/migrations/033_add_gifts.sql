@@ -0,0 +1,37 @@
+-- Migration 033: Gifting lemons and items between friends
+-- A gift moves lemons from the sender's wallet to a friend's wallet, or
+-- buys a shop item the friend does not own yet and adds it to the friend's
+-- inventory. Both are posted to the lemon ledger as 'gift' transactions
+-- (source_id = gifts.id) in the same transaction as the gift row.
+-- Anti-abuse limits, checked for the sender over the current UTC day:
+--   gift_daily_count_limit     - gifts of any kind
+--   gift_daily_lemon_limit     - lemons given directly (item prices excluded)
+--   gift_min_account_age_days  - both accounts must be at least this old
+-- The gift row is also the recipient's notification: unseen gifts have
+-- seen_at NULL until the recipient opens them.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS gift_daily_count_limit INTEGER DEFAULT 5;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS gift_daily_lemon_limit INTEGER DEFAULT 50;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS gift_min_account_age_days INTEGER DEFAULT 7;
+
+CREATE TABLE IF NOT EXISTS gifts (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('lemons', 'item')),
+    lemons INTEGER NOT NULL DEFAULT 0 CHECK (lemons >= 0),
+    item_id INTEGER REFERENCES character_items(id) ON DELETE SET NULL,
+    message VARCHAR(200),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    seen_at TIMESTAMPTZ,
+    CHECK (sender_id != recipient_id),
+    CHECK (kind != 'lemons' OR lemons > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gifts_recipient ON gifts (recipient_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_gifts_recipient_unseen ON gifts (recipient_id) WHERE seen_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_gifts_sender ON gifts (sender_id, created_at DESC);
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'wither', 'opening', 'adjustment', 'quest', 'league', 'gift'));
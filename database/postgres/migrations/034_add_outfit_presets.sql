@@ -0,0 +1,19 @@
+-- Migration 034: Saved outfit presets
+-- A preset is a named look: the full slot map ({"hat": 12, "top": 20, ...},
+-- empty slots left out) and a skin colour. Applying a preset replaces the
+-- whole character in one transaction after checking that every item still
+-- fits its slot and is owned (or is a default item). Required slots missing
+-- from the map get their default item.
+
+CREATE TABLE IF NOT EXISTS outfit_presets (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    slots JSONB NOT NULL DEFAULT '{}',
+    skin_color VARCHAR(7) NOT NULL DEFAULT '#FFDBB4',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_outfit_presets_user ON outfit_presets (user_id, created_at);
@@ -0,0 +1,37 @@
+-- Migration 035: Multiple rooms and validated furniture layouts
+-- A user can have several named rooms; exactly one is active and is the
+-- room visitors see. Furniture now belongs to a room and has a rotation
+-- (clockwise degrees) and a layer (0 = floor, higher = stacked on a
+-- surface item). Layouts are validated in the progress service against
+-- ownership, the room grid and the items' footprint/stacking metadata.
+-- Existing furniture moves into an active "My Room" per user.
+
+CREATE TABLE IF NOT EXISTS user_rooms (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(30) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_rooms_active ON user_rooms (user_id) WHERE is_active;
+
+INSERT INTO user_rooms (user_id, name, is_active)
+SELECT DISTINCT user_id, 'My Room', true FROM user_room_furniture
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE user_room_furniture
+    ADD COLUMN IF NOT EXISTS room_id BIGINT REFERENCES user_rooms(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS rotation SMALLINT NOT NULL DEFAULT 0 CHECK (rotation IN (0, 90, 180, 270)),
+    ADD COLUMN IF NOT EXISTS layer SMALLINT NOT NULL DEFAULT 0 CHECK (layer BETWEEN 0 AND 2);
+
+UPDATE user_room_furniture f
+SET room_id = r.id
+FROM user_rooms r
+WHERE r.user_id = f.user_id AND r.is_active AND f.room_id IS NULL;
+
+ALTER TABLE user_room_furniture ALTER COLUMN room_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_room_furniture_room ON user_room_furniture (room_id);
@@ -0,0 +1,50 @@
+-- Migration 036: Visiting and liking rooms
+-- Each room has a visibility: 'public' (anyone who has not been blocked),
+-- 'friends' or 'private' (owner only). Visitors always see the owner's
+-- active room. A visit counts once per visitor per room per UTC day.
+-- A user can like a room once. The owner earns
+-- room_like_reward_lemons for a like, posted to the ledger as a
+-- 'room_like' transaction (source_id = user_rooms.id), up to
+-- room_like_daily_lemon_cap lemons per UTC day over all their rooms.
+-- room_like_rewards remembers every (room, liker) pair that was
+-- considered for a reward, so unliking and liking again pays nothing.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS room_like_reward_lemons INTEGER DEFAULT 1;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS room_like_daily_lemon_cap INTEGER DEFAULT 10;
+
+ALTER TABLE user_rooms
+    ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'friends', 'private')),
+    ADD COLUMN IF NOT EXISTS visit_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS like_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS room_visits (
+    room_id BIGINT NOT NULL REFERENCES user_rooms(id) ON DELETE CASCADE,
+    visitor_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    visit_date DATE NOT NULL,
+    PRIMARY KEY (room_id, visitor_id, visit_date)
+);
+
+CREATE TABLE IF NOT EXISTS room_likes (
+    room_id BIGINT NOT NULL REFERENCES user_rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_likes_created ON room_likes (created_at);
+
+CREATE TABLE IF NOT EXISTS room_like_rewards (
+    room_id BIGINT NOT NULL REFERENCES user_rooms(id) ON DELETE CASCADE,
+    liker_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    lemons INTEGER NOT NULL DEFAULT 0 CHECK (lemons >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, liker_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_like_rewards_owner ON room_like_rewards (owner_id, created_at);
+
+ALTER TABLE lemon_transactions DROP CONSTRAINT IF EXISTS lemon_transactions_type_check;
+ALTER TABLE lemon_transactions ADD CONSTRAINT lemon_transactions_type_check
+    CHECK (type IN ('lesson', 'boss', 'harvest', 'bonus', 'purchase', 'wither', 'opening', 'adjustment', 'quest', 'league', 'gift', 'room_like'));
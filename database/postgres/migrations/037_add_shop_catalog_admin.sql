@@ -0,0 +1,82 @@
+-- Migration 037: Shop catalogue administration
+-- Admins manage character_items through the progress service. Items can be
+-- scheduled: they are on sale from available_from (inclusive) until
+-- available_until (exclusive); NULL leaves that side of the window open.
+-- sort_order orders items within a category in the shop.
+-- Every catalogue change is recorded in shop_item_audit with the admin who
+-- made it and the changed fields as {"field": {"old": ..., "new": ...}}.
+-- The admin panel (services/admin) still writes character_items directly,
+-- so a trigger audits writes the progress service has not: the writer may
+-- set shop.admin_id for the transaction to be recorded as the admin, and
+-- the progress service sets shop.audited to skip the trigger.
+
+ALTER TABLE character_items
+    ADD COLUMN IF NOT EXISTS sort_order INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS available_from TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS available_until TIMESTAMPTZ;
+
+ALTER TABLE character_items DROP CONSTRAINT IF EXISTS character_items_availability_check;
+ALTER TABLE character_items ADD CONSTRAINT character_items_availability_check
+    CHECK (available_from IS NULL OR available_until IS NULL OR available_until > available_from);
+
+CREATE INDEX IF NOT EXISTS idx_character_items_shop ON character_items (category, sort_order, price)
+    WHERE is_active = true;
+
+CREATE TABLE IF NOT EXISTS shop_item_audit (
+    id BIGSERIAL PRIMARY KEY,
+    item_id INTEGER REFERENCES character_items(id) ON DELETE SET NULL,
+    admin_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'deactivate', 'reorder')),
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shop_item_audit_item ON shop_item_audit (item_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shop_item_audit_created ON shop_item_audit (created_at DESC);
+
+CREATE OR REPLACE FUNCTION audit_character_item_write()
+RETURNS TRIGGER AS $$
+DECLARE
+    before_row JSONB := '{}';
+    after_row JSONB := to_jsonb(NEW);
+    item_changes JSONB;
+    audit_action VARCHAR(20) := 'update';
+BEGIN
+    IF current_setting('shop.audited', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        audit_action := 'create';
+    ELSE
+        before_row := to_jsonb(OLD);
+        IF COALESCE(OLD.is_active, true) AND NOT COALESCE(NEW.is_active, true) THEN
+            audit_action := 'deactivate';
+        END IF;
+    END IF;
+
+    SELECT COALESCE(jsonb_object_agg(a.key, jsonb_build_object('old', b.value, 'new', a.value)), '{}')
+    INTO item_changes
+    FROM jsonb_each(after_row) a
+    LEFT JOIN jsonb_each(before_row) b ON b.key = a.key
+    WHERE a.key NOT IN ('id', 'created_at', 'updated_at')
+      AND (TG_OP = 'INSERT' OR a.value IS DISTINCT FROM b.value);
+
+    -- Purchases only ever decrement stock (migration 038) and are not
+    -- catalogue changes; restocks and every other change are audited
+    IF TG_OP = 'UPDATE' AND (item_changes = '{}' OR (item_changes - 'stock' = '{}'
+        AND (after_row->>'stock')::int < (before_row->>'stock')::int)) THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO shop_item_audit (item_id, admin_id, action, changes)
+    VALUES (NEW.id, NULLIF(current_setting('shop.admin_id', true), '')::int, audit_action, item_changes);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_character_items_audit ON character_items;
+CREATE TRIGGER trigger_character_items_audit
+    AFTER INSERT OR UPDATE ON character_items
+    FOR EACH ROW
+    EXECUTE FUNCTION audit_character_item_write();
@@ -0,0 +1,32 @@
+-- Migration 038: Daily featured shop, sales and limited stock
+-- Every UTC day the shop features shop_featured_count items, picked
+-- deterministically from what is on sale: the same for everyone when
+-- shop_featured_scope is 'global', different per user when it is 'user'.
+-- A sale takes discount_percent off an item's price from starts_at
+-- (inclusive) until ends_at (exclusive). When sales overlap the biggest
+-- discount applies. Purchases and item gifts are charged the sale price.
+-- stock is how many more copies can be sold; NULL means unlimited. It is
+-- decremented in the purchase transaction and never goes below 0.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS shop_featured_count INTEGER DEFAULT 6;
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS shop_featured_scope VARCHAR(10) DEFAULT 'global';
+
+ALTER TABLE character_items ADD COLUMN IF NOT EXISTS stock INTEGER CHECK (stock IS NULL OR stock >= 0);
+
+CREATE TABLE IF NOT EXISTS shop_sales (
+    id BIGSERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL REFERENCES character_items(id) ON DELETE CASCADE,
+    discount_percent INTEGER NOT NULL CHECK (discount_percent BETWEEN 1 AND 90),
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shop_sales_item ON shop_sales (item_id, ends_at);
+CREATE INDEX IF NOT EXISTS idx_shop_sales_ends ON shop_sales (ends_at);
+
+ALTER TABLE shop_item_audit DROP CONSTRAINT IF EXISTS shop_item_audit_action_check;
+ALTER TABLE shop_item_audit ADD CONSTRAINT shop_item_audit_action_check
+    CHECK (action IN ('create', 'update', 'deactivate', 'reorder', 'sale_create', 'sale_end'));
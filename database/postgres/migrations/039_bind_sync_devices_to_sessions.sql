@@ -0,0 +1,7 @@
+-- Migration 039: Bind sync devices to auth sessions
+-- device_id is chosen by the client, so revoking it alone does not stop a
+-- device from syncing under a new ID. Each sync now records the auth
+-- session (sessions.id) it was made with, and revoking a device ends that
+-- session: its tokens stop working for sync and can no longer be refreshed.
+
+ALTER TABLE sync_devices ADD COLUMN IF NOT EXISTS session_id UUID;
@@ -0,0 +1,10 @@
+-- Migration 040: Cap study minutes counted on the leaderboards
+-- Lesson time is reported by the client. Only study_minutes_request_cap
+-- minutes of one write (a lesson completion, progress update or sync unit)
+-- and study_minutes_daily_cap minutes per user per UTC day count towards
+-- the study_minutes boards. Progress records keep the reported time.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS study_minutes_request_cap INTEGER DEFAULT 120
+    CHECK (study_minutes_request_cap >= 0);
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS study_minutes_daily_cap INTEGER DEFAULT 480
+    CHECK (study_minutes_daily_cap >= 0);
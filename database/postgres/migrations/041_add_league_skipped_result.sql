@@ -0,0 +1,8 @@
+-- Migration 041: Skip league members who opted out of leaderboards
+-- Members with users.leaderboard_opt_out are hidden from their cohort's
+-- standings. When the cohort is finalized they are not ranked, keep their
+-- tier and get result 'skipped' (no final_rank or weekly_lemons).
+
+ALTER TABLE league_members DROP CONSTRAINT IF EXISTS league_members_result_check;
+ALTER TABLE league_members ADD CONSTRAINT league_members_result_check
+    CHECK (result IN ('promoted', 'stayed', 'demoted', 'skipped'));
@@ -0,0 +1,39 @@
+-- Migration 042: Achievements in the friend activity feed
+-- Badges are written to user_achievements by several services, so the
+-- feed item is recorded by a trigger rather than by each writer. An
+-- achievement is earned once per user, so achievement_type is the dedupe
+-- key. Existing achievements are backfilled at their earned_at time.
+
+ALTER TABLE activity_feed DROP CONSTRAINT IF EXISTS activity_feed_kind_check;
+ALTER TABLE activity_feed ADD CONSTRAINT activity_feed_kind_check CHECK (kind IN (
+    'lesson_completed', 'streak_milestone', 'level_up', 'league_promoted', 'achievement_earned'
+));
+
+CREATE OR REPLACE FUNCTION record_achievement_activity()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO activity_feed (user_id, kind, dedupe_key, data, created_at)
+    VALUES (
+        NEW.user_id, 'achievement_earned', NEW.achievement_type,
+        jsonb_build_object('achievement_type', NEW.achievement_type,
+                           'achievement_data', COALESCE(NEW.achievement_data, '{}'::jsonb)),
+        COALESCE(NEW.earned_at, NOW())
+    )
+    ON CONFLICT (user_id, kind, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_user_achievements_activity ON user_achievements;
+CREATE TRIGGER trigger_user_achievements_activity
+    AFTER INSERT ON user_achievements
+    FOR EACH ROW
+    EXECUTE FUNCTION record_achievement_activity();
+
+INSERT INTO activity_feed (user_id, kind, dedupe_key, data, created_at)
+SELECT user_id, 'achievement_earned', achievement_type,
+       jsonb_build_object('achievement_type', achievement_type,
+                          'achievement_data', COALESCE(achievement_data, '{}'::jsonb)),
+       COALESCE(earned_at, NOW())
+FROM user_achievements
+ON CONFLICT (user_id, kind, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING;
@@ -0,0 +1,6 @@
+-- Migration 043: Daily limit on lemons received as gifts
+-- The gift limits were per sender only, so many accounts could funnel
+-- lemons into one. gift_received_daily_lemon_limit caps the lemons a user
+-- can receive from gifts per UTC day, across all senders.
+
+ALTER TABLE gamification_settings ADD COLUMN IF NOT EXISTS gift_received_daily_lemon_limit INTEGER DEFAULT 100;
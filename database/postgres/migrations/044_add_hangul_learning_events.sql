@@ -0,0 +1,24 @@
+-- Migration 044: Hangul practice in the learning event log
+-- Hangul answers are now recorded as hangul_practiced events, and
+-- hangul_progress becomes a projection of the log like
+-- vocabulary_progress. Existing rows are backfilled as hangul_imported
+-- snapshot events so rebuilding projections keeps them.
+
+INSERT INTO learning_events (user_id, event_type, data, occurred_at)
+SELECT h.user_id, 'hangul_imported',
+       jsonb_build_object(
+           'character_id', h.character_id,
+           'mastery_level', COALESCE(h.mastery_level, 0),
+           'correct_count', COALESCE(h.correct_count, 0),
+           'wrong_count', COALESCE(h.wrong_count, 0),
+           'streak_count', COALESCE(h.streak_count, 0),
+           'last_practiced', h.last_practiced::timestamptz,
+           'next_review', h.next_review::timestamptz,
+           'easiness_factor', COALESCE(h.ease_factor, 2.5),
+           'interval_days', COALESCE(h.interval_days, 1),
+           'repetition_count', COALESCE(h.repetition_count, 0)
+       ),
+       COALESCE(h.created_at::timestamptz, NOW())
+FROM hangul_progress h
+WHERE NOT EXISTS (SELECT 1 FROM learning_events e WHERE e.event_type = 'hangul_imported')
+ORDER BY h.user_id, h.id;
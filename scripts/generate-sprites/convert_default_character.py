@@ -36,6 +36,8 @@ SHEET_H = ROWS * FRAME_H        # 192
 SCRIPT_DIR = os.path.dirname(os.path.abspath(__file__))
 REPO_ROOT = os.path.abspath(os.path.join(SCRIPT_DIR, '..', '..'))
 OUTPUT_BASE = os.path.join(REPO_ROOT, 'mobile', 'lemon_korean', 'assets', 'sprites', 'character')
+# The media service renders avatars server-side from its own copy
+MEDIA_OUTPUT_BASE = os.path.join(REPO_ROOT, 'services', 'media', 'utils', 'bundled', 'assets', 'sprites', 'character')
 
 # Other default layers to make transparent
 OTHER_DEFAULTS = [
@@ -230,19 +232,21 @@ def main():
     sheet = create_spritesheet(front_idle, back_idle, right_idle)
 
     # Save body_default.png
-    body_path = os.path.join(OUTPUT_BASE, 'body', 'body_default.png')
-    os.makedirs(os.path.dirname(body_path), exist_ok=True)
-    sheet.save(body_path, 'PNG')
-    print(f"\n  Saved: {os.path.relpath(body_path, REPO_ROOT)}")
+    for base in (OUTPUT_BASE, MEDIA_OUTPUT_BASE):
+        body_path = os.path.join(base, 'body', 'body_default.png')
+        os.makedirs(os.path.dirname(body_path), exist_ok=True)
+        sheet.save(body_path, 'PNG')
+        print(f"\n  Saved: {os.path.relpath(body_path, REPO_ROOT)}")
 
     # Create transparent spritesheets for other default layers
     # (so they don't overlay geometric shapes on the full character)
     transparent = Image.new('RGBA', (SHEET_W, SHEET_H), (0, 0, 0, 0))
     for rel_path in OTHER_DEFAULTS:
-        out_path = os.path.join(OUTPUT_BASE, rel_path)
-        os.makedirs(os.path.dirname(out_path), exist_ok=True)
-        transparent.save(out_path, 'PNG')
-        print(f"  Saved (transparent): {os.path.relpath(out_path, REPO_ROOT)}")
+        for base in (OUTPUT_BASE, MEDIA_OUTPUT_BASE):
+            out_path = os.path.join(base, rel_path)
+            os.makedirs(os.path.dirname(out_path), exist_ok=True)
+            transparent.save(out_path, 'PNG')
+            print(f"  Saved (transparent): {os.path.relpath(out_path, REPO_ROOT)}")
 
     print(f"\nDone! Default character spritesheets generated.")
 
@@ -40,6 +40,8 @@ SKIN_PALETTES = ['#FFF0DB', '#FFDBAC', '#D4A574', '#A67C52']
 SCRIPT_DIR = os.path.dirname(os.path.abspath(__file__))
 REPO_ROOT = os.path.abspath(os.path.join(SCRIPT_DIR, '..', '..'))
 OUTPUT_BASE = os.path.join(REPO_ROOT, 'mobile', 'lemon_korean', 'assets', 'sprites', 'character')
+# The media service renders avatars server-side from its own copy
+MEDIA_OUTPUT_BASE = os.path.join(REPO_ROOT, 'services', 'media', 'utils', 'bundled', 'assets', 'sprites', 'character')
 
 
 def hex_to_rgb(h):
@@ -236,12 +238,15 @@ def generate_spritesheet(key, spec):
         draw_fn(draw, ox, oy, 'front', i, color)
         gesture_col += 1
 
-    # Save
-    out_dir = os.path.join(OUTPUT_BASE, os.path.dirname(key))
-    os.makedirs(out_dir, exist_ok=True)
-    out_path = os.path.join(OUTPUT_BASE, f'{key}.png')
-    img.save(out_path, 'PNG')
-    return out_path
+    # Save (the first path is the app's copy)
+    paths = []
+    for base in (OUTPUT_BASE, MEDIA_OUTPUT_BASE):
+        out_dir = os.path.join(base, os.path.dirname(key))
+        os.makedirs(out_dir, exist_ok=True)
+        out_path = os.path.join(base, f'{key}.png')
+        img.save(out_path, 'PNG')
+        paths.append(out_path)
+    return paths[0]
 
 
 def main():
@@ -6,6 +6,27 @@ const mediaService = require('../services/media-upload.service');
  * Manages character customization item catalog (CRUD)
  */
 
+/**
+ * Run catalogue writes in a transaction tagged with the admin's ID, so the
+ * character_items audit trigger (migration 037) records who made them
+ */
+const withAdminAudit = async (req, fn) => {
+  const client = await pool.getClient();
+  try {
+    await client.query('BEGIN');
+    const adminId = req.user && req.user.id ? String(req.user.id) : '';
+    await client.query("SELECT set_config('shop.admin_id', $1, true)", [adminId]);
+    const result = await fn(client);
+    await client.query('COMMIT');
+    return result;
+  } catch (error) {
+    await client.query('ROLLBACK');
+    throw error;
+  } finally {
+    client.release();
+  }
+};
+
 /**
  * Get character items with filters
  * @route GET /api/admin/character-items
@@ -96,14 +117,14 @@ const createItem = async (req, res) => {
       return res.status(400).json({ error: 'category, name, and asset_key are required' });
     }
 
-    const result = await pool.query(
+    const result = await withAdminAudit(req, (client) => client.query(
       `INSERT INTO character_items
        (category, name, description, asset_key, asset_type, is_bundled, render_order, price, rarity, is_default, metadata)
        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
        RETURNING *`,
       [category, name, description, asset_key, asset_type, is_bundled,
        render_order, price, rarity, is_default, JSON.stringify(metadata)]
-    );
+    ));
 
     res.status(201).json({ success: true, data: result.rows[0] });
   } catch (error) {
@@ -124,6 +145,15 @@ const updateItem = async (req, res) => {
       is_bundled, render_order, price, rarity, is_default, is_active, metadata,
     } = req.body;
 
+    // Users may own or wear the item, and equipment slots are checked
+    // against its category
+    if (category !== undefined) {
+      const existing = await pool.query('SELECT category FROM character_items WHERE id = $1', [id]);
+      if (existing.rows.length > 0 && existing.rows[0].category !== category) {
+        return res.status(400).json({ error: 'category cannot be changed; create a new item instead' });
+      }
+    }
+
     // Build dynamic UPDATE query
     const fields = [];
     const values = [];
@@ -149,10 +179,10 @@ const updateItem = async (req, res) => {
     fields.push(`updated_at = NOW()`);
     values.push(id);
 
-    const result = await pool.query(
+    const result = await withAdminAudit(req, (client) => client.query(
       `UPDATE character_items SET ${fields.join(', ')} WHERE id = $${paramIndex} RETURNING *`,
       values
-    );
+    ));
 
     if (result.rows.length === 0) {
       return res.status(404).json({ error: 'Item not found' });
@@ -173,10 +203,10 @@ const deleteItem = async (req, res) => {
   try {
     const { id } = req.params;
 
-    const result = await pool.query(
+    const result = await withAdminAudit(req, (client) => client.query(
       'UPDATE character_items SET is_active = false, updated_at = NOW() WHERE id = $1 RETURNING id',
       [id]
-    );
+    ));
 
     if (result.rows.length === 0) {
       return res.status(404).json({ error: 'Item not found' });
@@ -218,10 +248,10 @@ const uploadSprite = async (req, res) => {
         const currentMeta = existing.rows[0].metadata || {};
         const updatedMeta = { ...currentMeta, spritesheet_key: result.key };
 
-        await pool.query(
+        await withAdminAudit(req, (client) => client.query(
           `UPDATE character_items SET asset_type = 'spritesheet', asset_key = $1, metadata = $2, updated_at = NOW() WHERE id = $3`,
           [result.key, JSON.stringify(updatedMeta), itemId]
-        );
+        ));
         console.log(`[CHARACTER-ITEMS] Item ${itemId} updated with sprite key: ${result.key}`);
       }
     }
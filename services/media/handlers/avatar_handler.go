@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"lemonkorean/media/config"
+	"lemonkorean/media/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+)
+
+// AvatarHandler renders characters to images and serves cached renders
+type AvatarHandler struct {
+	minioClient *minio.Client
+	progressURL string
+	httpClient  *http.Client
+}
+
+// NewAvatarHandler creates a new avatar handler
+func NewAvatarHandler(minioClient *minio.Client) *AvatarHandler {
+	progressURL := os.Getenv("PROGRESS_SERVICE_URL")
+	if progressURL == "" {
+		progressURL = "http://progress-service:3003"
+	}
+
+	return &AvatarHandler{
+		minioClient: minioClient,
+		progressURL: strings.TrimSuffix(progressURL, "/"),
+		httpClient:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ================================================================
+// CONSTANTS
+// ================================================================
+
+const (
+	DefaultAvatarSize = 256
+	MinAvatarSize     = 32
+	MaxAvatarSize     = 1024
+	avatarPrefix      = "avatars/"
+)
+
+// ================================================================
+// HANDLER 1: RENDER AVATAR
+// ================================================================
+
+// RenderAvatar renders a user's equipped character to an image
+// GET /media/avatars/render/:userId?size=256&format=png|webp
+// Requires authentication; the caller's token is used to read the
+// character from the progress service. Renders are cached by outfit hash,
+// and Content-Location holds the public URL of the cached image.
+func (h *AvatarHandler) RenderAvatar(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid user ID",
+		})
+		return
+	}
+
+	size := DefaultAvatarSize
+	if v := c.Query("size"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil || size < MinAvatarSize || size > MaxAvatarSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": fmt.Sprintf("size must be between %d and %d", MinAvatarSize, MaxAvatarSize),
+			})
+			return
+		}
+	}
+
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "webp" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "format must be png or webp",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	spec, status, err := h.fetchAvatarSpec(ctx, userID, c.GetHeader("Authorization"))
+	if err != nil {
+		log.Printf("[MEDIA] Error fetching avatar for user %d: %v", userID, err)
+		c.JSON(status, gin.H{
+			"error":   http.StatusText(status),
+			"message": "Failed to get character",
+		})
+		return
+	}
+
+	name := utils.AvatarCacheName(spec.Hash, size, format)
+	etag := `"` + strings.TrimSuffix(name, "."+format) + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, contentType, err := h.cachedAvatar(ctx, name)
+	if err != nil {
+		data, contentType, err = h.renderAvatar(ctx, spec, size, format)
+		if err != nil {
+			log.Printf("[MEDIA] Error rendering avatar for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to render avatar",
+			})
+			return
+		}
+
+		// Caching is best-effort; the render is served either way
+		_, err = h.minioClient.PutObject(ctx, config.BucketImages, avatarPrefix+name,
+			bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+		if err != nil {
+			log.Printf("[MEDIA] Error caching avatar %s: %v", name, err)
+		}
+	}
+
+	// The render depends on the user's current outfit, so clients revalidate
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	c.Header("Content-Location", "/media/avatars/"+name)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ================================================================
+// HANDLER 2: SERVE CACHED AVATAR
+// ================================================================
+
+// ServeAvatar serves a cached render by file name. The name contains the
+// outfit hash, so the content never changes.
+// GET /media/avatars/:file
+func (h *AvatarHandler) ServeAvatar(c *gin.Context) {
+	file := c.Param("file")
+	if file == "" || strings.Contains(file, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Avatar file is required",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	object, err := h.minioClient.GetObject(ctx, config.BucketImages, avatarPrefix+file, minio.GetObjectOptions{})
+	if err != nil {
+		log.Printf("[MEDIA] Error fetching avatar: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Avatar not found",
+		})
+		return
+	}
+	defer object.Close()
+
+	stat, err := object.Stat()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Avatar not found",
+		})
+		return
+	}
+
+	if c.GetHeader("If-None-Match") == stat.ETag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contentType := stat.ContentType
+	if contentType == "" {
+		contentType = getContentType(file)
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", fmt.Sprintf("%d", stat.Size))
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", stat.ETag)
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, object)
+}
+
+// ================================================================
+// HELPER FUNCTIONS
+// ================================================================
+
+// fetchAvatarSpec reads the user's render layers from the progress service.
+// On failure it returns the status to answer with.
+func (h *AvatarHandler) fetchAvatarSpec(ctx context.Context, userID int64, authorization string) (*utils.AvatarSpec, int, error) {
+	url := fmt.Sprintf("%s/api/progress/character/%d/avatar", h.progressURL, userID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, resp.StatusCode, fmt.Errorf("progress service returned %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, http.StatusBadGateway, fmt.Errorf("progress service returned %d", resp.StatusCode)
+	}
+
+	var spec utils.AvatarSpec
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("failed to decode avatar: %w", err)
+	}
+	if spec.Hash == "" {
+		return nil, http.StatusBadGateway, fmt.Errorf("avatar has no hash")
+	}
+	return &spec, http.StatusOK, nil
+}
+
+// cachedAvatar reads a cached render
+func (h *AvatarHandler) cachedAvatar(ctx context.Context, name string) ([]byte, string, error) {
+	object, err := h.minioClient.GetObject(ctx, config.BucketImages, avatarPrefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer object.Close()
+
+	stat, err := object.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType := stat.ContentType
+	if contentType == "" {
+		contentType = getContentType(name)
+	}
+	return data, contentType, nil
+}
+
+// renderAvatar loads the layer images and composites them. SVG layers
+// cannot be drawn and are left out; any other layer that fails to load
+// fails the render, so an incomplete avatar is never cached.
+func (h *AvatarHandler) renderAvatar(ctx context.Context, spec *utils.AvatarSpec, size int, format string) ([]byte, string, error) {
+	skin, err := utils.ParseHexColor(spec.SkinColor)
+	if err != nil {
+		skin, _ = utils.ParseHexColor("#FFDBB4")
+	}
+
+	images := make([]image.Image, len(spec.Layers))
+	for i, layer := range spec.Layers {
+		key := layer.ObjectKey()
+		if key == "" {
+			log.Printf("[MEDIA] Skipping avatar layer %d: %s cannot be rendered", layer.ItemID, layer.AssetKey)
+			continue
+		}
+		img, err := h.loadImage(ctx, key)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load layer %d (%s): %w", layer.ItemID, key, err)
+		}
+		images[i] = img
+	}
+
+	canvas := utils.RenderAvatar(spec.Layers, images, skin, size)
+	return utils.EncodeAvatar(canvas, format)
+}
+
+// loadImage decodes an image bundled with the app or fetched from storage
+func (h *AvatarHandler) loadImage(ctx context.Context, key string) (image.Image, error) {
+	var data []byte
+	if utils.IsBundledAsset(key) {
+		var err error
+		if data, err = utils.ReadBundledAsset(key); err != nil {
+			return nil, err
+		}
+	} else {
+		object, err := h.minioClient.GetObject(ctx, config.UnifiedBucket, strings.TrimPrefix(key, "/"), minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer object.Close()
+
+		if data, err = io.ReadAll(object); err != nil {
+			return nil, err
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
@@ -37,8 +37,9 @@ func main() {
 
 	log.Println("✓ MinIO client initialized successfully")
 
-	// Initialize handler
+	// Initialize handlers
 	mediaHandler := handlers.NewMediaHandler(minioClient)
+	avatarHandler := handlers.NewAvatarHandler(minioClient)
 
 	// Create Gin router
 	router := gin.Default()
@@ -64,6 +65,10 @@ func main() {
 		media.GET("/audio/:key", mediaHandler.ServeAudio)     // Supports range requests for streaming
 		media.GET("/thumbnails/:key", mediaHandler.ServeThumbnail) // Supports ?size=X
 		media.GET("/models/*filepath", mediaHandler.ServeModel)     // AI model files (speech recognition)
+		media.GET("/avatars/:file", avatarHandler.ServeAvatar)      // Cached character renders
+
+		// Character rendering (requires authentication)
+		media.GET("/avatars/render/:userId", middleware.AuthMiddleware(), avatarHandler.RenderAvatar) // ?size=X&format=png|webp
 
 		// Admin endpoints - Upload and delete (require authentication)
 		media.POST("/upload", middleware.AuthMiddleware(), mediaHandler.UploadMedia)       // ?type=images|audio|video
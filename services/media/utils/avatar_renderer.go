@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+)
+
+// ================================================================
+// AVATAR RENDERING
+// ================================================================
+
+// AvatarRenderVersion is part of every cached avatar key. Bump it when the
+// rendering changes so old renders are not served.
+const AvatarRenderVersion = 2
+
+// AvatarAspect is the character canvas width:height, as drawn by the app
+const (
+	AvatarAspectWidth  = 3
+	AvatarAspectHeight = 4
+)
+
+// AvatarSprite locates the first (front-facing) frame of a spritesheet
+type AvatarSprite struct {
+	Key         string `json:"key"`
+	FrameWidth  int    `json:"frame_width"`
+	FrameHeight int    `json:"frame_height"`
+}
+
+// AvatarLayer is one equipped item, as returned by the progress service
+type AvatarLayer struct {
+	ItemID      int64         `json:"item_id"`
+	Category    string        `json:"category"`
+	AssetKey    string        `json:"asset_key"`
+	AssetType   string        `json:"asset_type"`
+	RenderOrder int           `json:"render_order"`
+	Sprite      *AvatarSprite `json:"sprite,omitempty"`
+	Tint        bool          `json:"tint"`
+}
+
+// AvatarSpec is a character to render, layers ordered back to front
+type AvatarSpec struct {
+	UserID    int64         `json:"user_id"`
+	SkinColor string        `json:"skin_color"`
+	Layers    []AvatarLayer `json:"layers"`
+	Hash      string        `json:"hash"`
+}
+
+// ObjectKey returns the storage key of the layer's image, or "" if the layer
+// cannot be drawn on the server (SVG assets)
+func (l AvatarLayer) ObjectKey() string {
+	if l.Sprite != nil && l.Sprite.Key != "" {
+		return l.Sprite.Key
+	}
+	if l.AssetType == "svg" || strings.HasSuffix(strings.ToLower(l.AssetKey), ".svg") {
+		return ""
+	}
+	return l.AssetKey
+}
+
+// AvatarCacheName returns the file name of a cached render
+func AvatarCacheName(hash string, size int, format string) string {
+	return fmt.Sprintf("%s_v%d_%d.%s", hash, AvatarRenderVersion, size, format)
+}
+
+// ParseHexColor parses a #RRGGBB colour
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// RenderAvatar composites decoded layer images (nil entries are skipped)
+// onto a transparent canvas size pixels tall. Spritesheets are cut to their
+// first frame, tinted layers take the skin colour, and every layer is scaled
+// to fit the canvas and centred, like the app's character widget.
+func RenderAvatar(layers []AvatarLayer, images []image.Image, skin color.NRGBA, size int) *image.NRGBA {
+	width := size * AvatarAspectWidth / AvatarAspectHeight
+	canvas := imaging.New(width, size, color.NRGBA{})
+
+	for i, layer := range layers {
+		img := images[i]
+		if img == nil {
+			continue
+		}
+
+		if s := layer.Sprite; s != nil && s.FrameWidth > 0 && s.FrameHeight > 0 {
+			b := img.Bounds()
+			img = imaging.Crop(img, image.Rect(b.Min.X, b.Min.Y, b.Min.X+s.FrameWidth, b.Min.Y+s.FrameHeight))
+		}
+		if layer.Tint {
+			img = tintImage(img, skin)
+		}
+
+		// Pixel-art sprites stay crisp when scaled up
+		filter := imaging.Lanczos
+		if b := img.Bounds(); b.Dx() < width && b.Dy() < size {
+			filter = imaging.NearestNeighbor
+		}
+		fitted := fitInto(img, width, size, filter)
+
+		pos := image.Pt((width-fitted.Bounds().Dx())/2, (size-fitted.Bounds().Dy())/2)
+		canvas = imaging.Overlay(canvas, fitted, pos, 1.0)
+	}
+
+	return canvas
+}
+
+// EncodeAvatar encodes a render as png or webp (lossless, keeps transparency)
+func EncodeAvatar(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "webp":
+		if err := webp.Encode(&buf, img, &webp.Options{Lossless: true}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode webp: %w", err)
+		}
+		return buf.Bytes(), "image/webp", nil
+	default:
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode png: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+}
+
+// tintImage replaces every pixel's colour with c, keeping its alpha
+// (the app's BlendMode.srcIn colour filter)
+func tintImage(img image.Image, c color.NRGBA) *image.NRGBA {
+	out := imaging.Clone(img)
+	for i := 0; i < len(out.Pix); i += 4 {
+		out.Pix[i] = c.R
+		out.Pix[i+1] = c.G
+		out.Pix[i+2] = c.B
+	}
+	return out
+}
+
+// fitInto scales img to the largest size that fits width x height, keeping
+// its aspect ratio. Unlike imaging.Fit it also scales up.
+func fitInto(img image.Image, width, height int, filter imaging.ResampleFilter) *image.NRGBA {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return imaging.New(1, 1, color.NRGBA{})
+	}
+
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return imaging.Resize(img, w, h, filter)
+}
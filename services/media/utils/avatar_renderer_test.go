@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	red   = color.NRGBA{R: 255, A: 255}
+	green = color.NRGBA{G: 255, A: 255}
+	blue  = color.NRGBA{B: 255, A: 255}
+)
+
+// sheet returns a spritesheet of one row of 32x48 frames in the given colours
+func sheet(frames ...color.NRGBA) *image.NRGBA {
+	img := imaging.New(32*len(frames), 48, color.NRGBA{})
+	for i, c := range frames {
+		img = imaging.Paste(img, imaging.New(32, 48, c), image.Pt(32*i, 0))
+	}
+	return img
+}
+
+var frame = &AvatarSprite{Key: "assets/sprites/character/body/body_default.png", FrameWidth: 32, FrameHeight: 48}
+
+// centre returns the colour in the middle of a render
+func centre(img *image.NRGBA) color.NRGBA {
+	b := img.Bounds()
+	return img.NRGBAAt(b.Dx()/2, b.Dy()/2)
+}
+
+func TestRenderAvatarLayerOrder(t *testing.T) {
+	layers := []AvatarLayer{{ItemID: 1, Sprite: frame}, {ItemID: 2, Sprite: frame}, {ItemID: 3, Sprite: frame}}
+
+	img := RenderAvatar(layers, []image.Image{sheet(red), sheet(green), nil}, red, 96)
+	if got := centre(img); got != green {
+		t.Errorf("later layers should be drawn on top: got %v, want %v", got, green)
+	}
+
+	img = RenderAvatar(layers, []image.Image{sheet(green), sheet(red), nil}, red, 96)
+	if got := centre(img); got != red {
+		t.Errorf("later layers should be drawn on top: got %v, want %v", got, red)
+	}
+
+	// 32x48 frames fit a 72x96 canvas at 64x96, centred
+	if got := img.Bounds().Size(); got != image.Pt(72, 96) {
+		t.Errorf("canvas size = %v, want 72x96", got)
+	}
+	if got := img.NRGBAAt(1, 48); got.A != 0 {
+		t.Errorf("canvas outside the layers should be transparent, got %v", got)
+	}
+}
+
+func TestRenderAvatarTintsBody(t *testing.T) {
+	skin, _ := ParseHexColor("#FFDBB4")
+
+	body := sheet(color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	img := RenderAvatar([]AvatarLayer{{ItemID: 1, Sprite: frame, Tint: true}}, []image.Image{body}, skin, 96)
+	if got := centre(img); got != skin {
+		t.Errorf("tinted layer = %v, want skin colour %v", got, skin)
+	}
+
+	img = RenderAvatar([]AvatarLayer{{ItemID: 1, Sprite: frame}}, []image.Image{body}, skin, 96)
+	if got := centre(img); got == skin {
+		t.Error("untinted layer should keep its colour")
+	}
+}
+
+func TestRenderAvatarUsesFirstFrame(t *testing.T) {
+	layers := []AvatarLayer{{ItemID: 1, Sprite: frame}}
+
+	img := RenderAvatar(layers, []image.Image{sheet(red, blue, blue)}, red, 96)
+	for _, x := range []int{5, 36, 66} {
+		if got := img.NRGBAAt(x, 48); got != red {
+			t.Errorf("pixel %d = %v, want the first frame's %v", x, got, red)
+		}
+	}
+}
+
+func TestBundledSpritesDecode(t *testing.T) {
+	if !IsBundledAsset(frame.Key) || !IsBundledAsset("/"+frame.Key) || IsBundledAsset("images/crown.png") {
+		t.Fatal("IsBundledAsset does not match app asset keys")
+	}
+
+	data, err := ReadBundledAsset(frame.Key)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", frame.Key, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", frame.Key, err)
+	}
+	if b := img.Bounds(); b.Dx() < frame.FrameWidth || b.Dy() < frame.FrameHeight {
+		t.Errorf("%s is smaller than one frame: %v", frame.Key, b)
+	}
+
+	if _, err := ReadBundledAsset("assets/../bundled_assets.go"); err == nil {
+		t.Error("paths outside the bundled assets should not be readable")
+	}
+}
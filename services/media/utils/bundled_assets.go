@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+// ================================================================
+// BUNDLED ASSETS
+// ================================================================
+// Default character sprites ship inside the app (keys starting with
+// "assets/") and are not uploaded to storage. Copies live in bundled/ so
+// the avatar renderer can draw them; scripts/generate-sprites writes both.
+// ================================================================
+
+// BundledAssetPrefix is the key prefix of assets bundled with the app
+const BundledAssetPrefix = "assets/"
+
+//go:embed bundled
+var bundledAssets embed.FS
+
+// IsBundledAsset reports whether key refers to an asset bundled with the app
+func IsBundledAsset(key string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(key, "/"), BundledAssetPrefix)
+}
+
+// ReadBundledAsset returns the contents of a bundled asset
+func ReadBundledAsset(key string) ([]byte, error) {
+	return fs.ReadFile(bundledAssets, "bundled/"+strings.TrimPrefix(key, "/"))
+}
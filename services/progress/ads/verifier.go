@@ -0,0 +1,243 @@
+package ads
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ================================================================
+// AD REWARD VERIFICATION
+// ================================================================
+// AdMob server-side verification (SSV): after a rewarded ad completes,
+// AdMob calls our callback URL with the reward details, signed with
+// ECDSA (SHA-256) over the query string preceding "&signature=". Keys are
+// published as JSON at DefaultKeysURL; ADMOB_VERIFIER_KEYS_FILE points at
+// a local file in the same format for development and tests.
+// ================================================================
+
+const (
+	DefaultKeysURL = "https://www.gstatic.com/admob/reward/verifier-keys.json"
+
+	keysRefreshInterval = 24 * time.Hour
+	keysRetryInterval   = time.Minute
+)
+
+var (
+	// ErrInvalidSignature is returned for callbacks that fail verification
+	ErrInvalidSignature = errors.New("invalid ad reward signature")
+
+	// ErrUnknownKey is returned when the callback's key_id is not published
+	ErrUnknownKey = errors.New("unknown ad reward verifier key")
+)
+
+// RewardCallback is a verified SSV callback
+type RewardCallback struct {
+	AdNetwork     string
+	AdUnit        string
+	CustomData    string
+	RewardAmount  int
+	RewardItem    string
+	Timestamp     time.Time
+	TransactionID string
+	UserID        string
+	KeyID         int64
+}
+
+// verifierKeys is the published key list format
+type verifierKeys struct {
+	Keys []struct {
+		KeyID int64  `json:"keyId"`
+		PEM   string `json:"pem"`
+	} `json:"keys"`
+}
+
+// Verifier checks SSV callback signatures
+type Verifier struct {
+	keysURL  string
+	keysFile string
+	client   *http.Client
+
+	mu          sync.Mutex
+	keys        map[int64]*ecdsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	static      bool
+}
+
+// NewVerifier creates a verifier configured from ADMOB_VERIFIER_KEYS_URL
+// and ADMOB_VERIFIER_KEYS_FILE (the file takes precedence)
+func NewVerifier() *Verifier {
+	keysURL := os.Getenv("ADMOB_VERIFIER_KEYS_URL")
+	if keysURL == "" {
+		keysURL = DefaultKeysURL
+	}
+
+	return &Verifier{
+		keysURL:  keysURL,
+		keysFile: os.Getenv("ADMOB_VERIFIER_KEYS_FILE"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		keys:     map[int64]*ecdsa.PublicKey{},
+	}
+}
+
+// NewStaticVerifier creates a verifier with a fixed key set
+func NewStaticVerifier(keys map[int64]*ecdsa.PublicKey) *Verifier {
+	return &Verifier{keys: keys, static: true}
+}
+
+// Verify checks the signature of a raw callback query string and parses it
+func (v *Verifier) Verify(ctx context.Context, rawQuery string) (*RewardCallback, error) {
+	idx := strings.Index(rawQuery, "&signature=")
+	if idx < 0 {
+		return nil, ErrInvalidSignature
+	}
+	message := rawQuery[:idx]
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid callback query: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(params.Get("signature"), "="))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	keyID, err := strconv.ParseInt(params.Get("key_id"), 10, 64)
+	if err != nil {
+		return nil, ErrUnknownKey
+	}
+
+	key, err := v.key(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(message))
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	callback := &RewardCallback{
+		AdNetwork:     params.Get("ad_network"),
+		AdUnit:        params.Get("ad_unit"),
+		CustomData:    params.Get("custom_data"),
+		RewardItem:    params.Get("reward_item"),
+		TransactionID: params.Get("transaction_id"),
+		UserID:        params.Get("user_id"),
+		KeyID:         keyID,
+	}
+	callback.RewardAmount, _ = strconv.Atoi(params.Get("reward_amount"))
+	if ms, err := strconv.ParseInt(params.Get("timestamp"), 10, 64); err == nil {
+		callback.Timestamp = time.UnixMilli(ms).UTC()
+	}
+
+	if callback.TransactionID == "" {
+		return nil, fmt.Errorf("callback is missing transaction_id")
+	}
+
+	return callback, nil
+}
+
+// key returns a verifier key. The key set is reloaded when it is older than
+// a day or the key is unknown (keys rotate), at most once a minute.
+func (v *Verifier) key(ctx context.Context, keyID int64) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[keyID]
+	if v.static || (ok && time.Since(v.fetchedAt) < keysRefreshInterval) {
+		if ok {
+			return key, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	if time.Since(v.lastAttempt) >= keysRetryInterval {
+		v.lastAttempt = time.Now()
+		keys, err := v.loadKeys(ctx)
+		if err != nil {
+			log.Printf("[ADS] Failed to load verifier keys: %v", err)
+		} else {
+			v.keys = keys
+			v.fetchedAt = time.Now()
+		}
+	}
+
+	if key, ok := v.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// loadKeys reads the key set from the local file or the published URL
+func (v *Verifier) loadKeys(ctx context.Context) (map[int64]*ecdsa.PublicKey, error) {
+	var data []byte
+	if v.keysFile != "" {
+		var err error
+		if data, err = os.ReadFile(v.keysFile); err != nil {
+			return nil, fmt.Errorf("failed to read keys file: %w", err)
+		}
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.keysURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := v.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch keys: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch keys: status %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, fmt.Errorf("failed to read keys: %w", err)
+		}
+	}
+
+	return ParseKeys(data)
+}
+
+// ParseKeys parses a key list in the published JSON format
+func ParseKeys(data []byte) (map[int64]*ecdsa.PublicKey, error) {
+	var list verifierKeys
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid keys JSON: %w", err)
+	}
+
+	keys := make(map[int64]*ecdsa.PublicKey, len(list.Keys))
+	for _, k := range list.Keys {
+		block, _ := pem.Decode([]byte(k.PEM))
+		if block == nil {
+			return nil, fmt.Errorf("key %d: invalid PEM", k.KeyID)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", k.KeyID, err)
+		}
+		ecKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key %d: not an ECDSA key", k.KeyID)
+		}
+		keys[k.KeyID] = ecKey
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,100 @@
+package ads
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyID = 1234567890
+
+func signCallback(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(message))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return message + "&signature=" + base64.RawURLEncoding.EncodeToString(sig) + "&key_id=1234567890"
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+const testMessage = "ad_network=5450213213286189855&ad_unit=1234567890&custom_data=harvest" +
+	"&reward_amount=1&reward_item=lemon&timestamp=1760000000000&transaction_id=abc123&user_id=42"
+
+func TestVerifyValidCallback(t *testing.T) {
+	key := newTestKey(t)
+	v := NewStaticVerifier(map[int64]*ecdsa.PublicKey{testKeyID: &key.PublicKey})
+
+	cb, err := v.Verify(context.Background(), signCallback(t, key, testMessage))
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", cb.TransactionID)
+	assert.Equal(t, "42", cb.UserID)
+	assert.Equal(t, "harvest", cb.CustomData)
+	assert.Equal(t, 1, cb.RewardAmount)
+	assert.Equal(t, int64(1760000000000), cb.Timestamp.UnixMilli())
+}
+
+func TestVerifyRejectsTamperedCallback(t *testing.T) {
+	key := newTestKey(t)
+	v := NewStaticVerifier(map[int64]*ecdsa.PublicKey{testKeyID: &key.PublicKey})
+
+	raw := signCallback(t, key, testMessage)
+	tampered := "ad_network=5450213213286189855&ad_unit=1234567890&custom_data=harvest" +
+		"&reward_amount=1&reward_item=lemon&timestamp=1760000000000&transaction_id=abc123&user_id=43" +
+		raw[len(testMessage):]
+
+	_, err := v.Verify(context.Background(), tampered)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerifyRejectsOtherKey(t *testing.T) {
+	key := newTestKey(t)
+	other := newTestKey(t)
+	v := NewStaticVerifier(map[int64]*ecdsa.PublicKey{testKeyID: &other.PublicKey})
+
+	_, err := v.Verify(context.Background(), signCallback(t, key, testMessage))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	v = NewStaticVerifier(map[int64]*ecdsa.PublicKey{})
+	_, err = v.Verify(context.Background(), signCallback(t, key, testMessage))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestVerifierLoadsKeysFile(t *testing.T) {
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{{
+			"keyId": testKeyID,
+			"pem":   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		}},
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	t.Setenv("ADMOB_VERIFIER_KEYS_FILE", path)
+
+	cb, err := NewVerifier().Verify(context.Background(), signCallback(t, key, testMessage))
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", cb.TransactionID)
+}
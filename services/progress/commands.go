@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"lemonkorean/progress/repository"
+)
+
+// ================================================================
+// MAINTENANCE COMMANDS
+// ================================================================
+// Run with the service binary instead of starting the server:
+//   progress-service rebuild-projections -user 42
+//   progress-service rebuild-projections -all
+//   progress-service reconcile-lemons [-user 42] [-repair]
+//   progress-service finalize-leagues
+// ================================================================
+
+// runCommand dispatches a maintenance subcommand
+func runCommand(ctx context.Context, repo *repository.ProgressRepository, args []string) error {
+	switch args[0] {
+	case "rebuild-projections":
+		return rebuildProjections(ctx, repo, args[1:])
+	case "reconcile-lemons":
+		return reconcileLemons(ctx, repo, args[1:])
+	case "finalize-leagues":
+		return finalizeLeagues(ctx, repo)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// rebuildProjections replays learning events into the projection tables
+func rebuildProjections(ctx context.Context, repo *repository.ProgressRepository, args []string) error {
+	fs := flag.NewFlagSet("rebuild-projections", flag.ContinueOnError)
+	userID := fs.Int64("user", 0, "rebuild projections for a single user")
+	all := fs.Bool("all", false, "rebuild projections for all users")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *userID > 0 && !*all:
+		replayed, err := repo.RebuildProjections(ctx, *userID)
+		if err != nil {
+			return err
+		}
+		log.Printf("[PROJECTIONS] Rebuilt user %d from %d events", *userID, replayed)
+		return nil
+
+	case *all && *userID == 0:
+		users, events, err := repo.RebuildAllProjections(ctx, func(userID int64, replayed int) {
+			log.Printf("[PROJECTIONS] Rebuilt user %d from %d events", userID, replayed)
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("[PROJECTIONS] Rebuilt %d users from %d events", users, events)
+		return nil
+
+	default:
+		return errors.New("rebuild-projections requires exactly one of -user or -all")
+	}
+}
+
+// reconcileLemons recomputes lemon balances from the ledger and reports
+// (or, with -repair, fixes) users whose lemon_currency row has drifted
+func reconcileLemons(ctx context.Context, repo *repository.ProgressRepository, args []string) error {
+	fs := flag.NewFlagSet("reconcile-lemons", flag.ContinueOnError)
+	userID := fs.Int64("user", 0, "reconcile a single user (default: all users)")
+	repair := fs.Bool("repair", false, "overwrite mismatched balances with the ledger values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mismatches, err := repo.ReconcileLemons(ctx, *userID, *repair)
+	for _, m := range mismatches {
+		action := "mismatch"
+		if m.Repaired {
+			action = "repaired"
+		}
+		log.Printf("[LEDGER] User %d %s: stored total=%d tree=%d harvested=%d, ledger total=%d tree=%d harvested=%d",
+			m.UserID, action,
+			m.Stored.TotalLemons, m.Stored.TreeLemonsAvailable, m.Stored.TreeLemonsHarvested,
+			m.Ledger.TotalLemons, m.Ledger.TreeLemonsAvailable, m.Ledger.TreeLemonsHarvested,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	unbalanced, err := repo.UnbalancedLemonPostings(ctx, *userID)
+	if err != nil {
+		return err
+	}
+	for _, postingID := range unbalanced {
+		log.Printf("[LEDGER] Posting %d does not balance", postingID)
+	}
+
+	log.Printf("[LEDGER] %d balance mismatches, %d unbalanced postings", len(mismatches), len(unbalanced))
+
+	if len(unbalanced) > 0 || (len(mismatches) > 0 && !*repair) {
+		return errors.New("lemon ledger is not reconciled")
+	}
+	return nil
+}
+
+// finalizeLeagues settles every league cohort of a finished week now,
+// without waiting for the scheduler
+func finalizeLeagues(ctx context.Context, repo *repository.ProgressRepository) error {
+	finalized, err := repo.FinalizeLeagues(ctx, time.Now())
+	log.Printf("[LEAGUE] Finalized %d cohorts", finalized)
+	return err
+}
@@ -0,0 +1,177 @@
+// Package dbtest provides a scripted database/sql driver for tests that
+// need a *sql.DB without a running PostgreSQL.
+//
+// Statements run inside a transaction are buffered and only recorded as
+// applied when the transaction commits, so tests can check that a failed
+// unit of work left nothing behind. Query results and errors are scripted
+// through the driver's Query and Exec hooks.
+package dbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// Statement is an executed statement with its arguments
+type Statement struct {
+	Query string
+	Args  []driver.Value
+}
+
+// Rows is a scripted query result
+type Rows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// Driver records statements and answers queries through its hooks.
+// A nil Query hook returns no rows; a nil Exec hook affects one row.
+type Driver struct {
+	Query func(query string, args []driver.Value) (*Rows, error)
+	Exec  func(query string, args []driver.Value) (int64, error)
+
+	mu        sync.Mutex
+	applied   []Statement
+	rollbacks int
+}
+
+// Applied returns the statements that were executed outside a transaction
+// or in a committed one, in order
+func (d *Driver) Applied() []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Statement{}, d.applied...)
+}
+
+// Rollbacks returns how many transactions were rolled back
+func (d *Driver) Rollbacks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rollbacks
+}
+
+var (
+	registerMu sync.Mutex
+	registered int
+)
+
+// Open registers d under a unique name and opens a *sql.DB on it, closed
+// when the test ends
+func Open(t testing.TB, d *Driver) *sql.DB {
+	t.Helper()
+
+	registerMu.Lock()
+	registered++
+	name := fmt.Sprintf("dbtest-%d", registered)
+	registerMu.Unlock()
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Open implements driver.Driver
+func (d *Driver) Open(string) (driver.Conn, error) {
+	return &conn{d: d}, nil
+}
+
+type conn struct {
+	d       *Driver
+	inTx    bool
+	pending []Statement
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	c.inTx, c.pending = true, nil
+	return c, nil
+}
+
+func (c *conn) Commit() error {
+	c.d.mu.Lock()
+	c.d.applied = append(c.d.applied, c.pending...)
+	c.d.mu.Unlock()
+	c.inTx, c.pending = false, nil
+	return nil
+}
+
+func (c *conn) Rollback() error {
+	c.d.mu.Lock()
+	c.d.rollbacks++
+	c.d.mu.Unlock()
+	c.inTx, c.pending = false, nil
+	return nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	affected := int64(1)
+	if s.c.d.Exec != nil {
+		n, err := s.c.d.Exec(s.query, args)
+		if err != nil {
+			return nil, err
+		}
+		affected = n
+	}
+
+	st := Statement{Query: s.query, Args: args}
+	if s.c.inTx {
+		s.c.pending = append(s.c.pending, st)
+	} else {
+		s.c.d.mu.Lock()
+		s.c.d.applied = append(s.c.d.applied, st)
+		s.c.d.mu.Unlock()
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.c.d.Query == nil {
+		return &rows{}, nil
+	}
+	r, err := s.c.d.Query(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = &Rows{}
+	}
+	return &rows{Rows: *r}, nil
+}
+
+type rows struct {
+	Rows
+	next int
+}
+
+func (r *rows) Columns() []string { return r.Rows.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.Values) {
+		return io.EOF
+	}
+	copy(dest, r.Values[r.next])
+	r.next++
+	return nil
+}
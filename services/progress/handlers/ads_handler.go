@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"lemonkorean/progress/ads"
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// AD REWARDS HANDLER
+// ================================================================
+// Receives AdMob server-side verification callbacks and exposes the
+// resulting harvest grants to the app
+// ================================================================
+
+// AdsHandler handles rewarded ad endpoints
+type AdsHandler struct {
+	repo     *repository.ProgressRepository
+	verifier *ads.Verifier
+}
+
+// NewAdsHandler creates a new ads handler
+func NewAdsHandler(repo *repository.ProgressRepository, verifier *ads.Verifier) *AdsHandler {
+	return &AdsHandler{repo: repo, verifier: verifier}
+}
+
+// RewardCallback records a signed AdMob reward callback (public, no JWT)
+// GET /api/progress/ads/reward-callback
+// AdMob retries non-2xx responses, so callbacks that can never succeed
+// (bad signature, unknown user) are answered with 4xx and logged.
+func (h *AdsHandler) RewardCallback(c *gin.Context) {
+	callback, err := h.verifier.Verify(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		log.Printf("[ADS] Rejected reward callback: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	grant, err := h.repo.RecordAdReward(c.Request.Context(), callback)
+	if errors.Is(err, repository.ErrUnknownAdUser) {
+		log.Printf("[ADS] Reward callback %s for unknown user %q", callback.TransactionID, callback.UserID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown user"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ADS] Error recording reward %s: %v", callback.TransactionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record reward"})
+		return
+	}
+
+	log.Printf("[ADS] Reward %s for user %d: %s", grant.TransactionID, grant.UserID, grant.Status)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"status":  grant.Status,
+	})
+}
+
+// GetAdRewardStatus returns the authenticated user's unused harvest grants
+// GET /api/progress/ads/grants
+func (h *AdsHandler) GetAdRewardStatus(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status, err := h.repo.GetAdRewardStatus(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[ADS] Error fetching grants for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get ad rewards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
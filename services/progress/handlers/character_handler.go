@@ -3,9 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"lemonkorean/progress/models"
 	"lemonkorean/progress/repository"
 
 	"github.com/gin-gonic/gin"
@@ -21,10 +26,11 @@ func NewCharacterHandler(repo *repository.ProgressRepository) *CharacterHandler
 	return &CharacterHandler{repo: repo}
 }
 
-// EquipRequest is the request body for equipping items
+// EquipRequest is the request body for equipping items. A null item_id
+// unequips the slot.
 type EquipRequest struct {
 	Category string `json:"category" binding:"required"`
-	ItemID   int    `json:"item_id" binding:"required"`
+	ItemID   *int64 `json:"item_id"`
 }
 
 // SkinColorRequest is the request body for changing skin color
@@ -37,34 +43,17 @@ type PurchaseRequest struct {
 	ItemID int `json:"item_id" binding:"required"`
 }
 
-// FurnitureItem represents a single furniture placement
-type FurnitureItem struct {
-	ItemID    int     `json:"item_id" binding:"required"`
-	PositionX float32 `json:"position_x"`
-	PositionY float32 `json:"position_y"`
+// OutfitRequest is the request body for saving an outfit preset. slots is
+// always required; when creating, an omitted skin_color is copied from the
+// current character.
+type OutfitRequest struct {
+	Name      string         `json:"name" binding:"required"`
+	Slots     models.Loadout `json:"slots"`
+	SkinColor string         `json:"skin_color"`
 }
 
-// RoomFurnitureRequest is the request body for updating room furniture
-type RoomFurnitureRequest struct {
-	Furniture []FurnitureItem `json:"furniture" binding:"required"`
-}
-
-// getUserID safely extracts the user ID from the gin context
-func getUserID(c *gin.Context) (int64, bool) {
-	userID, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return 0, false
-	}
-	uidFloat, ok := userID.(float64)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
-		return 0, false
-	}
-	return int64(uidFloat), true
-}
-
-// GetCharacter retrieves a user's equipped character items
+// GetCharacter retrieves a user's equipped character items. Empty required
+// slots show the category's default item.
 func (h *CharacterHandler) GetCharacter(c *gin.Context) {
 	userIDStr := c.Param("userId")
 	userID, err := strconv.ParseInt(userIDStr, 10, 64)
@@ -73,159 +62,87 @@ func (h *CharacterHandler) GetCharacter(c *gin.Context) {
 		return
 	}
 
-	query := `
-		SELECT uc.body_item_id, uc.skin_color, uc.hair_item_id, uc.eyes_item_id,
-		       uc.eyebrows_item_id, uc.nose_item_id, uc.mouth_item_id,
-		       uc.top_item_id, uc.bottom_item_id, uc.shoes_item_id,
-		       uc.hat_item_id, uc.accessory_item_id, uc.pet_item_id,
-		       uc.wallpaper_item_id, uc.floor_item_id
-		FROM user_characters uc
-		WHERE uc.user_id = $1
-	`
-
-	var body, hair, eyes, eyebrows, nose, mouth, top, bottom, shoes, hat, accessory, pet, wallpaper, floor sql.NullInt64
-	var skinColor string
-
-	err = h.repo.GetDB().QueryRowContext(c.Request.Context(), query, userID).Scan(
-		&body, &skinColor, &hair, &eyes, &eyebrows, &nose, &mouth,
-		&top, &bottom, &shoes, &hat, &accessory, &pet, &wallpaper, &floor,
-	)
-
-	if err == sql.ErrNoRows {
-		// Return defaults
-		c.JSON(http.StatusOK, gin.H{
-			"equipped":   gin.H{},
-			"skin_color": "#FFDBB4",
-		})
-		return
-	}
+	equipped, skinColor, err := h.repo.GetCharacter(c.Request.Context(), userID)
 	if err != nil {
+		log.Printf("[CHARACTER] Error getting character for user %d: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get character"})
 		return
 	}
 
-	equipped := gin.H{}
-	if body.Valid {
-		equipped["body"] = body.Int64
-	}
-	if hair.Valid {
-		equipped["hair"] = hair.Int64
-	}
-	if eyes.Valid {
-		equipped["eyes"] = eyes.Int64
-	}
-	if eyebrows.Valid {
-		equipped["eyebrows"] = eyebrows.Int64
-	}
-	if nose.Valid {
-		equipped["nose"] = nose.Int64
-	}
-	if mouth.Valid {
-		equipped["mouth"] = mouth.Int64
-	}
-	if top.Valid {
-		equipped["top"] = top.Int64
-	}
-	if bottom.Valid {
-		equipped["bottom"] = bottom.Int64
-	}
-	if shoes.Valid {
-		equipped["shoes"] = shoes.Int64
-	}
-	if hat.Valid {
-		equipped["hat"] = hat.Int64
-	}
-	if accessory.Valid {
-		equipped["accessory"] = accessory.Int64
-	}
-	if pet.Valid {
-		equipped["pet"] = pet.Int64
-	}
-	if wallpaper.Valid {
-		equipped["wallpaper"] = wallpaper.Int64
-	}
-	if floor.Valid {
-		equipped["floor"] = floor.Int64
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"equipped":   equipped,
 		"skin_color": skinColor,
 	})
 }
 
-// EquipItem equips an item on the user's character
-func (h *CharacterHandler) EquipItem(c *gin.Context) {
-	uid, ok := getUserID(c)
+// GetAvatar returns a user's equipped items as ordered render layers with an
+// outfit hash, for server-side avatar rendering
+func (h *CharacterHandler) GetAvatar(c *gin.Context) {
+	userID, ok := idParam(c, "userId")
 	if !ok {
 		return
 	}
 
-	var req EquipRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	avatar, err := h.repo.GetAvatar(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[CHARACTER] Error getting avatar for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get avatar"})
 		return
 	}
 
-	// Verify user owns the item
-	var count int
-	err := h.repo.GetDB().QueryRowContext(c.Request.Context(),
-		`SELECT COUNT(*) FROM user_inventory WHERE user_id = $1 AND item_id = $2`,
-		uid, req.ItemID,
-	).Scan(&count)
-	if err != nil || count == 0 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "item not owned"})
-		return
-	}
-
-	// Map category to column name
-	columnMap := map[string]string{
-		"body":      "body_item_id",
-		"hair":      "hair_item_id",
-		"eyes":      "eyes_item_id",
-		"eyebrows":  "eyebrows_item_id",
-		"nose":      "nose_item_id",
-		"mouth":     "mouth_item_id",
-		"top":       "top_item_id",
-		"bottom":    "bottom_item_id",
-		"shoes":     "shoes_item_id",
-		"hat":       "hat_item_id",
-		"accessory": "accessory_item_id",
-		"pet":       "pet_item_id",
-		"wallpaper": "wallpaper_item_id",
-		"floor":     "floor_item_id",
-	}
-
-	column, ok := columnMap[req.Category]
+	c.JSON(http.StatusOK, avatar)
+}
+
+// EquipItem equips an item on the user's character, or clears the slot
+// when item_id is null
+func (h *CharacterHandler) EquipItem(c *gin.Context) {
+	uid, ok := authUser(c)
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category"})
 		return
 	}
 
-	// Upsert user_characters
-	query := `
-		INSERT INTO user_characters (user_id, ` + column + `, updated_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (user_id) DO UPDATE
-		SET ` + column + ` = $2, updated_at = NOW()
-	`
+	var req EquipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	_, err = h.repo.GetDB().ExecContext(c.Request.Context(), query, uid, req.ItemID)
+	equipped, err := h.repo.EquipItem(c.Request.Context(), uid, req.Category, req.ItemID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to equip item"})
+		switch {
+		case errors.Is(err, repository.ErrInvalidSlot),
+			errors.Is(err, repository.ErrSlotMismatch),
+			errors.Is(err, repository.ErrSlotRequired),
+			errors.Is(err, repository.ErrItemUnavailable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrItemNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrItemNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[CHARACTER] Error equipping item for user %d: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to equip item"})
+		}
 		return
 	}
 
+	itemID, equippedNow := equipped[req.Category]
+	var resultID interface{}
+	if equippedNow {
+		resultID = itemID
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"category": req.Category,
-		"item_id":  req.ItemID,
+		"item_id":  resultID,
+		"equipped": equipped,
 	})
 }
 
 // UpdateSkinColor changes the user's skin color
 func (h *CharacterHandler) UpdateSkinColor(c *gin.Context) {
-	uid, ok := getUserID(c)
+	uid, ok := authUser(c)
 	if !ok {
 		return
 	}
@@ -255,6 +172,158 @@ func (h *CharacterHandler) UpdateSkinColor(c *gin.Context) {
 	})
 }
 
+// ListOutfits returns the user's outfit presets
+func (h *CharacterHandler) ListOutfits(c *gin.Context) {
+	uid, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	outfits, err := h.repo.ListOutfits(c.Request.Context(), uid)
+	if err != nil {
+		log.Printf("[CHARACTER] Error listing outfits for user %d: %v", uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get outfits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"outfits": outfits})
+}
+
+// CreateOutfit saves a new outfit preset
+func (h *CharacterHandler) CreateOutfit(c *gin.Context) {
+	uid, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindOutfitRequest(c, false)
+	if !ok {
+		return
+	}
+
+	outfit, err := h.repo.CreateOutfit(c.Request.Context(), uid, req.Name, req.Slots, req.SkinColor)
+	if err != nil {
+		respondOutfitError(c, err, "failed to save outfit", uid)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"outfit": outfit})
+}
+
+// UpdateOutfit replaces an outfit preset
+func (h *CharacterHandler) UpdateOutfit(c *gin.Context) {
+	uid, ok := authUser(c)
+	if !ok {
+		return
+	}
+	outfitID, ok := idParam(c, "outfitId")
+	if !ok {
+		return
+	}
+
+	req, ok := bindOutfitRequest(c, true)
+	if !ok {
+		return
+	}
+
+	outfit, err := h.repo.UpdateOutfit(c.Request.Context(), uid, outfitID, req.Name, req.Slots, req.SkinColor)
+	if err != nil {
+		respondOutfitError(c, err, "failed to update outfit", uid)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"outfit": outfit})
+}
+
+// DeleteOutfit removes an outfit preset
+func (h *CharacterHandler) DeleteOutfit(c *gin.Context) {
+	uid, ok := authUser(c)
+	if !ok {
+		return
+	}
+	outfitID, ok := idParam(c, "outfitId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteOutfit(c.Request.Context(), uid, outfitID); err != nil {
+		respondOutfitError(c, err, "failed to delete outfit", uid)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ApplyOutfit equips every item of an outfit preset at once
+func (h *CharacterHandler) ApplyOutfit(c *gin.Context) {
+	uid, ok := authUser(c)
+	if !ok {
+		return
+	}
+	outfitID, ok := idParam(c, "outfitId")
+	if !ok {
+		return
+	}
+
+	equipped, skinColor, err := h.repo.ApplyOutfit(c.Request.Context(), uid, outfitID)
+	if err != nil {
+		respondOutfitError(c, err, "failed to apply outfit", uid)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"equipped":   equipped,
+		"skin_color": skinColor,
+	})
+}
+
+// bindOutfitRequest parses and validates an outfit body. The slot map is
+// required ({} for an empty outfit); full also requires the skin colour,
+// for replacing an existing preset.
+func bindOutfitRequest(c *gin.Context, full bool) (*OutfitRequest, bool) {
+	var req OutfitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-50 characters"})
+		return nil, false
+	}
+	if req.Slots == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slots is required"})
+		return nil, false
+	}
+	if (full || req.SkinColor != "") && !models.IsSkinColor(req.SkinColor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid skin_color"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// respondOutfitError maps outfit and equip errors to responses
+func respondOutfitError(c *gin.Context, err error, message string, userID int64) {
+	switch {
+	case errors.Is(err, repository.ErrInvalidSlot),
+		errors.Is(err, repository.ErrSlotMismatch),
+		errors.Is(err, repository.ErrItemUnavailable),
+		errors.Is(err, repository.ErrOutfitConflict):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrItemNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrItemNotFound), errors.Is(err, repository.ErrOutfitNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrOutfitNameTaken), errors.Is(err, repository.ErrOutfitLimitReached):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[CHARACTER] %s for user %d: %v", message, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
 // GetInventory retrieves a user's owned items
 func (h *CharacterHandler) GetInventory(c *gin.Context) {
 	userIDStr := c.Param("userId")
@@ -326,7 +395,7 @@ func (h *CharacterHandler) GetInventory(c *gin.Context) {
 
 // PurchaseItem purchases an item from the shop
 func (h *CharacterHandler) PurchaseItem(c *gin.Context) {
-	uid, ok := getUserID(c)
+	uid, ok := authUser(c)
 	if !ok {
 		return
 	}
@@ -360,25 +429,24 @@ func (h *CharacterHandler) PurchaseItem(c *gin.Context) {
 		return
 	}
 
-	// Get item price
-	var price int
-	var isActive bool
-	err = tx.QueryRowContext(c.Request.Context(),
-		`SELECT price, is_active FROM character_items WHERE id = $1`,
-		req.ItemID,
-	).Scan(&price, &isActive)
-	if err == sql.ErrNoRows {
+	// Price the item at the current sale and take it from limited stock
+	offer, err := h.repo.CheckoutShopItem(c.Request.Context(), tx, int64(req.ItemID))
+	switch {
+	case errors.Is(err, repository.ErrItemNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
 		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get item"})
-		return
-	}
-	if !isActive {
+	case errors.Is(err, repository.ErrItemUnavailable):
 		c.JSON(http.StatusBadRequest, gin.H{"error": "item not available"})
 		return
+	case errors.Is(err, repository.ErrItemSoldOut):
+		c.JSON(http.StatusConflict, gin.H{"error": "item sold out"})
+		return
+	case err != nil:
+		log.Printf("[CHARACTER] Error checking out item %d for user %d: %v", req.ItemID, uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get item"})
+		return
 	}
+	price := offer.Price
 
 	// Check lemon balance with row lock to prevent race condition
 	var totalLemons int
@@ -402,24 +470,20 @@ func (h *CharacterHandler) PurchaseItem(c *gin.Context) {
 		return
 	}
 
-	// Deduct lemons
+	// Deduct lemons through the ledger
 	if price > 0 {
-		_, err = tx.ExecContext(c.Request.Context(),
-			`UPDATE lemon_currency SET total_lemons = total_lemons - $1, updated_at = NOW() WHERE user_id = $2`,
-			price, uid,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to deduct lemons"})
+		itemID := int64(req.ItemID)
+		_, err = h.repo.SpendLemons(c.Request.Context(), tx, uid, price, models.LemonTxPurchase, &itemID)
+		if errors.Is(err, repository.ErrInsufficientLemons) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":        "insufficient lemons",
+				"total_lemons": totalLemons,
+				"required":     price,
+			})
 			return
 		}
-
-		// Record transaction
-		_, err = tx.ExecContext(c.Request.Context(),
-			`INSERT INTO lemon_transactions (user_id, amount, type, source_id) VALUES ($1, $2, 'purchase', $3)`,
-			uid, -price, req.ItemID,
-		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record transaction"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to deduct lemons"})
 			return
 		}
 	}
@@ -434,189 +498,58 @@ func (h *CharacterHandler) PurchaseItem(c *gin.Context) {
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete purchase"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success":          true,
-		"item_id":          req.ItemID,
-		"price":            price,
-		"remaining_lemons": totalLemons - price,
-	})
-}
-
-// GetShopItems retrieves available items for purchase
-func (h *CharacterHandler) GetShopItems(c *gin.Context) {
-	category := c.Query("category")
-
-	query := `
-		SELECT id, category, name, description, asset_key, asset_type,
-		       is_bundled, render_order, price, rarity, is_default, metadata
-		FROM character_items
-		WHERE is_active = true
-	`
-	args := []interface{}{}
-
-	if category != "" {
-		query += ` AND category = $1`
-		args = append(args, category)
-	}
-
-	query += ` ORDER BY category, price, render_order`
-
-	rows, err := h.repo.GetDB().QueryContext(c.Request.Context(), query, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get shop items"})
-		return
-	}
-	defer rows.Close()
-
-	items := []gin.H{}
-	for rows.Next() {
-		var id, renderOrder, price int
-		var category, name, assetKey, assetType, rarity string
-		var description sql.NullString
-		var isBundled, isDefault bool
-		var metadata []byte
-
-		if err := rows.Scan(&id, &category, &name, &description, &assetKey, &assetType,
-			&isBundled, &renderOrder, &price, &rarity, &isDefault, &metadata); err != nil {
-			continue
-		}
-
-		var metaMap interface{}
-		if len(metadata) > 0 {
-			json.Unmarshal(metadata, &metaMap)
-		}
-		if metaMap == nil {
-			metaMap = map[string]interface{}{}
-		}
-
-		item := gin.H{
-			"id":           id,
-			"category":     category,
-			"name":         name,
-			"asset_key":    assetKey,
-			"asset_type":   assetType,
-			"is_bundled":   isBundled,
-			"render_order": renderOrder,
-			"price":        price,
-			"rarity":       rarity,
-			"is_default":   isDefault,
-			"metadata":     metaMap,
-		}
-		if description.Valid {
-			item["description"] = description.String
-		}
-
-		items = append(items, item)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"items": items,
+	err = h.repo.AppendLearningEvent(c.Request.Context(), tx, uid, models.LearningEventItemPurchased, &models.ItemPurchasedData{
+		ItemID: req.ItemID,
+		Price:  price,
 	})
-}
-
-// GetRoom retrieves a user's room furniture layout
-func (h *CharacterHandler) GetRoom(c *gin.Context) {
-	userIDStr := c.Param("userId")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record purchase"})
 		return
 	}
 
-	query := `
-		SELECT urf.id, urf.item_id, ci.name, ci.asset_key, ci.asset_type,
-		       urf.position_x, urf.position_y
-		FROM user_room_furniture urf
-		JOIN character_items ci ON ci.id = urf.item_id
-		WHERE urf.user_id = $1
-		ORDER BY urf.id
-	`
-
-	rows, err := h.repo.GetDB().QueryContext(c.Request.Context(), query, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get room"})
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete purchase"})
 		return
 	}
-	defer rows.Close()
-
-	furniture := []gin.H{}
-	for rows.Next() {
-		var id, itemID int
-		var name, assetKey, assetType string
-		var posX, posY float32
-
-		if err := rows.Scan(&id, &itemID, &name, &assetKey, &assetType, &posX, &posY); err != nil {
-			continue
-		}
 
-		furniture = append(furniture, gin.H{
-			"id":         id,
-			"item_id":    itemID,
-			"name":       name,
-			"asset_key":  assetKey,
-			"asset_type": assetType,
-			"position_x": posX,
-			"position_y": posY,
+	h.repo.PublishEvent(c.Request.Context(), uid, models.EventTypeInventory, map[string]interface{}{
+		"item_id": req.ItemID,
+		"action":  "purchased",
+	})
+	if price > 0 {
+		h.repo.PublishEvent(c.Request.Context(), uid, models.EventTypeLemons, map[string]interface{}{
+			"reason":       "purchase",
+			"delta":        -price,
+			"total_lemons": totalLemons - price,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"furniture": furniture,
+		"success":          true,
+		"item_id":          req.ItemID,
+		"price":            price,
+		"original_price":   offer.OriginalPrice,
+		"discount_percent": offer.DiscountPercent,
+		"stock":            offer.Stock,
+		"remaining_lemons": totalLemons - price,
 	})
 }
 
-// UpdateRoomFurniture replaces all furniture in a user's room
-func (h *CharacterHandler) UpdateRoomFurniture(c *gin.Context) {
-	uid, ok := getUserID(c)
+// GetShopItems returns the items on sale at their current prices, with
+// today's featured selection
+// GET /api/progress/shop/items?category=hat
+func (h *CharacterHandler) GetShopItems(c *gin.Context) {
+	uid, ok := authUser(c)
 	if !ok {
 		return
 	}
 
-	var req RoomFurnitureRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	tx, err := h.repo.GetDB().BeginTx(c.Request.Context(), nil)
+	listing, err := h.repo.ListShop(c.Request.Context(), uid, c.Query("category"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
-		return
-	}
-	defer tx.Rollback()
-
-	// Clear existing furniture
-	_, err = tx.ExecContext(c.Request.Context(),
-		`DELETE FROM user_room_furniture WHERE user_id = $1`, uid)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear furniture"})
-		return
-	}
-
-	// Insert new furniture
-	for _, f := range req.Furniture {
-		_, err = tx.ExecContext(c.Request.Context(),
-			`INSERT INTO user_room_furniture (user_id, item_id, position_x, position_y) VALUES ($1, $2, $3, $4)`,
-			uid, f.ItemID, f.PositionX, f.PositionY,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to place furniture"})
-			return
-		}
-	}
-
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save room"})
+		log.Printf("[CHARACTER] Error listing shop for user %d: %v", uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get shop items"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"count":   len(req.Furniture),
-	})
+	c.JSON(http.StatusOK, listing)
 }
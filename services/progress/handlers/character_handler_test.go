@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// bindOutfit runs bindOutfitRequest on body and returns the response code
+func bindOutfit(full bool, body string) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/outfits", func(c *gin.Context) {
+		if _, ok := bindOutfitRequest(c, full); ok {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/outfits", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestBindOutfitRequestRequiresSlots(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		assert.Equal(t, http.StatusBadRequest, bindOutfit(full, `{"name": "weekend", "skin_color": "#FFDBB4"}`))
+		assert.Equal(t, http.StatusBadRequest, bindOutfit(full, `{"name": "weekend", "slots": null, "skin_color": "#FFDBB4"}`))
+		assert.Equal(t, http.StatusOK, bindOutfit(full, `{"name": "weekend", "slots": {}, "skin_color": "#FFDBB4"}`))
+	}
+
+	// Only a full update requires the skin colour
+	assert.Equal(t, http.StatusOK, bindOutfit(false, `{"name": "weekend", "slots": {"hat": 12}}`))
+	assert.Equal(t, http.StatusBadRequest, bindOutfit(true, `{"name": "weekend", "slots": {"hat": 12}}`))
+}
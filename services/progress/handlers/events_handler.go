@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/realtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// REAL-TIME EVENTS HANDLER
+// ================================================================
+// Streams progress change notifications to the authenticated user's
+// devices using Server-Sent Events
+// ================================================================
+
+// heartbeatInterval keeps idle streams alive through proxies
+const heartbeatInterval = 25 * time.Second
+
+// EventsHandler handles real-time event streams
+type EventsHandler struct {
+	hub *realtime.Hub
+}
+
+// NewEventsHandler creates a new events handler
+func NewEventsHandler(hub *realtime.Hub) *EventsHandler {
+	return &EventsHandler{hub: hub}
+}
+
+// StreamEvents streams progress, lemons, inventory and streak events
+// GET /api/progress/events/stream?device_id=...
+// Events originating from device_id are not echoed back to it.
+func (h *EventsHandler) StreamEvents(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": err.Error(),
+		})
+		return
+	}
+	deviceID := c.Query("device_id")
+
+	// Streams outlive the server-wide write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[EVENTS] Cannot clear write deadline: %v", err)
+	}
+
+	events, unsubscribe := h.hub.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	log.Printf("[EVENTS] User %d connected (device=%s)", userID, deviceID)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.SSEvent("connected", gin.H{"user_id": userID})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-h.hub.Done():
+			return false
+		case event := <-events:
+			if deviceID != "" && event.SourceDevice == deviceID {
+				return true
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"at": time.Now()})
+			return true
+		}
+	})
+
+	log.Printf("[EVENTS] User %d disconnected (device=%s)", userID, deviceID)
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// FRIENDS HANDLER
+// ================================================================
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 50
+)
+
+// FriendHandler handles friends, follows, blocks and the activity feed
+type FriendHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewFriendHandler creates a new friend handler
+func NewFriendHandler(repo *repository.ProgressRepository) *FriendHandler {
+	return &FriendHandler{repo: repo}
+}
+
+// UserTargetRequest is the request body naming another user
+type UserTargetRequest struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+// ActivityPrivacyRequest is the request body for the activity privacy level
+type ActivityPrivacyRequest struct {
+	ActivityPrivacy string `json:"activity_privacy" binding:"required"`
+}
+
+// ListFriends returns the caller's friends
+// GET /api/progress/friends
+func (h *FriendHandler) ListFriends(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	friends, err := h.repo.ListFriends(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[FRIENDS] Error listing friends for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get friends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"friends": friends})
+}
+
+// RemoveFriend ends a friendship
+// DELETE /api/progress/friends/:userId
+func (h *FriendHandler) RemoveFriend(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	friendID, ok := idParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.RemoveFriend(c.Request.Context(), userID, friendID); err != nil {
+		respondRelationError(c, err, "failed to remove friend", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListRequests returns the caller's pending incoming and outgoing requests
+// GET /api/progress/friends/requests
+func (h *FriendHandler) ListRequests(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	incoming, outgoing, err := h.repo.ListFriendRequests(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[FRIENDS] Error listing requests for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get friend requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"incoming": incoming,
+		"outgoing": outgoing,
+	})
+}
+
+// SendRequest asks another user to be friends, or accepts their pending
+// request to the caller
+// POST /api/progress/friends/requests
+func (h *FriendHandler) SendRequest(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req UserTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, friends, err := h.repo.SendFriendRequest(c.Request.Context(), userID, req.UserID)
+	if err != nil {
+		respondRelationError(c, err, "failed to send friend request", userID)
+		return
+	}
+
+	status := http.StatusCreated
+	if friends {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"request": request,
+		"friends": friends,
+	})
+}
+
+// AcceptRequest accepts a friend request sent to the caller
+// POST /api/progress/friends/requests/:requestId/accept
+func (h *FriendHandler) AcceptRequest(c *gin.Context) {
+	h.respond(c, true)
+}
+
+// DeclineRequest declines a friend request sent to the caller
+// POST /api/progress/friends/requests/:requestId/decline
+func (h *FriendHandler) DeclineRequest(c *gin.Context) {
+	h.respond(c, false)
+}
+
+func (h *FriendHandler) respond(c *gin.Context, accept bool) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	requestID, ok := idParam(c, "requestId")
+	if !ok {
+		return
+	}
+
+	request, err := h.repo.RespondFriendRequest(c.Request.Context(), userID, requestID, accept)
+	if err != nil {
+		respondRelationError(c, err, "failed to answer friend request", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"request": request})
+}
+
+// CancelRequest withdraws a friend request sent by the caller
+// DELETE /api/progress/friends/requests/:requestId
+func (h *FriendHandler) CancelRequest(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	requestID, ok := idParam(c, "requestId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.CancelFriendRequest(c.Request.Context(), userID, requestID); err != nil {
+		respondRelationError(c, err, "failed to cancel friend request", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListFollowing returns the users the caller follows
+// GET /api/progress/follows
+func (h *FriendHandler) ListFollowing(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	following, err := h.repo.ListFollowing(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[FRIENDS] Error listing follows for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get follows"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"following": following})
+}
+
+// Follow follows another user
+// POST /api/progress/follows
+func (h *FriendHandler) Follow(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req UserTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.Follow(c.Request.Context(), userID, req.UserID); err != nil {
+		respondRelationError(c, err, "failed to follow user", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Unfollow stops following a user
+// DELETE /api/progress/follows/:userId
+func (h *FriendHandler) Unfollow(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	targetID, ok := idParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Unfollow(c.Request.Context(), userID, targetID); err != nil {
+		respondRelationError(c, err, "failed to unfollow user", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListBlocked returns the users the caller has blocked
+// GET /api/progress/blocks
+func (h *FriendHandler) ListBlocked(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	blocked, err := h.repo.ListBlocked(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[FRIENDS] Error listing blocks for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked": blocked})
+}
+
+// Block blocks a user, ending any friendship and follows between them
+// POST /api/progress/blocks
+func (h *FriendHandler) Block(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req UserTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.Block(c.Request.Context(), userID, req.UserID); err != nil {
+		respondRelationError(c, err, "failed to block user", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Unblock removes a block
+// DELETE /api/progress/blocks/:userId
+func (h *FriendHandler) Unblock(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	targetID, ok := idParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Unblock(c.Request.Context(), userID, targetID); err != nil {
+		respondRelationError(c, err, "failed to unblock user", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetFeed returns recent activity of the caller's friends and followed users
+// GET /api/progress/friends/feed?cursor=&limit=20
+func (h *FriendHandler) GetFeed(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultFeedLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxFeedLimit {
+			n = maxFeedLimit
+		}
+		limit = n
+	}
+
+	var beforeID int64
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		beforeID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || beforeID < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	items, nextCursor, err := h.repo.GetFriendFeed(c.Request.Context(), userID, beforeID, limit)
+	if err != nil {
+		log.Printf("[FRIENDS] Error fetching feed for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get activity feed"})
+		return
+	}
+
+	var next interface{}
+	if nextCursor > 0 {
+		next = strconv.FormatInt(nextCursor, 10)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       items,
+		"next_cursor": next,
+		"has_more":    nextCursor > 0,
+	})
+}
+
+// GetPrivacy returns who may see the caller's activity
+// GET /api/progress/friends/privacy
+func (h *FriendHandler) GetPrivacy(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	privacy, err := h.repo.GetActivityPrivacy(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[FRIENDS] Error fetching privacy for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get activity privacy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"activity_privacy": privacy})
+}
+
+// UpdatePrivacy sets who may see the caller's activity
+// PUT /api/progress/friends/privacy
+func (h *FriendHandler) UpdatePrivacy(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req ActivityPrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsActivityPrivacy(req.ActivityPrivacy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "activity_privacy must be public, friends or private"})
+		return
+	}
+
+	if err := h.repo.SetActivityPrivacy(c.Request.Context(), userID, req.ActivityPrivacy); err != nil {
+		log.Printf("[FRIENDS] Error updating privacy for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update activity privacy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"activity_privacy": req.ActivityPrivacy,
+	})
+}
+
+// authUser returns the authenticated user, writing a 401 if there is none
+func authUser(c *gin.Context) (int64, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// idParam parses a positive ID path parameter, writing a 400 if invalid
+func idParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondRelationError maps friend/follow/block errors to responses
+func respondRelationError(c *gin.Context, err error, message string, userID int64) {
+	switch {
+	case errors.Is(err, repository.ErrSelfRelation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrUserNotFound),
+		errors.Is(err, repository.ErrFriendRequestNotFound),
+		errors.Is(err, repository.ErrNotFriends):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrUserBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrAlreadyFriends):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[FRIENDS] %s for user %d: %v", message, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
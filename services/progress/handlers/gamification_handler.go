@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/models"
 	"lemonkorean/progress/repository"
 
 	"github.com/gin-gonic/gin"
@@ -21,10 +26,12 @@ func NewGamificationHandler(repo *repository.ProgressRepository) *GamificationHa
 	return &GamificationHandler{repo: repo}
 }
 
-// LessonRewardRequest is the request body for saving lesson rewards
+// LessonRewardRequest is the request body for saving lesson rewards.
+// LemonsEarned and QuizScore are ignored; the reward is computed from the
+// quiz score stored when the lesson was completed.
 type LessonRewardRequest struct {
 	LessonID     int `json:"lesson_id" binding:"required"`
-	LemonsEarned int `json:"lemons_earned" binding:"required,min=1,max=3"`
+	LemonsEarned int `json:"lemons_earned"`
 	QuizScore    int `json:"quiz_score" binding:"min=0,max=100"`
 }
 
@@ -33,7 +40,8 @@ type LemonHarvestRequest struct {
 	// No fields needed - user is from JWT context
 }
 
-// BossQuizCompleteRequest is the request body for completing a boss quiz
+// BossQuizCompleteRequest is the request body for completing a boss quiz.
+// BonusLemons is ignored; the bonus is computed from the reward rules.
 type BossQuizCompleteRequest struct {
 	Level       int `json:"level" binding:"required"`
 	Week        int `json:"week" binding:"required"`
@@ -43,8 +51,8 @@ type BossQuizCompleteRequest struct {
 
 // SaveLessonReward saves or updates a lesson's lemon reward
 func (h *GamificationHandler) SaveLessonReward(c *gin.Context) {
-	userID, exists := c.Get("userId")
-	if !exists {
+	uid, err := middleware.GetUserID(c)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -55,47 +63,21 @@ func (h *GamificationHandler) SaveLessonReward(c *gin.Context) {
 		return
 	}
 
-	uid := int64(userID.(float64))
-
-	// Upsert lesson reward (only update if new score is better)
-	query := `
-		INSERT INTO lesson_rewards (user_id, lesson_id, lemons_earned, best_quiz_score, earned_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		ON CONFLICT (user_id, lesson_id) DO UPDATE
-		SET lemons_earned = GREATEST(lesson_rewards.lemons_earned, EXCLUDED.lemons_earned),
-		    best_quiz_score = GREATEST(lesson_rewards.best_quiz_score, EXCLUDED.best_quiz_score),
-		    updated_at = NOW()
-		RETURNING lemons_earned
-	`
-
-	var actualLemons int
-	err := h.repo.GetDB().QueryRowContext(c.Request.Context(), query,
-		uid, req.LessonID, req.LemonsEarned, req.QuizScore,
-	).Scan(&actualLemons)
-
+	result, err := h.repo.SaveLessonReward(c.Request.Context(), uid, int64(req.LessonID))
+	if err == repository.ErrLessonNotCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "lesson not completed"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save reward"})
 		return
 	}
 
-	// Update lemon currency
-	currencyQuery := `
-		INSERT INTO lemon_currency (user_id, total_lemons, tree_lemons_available, updated_at)
-		VALUES ($1, $2, $2, NOW())
-		ON CONFLICT (user_id) DO UPDATE
-		SET total_lemons = lemon_currency.total_lemons + $2,
-		    tree_lemons_available = lemon_currency.tree_lemons_available + $2,
-		    updated_at = NOW()
-	`
-	h.repo.GetDB().ExecContext(c.Request.Context(), currencyQuery, uid, req.LemonsEarned)
-
-	// Record transaction
-	txQuery := `INSERT INTO lemon_transactions (user_id, amount, type, source_id) VALUES ($1, $2, 'lesson', $3)`
-	h.repo.GetDB().ExecContext(c.Request.Context(), txQuery, uid, req.LemonsEarned, req.LessonID)
-
 	c.JSON(http.StatusOK, gin.H{
-		"success":       true,
-		"lemons_earned": actualLemons,
+		"success":          true,
+		"lemons_earned":    result.LemonsEarned,
+		"lemons_credited":  result.LemonsCredited,
+		"first_completion": result.FirstCompletion,
 	})
 }
 
@@ -181,54 +163,87 @@ func (h *GamificationHandler) GetLessonRewards(c *gin.Context) {
 	})
 }
 
-// HarvestLemon handles tree lemon harvesting (after ad watched)
-func (h *GamificationHandler) HarvestLemon(c *gin.Context) {
-	userID, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+// GetLemonTree returns the user's lemon tree for rendering
+// GET /api/progress/lemon-tree/:userId
+func (h *GamificationHandler) GetLemonTree(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	uid := int64(userID.(float64))
+	authUserID, err := middleware.GetUserID(c)
+	if err != nil || authUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot access lemon tree for other users"})
+		return
+	}
 
-	// Decrement available, increment harvested
-	query := `
-		UPDATE lemon_currency
-		SET tree_lemons_available = tree_lemons_available - 1,
-		    tree_lemons_harvested = tree_lemons_harvested + 1,
-		    updated_at = NOW()
-		WHERE user_id = $1 AND tree_lemons_available > 0
-		RETURNING tree_lemons_available, tree_lemons_harvested
-	`
+	tree, err := h.repo.GetLemonTree(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[LEMONS] Error fetching tree for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get lemon tree"})
+		return
+	}
 
-	var available, harvested int
-	err := h.repo.GetDB().QueryRowContext(c.Request.Context(), query, uid).
-		Scan(&available, &harvested)
+	c.JSON(http.StatusOK, gin.H{
+		"tree": tree,
+	})
+}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no lemons available"})
+// HarvestLemon handles tree lemon harvesting (after ad watched).
+// Only ripe lemons can be picked, once per cooldown, and each harvest
+// consumes a verified ad reward grant while ads are enabled.
+func (h *GamificationHandler) HarvestLemon(c *gin.Context) {
+	uid, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	tree, err := h.repo.HarvestLemon(c.Request.Context(), uid)
+	if errors.Is(err, repository.ErrNoRipeLemons) || errors.Is(err, repository.ErrHarvestCooldown) ||
+		errors.Is(err, repository.ErrAdRewardRequired) {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, repository.ErrHarvestCooldown):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, repository.ErrAdRewardRequired):
+			status = http.StatusForbidden
+		}
+		current, treeErr := h.repo.GetLemonTree(c.Request.Context(), uid)
+		if treeErr != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "tree": current})
 		return
 	}
 	if err != nil {
+		log.Printf("[LEMONS] Error harvesting for user %d: %v", uid, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to harvest"})
 		return
 	}
 
-	// Record transaction
-	txQuery := `INSERT INTO lemon_transactions (user_id, amount, type) VALUES ($1, 1, 'harvest')`
-	h.repo.GetDB().ExecContext(c.Request.Context(), txQuery, uid)
+	available := tree.RipeLemons + tree.GrowingLemons
+
+	h.repo.PublishEvent(c.Request.Context(), uid, models.EventTypeLemons, map[string]interface{}{
+		"reason":                "harvest",
+		"tree_lemons_available": available,
+		"tree_lemons_harvested": tree.TreeLemonsHarvested,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
-		"success":                true,
-		"tree_lemons_available":  available,
-		"tree_lemons_harvested":  harvested,
+		"success":               true,
+		"tree_lemons_available": available,
+		"tree_lemons_harvested": tree.TreeLemonsHarvested,
+		"tree":                  tree,
 	})
 }
 
 // CompleteBossQuiz records boss quiz completion
 func (h *GamificationHandler) CompleteBossQuiz(c *gin.Context) {
-	userID, exists := c.Get("userId")
-	if !exists {
+	uid, err := middleware.GetUserID(c)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -239,55 +254,184 @@ func (h *GamificationHandler) CompleteBossQuiz(c *gin.Context) {
 		return
 	}
 
-	uid := int64(userID.(float64))
-	bonusLemons := req.BonusLemons
-	if bonusLemons <= 0 {
-		bonusLemons = 5
+	result, err := h.repo.CompleteBossQuiz(c.Request.Context(), uid, req.Level, req.Week, req.Score)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record boss quiz"})
+		return
 	}
 
-	// Insert boss quiz completion (ignore if already done)
-	query := `
-		INSERT INTO boss_quiz_completions (user_id, level, week, score, bonus_lemons)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (user_id, level, week) DO NOTHING
-		RETURNING id
-	`
-
-	var id int
-	err := h.repo.GetDB().QueryRowContext(c.Request.Context(), query,
-		uid, req.Level, req.Week, req.Score, bonusLemons,
-	).Scan(&id)
-
-	if err == sql.ErrNoRows {
-		// Already completed
+	if !result.Passed {
 		c.JSON(http.StatusOK, gin.H{
-			"success":       true,
-			"already_completed": true,
+			"success": false,
+			"passed":  false,
 		})
 		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"passed":            true,
+		"already_completed": result.AlreadyCompleted,
+		"bonus_lemons":      result.BonusLemons,
+	})
+}
+
+// GetRewardRules returns the active reward rules so clients can show
+// expected rewards
+func (h *GamificationHandler) GetRewardRules(c *gin.Context) {
+	rules, err := h.repo.GetRewardRules(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record boss quiz"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get reward rules"})
 		return
 	}
 
-	// Add bonus lemons
-	currencyQuery := `
-		INSERT INTO lemon_currency (user_id, total_lemons, tree_lemons_available, updated_at)
-		VALUES ($1, $2, $2, NOW())
-		ON CONFLICT (user_id) DO UPDATE
-		SET total_lemons = lemon_currency.total_lemons + $2,
-		    tree_lemons_available = lemon_currency.tree_lemons_available + $2,
-		    updated_at = NOW()
-	`
-	h.repo.GetDB().ExecContext(c.Request.Context(), currencyQuery, uid, bonusLemons)
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// GetLemonTransactions returns a page of lemon history with per-period summaries
+// GET /api/progress/lemon-transactions/:userId
+//
+// Query: type (comma-separated), account (wallet, tree, harvested),
+// from/to (RFC3339 or YYYY-MM-DD, to is inclusive for dates),
+// period (day, week, month), cursor, limit (max 200)
+func (h *GamificationHandler) GetLemonTransactions(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	// Users see their own history; admins can look up any user for support
+	authUserID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if authUserID != userID {
+		isAdmin, err := h.repo.IsAdmin(c.Request.Context(), authUserID)
+		if err != nil || !isAdmin {
+			log.Printf("[LEMONS] Unauthorized history access for user %d by user %d", userID, authUserID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot access lemon history for other users"})
+			return
+		}
+	}
+
+	filter := &models.LemonTransactionFilter{UserID: userID, Limit: 50}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+			filter.Limit = l
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		filter.BeforeID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || filter.BeforeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	for _, t := range splitQueryList(c.Query("type")) {
+		if !models.IsLemonTransactionType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid type: " + t})
+			return
+		}
+		filter.Types = append(filter.Types, t)
+	}
+
+	for _, account := range splitQueryList(c.Query("account")) {
+		if !models.IsUserLedgerAccount(account) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account: " + account})
+			return
+		}
+		filter.Accounts = append(filter.Accounts, account)
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseHistoryTime(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseHistoryTime(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	period := c.DefaultQuery("period", models.SummaryPeriodMonth)
+	switch period {
+	case models.SummaryPeriodDay, models.SummaryPeriodWeek, models.SummaryPeriodMonth:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day, week or month"})
+		return
+	}
+
+	transactions, nextCursor, err := h.repo.GetLemonTransactions(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("[LEMONS] Error fetching history for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get lemon transactions"})
+		return
+	}
+
+	summaries, err := h.repo.GetLemonTransactionSummaries(c.Request.Context(), filter, period)
+	if err != nil {
+		log.Printf("[LEMONS] Error summarizing history for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get lemon transactions"})
+		return
+	}
 
-	// Record transaction
-	txQuery := `INSERT INTO lemon_transactions (user_id, amount, type) VALUES ($1, $2, 'boss')`
-	h.repo.GetDB().ExecContext(c.Request.Context(), txQuery, uid, bonusLemons)
+	var next interface{}
+	if nextCursor > 0 {
+		next = strconv.FormatInt(nextCursor, 10)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success":       true,
-		"bonus_lemons":  bonusLemons,
+		"transactions": transactions,
+		"next_cursor":  next,
+		"has_more":     nextCursor > 0,
+		"period":       period,
+		"summaries":    summaries,
 	})
 }
+
+// splitQueryList splits a comma-separated query value, dropping empty items
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseHistoryTime parses an RFC3339 timestamp or a YYYY-MM-DD date (UTC).
+// With endOfDay, a date means the start of the following day so the whole
+// day is included by an exclusive upper bound.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
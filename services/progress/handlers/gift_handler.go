@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// GIFTS HANDLER
+// ================================================================
+
+const (
+	defaultGiftLimit = 20
+	maxGiftLimit     = 50
+	maxGiftMessage   = 200
+)
+
+// GiftHandler handles gifts between friends
+type GiftHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewGiftHandler creates a new gift handler
+func NewGiftHandler(repo *repository.ProgressRepository) *GiftHandler {
+	return &GiftHandler{repo: repo}
+}
+
+// SendGiftRequest is the request body for a gift: either lemons or an item
+type SendGiftRequest struct {
+	RecipientID int64  `json:"recipient_id" binding:"required"`
+	Lemons      int    `json:"lemons"`
+	ItemID      int64  `json:"item_id"`
+	Message     string `json:"message"`
+}
+
+// MarkGiftsSeenRequest is the request body for opening gifts. No IDs marks
+// every received gift as seen.
+type MarkGiftsSeenRequest struct {
+	GiftIDs []int64 `json:"gift_ids"`
+}
+
+// SendGift gives a friend lemons or a shop item
+// POST /api/progress/gifts
+func (h *GiftHandler) SendGift(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req SendGiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Lemons < 0 || req.ItemID < 0 || (req.Lemons > 0) == (req.ItemID > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gift either lemons or item_id"})
+		return
+	}
+	if utf8.RuneCountInString(req.Message) > maxGiftMessage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message too long"})
+		return
+	}
+
+	gift, remaining, err := h.repo.SendGift(c.Request.Context(), userID, req.RecipientID, req.Lemons, req.ItemID, req.Message)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientLemons):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrItemUnavailable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrItemNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrItemAlreadyOwned), errors.Is(err, repository.ErrItemSoldOut):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrNotFriends), errors.Is(err, repository.ErrAccountTooNew):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrGiftLimitReached), errors.Is(err, repository.ErrGiftReceiveLimitReached):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			respondRelationError(c, err, "failed to send gift", userID)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"gift":             gift,
+		"remaining_lemons": remaining,
+	})
+}
+
+// ListReceived returns gifts the caller received, newest first, with the
+// number not yet seen
+// GET /api/progress/gifts/received?cursor=&limit=
+func (h *GiftHandler) ListReceived(c *gin.Context) {
+	h.listGifts(c, true)
+}
+
+// ListSent returns gifts the caller sent, newest first
+// GET /api/progress/gifts/sent?cursor=&limit=
+func (h *GiftHandler) ListSent(c *gin.Context) {
+	h.listGifts(c, false)
+}
+
+func (h *GiftHandler) listGifts(c *gin.Context, received bool) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultGiftLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxGiftLimit {
+			n = maxGiftLimit
+		}
+		limit = n
+	}
+
+	var beforeID int64
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		beforeID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || beforeID < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	gifts, nextCursor, err := h.repo.ListGifts(c.Request.Context(), userID, received, beforeID, limit)
+	if err != nil {
+		log.Printf("[GIFTS] Error listing gifts for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get gifts"})
+		return
+	}
+
+	var next interface{}
+	if nextCursor > 0 {
+		next = strconv.FormatInt(nextCursor, 10)
+	}
+	resp := gin.H{
+		"gifts":       gifts,
+		"next_cursor": next,
+		"has_more":    nextCursor > 0,
+	}
+
+	if received {
+		unseen, err := h.repo.CountUnseenGifts(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("[GIFTS] Error counting unseen gifts for user %d: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get gifts"})
+			return
+		}
+		resp["unseen_count"] = unseen
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// MarkSeen marks received gifts as seen
+// POST /api/progress/gifts/seen
+func (h *GiftHandler) MarkSeen(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req MarkGiftsSeenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	seen, err := h.repo.MarkGiftsSeen(c.Request.Context(), userID, req.GiftIDs)
+	if err != nil {
+		log.Printf("[GIFTS] Error marking gifts seen for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark gifts seen"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "seen": seen})
+}
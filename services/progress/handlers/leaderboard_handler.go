@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// LEADERBOARDS HANDLER
+// ================================================================
+
+const (
+	defaultLeaderboardLimit  = 50
+	maxLeaderboardLimit      = 100
+	defaultLeaderboardRadius = 5
+	maxLeaderboardRadius     = 25
+)
+
+// LeaderboardHandler handles leaderboard endpoints
+type LeaderboardHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewLeaderboardHandler creates a new leaderboard handler
+func NewLeaderboardHandler(repo *repository.ProgressRepository) *LeaderboardHandler {
+	return &LeaderboardHandler{repo: repo}
+}
+
+// LeaderboardPrivacyRequest is the request body for the privacy setting
+type LeaderboardPrivacyRequest struct {
+	OptOut *bool `json:"opt_out" binding:"required"`
+}
+
+// GetLeaderboard returns the top users of a board
+// GET /api/progress/leaderboards/:metric?scope=weekly|all_time&limit=50
+// With week=YYYY-MM-DD the archived results of that finished week are returned.
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	metric, scope, ok := leaderboardParams(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultLeaderboardLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxLeaderboardLimit {
+			n = maxLeaderboardLimit
+		}
+		limit = n
+	}
+
+	ctx := c.Request.Context()
+	weekStart := models.WeekStart(time.Now())
+
+	var entries []models.LeaderboardEntry
+	var err error
+	if week := c.Query("week"); week != "" {
+		t, parseErr := time.Parse("2006-01-02", week)
+		if parseErr != nil || !t.Equal(models.WeekStart(t)) || !t.Before(weekStart) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "week must be the Monday of a finished week (YYYY-MM-DD)"})
+			return
+		}
+		scope, weekStart = models.LeaderboardWeekly, t
+		entries, err = h.repo.GetArchivedLeaderboard(ctx, metric, t, limit)
+	} else {
+		entries, err = h.repo.GetLeaderboard(ctx, scope, metric, limit)
+	}
+	if err != nil {
+		log.Printf("[LEADERBOARD] Error fetching %s %s board: %v", scope, metric, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get leaderboard"})
+		return
+	}
+
+	response := gin.H{
+		"metric":  metric,
+		"scope":   scope,
+		"entries": entries,
+	}
+	if scope == models.LeaderboardWeekly {
+		response["week_start"] = weekStart.Format("2006-01-02")
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMyPosition returns the caller's rank and the users around it
+// GET /api/progress/leaderboards/:metric/me?scope=weekly|all_time&radius=5
+func (h *LeaderboardHandler) GetMyPosition(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	metric, scope, ok := leaderboardParams(c)
+	if !ok {
+		return
+	}
+
+	radius := defaultLeaderboardRadius
+	if v := c.Query("radius"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid radius"})
+			return
+		}
+		if n > maxLeaderboardRadius {
+			n = maxLeaderboardRadius
+		}
+		radius = n
+	}
+
+	ctx := c.Request.Context()
+	optedOut, err := h.repo.GetLeaderboardOptOut(ctx, userID)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Error fetching opt-out for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get leaderboard"})
+		return
+	}
+
+	me, neighbours, err := h.repo.GetLeaderboardPosition(ctx, scope, metric, userID, radius)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Error fetching position for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metric":     metric,
+		"scope":      scope,
+		"me":         me,
+		"neighbours": neighbours,
+		"opted_out":  optedOut,
+	})
+}
+
+// GetFriendsLeaderboard ranks the caller among their friends
+// GET /api/progress/leaderboards/:metric/friends?scope=weekly|all_time
+func (h *LeaderboardHandler) GetFriendsLeaderboard(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	metric, scope, ok := leaderboardParams(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.repo.GetFriendLeaderboard(c.Request.Context(), scope, metric, userID)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Error fetching friends board for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get leaderboard"})
+		return
+	}
+
+	response := gin.H{
+		"metric":  metric,
+		"scope":   scope,
+		"entries": entries,
+	}
+	if scope == models.LeaderboardWeekly {
+		response["week_start"] = models.WeekStart(time.Now()).Format("2006-01-02")
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdatePrivacy sets whether the caller appears on leaderboards
+// PUT /api/progress/leaderboards/privacy
+func (h *LeaderboardHandler) UpdatePrivacy(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req LeaderboardPrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.SetLeaderboardOptOut(c.Request.Context(), userID, *req.OptOut); err != nil {
+		log.Printf("[LEADERBOARD] Error updating opt-out for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update leaderboard privacy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"opted_out": *req.OptOut,
+	})
+}
+
+// leaderboardParams validates the metric path parameter and scope query.
+// Writes a 400 response and returns false if either is invalid.
+func leaderboardParams(c *gin.Context) (string, string, bool) {
+	metric := c.Param("metric")
+	if !models.IsLeaderboardMetric(metric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metric", "metrics": models.LeaderboardMetrics})
+		return "", "", false
+	}
+
+	scope := c.DefaultQuery("scope", models.LeaderboardWeekly)
+	if scope != models.LeaderboardWeekly && scope != models.LeaderboardAllTime {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be weekly or all_time"})
+		return "", "", false
+	}
+
+	return metric, scope, true
+}
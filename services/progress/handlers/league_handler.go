@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// LEAGUES HANDLER
+// ================================================================
+
+const (
+	defaultLeagueHistoryLimit = 10
+	maxLeagueHistoryLimit     = 52
+)
+
+// LeagueHandler handles weekly league endpoints
+type LeagueHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewLeagueHandler creates a new league handler
+func NewLeagueHandler(repo *repository.ProgressRepository) *LeagueHandler {
+	return &LeagueHandler{repo: repo}
+}
+
+// GetLeague returns the caller's cohort for this week with live standings
+// GET /api/progress/league
+func (h *LeagueHandler) GetLeague(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	league, err := h.repo.GetLeague(c.Request.Context(), userID)
+	if errors.Is(err, repository.ErrLeagueOptedOut) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[LEAGUE] Error fetching league for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get league"})
+		return
+	}
+
+	c.JSON(http.StatusOK, league)
+}
+
+// GetHistory returns the caller's finalized league weeks, newest first
+// GET /api/progress/league/history?limit=10
+func (h *LeagueHandler) GetHistory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit := defaultLeagueHistoryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxLeagueHistoryLimit {
+			n = maxLeagueHistoryLimit
+		}
+		limit = n
+	}
+
+	results, err := h.repo.GetLeagueHistory(c.Request.Context(), userID, limit)
+	if err != nil {
+		log.Printf("[LEAGUE] Error fetching history for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get league history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
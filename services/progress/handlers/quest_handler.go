@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// QUESTS HANDLER
+// ================================================================
+// Lists the user's daily and weekly quests and pays out completed ones.
+// Progress is advanced by the lesson, vocabulary and hangul endpoints.
+// ================================================================
+
+// QuestHandler handles quest endpoints
+type QuestHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewQuestHandler creates a new quest handler
+func NewQuestHandler(repo *repository.ProgressRepository) *QuestHandler {
+	return &QuestHandler{repo: repo}
+}
+
+// ClaimQuestRequest is the request body for claiming a quest
+type ClaimQuestRequest struct {
+	QuestID int64 `json:"quest_id" binding:"required"`
+}
+
+// GetQuests returns the user's active quests with progress
+// GET /api/progress/quests/:userId
+func (h *QuestHandler) GetQuests(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	authUserID, err := middleware.GetUserID(c)
+	if err != nil || authUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot access quests for other users"})
+		return
+	}
+
+	quests, err := h.repo.GetActiveQuests(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[QUESTS] Error fetching quests for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get quests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quests": quests,
+	})
+}
+
+// ClaimQuest pays out a completed quest
+// POST /api/progress/quests/claim
+func (h *QuestHandler) ClaimQuest(c *gin.Context) {
+	uid, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ClaimQuestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quest, total, err := h.repo.ClaimQuest(c.Request.Context(), uid, req.QuestID)
+	switch {
+	case errors.Is(err, repository.ErrQuestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, repository.ErrQuestNotCompleted), errors.Is(err, repository.ErrQuestAlreadyClaimed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("[QUESTS] Error claiming quest %d for user %d: %v", req.QuestID, uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim quest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"lemons_earned": quest.RewardLemons,
+		"total_lemons":  total,
+		"quest":         quest,
+	})
+}
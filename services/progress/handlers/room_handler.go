@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// ROOMS HANDLER
+// ================================================================
+
+const (
+	maxRoomName         = 30
+	defaultPopularRooms = 20
+	maxPopularRooms     = 50
+)
+
+// RoomHandler handles the user's rooms and their furniture layouts
+type RoomHandler struct {
+	repo *repository.ProgressRepository
+}
+
+// NewRoomHandler creates a new room handler
+func NewRoomHandler(repo *repository.ProgressRepository) *RoomHandler {
+	return &RoomHandler{repo: repo}
+}
+
+// RoomRequest is the request body for creating or renaming a room
+type RoomRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// RoomVisibilityRequest is the request body for changing who may visit a room
+type RoomVisibilityRequest struct {
+	Visibility string `json:"visibility" binding:"required"`
+}
+
+// RoomFurnitureRequest is the request body for saving a room layout. It
+// replaces all furniture in the room.
+type RoomFurnitureRequest struct {
+	Furniture []models.FurniturePlacement `json:"furniture" binding:"required"`
+}
+
+// GetRoom visits a user's active room: returns it with its furniture and
+// counts the visit
+// GET /api/progress/room/:userId
+func (h *RoomHandler) GetRoom(c *gin.Context) {
+	viewerID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	ownerID, ok := idParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	room, furniture, err := h.repo.VisitRoom(c.Request.Context(), ownerID, viewerID)
+	if err != nil {
+		respondRoomError(c, err, "failed to get room", viewerID)
+		return
+	}
+
+	respondRoom(c, room, furniture)
+}
+
+// UpdateRoomFurniture replaces the furniture of the active room
+// PUT /api/progress/room/furniture
+func (h *RoomHandler) UpdateRoomFurniture(c *gin.Context) {
+	h.saveFurniture(c, 0)
+}
+
+// ListRooms returns the user's rooms
+// GET /api/progress/rooms
+func (h *RoomHandler) ListRooms(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	rooms, err := h.repo.ListRooms(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[ROOMS] Error listing rooms for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get rooms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rooms":     rooms,
+		"max_rooms": models.MaxRooms,
+	})
+}
+
+// CreateRoom adds an empty room
+// POST /api/progress/rooms
+func (h *RoomHandler) CreateRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	name, ok := bindRoomName(c)
+	if !ok {
+		return
+	}
+
+	room, err := h.repo.CreateRoom(c.Request.Context(), userID, name)
+	if err != nil {
+		respondRoomError(c, err, "failed to create room", userID)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"room": room})
+}
+
+// GetOwnRoom returns one of the user's rooms and its furniture
+// GET /api/progress/rooms/:roomId
+func (h *RoomHandler) GetOwnRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	room, furniture, err := h.repo.GetRoom(c.Request.Context(), userID, roomID)
+	if err != nil {
+		respondRoomError(c, err, "failed to get room", userID)
+		return
+	}
+
+	respondRoom(c, room, furniture)
+}
+
+// RenameRoom changes a room's name
+// PUT /api/progress/rooms/:roomId
+func (h *RoomHandler) RenameRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+	name, ok := bindRoomName(c)
+	if !ok {
+		return
+	}
+
+	room, err := h.repo.RenameRoom(c.Request.Context(), userID, roomID, name)
+	if err != nil {
+		respondRoomError(c, err, "failed to rename room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// DeleteRoom removes an inactive room
+// DELETE /api/progress/rooms/:roomId
+func (h *RoomHandler) DeleteRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteRoom(c.Request.Context(), userID, roomID); err != nil {
+		respondRoomError(c, err, "failed to delete room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ActivateRoom makes a room the one visitors see
+// POST /api/progress/rooms/:roomId/activate
+func (h *RoomHandler) ActivateRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	room, err := h.repo.ActivateRoom(c.Request.Context(), userID, roomID)
+	if err != nil {
+		respondRoomError(c, err, "failed to activate room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// SetVisibility changes who may visit a room
+// PUT /api/progress/rooms/:roomId/visibility
+func (h *RoomHandler) SetVisibility(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	var req RoomVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.IsRoomVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be public, friends or private"})
+		return
+	}
+
+	room, err := h.repo.SetRoomVisibility(c.Request.Context(), userID, roomID, req.Visibility)
+	if err != nil {
+		respondRoomError(c, err, "failed to update room visibility", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"room": room})
+}
+
+// LikeRoom likes another user's room
+// POST /api/progress/rooms/:roomId/like
+func (h *RoomHandler) LikeRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	likes, reward, err := h.repo.LikeRoom(c.Request.Context(), userID, roomID)
+	if err != nil {
+		respondRoomError(c, err, "failed to like room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"liked":        true,
+		"like_count":   likes,
+		"owner_reward": reward,
+	})
+}
+
+// UnlikeRoom removes the user's like from a room
+// DELETE /api/progress/rooms/:roomId/like
+func (h *RoomHandler) UnlikeRoom(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+
+	likes, err := h.repo.UnlikeRoom(c.Request.Context(), userID, roomID)
+	if err != nil {
+		respondRoomError(c, err, "failed to unlike room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"liked":      false,
+		"like_count": likes,
+	})
+}
+
+// ListPopular returns the most liked public rooms of the week
+// GET /api/progress/rooms/popular?limit=20
+func (h *RoomHandler) ListPopular(c *gin.Context) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPopularRooms)))
+	if err != nil || limit < 1 {
+		limit = defaultPopularRooms
+	}
+	if limit > maxPopularRooms {
+		limit = maxPopularRooms
+	}
+
+	rooms, err := h.repo.ListPopularRooms(c.Request.Context(), userID, limit)
+	if err != nil {
+		log.Printf("[ROOMS] Error listing popular rooms: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get popular rooms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
+
+// UpdateFurniture replaces the furniture of one of the user's rooms
+// PUT /api/progress/rooms/:roomId/furniture
+func (h *RoomHandler) UpdateFurniture(c *gin.Context) {
+	roomID, ok := idParam(c, "roomId")
+	if !ok {
+		return
+	}
+	h.saveFurniture(c, roomID)
+}
+
+// saveFurniture validates and saves a layout for roomID (0 = active room)
+func (h *RoomHandler) saveFurniture(c *gin.Context, roomID int64) {
+	userID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req RoomFurnitureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room, furniture, err := h.repo.SaveRoomFurniture(c.Request.Context(), userID, roomID, req.Furniture)
+	if err != nil {
+		respondRoomError(c, err, "failed to save room", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"count":     len(furniture),
+		"room":      room,
+		"furniture": furniture,
+	})
+}
+
+// respondRoom writes a room with its furniture and the grid it is laid out on
+func respondRoom(c *gin.Context, room *models.Room, furniture []models.RoomFurniture) {
+	c.JSON(http.StatusOK, gin.H{
+		"room":      room,
+		"furniture": furniture,
+		"grid": gin.H{
+			"width":     models.RoomGridWidth,
+			"depth":     models.RoomGridDepth,
+			"max_layer": models.MaxRoomLayer,
+		},
+	})
+}
+
+// bindRoomName parses and validates a room name body
+func bindRoomName(c *gin.Context) (string, bool) {
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxRoomName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-30 characters"})
+		return "", false
+	}
+	return name, true
+}
+
+// respondRoomError maps room and layout errors to responses. Layout errors
+// carry the index and code of the rejected placement.
+func respondRoomError(c *gin.Context, err error, message string, userID int64) {
+	var layoutErr *models.RoomLayoutError
+	switch {
+	case errors.As(err, &layoutErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   layoutErr.Error(),
+			"details": layoutErr,
+		})
+	case errors.Is(err, repository.ErrRoomNotFound),
+		errors.Is(err, repository.ErrRoomNotLiked),
+		errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrRoomActive), errors.Is(err, repository.ErrSelfRelation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrRoomPrivate), errors.Is(err, repository.ErrUserBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrRoomNameTaken),
+		errors.Is(err, repository.ErrRoomLimitReached),
+		errors.Is(err, repository.ErrRoomAlreadyLiked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[ROOMS] %s for user %d: %v", message, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
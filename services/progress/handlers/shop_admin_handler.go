@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/media"
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ================================================================
+// SHOP CATALOGUE ADMIN HANDLER
+// ================================================================
+
+const (
+	defaultShopAuditLimit = 50
+	maxShopAuditLimit     = 200
+	maxShopSales          = 200
+)
+
+// ShopAdminHandler handles catalogue administration for admins
+type ShopAdminHandler struct {
+	repo   *repository.ProgressRepository
+	assets *media.AssetChecker
+}
+
+// NewShopAdminHandler creates a new shop admin handler
+func NewShopAdminHandler(repo *repository.ProgressRepository, assets *media.AssetChecker) *ShopAdminHandler {
+	return &ShopAdminHandler{repo: repo, assets: assets}
+}
+
+// ShopOrderRequest is the request body for reordering a category
+type ShopOrderRequest struct {
+	Category string  `json:"category" binding:"required"`
+	ItemIDs  []int64 `json:"item_ids" binding:"required"`
+}
+
+// ShopSaleRequest is the request body for scheduling a sale. The sale
+// starts immediately when starts_at is omitted.
+type ShopSaleRequest struct {
+	ItemID          int64      `json:"item_id" binding:"required"`
+	DiscountPercent int        `json:"discount_percent" binding:"required"`
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          time.Time  `json:"ends_at" binding:"required"`
+}
+
+// RequireAdmin only lets admin users through
+func RequireAdmin(repo *repository.ProgressRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := authUser(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		isAdmin, err := repo.IsAdmin(c.Request.Context(), userID)
+		if err != nil || !isAdmin {
+			log.Printf("[SHOP] Admin access denied for user %d", userID)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListItems returns the whole catalogue, including inactive and scheduled
+// items
+// GET /api/progress/admin/shop/items?category=hat
+func (h *ShopAdminHandler) ListItems(c *gin.Context) {
+	items, err := h.repo.ListCatalogItems(c.Request.Context(), c.Query("category"))
+	if err != nil {
+		log.Printf("[SHOP] Error listing catalogue: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetItem returns one catalogue item
+// GET /api/progress/admin/shop/items/:itemId
+func (h *ShopAdminHandler) GetItem(c *gin.Context) {
+	itemID, ok := idParam(c, "itemId")
+	if !ok {
+		return
+	}
+
+	item, err := h.repo.GetCatalogItem(c.Request.Context(), itemID)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to get item")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// CreateItem adds an item. category, name and asset_key are required;
+// other fields default to an active, common, svg item.
+// POST /api/progress/admin/shop/items
+func (h *ShopAdminHandler) CreateItem(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var patch models.ShopItemPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	item := patch.Apply(models.ShopItem{
+		AssetType: "svg",
+		Rarity:    "common",
+		IsActive:  true,
+		Metadata:  json.RawMessage(`{}`),
+	})
+	if !h.checkItem(c, nil, item) {
+		return
+	}
+
+	created, err := h.repo.CreateCatalogItem(c.Request.Context(), adminID, item)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to create item")
+		return
+	}
+
+	log.Printf("[SHOP] Admin %d created item %d (%s)", adminID, created.ID, created.Name)
+	c.JSON(http.StatusCreated, gin.H{"item": created})
+}
+
+// UpdateItem changes the fields present in the body. A null
+// available_from or available_until opens that side of the window.
+// PATCH /api/progress/admin/shop/items/:itemId
+func (h *ShopAdminHandler) UpdateItem(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	itemID, ok := idParam(c, "itemId")
+	if !ok {
+		return
+	}
+
+	var patch models.ShopItemPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := h.repo.GetCatalogItem(c.Request.Context(), itemID)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to get item")
+		return
+	}
+	if !h.checkItem(c, current, patch.Apply(*current)) {
+		return
+	}
+
+	updated, err := h.repo.UpdateCatalogItem(c.Request.Context(), adminID, itemID, patch, models.ShopAuditUpdate)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to update item")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": updated})
+}
+
+// DeactivateItem takes an item off sale. It can no longer be bought or
+// gifted; owners keep it and can still equip it.
+// POST /api/progress/admin/shop/items/:itemId/deactivate
+func (h *ShopAdminHandler) DeactivateItem(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	itemID, ok := idParam(c, "itemId")
+	if !ok {
+		return
+	}
+
+	inactive := false
+	patch := models.ShopItemPatch{IsActive: &inactive}
+	item, err := h.repo.UpdateCatalogItem(c.Request.Context(), adminID, itemID, patch, models.ShopAuditDeactivate)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to deactivate item")
+		return
+	}
+
+	log.Printf("[SHOP] Admin %d deactivated item %d", adminID, itemID)
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// ReorderItems sets the shop order of a category
+// PUT /api/progress/admin/shop/order
+func (h *ShopAdminHandler) ReorderItems(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req ShopOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsShopItemCategory(req.Category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown category"})
+		return
+	}
+
+	items, err := h.repo.ReorderCatalogItems(c.Request.Context(), adminID, req.Category, req.ItemIDs)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to reorder items")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// ListAudit returns recent catalogue changes
+// GET /api/progress/admin/shop/audit?item_id=12&limit=50
+func (h *ShopAdminHandler) ListAudit(c *gin.Context) {
+	var itemID int64
+	if s := c.Query("item_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item_id"})
+			return
+		}
+		itemID = id
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultShopAuditLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultShopAuditLimit
+	}
+	if limit > maxShopAuditLimit {
+		limit = maxShopAuditLimit
+	}
+
+	entries, err := h.repo.ListShopAudit(c.Request.Context(), itemID, limit)
+	if err != nil {
+		log.Printf("[SHOP] Error listing audit: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get audit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// ListSales returns running and upcoming sales, or recent ended ones too
+// with ?all=true
+// GET /api/progress/admin/shop/sales
+func (h *ShopAdminHandler) ListSales(c *gin.Context) {
+	sales, err := h.repo.ListShopSales(c.Request.Context(), c.Query("all") == "true", maxShopSales)
+	if err != nil {
+		log.Printf("[SHOP] Error listing sales: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get sales"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sales": sales})
+}
+
+// CreateSale schedules a discount on an item
+// POST /api/progress/admin/shop/sales
+func (h *ShopAdminHandler) CreateSale(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+
+	var req ShopSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sale := models.ShopSale{
+		ItemID:          req.ItemID,
+		DiscountPercent: req.DiscountPercent,
+		StartsAt:        time.Now(),
+		EndsAt:          req.EndsAt,
+	}
+	if req.StartsAt != nil {
+		sale.StartsAt = *req.StartsAt
+	}
+
+	created, err := h.repo.CreateShopSale(c.Request.Context(), adminID, sale)
+	if err != nil {
+		respondShopAdminError(c, err, "failed to create sale")
+		return
+	}
+
+	log.Printf("[SHOP] Admin %d created sale %d: %d%% off item %d", adminID, created.ID, created.DiscountPercent, created.ItemID)
+	c.JSON(http.StatusCreated, gin.H{"sale": created})
+}
+
+// EndSale stops a running sale, or cancels an upcoming one
+// DELETE /api/progress/admin/shop/sales/:saleId
+func (h *ShopAdminHandler) EndSale(c *gin.Context) {
+	adminID, ok := authUser(c)
+	if !ok {
+		return
+	}
+	saleID, ok := idParam(c, "saleId")
+	if !ok {
+		return
+	}
+
+	if err := h.repo.EndShopSale(c.Request.Context(), adminID, saleID); err != nil {
+		respondShopAdminError(c, err, "failed to end sale")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// checkItem validates an item about to be saved and checks that its newly
+// referenced uploaded assets exist. before is nil for new items. Writes
+// the error response and returns false if the item is rejected.
+func (h *ShopAdminHandler) checkItem(c *gin.Context, before *models.ShopItem, item models.ShopItem) bool {
+	if err := models.ValidateShopItem(item); err != nil {
+		respondShopAdminError(c, err, "invalid item")
+		return false
+	}
+
+	known := map[string]bool{}
+	if before != nil {
+		for _, key := range before.MediaAssetKeys() {
+			known[key] = true
+		}
+	}
+	for _, key := range item.MediaAssetKeys() {
+		if known[key] {
+			continue
+		}
+		err := h.assets.Check(c.Request.Context(), key)
+		if errors.Is(err, media.ErrAssetNotFound) {
+			respondShopAdminError(c, &models.ShopItemError{Field: "asset_key", Message: key + " not found in media service"}, "invalid item")
+			return false
+		}
+		if err != nil {
+			log.Printf("[SHOP] Error checking asset %s: %v", key, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "media service unavailable"})
+			return false
+		}
+	}
+	return true
+}
+
+// respondShopAdminError maps catalogue errors to responses. Validation
+// errors carry the rejected field.
+func respondShopAdminError(c *gin.Context, err error, message string) {
+	var itemErr *models.ShopItemError
+	switch {
+	case errors.As(err, &itemErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   itemErr.Error(),
+			"details": itemErr,
+		})
+	case errors.Is(err, repository.ErrItemNotFound), errors.Is(err, repository.ErrSaleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrShopOrderMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[SHOP] %s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"lemonkorean/progress/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+// ================================================================
+// SYNC BODY ENCODINGS
+// ================================================================
+// Sync endpoints accept JSON (default) or MessagePack, selected by
+// Content-Type. MessagePack bodies are normalised to JSON so both
+// encodings go through the same typed payload validation.
+// ================================================================
+
+// errUnsupportedContentType is returned for unknown sync body encodings
+var errUnsupportedContentType = errors.New("unsupported Content-Type")
+
+// msgpackHandle decodes maps with string keys and strings as Go strings
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := new(codec.MsgpackHandle)
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return h
+}()
+
+// bindSyncBody decodes the request body into obj according to its Content-Type
+func bindSyncBody(c *gin.Context, obj interface{}) error {
+	switch c.ContentType() {
+	case "", binding.MIMEJSON:
+		return c.ShouldBindJSON(obj)
+
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		var generic interface{}
+		if err := codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(&generic); err != nil {
+			if err == io.EOF {
+				return errors.New("empty request body")
+			}
+			return err
+		}
+
+		normalised, err := json.Marshal(generic)
+		if err != nil {
+			return fmt.Errorf("invalid msgpack body: %w", err)
+		}
+		return binding.JSON.BindBody(normalised, obj)
+
+	default:
+		return fmt.Errorf("%w %q", errUnsupportedContentType, c.ContentType())
+	}
+}
+
+// respondBindError maps sync body decoding errors to HTTP responses
+func respondBindError(c *gin.Context, err error) {
+	switch {
+	case middleware.IsBodyTooLarge(err):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Payload Too Large",
+			"message": err.Error(),
+		})
+	case errors.Is(err, errUnsupportedContentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported Media Type",
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+// bindSync decodes body with bindSyncBody the way the sync endpoints do
+func bindSync(t *testing.T, contentType string, body []byte) (*models.SyncProgressRequest, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	var bound *models.SyncProgressRequest
+
+	router := gin.New()
+	router.POST("/sync", func(c *gin.Context) {
+		var req models.SyncProgressRequest
+		if err := bindSyncBody(c, &req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		bound = &req
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/sync", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return bound, w
+}
+
+func encodeMsgpack(t *testing.T, v interface{}) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, codec.NewEncoder(&buf, msgpackHandle).Encode(v))
+	return buf.Bytes()
+}
+
+func TestBindSyncBodyMsgpack(t *testing.T) {
+	syncedAt := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+	body := encodeMsgpack(t, map[string]interface{}{
+		"user_id":        int64(7),
+		"device_id":      "phone",
+		"atomic":         true,
+		"last_synced_at": syncedAt,
+		"sync_items": []interface{}{
+			map[string]interface{}{
+				"id":    "q-1",
+				"type":  models.SyncTypeLessonComplete,
+				"group": "lesson-12",
+				"data":  map[string]interface{}{"lesson_id": 12, "quiz_score": 80, "time_spent": 5},
+			},
+			map[string]interface{}{
+				"type": models.SyncTypeVocabularyPractice,
+				"data": map[string]interface{}{"vocabulary_id": 3, "is_correct": true, "response_time": 1200},
+			},
+		},
+	})
+
+	req, w := bindSync(t, "application/x-msgpack", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, int64(7), req.UserID)
+	assert.Equal(t, "phone", req.DeviceID)
+	assert.True(t, req.Atomic)
+	require.NotNil(t, req.LastSyncedAt)
+	assert.True(t, syncedAt.Equal(*req.LastSyncedAt))
+	require.Len(t, req.SyncItems, 2)
+	assert.Equal(t, "q-1", req.SyncItems[0].ID)
+	assert.Equal(t, "lesson-12", req.SyncItems[0].Group)
+
+	// Item data goes through the same typed validation as JSON bodies
+	payload, itemErr := models.DecodeSyncItem(0, &req.SyncItems[0])
+	require.Nil(t, itemErr)
+	lesson := payload.(*models.LessonCompletePayload)
+	assert.Equal(t, int64(12), lesson.LessonID)
+	assert.Equal(t, 80, lesson.QuizScore)
+
+	payload, itemErr = models.DecodeSyncItem(1, &req.SyncItems[1])
+	require.Nil(t, itemErr)
+	practice := payload.(*models.VocabularyPracticePayload)
+	assert.Equal(t, int64(3), practice.VocabularyID)
+	assert.True(t, *practice.IsCorrect)
+}
+
+func TestBindSyncBodyMsgpackErrors(t *testing.T) {
+	_, w := bindSync(t, "application/msgpack", []byte{0xc1})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "invalid msgpack")
+
+	_, w = bindSync(t, "application/x-msgpack", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "empty body")
+
+	// Required fields are enforced after normalisation
+	_, w = bindSync(t, "application/x-msgpack", encodeMsgpack(t, map[string]interface{}{"user_id": 7}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	_, w = bindSync(t, "text/xml", []byte("<sync/>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
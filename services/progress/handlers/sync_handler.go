@@ -3,14 +3,15 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"lemonkorean/progress/middleware"
 	"lemonkorean/progress/models"
+	"lemonkorean/progress/realtime"
 	"lemonkorean/progress/repository"
-	"lemonkorean/progress/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -34,11 +35,8 @@ func NewSyncHandler(repo *repository.ProgressRepository) *SyncHandler {
 // POST /api/progress/sync
 func (h *SyncHandler) SyncProgress(c *gin.Context) {
 	var req models.SyncProgressRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": err.Error(),
-		})
+	if err := bindSyncBody(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -52,32 +50,30 @@ func (h *SyncHandler) SyncProgress(c *gin.Context) {
 		return
 	}
 
-	// Process each sync item
-	successCount := 0
-	failedCount := 0
-	errors := []string{}
-
-	for _, item := range req.SyncItems {
-		err := h.processSyncItem(c, &item, req.UserID)
-		if err != nil {
-			failedCount++
-			errors = append(errors, err.Error())
-		} else {
-			successCount++
-		}
+	// Reject ended sessions and devices the user has revoked
+	sessionID, err := h.authorizeSyncDevice(c, userID, req.DeviceID)
+	if err != nil {
+		respondSyncAuthError(c, req.DeviceID, err)
+		return
 	}
 
+	// Process each sync item
+	outcome := h.processSyncRequest(c, &req)
+	h.recordDeviceSync(c, &req, sessionID, outcome)
+
 	response := gin.H{
-		"success":       true,
-		"total_items":   len(req.SyncItems),
-		"synced":        successCount,
-		"failed":        failedCount,
-		"device_id":     req.DeviceID,
-		"synced_at":     req.LastSyncedAt,
+		"success":     true,
+		"total_items": len(req.SyncItems),
+		"synced":      outcome.synced,
+		"failed":      outcome.failed(),
+		"rejected":    outcome.rejected,
+		"device_id":   req.DeviceID,
+		"synced_at":   req.LastSyncedAt,
+		"results":     outcome.results,
 	}
 
-	if len(errors) > 0 {
-		response["errors"] = errors
+	if len(outcome.errors) > 0 {
+		response["errors"] = outcome.errors
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -87,11 +83,8 @@ func (h *SyncHandler) SyncProgress(c *gin.Context) {
 // POST /api/progress/sync/batch
 func (h *SyncHandler) BatchSync(c *gin.Context) {
 	var requests []models.SyncProgressRequest
-	if err := c.ShouldBindJSON(&requests); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": err.Error(),
-		})
+	if err := bindSyncBody(c, &requests); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -116,42 +109,65 @@ func (h *SyncHandler) BatchSync(c *gin.Context) {
 		}
 	}
 
+	// Check every device before applying anything
+	sessionIDs := make([]string, len(requests))
+	revoked := make([]bool, len(requests))
+	for i, req := range requests {
+		sessionID, err := h.authorizeSyncDevice(c, userID, req.DeviceID)
+		if err == repository.ErrDeviceRevoked {
+			revoked[i] = true
+			continue
+		}
+		if err != nil {
+			respondSyncAuthError(c, req.DeviceID, err)
+			return
+		}
+		sessionIDs[i] = sessionID
+	}
+
 	// Process all requests
 	results := make([]gin.H, len(requests))
 	totalSynced := 0
 	totalFailed := 0
 
-	for i, req := range requests {
-		successCount := 0
-		failedCount := 0
-		errors := []string{}
-
-		// Process each sync item in this request
-		for _, item := range req.SyncItems {
-			err := h.processSyncItem(c, &item, req.UserID)
-			if err != nil {
-				failedCount++
-				errors = append(errors, err.Error())
-			} else {
-				successCount++
+	for i := range requests {
+		req := &requests[i]
+
+		// Skip requests from revoked devices
+		if revoked[i] {
+			results[i] = gin.H{
+				"device_id": req.DeviceID,
+				"synced":    0,
+				"failed":    len(req.SyncItems),
+				"total":     len(req.SyncItems),
+				"revoked":   true,
+				"message":   "Device has been revoked",
 			}
+			totalFailed += len(req.SyncItems)
+			continue
 		}
 
+		// Process each sync item in this request
+		outcome := h.processSyncRequest(c, req)
+		h.recordDeviceSync(c, req, sessionIDs[i], outcome)
+
 		// Build result for this request
 		results[i] = gin.H{
-			"device_id":  req.DeviceID,
-			"synced":     successCount,
-			"failed":     failedCount,
-			"total":      len(req.SyncItems),
-			"synced_at":  req.LastSyncedAt,
+			"device_id": req.DeviceID,
+			"synced":    outcome.synced,
+			"failed":    outcome.failed(),
+			"rejected":  outcome.rejected,
+			"total":     len(req.SyncItems),
+			"synced_at": req.LastSyncedAt,
+			"results":   outcome.results,
 		}
 
-		if len(errors) > 0 {
-			results[i]["errors"] = errors
+		if len(outcome.errors) > 0 {
+			results[i]["errors"] = outcome.errors
 		}
 
-		totalSynced += successCount
-		totalFailed += failedCount
+		totalSynced += outcome.synced
+		totalFailed += outcome.failed()
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -194,10 +210,11 @@ func (h *SyncHandler) GetSyncStatus(c *gin.Context) {
 	cacheKey := fmt.Sprintf("sync:status:%d", userID)
 
 	type SyncStatus struct {
-		LastSyncedAt  *time.Time `json:"last_synced_at"`
-		PendingItems  int        `json:"pending_items"`
-		SyncQueueSize int        `json:"sync_queue_size"`
-		LastDeviceID  string     `json:"last_device_id"`
+		LastSyncedAt  *time.Time          `json:"last_synced_at"`
+		PendingItems  int                 `json:"pending_items"`
+		SyncQueueSize int                 `json:"sync_queue_size"`
+		LastDeviceID  string              `json:"last_device_id"`
+		Devices       []models.SyncDevice `json:"devices"`
 	}
 
 	var syncStatus SyncStatus
@@ -215,6 +232,7 @@ func (h *SyncHandler) GetSyncStatus(c *gin.Context) {
 				"pending_items":   syncStatus.PendingItems,
 				"sync_queue_size": syncStatus.SyncQueueSize,
 				"last_device_id":  syncStatus.LastDeviceID,
+				"devices":         syncStatus.Devices,
 				"cached":          true,
 			})
 			return
@@ -224,17 +242,39 @@ func (h *SyncHandler) GetSyncStatus(c *gin.Context) {
 		fmt.Printf("[SYNC] Redis error getting sync status: %v\n", err)
 	}
 
-	// Get from database if not in cache
-	lastSync, err := h.repo.GetLastSyncTime(ctx, userID)
-	if err == nil && !lastSync.IsZero() {
-		syncStatus.LastSyncedAt = &lastSync
+	// Build status from the device registry
+	devices, err := h.repo.GetSyncDevices(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to get sync status",
+		})
+		return
 	}
+	syncStatus.Devices = devices
 
-	// Note: pending_items and sync_queue_size would require additional queries
-	// For now, we'll set them to 0 as placeholders
-	syncStatus.PendingItems = 0
-	syncStatus.SyncQueueSize = 0
-	syncStatus.LastDeviceID = ""
+	for i := range devices {
+		d := &devices[i]
+		if d.RevokedAt != nil {
+			continue
+		}
+		// pending_items: items devices report still queued locally
+		// sync_queue_size: items from each device's last sync awaiting retry
+		syncStatus.PendingItems += d.PendingItems
+		syncStatus.SyncQueueSize += d.RetryItems
+		if d.LastSyncAt != nil && (syncStatus.LastSyncedAt == nil || d.LastSyncAt.After(*syncStatus.LastSyncedAt)) {
+			syncStatus.LastSyncedAt = d.LastSyncAt
+			syncStatus.LastDeviceID = d.DeviceID
+		}
+	}
+
+	// Fall back to progress timestamps for users who synced before the registry existed
+	if syncStatus.LastSyncedAt == nil {
+		lastSync, err := h.repo.GetLastSyncTime(ctx, userID)
+		if err == nil && !lastSync.IsZero() {
+			syncStatus.LastSyncedAt = &lastSync
+		}
+	}
 
 	// Cache for 5 minutes
 	statusJSON, _ := json.Marshal(syncStatus)
@@ -247,176 +287,267 @@ func (h *SyncHandler) GetSyncStatus(c *gin.Context) {
 		"pending_items":   syncStatus.PendingItems,
 		"sync_queue_size": syncStatus.SyncQueueSize,
 		"last_device_id":  syncStatus.LastDeviceID,
+		"devices":         syncStatus.Devices,
 		"cached":          false,
 	})
 }
 
-// ================================================================
-// HELPER FUNCTIONS
-// ================================================================
-
-// processSyncItem processes a single sync item based on its type
-func (h *SyncHandler) processSyncItem(c *gin.Context, item *models.SyncItem, userID int64) error {
-	switch item.Type {
-	case "lesson_complete":
-		return h.syncLessonComplete(c, item, userID)
-
-	case "progress_update":
-		return h.syncProgressUpdate(c, item, userID)
-
-	case "vocabulary_practice":
-		return h.syncVocabularyPractice(c, item, userID)
-
-	case "vocabulary_batch":
-		return h.syncVocabularyBatch(c, item, userID)
-
-	default:
-		return nil // Ignore unknown types
+// GetSyncDevices lists the devices that have synced for a user
+// GET /api/progress/sync/devices/:userId
+func (h *SyncHandler) GetSyncDevices(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid user ID",
+		})
+		return
 	}
-}
 
-// syncLessonComplete syncs lesson completion
-func (h *SyncHandler) syncLessonComplete(c *gin.Context, item *models.SyncItem, userID int64) error {
-	lessonID, ok := item.Data["lesson_id"].(float64)
-	if !ok {
-		return nil
+	// Verify authenticated user
+	authUserID, err := middleware.GetUserID(c)
+	if err != nil || authUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Cannot view devices for other users",
+		})
+		return
 	}
 
-	quizScore, _ := item.Data["quiz_score"].(float64)
-	timeSpent, _ := item.Data["time_spent"].(float64)
-
-	req := &models.CompleteProgressRequest{
-		UserID:    userID,
-		LessonID:  int64(lessonID),
-		QuizScore: int(quizScore),
-		TimeSpent: int(timeSpent),
+	devices, err := h.repo.GetSyncDevices(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[SYNC] Error listing devices for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to list devices",
+		})
+		return
 	}
 
-	return h.repo.CompleteLesson(c.Request.Context(), req)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+		"count":   len(devices),
+		"devices": devices,
+	})
 }
 
-// syncProgressUpdate syncs progress update
-func (h *SyncHandler) syncProgressUpdate(c *gin.Context, item *models.SyncItem, userID int64) error {
-	lessonID, ok := item.Data["lesson_id"].(float64)
-	if !ok {
-		return nil
+// RevokeSyncDevice revokes one of the authenticated user's devices
+// DELETE /api/progress/sync/devices/:deviceId
+func (h *SyncHandler) RevokeSyncDevice(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": err.Error(),
+		})
+		return
 	}
 
-	progressPercent, _ := item.Data["progress_percent"].(float64)
-	timeSpent, _ := item.Data["time_spent"].(float64)
-	status, _ := item.Data["status"].(string)
-
-	req := &models.UpdateProgressRequest{
-		UserID:          userID,
-		LessonID:        int64(lessonID),
-		Status:          models.ProgressStatus(status),
-		ProgressPercent: int(progressPercent),
-		TimeSpent:       int(timeSpent),
+	deviceID := c.Param("deviceId")
+	revoked, err := h.repo.RevokeSyncDevice(c.Request.Context(), userID, deviceID)
+	if err != nil {
+		log.Printf("[SYNC] Error revoking device %s for user %d: %v", deviceID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to revoke device",
+		})
+		return
 	}
-
-	return h.repo.UpdateProgress(c.Request.Context(), req)
-}
-
-// syncVocabularyPractice syncs vocabulary practice
-func (h *SyncHandler) syncVocabularyPractice(c *gin.Context, item *models.SyncItem, userID int64) error {
-	vocabID, ok := item.Data["vocabulary_id"].(float64)
-	if !ok {
-		return nil
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Device not found or already revoked",
+		})
+		return
 	}
 
-	isCorrect, _ := item.Data["is_correct"].(bool)
-	responseTime, _ := item.Data["response_time"].(float64)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"device_id": deviceID,
+		"revoked":   true,
+	})
+}
 
-	req := &models.VocabularyPracticeRequest{
-		UserID:       userID,
-		VocabularyID: int64(vocabID),
-		IsCorrect:    isCorrect,
-		ResponseTime: int(responseTime),
-	}
+// ================================================================
+// HELPER FUNCTIONS
+// ================================================================
 
-	// Get current vocabulary progress from repository
-	currentProgress, err := h.repo.GetVocabularyProgressByID(c.Request.Context(), userID, int64(vocabID))
+// syncOutcome aggregates per-item results for one sync request
+type syncOutcome struct {
+	synced   int
+	rejected int // failed validation, client should drop
+	retry    int // failed to apply or rolled back, client should retry
+	results  []models.SyncItemResult
+	errors   []*models.SyncItemError
+}
 
-	// Initialize SRS values from existing progress or use defaults
-	var currentEasiness float64 = utils.InitialEasinessFactor
-	var currentInterval int = 0
-	var currentRepetitions int = 0
-	var currentMastery int = utils.MasteryLevelNew
+func (o *syncOutcome) failed() int {
+	return o.rejected + o.retry
+}
 
-	if err == nil && currentProgress != nil {
-		// Extract SRS data from existing progress
-		currentEasiness = currentProgress.EasinessFactor
-		currentInterval = currentProgress.IntervalDays
-		currentRepetitions = currentProgress.RepetitionCount
-		currentMastery = currentProgress.MasteryLevel
+// syncUnits splits a request into units applied all-or-nothing.
+// An atomic request is a single unit; otherwise items sharing a group form
+// one unit and ungrouped items are units of their own, in request order.
+func syncUnits(req *models.SyncProgressRequest) [][]int {
+	if req.Atomic {
+		all := make([]int, len(req.SyncItems))
+		for i := range all {
+			all[i] = i
+		}
+		return [][]int{all}
 	}
 
-	// Calculate quality from response time and correctness
-	quality := utils.CalculateQualityFromResponseTime(isCorrect, int(responseTime))
-
-	// Calculate next review using SM-2 algorithm
-	srsResult := utils.CalculateNextReview(
-		quality,
-		currentEasiness,
-		currentInterval,
-		currentRepetitions,
-		currentMastery,
-	)
-
-	srsData := map[string]interface{}{
-		"mastery_level":    float64(srsResult.MasteryLevel),
-		"easiness_factor":  srsResult.EasinessFactor,
-		"interval_days":    float64(srsResult.IntervalDays),
-		"repetition_count": float64(srsResult.RepetitionCount),
-		"next_review_at":   srsResult.NextReviewAt,
+	units := [][]int{}
+	groupUnit := map[string]int{}
+	for i, item := range req.SyncItems {
+		if item.Group == "" {
+			units = append(units, []int{i})
+			continue
+		}
+		if u, ok := groupUnit[item.Group]; ok {
+			units[u] = append(units[u], i)
+			continue
+		}
+		groupUnit[item.Group] = len(units)
+		units = append(units, []int{i})
 	}
+	return units
+}
 
-	return h.repo.RecordVocabularyPractice(c.Request.Context(), req, srsData)
+// processSyncRequest validates and applies every item in a sync request
+func (h *SyncHandler) processSyncRequest(c *gin.Context, req *models.SyncProgressRequest) *syncOutcome {
+	ctx := realtime.WithSourceDevice(c.Request.Context(), req.DeviceID)
+	return applySyncUnits(req, func(payloads []models.SyncPayload) (int, error) {
+		return h.repo.ApplySyncPayloadsAtomic(ctx, req.UserID, payloads)
+	})
 }
 
-// syncVocabularyBatch syncs batch vocabulary results from lesson quiz
-func (h *SyncHandler) syncVocabularyBatch(c *gin.Context, item *models.SyncItem, userID int64) error {
-	lessonID, ok := item.Data["lesson_id"].(float64)
-	if !ok {
-		return fmt.Errorf("missing lesson_id")
+// applySyncUnits decodes each unit of req and hands it to apply, which
+// commits the unit or returns the index of the payload that failed.
+// Invalid items are rejected with an error code and never applied; when an
+// item in a multi-item unit fails, the rest of the unit is rolled back.
+func applySyncUnits(req *models.SyncProgressRequest, apply func(payloads []models.SyncPayload) (int, error)) *syncOutcome {
+	outcome := &syncOutcome{
+		results: make([]models.SyncItemResult, len(req.SyncItems)),
+		errors:  []*models.SyncItemError{},
 	}
+	itemErrors := make([]*models.SyncItemError, len(req.SyncItems))
+
+	for _, unit := range syncUnits(req) {
+		// Decode the whole unit before touching the database
+		payloads := make([]models.SyncPayload, 0, len(unit))
+		culprit := -1
+		for _, i := range unit {
+			payload, itemErr := models.DecodeSyncItem(i, &req.SyncItems[i])
+			if itemErr != nil {
+				itemErrors[i] = itemErr
+				outcome.results[i].Status = models.SyncStatusRejected
+				outcome.rejected++
+				if culprit < 0 {
+					culprit = i
+				}
+				continue
+			}
+			payloads = append(payloads, payload)
+		}
 
-	resultsRaw, ok := item.Data["vocabulary_results"].([]interface{})
-	if !ok {
-		return fmt.Errorf("missing vocabulary_results")
-	}
+		if culprit < 0 {
+			failedAt, err := apply(payloads)
+			if err == nil {
+				for _, i := range unit {
+					outcome.results[i].Status = models.SyncStatusApplied
+					outcome.synced++
+				}
+				continue
+			}
 
-	// Convert results to VocabularyResult slice
-	results := make([]models.VocabularyResult, 0, len(resultsRaw))
-	for _, r := range resultsRaw {
-		result, ok := r.(map[string]interface{})
-		if !ok {
-			continue
+			// Commit failures are not attributable to one item
+			culprit = unit[0]
+			if failedAt >= 0 {
+				culprit = unit[failedAt]
+			}
+			itemErrors[culprit] = models.NewSyncApplyError(culprit, &req.SyncItems[culprit], err)
+			outcome.results[culprit].Status = models.SyncStatusFailed
+			outcome.retry++
 		}
 
-		vocabID, ok := result["vocabulary_id"].(float64)
-		if !ok {
-			continue
+		for _, i := range unit {
+			if itemErrors[i] == nil {
+				itemErrors[i] = models.NewSyncRolledBackError(i, &req.SyncItems[i], culprit)
+				outcome.results[i].Status = models.SyncStatusRolledBack
+				outcome.retry++
+			}
 		}
+	}
 
-		isCorrect, _ := result["is_correct"].(bool)
+	for i := range req.SyncItems {
+		item := &req.SyncItems[i]
+		outcome.results[i].Index = i
+		outcome.results[i].ItemID = item.ID
+		outcome.results[i].Type = item.Type
 
-		results = append(results, models.VocabularyResult{
-			VocabularyID: int64(vocabID),
-			IsCorrect:    isCorrect,
-		})
+		if itemErrors[i] != nil {
+			log.Printf("[SYNC] User %d device %s: %v", req.UserID, req.DeviceID, itemErrors[i])
+			outcome.errors = append(outcome.errors, itemErrors[i])
+		}
 	}
 
-	if len(results) == 0 {
-		return nil // No results to sync
+	return outcome
+}
+
+// authorizeSyncDevice checks that the request's session is live and that
+// deviceID has not been revoked. Returns the session ID.
+func (h *SyncHandler) authorizeSyncDevice(c *gin.Context, userID int64, deviceID string) (string, error) {
+	token, err := middleware.GetToken(c)
+	if err != nil {
+		return "", repository.ErrSyncSessionEnded
+	}
+	return h.repo.AuthorizeSyncDevice(c.Request.Context(), userID, token, deviceID)
+}
+
+// respondSyncAuthError rejects a sync that failed authorizeSyncDevice.
+// The registry must not fail open: if it cannot be checked, the client
+// retries later.
+func respondSyncAuthError(c *gin.Context, deviceID string, err error) {
+	switch err {
+	case repository.ErrSyncSessionEnded:
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Session has ended",
+		})
+	case repository.ErrDeviceRevoked:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Device has been revoked",
+		})
+	default:
+		log.Printf("[SYNC] Error checking device %s: %v", deviceID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Service Unavailable",
+			"message": "Sync is temporarily unavailable",
+		})
 	}
+}
 
-	req := &models.VocabularyBatchRequest{
-		UserID:            userID,
-		LessonID:          int64(lessonID),
-		VocabularyResults: results,
+// recordDeviceSync stores the outcome of a sync request in the device registry
+func (h *SyncHandler) recordDeviceSync(c *gin.Context, req *models.SyncProgressRequest, sessionID string, outcome *syncOutcome) {
+	report := &models.SyncDeviceReport{
+		SessionID:    sessionID,
+		DeviceID:     req.DeviceID,
+		Platform:     req.Platform,
+		AppVersion:   req.AppVersion,
+		ItemsApplied: outcome.synced,
+		ItemsFailed:  outcome.failed(),
+		PendingItems: req.PendingItems,
+		RetryItems:   outcome.retry,
+	}
+	if len(outcome.errors) > 0 {
+		report.LastError = outcome.errors[0].Error()
 	}
 
-	_, _, err := h.repo.RecordVocabularyBatch(c.Request.Context(), req)
-	return err
+	if err := h.repo.RecordDeviceSync(c.Request.Context(), req.UserID, report); err != nil {
+		log.Printf("[SYNC] Failed to record sync for device %s: %v", req.DeviceID, err)
+	}
 }
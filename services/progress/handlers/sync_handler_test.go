@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lessonItem(id string, lessonID int, group string) models.SyncItem {
+	return models.SyncItem{
+		ID:    id,
+		Type:  models.SyncTypeProgressUpdate,
+		Group: group,
+		Data:  json.RawMessage(fmt.Sprintf(`{"lesson_id": %d, "progress_percent": 50}`, lessonID)),
+	}
+}
+
+func TestApplySyncUnitsReportsCulprit(t *testing.T) {
+	req := &models.SyncProgressRequest{
+		Atomic: true,
+		SyncItems: []models.SyncItem{
+			lessonItem("a", 1, ""),
+			lessonItem("b", 2, ""),
+			lessonItem("c", 3, ""),
+			lessonItem("d", 4, ""),
+		},
+	}
+
+	var applied [][]models.SyncPayload
+	outcome := applySyncUnits(req, func(payloads []models.SyncPayload) (int, error) {
+		applied = append(applied, payloads)
+		return 2, errors.New("lesson 3 not found")
+	})
+
+	require.Len(t, applied, 1, "an atomic request is one unit")
+	assert.Len(t, applied[0], 4)
+
+	assert.Equal(t, 0, outcome.synced)
+	assert.Equal(t, 4, outcome.retry)
+	statuses := []string{}
+	for _, r := range outcome.results {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []string{
+		models.SyncStatusRolledBack, models.SyncStatusRolledBack,
+		models.SyncStatusFailed, models.SyncStatusRolledBack,
+	}, statuses)
+
+	require.Len(t, outcome.errors, 4)
+	assert.Equal(t, models.SyncErrApplyFailed, outcome.errors[2].Code)
+	assert.Equal(t, "c", outcome.errors[2].ItemID)
+	assert.Equal(t, models.SyncErrRolledBack, outcome.errors[0].Code)
+	assert.Equal(t, "rolled back because item 2 failed", outcome.errors[0].Message)
+}
+
+func TestApplySyncUnitsIsolatesGroups(t *testing.T) {
+	req := &models.SyncProgressRequest{
+		SyncItems: []models.SyncItem{
+			lessonItem("a", 1, "g1"),
+			lessonItem("b", 2, ""),
+			lessonItem("c", 3, "g1"),
+			{ID: "d", Type: models.SyncTypeProgressUpdate, Group: "g2", Data: json.RawMessage(`{}`)},
+			lessonItem("e", 5, "g2"),
+		},
+	}
+
+	units := 0
+	outcome := applySyncUnits(req, func(payloads []models.SyncPayload) (int, error) {
+		units++
+		// The first group fails on its second item
+		if units == 1 {
+			return 1, errors.New("conflict")
+		}
+		return -1, nil
+	})
+
+	assert.Equal(t, 2, units, "a unit with an invalid item is never applied")
+	assert.Equal(t, 1, outcome.synced)
+	assert.Equal(t, 1, outcome.rejected)
+	assert.Equal(t, models.SyncStatusRolledBack, outcome.results[0].Status)
+	assert.Equal(t, models.SyncStatusApplied, outcome.results[1].Status)
+	assert.Equal(t, models.SyncStatusFailed, outcome.results[2].Status)
+	assert.Equal(t, models.SyncStatusRejected, outcome.results[3].Status)
+	assert.Equal(t, models.SyncStatusRolledBack, outcome.results[4].Status)
+	assert.Equal(t, "rolled back because item 3 failed", outcome.errors[3].Message)
+}
+
+// newSyncRouter serves the sync endpoints for user 1 authenticated with
+// token "tok", backed by d
+func newSyncRouter(t *testing.T, d *dbtest.Driver) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewSyncHandler(repository.NewProgressRepository(dbtest.Open(t, d), nil))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userId", int64(1))
+		c.Set("token", "tok")
+	})
+	router.POST("/sync", h.SyncProgress)
+	router.POST("/sync/batch", h.BatchSync)
+	return router
+}
+
+// sessionQuery answers the device check with the given session row
+func sessionQuery(row []driver.Value) func(string, []driver.Value) (*dbtest.Rows, error) {
+	return func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		if !strings.Contains(query, "FROM sessions") {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		rows := &dbtest.Rows{Columns: []string{"id", "revoked"}}
+		if row != nil {
+			rows.Values = [][]driver.Value{row}
+		}
+		return rows, nil
+	}
+}
+
+func postSync(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+const syncBody = `{"user_id": 1, "device_id": "phone", "sync_items": [
+	{"type": "progress_update", "data": {"lesson_id": 1, "progress_percent": 50}}
+]}`
+
+func TestSyncRejectsRevokedDevice(t *testing.T) {
+	d := &dbtest.Driver{Query: sessionQuery([]driver.Value{"s1", true})}
+	w := postSync(newSyncRouter(t, d), "/sync", syncBody)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Device has been revoked")
+	assert.Empty(t, d.Applied())
+}
+
+func TestSyncRejectsEndedSession(t *testing.T) {
+	// A revoked device's session is gone, whatever device_id it reports
+	d := &dbtest.Driver{Query: sessionQuery(nil)}
+	w := postSync(newSyncRouter(t, d), "/sync", strings.Replace(syncBody, "phone", "new-id", 1))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, d.Applied())
+}
+
+func TestSyncFailsClosedWhenRegistryUnavailable(t *testing.T) {
+	d := &dbtest.Driver{Query: func(string, []driver.Value) (*dbtest.Rows, error) {
+		return nil, errors.New("too many connections")
+	}}
+	router := newSyncRouter(t, d)
+
+	w := postSync(router, "/sync", syncBody)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = postSync(router, "/sync/batch", "["+syncBody+"]")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, d.Applied())
+}
@@ -0,0 +1,137 @@
+package leaderboard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+const (
+	archiveInterval  = time.Hour
+	archiveBatchSize = 1000
+)
+
+// Archiver moves finished weekly boards from Redis to leaderboard_archives.
+// Every replica runs one; archiving is idempotent, so overlaps are harmless.
+type Archiver struct {
+	db    *sql.DB
+	redis *redis.Client
+	done  chan struct{}
+}
+
+// NewArchiver creates an archiver
+func NewArchiver(db *sql.DB, redisClient *redis.Client) *Archiver {
+	return &Archiver{db: db, redis: redisClient, done: make(chan struct{})}
+}
+
+// Run archives finished weeks on start and then hourly until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := a.ArchiveFinishedWeeks(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[LEADERBOARD] Archiving failed: %v", err)
+		}
+		if archived > 0 {
+			log.Printf("[LEADERBOARD] Archived %d weekly boards", archived)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Done is closed when Run returns
+func (a *Archiver) Done() <-chan struct{} {
+	return a.done
+}
+
+// ArchiveFinishedWeeks archives every weekly board older than the current
+// week. Returns the number of boards archived.
+func (a *Archiver) ArchiveFinishedWeeks(ctx context.Context, now time.Time) (int, error) {
+	currentWeek := models.WeekStart(now)
+
+	archived := 0
+	iter := a.redis.Scan(ctx, 0, keyPrefix+models.LeaderboardWeekly+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		metric, weekStart, ok := ParseWeeklyKey(key)
+		if !ok || !weekStart.Before(currentWeek) {
+			continue
+		}
+		if err := a.archiveWeek(ctx, key, metric, weekStart); err != nil {
+			return archived, fmt.Errorf("%s: %w", key, err)
+		}
+		archived++
+	}
+	if err := iter.Err(); err != nil {
+		return archived, fmt.Errorf("failed to scan leaderboards: %w", err)
+	}
+
+	return archived, nil
+}
+
+// archiveWeek copies a weekly board to Postgres in rank order, then deletes it
+func (a *Archiver) archiveWeek(ctx context.Context, key, metric string, weekStart time.Time) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := int64(0); ; start += archiveBatchSize {
+		members, err := rangeMembers(ctx, a.redis, key, start, start+archiveBatchSize-1)
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			break
+		}
+
+		userIDs := make([]int64, len(members))
+		scores := make([]int64, len(members))
+		ranks := make([]int64, len(members))
+		for i, m := range members {
+			userIDs[i], scores[i], ranks[i] = m.UserID, m.Score, m.Rank
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO leaderboard_archives (week_start, metric, user_id, score, rank)
+			SELECT $1, $2, u.user_id, u.score, u.rank
+			FROM unnest($3::bigint[], $4::bigint[], $5::bigint[]) AS u(user_id, score, rank)
+			WHERE EXISTS (SELECT 1 FROM users WHERE id = u.user_id)
+			ON CONFLICT (week_start, metric, user_id) DO NOTHING
+		`, weekStart, metric, pq.Array(userIDs), pq.Array(scores), pq.Array(ranks))
+		if err != nil {
+			return fmt.Errorf("failed to archive leaderboard: %w", err)
+		}
+
+		if len(members) < archiveBatchSize {
+			break
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if err := a.redis.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to delete archived leaderboard: %w", err)
+	}
+
+	return nil
+}
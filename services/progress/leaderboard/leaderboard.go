@@ -0,0 +1,210 @@
+package leaderboard
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ================================================================
+// LEADERBOARDS
+// ================================================================
+// Weekly and all-time scores per metric are kept in Redis sorted sets
+// (member = user ID) and incremented after each committed write. Weekly
+// sets are keyed by the Monday (UTC) their week starts on, so every week
+// starts empty; the Archiver copies finished weeks to Postgres and then
+// deletes them. Opted-out users are never added.
+// ================================================================
+
+const (
+	keyPrefix = "leaderboard:"
+
+	// weeklyKeyTTL keeps unarchived weeks from piling up if archiving stops
+	weeklyKeyTTL = 28 * 24 * time.Hour
+
+	// dailyKeyTTL outlives the day a daily counter is for
+	dailyKeyTTL = 48 * time.Hour
+)
+
+// Member is a user's position on a board. Rank starts at 1.
+type Member struct {
+	UserID int64
+	Score  int64
+	Rank   int64
+}
+
+// WeeklyKey returns the sorted set for a metric's week
+func WeeklyKey(metric string, weekStart time.Time) string {
+	return keyPrefix + models.LeaderboardWeekly + ":" + metric + ":" + weekStart.UTC().Format("2006-01-02")
+}
+
+// AllTimeKey returns the sorted set for a metric's all-time board
+func AllTimeKey(metric string) string {
+	return keyPrefix + models.LeaderboardAllTime + ":" + metric
+}
+
+// Key returns the board for a scope at time now
+func Key(scope, metric string, now time.Time) string {
+	if scope == models.LeaderboardAllTime {
+		return AllTimeKey(metric)
+	}
+	return WeeklyKey(metric, models.WeekStart(now))
+}
+
+// ParseWeeklyKey returns the metric and week start of a weekly key
+func ParseWeeklyKey(key string) (string, time.Time, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, keyPrefix), ":")
+	if len(parts) != 3 || parts[0] != models.LeaderboardWeekly || !models.IsLeaderboardMetric(parts[1]) {
+		return "", time.Time{}, false
+	}
+	weekStart, err := time.Parse("2006-01-02", parts[2])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[1], weekStart, true
+}
+
+// Add increments a user's weekly and all-time scores
+func Add(ctx context.Context, rdb *redis.Client, userID int64, scores map[string]int, now time.Time) error {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	member := strconv.FormatInt(userID, 10)
+	weekStart := models.WeekStart(now)
+
+	pipe := rdb.TxPipeline()
+	for metric, score := range scores {
+		weekly := WeeklyKey(metric, weekStart)
+		pipe.ZIncrBy(ctx, weekly, float64(score), member)
+		pipe.Expire(ctx, weekly, weeklyKeyTTL)
+		pipe.ZIncrBy(ctx, AllTimeKey(metric), float64(score), member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update leaderboards: %w", err)
+	}
+
+	return nil
+}
+
+// DailyKey returns the counter of a user's points for a metric on now's
+// UTC day
+func DailyKey(metric string, userID int64, now time.Time) string {
+	return keyPrefix + "daily:" + metric + ":" + now.UTC().Format("2006-01-02") + ":" + strconv.FormatInt(userID, 10)
+}
+
+// AddDaily increments a user's daily counter for a metric and returns its
+// new total
+func AddDaily(ctx context.Context, rdb *redis.Client, metric string, userID int64, amount int, now time.Time) (int, error) {
+	key := DailyKey(metric, userID, now)
+
+	pipe := rdb.TxPipeline()
+	total := pipe.IncrBy(ctx, key, int64(amount))
+	pipe.Expire(ctx, key, dailyKeyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to update daily counter: %w", err)
+	}
+
+	return int(total.Val()), nil
+}
+
+// Remove takes a user off every all-time board and this week's boards
+func Remove(ctx context.Context, rdb *redis.Client, userID int64, now time.Time) error {
+	member := strconv.FormatInt(userID, 10)
+	weekStart := models.WeekStart(now)
+
+	pipe := rdb.TxPipeline()
+	for _, metric := range models.LeaderboardMetrics {
+		pipe.ZRem(ctx, WeeklyKey(metric, weekStart), member)
+		pipe.ZRem(ctx, AllTimeKey(metric), member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove from leaderboards: %w", err)
+	}
+
+	return nil
+}
+
+// Top returns the n highest scores of a board
+func Top(ctx context.Context, rdb *redis.Client, key string, n int) ([]Member, error) {
+	return rangeMembers(ctx, rdb, key, 0, int64(n)-1)
+}
+
+// Around returns the user's position and the members up to radius places
+// above and below it. The position is nil if the user is not on the board.
+func Around(ctx context.Context, rdb *redis.Client, key string, userID int64, radius int) (*Member, []Member, error) {
+	rank, err := rdb.ZRevRank(ctx, key, strconv.FormatInt(userID, 10)).Result()
+	if err == redis.Nil {
+		return nil, []Member{}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get leaderboard rank: %w", err)
+	}
+
+	start := rank - int64(radius)
+	if start < 0 {
+		start = 0
+	}
+	members, err := rangeMembers(ctx, rdb, key, start, rank+int64(radius))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range members {
+		if members[i].UserID == userID {
+			return &members[i], members, nil
+		}
+	}
+	// The user moved between the two reads; report the stale rank
+	return &Member{UserID: userID, Rank: rank + 1}, members, nil
+}
+
+// Scores returns the users' scores on a board, read atomically. exists is
+// false when the board is gone (archived, expired or never written).
+func Scores(ctx context.Context, rdb *redis.Client, key string, userIDs []int64) (map[int64]int64, bool, error) {
+	pipe := rdb.TxPipeline()
+	existsCmd := pipe.Exists(ctx, key)
+	scoreCmds := make([]*redis.FloatCmd, len(userIDs))
+	for i, userID := range userIDs {
+		scoreCmds[i] = pipe.ZScore(ctx, key, strconv.FormatInt(userID, 10))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, fmt.Errorf("failed to read leaderboard scores: %w", err)
+	}
+
+	scores := make(map[int64]int64, len(userIDs))
+	for i, userID := range userIDs {
+		score, err := scoreCmds[i].Result()
+		if err != nil && err != redis.Nil {
+			return nil, false, fmt.Errorf("failed to read leaderboard scores: %w", err)
+		}
+		scores[userID] = int64(score)
+	}
+
+	return scores, existsCmd.Val() > 0, nil
+}
+
+// rangeMembers reads ranks start..stop (0-based, inclusive) of a board
+func rangeMembers(ctx context.Context, rdb *redis.Client, key string, start, stop int64) ([]Member, error) {
+	results, err := rdb.ZRevRangeWithScores(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard: %w", err)
+	}
+
+	members := make([]Member, 0, len(results))
+	for i, z := range results {
+		userID, err := strconv.ParseInt(fmt.Sprint(z.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+		members = append(members, Member{UserID: userID, Score: int64(z.Score), Rank: start + int64(i) + 1})
+	}
+
+	return members, nil
+}
@@ -0,0 +1,47 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeeklyKeyRoundTrip(t *testing.T) {
+	// Wednesday; the week starts on Monday 2026-03-02
+	now := time.Date(2026, 3, 4, 18, 0, 0, 0, time.UTC)
+
+	key := Key(models.LeaderboardWeekly, models.LeaderboardReviews, now)
+	assert.Equal(t, "leaderboard:weekly:reviews:2026-03-02", key)
+
+	metric, weekStart, ok := ParseWeeklyKey(key)
+	assert.True(t, ok)
+	assert.Equal(t, models.LeaderboardReviews, metric)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), weekStart)
+
+	assert.Equal(t, "leaderboard:all_time:lemons", Key(models.LeaderboardAllTime, models.LeaderboardLemons, now))
+}
+
+func TestParseWeeklyKeyRejectsOtherKeys(t *testing.T) {
+	for _, key := range []string{
+		"leaderboard:all_time:lemons",
+		"leaderboard:weekly:unknown:2026-03-02",
+		"leaderboard:weekly:lemons:not-a-date",
+		"leaderboard:weekly:lemons",
+	} {
+		_, _, ok := ParseWeeklyKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestDailyKeyUsesUTCDay(t *testing.T) {
+	seoul := time.FixedZone("KST", 9*60*60)
+	// 08:00 in Seoul is still the previous day in UTC
+	now := time.Date(2026, 3, 5, 8, 0, 0, 0, seoul)
+
+	assert.Equal(t, "leaderboard:daily:study_minutes:2026-03-04:42", DailyKey(models.LeaderboardStudyMinutes, 42, now))
+	_, _, ok := ParseWeeklyKey(DailyKey(models.LeaderboardStudyMinutes, 42, now))
+	assert.False(t, ok, "daily counters are not archived as weekly boards")
+}
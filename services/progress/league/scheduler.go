@@ -0,0 +1,56 @@
+package league
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const finalizeInterval = 5 * time.Minute
+
+// Finalizer settles the league cohorts of finished weeks
+type Finalizer interface {
+	FinalizeLeagues(ctx context.Context, now time.Time) (int, error)
+}
+
+// Scheduler finalizes league weeks shortly after they end. Every replica
+// runs one; cohorts are claimed with SKIP LOCKED, so replicas share the work.
+type Scheduler struct {
+	finalizer Finalizer
+	done      chan struct{}
+}
+
+// NewScheduler creates a league scheduler
+func NewScheduler(f Finalizer) *Scheduler {
+	return &Scheduler{finalizer: f, done: make(chan struct{})}
+}
+
+// Run finalizes finished weeks on start and then every few minutes until
+// ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(finalizeInterval)
+	defer ticker.Stop()
+
+	for {
+		finalized, err := s.finalizer.FinalizeLeagues(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[LEAGUE] Finalizing failed: %v", err)
+		}
+		if finalized > 0 {
+			log.Printf("[LEAGUE] Finalized %d cohorts", finalized)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Done is closed when Run returns
+func (s *Scheduler) Done() <-chan struct{} {
+	return s.done
+}
@@ -10,9 +10,15 @@ import (
 	"syscall"
 	"time"
 
+	"lemonkorean/progress/ads"
 	"lemonkorean/progress/config"
 	"lemonkorean/progress/handlers"
+	"lemonkorean/progress/leaderboard"
+	"lemonkorean/progress/league"
+	"lemonkorean/progress/media"
 	"lemonkorean/progress/middleware"
+	"lemonkorean/progress/outbox"
+	"lemonkorean/progress/realtime"
 	"lemonkorean/progress/repository"
 
 	"github.com/gin-gonic/gin"
@@ -57,6 +63,38 @@ func main() {
 	// Initialize repository
 	progressRepo := repository.NewProgressRepository(db, redisClient)
 
+	// Run a maintenance command instead of the server if one was given
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, progressRepo, os.Args[1:]); err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+		return
+	}
+
+	// Start real-time event hub (Redis pub/sub fan-out across replicas)
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	eventHub := realtime.NewHub(redisClient)
+	go eventHub.Run(hubCtx)
+
+	// Start outbox relay (domain events -> Redis Streams for other services)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := outbox.NewRelay(db, redisClient)
+	go outboxRelay.Run(relayCtx)
+
+	// Start leaderboard archiver (finished weeks -> Postgres)
+	archiverCtx, stopArchiver := context.WithCancel(context.Background())
+	defer stopArchiver()
+	leaderboardArchiver := leaderboard.NewArchiver(db, redisClient)
+	go leaderboardArchiver.Run(archiverCtx)
+
+	// Start league scheduler (promotions and demotions after each week)
+	leagueCtx, stopLeagues := context.WithCancel(context.Background())
+	defer stopLeagues()
+	leagueScheduler := league.NewScheduler(progressRepo)
+	go leagueScheduler.Run(leagueCtx)
+
 	// Initialize handlers
 	progressHandler := handlers.NewProgressHandler(progressRepo)
 	syncHandler := handlers.NewSyncHandler(progressRepo)
@@ -64,9 +102,19 @@ func main() {
 	hangulLessonHandler := handlers.NewHangulLessonHandler(progressRepo)
 	gamificationHandler := handlers.NewGamificationHandler(progressRepo)
 	characterHandler := handlers.NewCharacterHandler(progressRepo)
+	eventsHandler := handlers.NewEventsHandler(eventHub)
+	adsHandler := handlers.NewAdsHandler(progressRepo, ads.NewVerifier())
+	questHandler := handlers.NewQuestHandler(progressRepo)
+	leaderboardHandler := handlers.NewLeaderboardHandler(progressRepo)
+	leagueHandler := handlers.NewLeagueHandler(progressRepo)
+	friendHandler := handlers.NewFriendHandler(progressRepo)
+	giftHandler := handlers.NewGiftHandler(progressRepo)
+	roomHandler := handlers.NewRoomHandler(progressRepo)
+	shopAdminHandler := handlers.NewShopAdminHandler(progressRepo, media.NewAssetChecker())
 
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware()
+	bodyMiddleware := middleware.NewBodyMiddleware()
 
 	// Create Gin router
 	router := gin.Default()
@@ -84,6 +132,9 @@ func main() {
 		})
 	})
 
+	// AdMob server-side verification callback (public, signature-checked)
+	router.GET("/api/progress/ads/reward-callback", adsHandler.RewardCallback)
+
 	// API routes (protected)
 	api := router.Group("/api/progress")
 	api.Use(authMiddleware.RequireAuth())
@@ -110,9 +161,11 @@ func main() {
 		api.GET("/session/stats/:userId", progressHandler.GetSessionStats)
 
 		// Sync endpoints
-		api.POST("/sync", syncHandler.SyncProgress)
-		api.POST("/sync/batch", syncHandler.BatchSync)
+		api.POST("/sync", bodyMiddleware.LimitAndDecompress(), syncHandler.SyncProgress)
+		api.POST("/sync/batch", bodyMiddleware.LimitAndDecompress(), syncHandler.BatchSync)
 		api.GET("/sync/status/:userId", syncHandler.GetSyncStatus)
+		api.GET("/sync/devices/:userId", syncHandler.GetSyncDevices)
+		api.DELETE("/sync/devices/:deviceId", syncHandler.RevokeSyncDevice)
 
 		// Statistics
 		api.GET("/stats/:userId", progressHandler.GetUserStats)
@@ -133,17 +186,97 @@ func main() {
 		api.GET("/lemon-currency/:userId", gamificationHandler.GetLemonCurrency)
 		api.GET("/lesson-rewards/:userId", gamificationHandler.GetLessonRewards)
 		api.POST("/lemon-harvest", gamificationHandler.HarvestLemon)
+		api.GET("/lemon-tree/:userId", gamificationHandler.GetLemonTree)
+		api.GET("/ads/grants", adsHandler.GetAdRewardStatus)
 		api.POST("/boss-quiz/complete", gamificationHandler.CompleteBossQuiz)
+		api.GET("/reward-rules", gamificationHandler.GetRewardRules)
+		api.GET("/lemon-transactions/:userId", gamificationHandler.GetLemonTransactions)
+
+		// Quests
+		api.GET("/quests/:userId", questHandler.GetQuests)
+		api.POST("/quests/claim", questHandler.ClaimQuest)
+
+		// Leaderboards
+		api.GET("/leaderboards/:metric", leaderboardHandler.GetLeaderboard)
+		api.GET("/leaderboards/:metric/me", leaderboardHandler.GetMyPosition)
+		api.GET("/leaderboards/:metric/friends", leaderboardHandler.GetFriendsLeaderboard)
+		api.PUT("/leaderboards/privacy", leaderboardHandler.UpdatePrivacy)
+
+		// Leagues
+		api.GET("/league", leagueHandler.GetLeague)
+		api.GET("/league/history", leagueHandler.GetHistory)
+
+		// Friends, follows and activity feed
+		api.GET("/friends", friendHandler.ListFriends)
+		api.DELETE("/friends/:userId", friendHandler.RemoveFriend)
+		api.GET("/friends/requests", friendHandler.ListRequests)
+		api.POST("/friends/requests", friendHandler.SendRequest)
+		api.POST("/friends/requests/:requestId/accept", friendHandler.AcceptRequest)
+		api.POST("/friends/requests/:requestId/decline", friendHandler.DeclineRequest)
+		api.DELETE("/friends/requests/:requestId", friendHandler.CancelRequest)
+		api.GET("/friends/feed", friendHandler.GetFeed)
+		api.GET("/friends/privacy", friendHandler.GetPrivacy)
+		api.PUT("/friends/privacy", friendHandler.UpdatePrivacy)
+		api.GET("/follows", friendHandler.ListFollowing)
+		api.POST("/follows", friendHandler.Follow)
+		api.DELETE("/follows/:userId", friendHandler.Unfollow)
+		api.GET("/blocks", friendHandler.ListBlocked)
+		api.POST("/blocks", friendHandler.Block)
+		api.DELETE("/blocks/:userId", friendHandler.Unblock)
+
+		// Gifts between friends
+		api.POST("/gifts", giftHandler.SendGift)
+		api.GET("/gifts/received", giftHandler.ListReceived)
+		api.GET("/gifts/sent", giftHandler.ListSent)
+		api.POST("/gifts/seen", giftHandler.MarkSeen)
 
 		// Character customization
 		api.GET("/character/:userId", characterHandler.GetCharacter)
+		api.GET("/character/:userId/avatar", characterHandler.GetAvatar)
 		api.PUT("/character/equip", characterHandler.EquipItem)
+		api.GET("/character/outfits", characterHandler.ListOutfits)
+		api.POST("/character/outfits", characterHandler.CreateOutfit)
+		api.PUT("/character/outfits/:outfitId", characterHandler.UpdateOutfit)
+		api.DELETE("/character/outfits/:outfitId", characterHandler.DeleteOutfit)
+		api.POST("/character/outfits/:outfitId/apply", characterHandler.ApplyOutfit)
 		api.PUT("/character/skin-color", characterHandler.UpdateSkinColor)
 		api.GET("/inventory/:userId", characterHandler.GetInventory)
 		api.POST("/shop/purchase", characterHandler.PurchaseItem)
 		api.GET("/shop/items", characterHandler.GetShopItems)
-		api.GET("/room/:userId", characterHandler.GetRoom)
-		api.PUT("/room/furniture", characterHandler.UpdateRoomFurniture)
+
+		// Rooms (visitors see the active room, if its visibility allows)
+		api.GET("/room/:userId", roomHandler.GetRoom)
+		api.PUT("/room/furniture", roomHandler.UpdateRoomFurniture)
+		api.GET("/rooms", roomHandler.ListRooms)
+		api.POST("/rooms", roomHandler.CreateRoom)
+		api.GET("/rooms/popular", roomHandler.ListPopular)
+		api.GET("/rooms/:roomId", roomHandler.GetOwnRoom)
+		api.PUT("/rooms/:roomId", roomHandler.RenameRoom)
+		api.DELETE("/rooms/:roomId", roomHandler.DeleteRoom)
+		api.POST("/rooms/:roomId/activate", roomHandler.ActivateRoom)
+		api.PUT("/rooms/:roomId/furniture", roomHandler.UpdateFurniture)
+		api.PUT("/rooms/:roomId/visibility", roomHandler.SetVisibility)
+		api.POST("/rooms/:roomId/like", roomHandler.LikeRoom)
+		api.DELETE("/rooms/:roomId/like", roomHandler.UnlikeRoom)
+
+		// Shop catalogue administration (admins only)
+		shopAdmin := api.Group("/admin/shop")
+		shopAdmin.Use(handlers.RequireAdmin(progressRepo))
+		{
+			shopAdmin.GET("/items", shopAdminHandler.ListItems)
+			shopAdmin.POST("/items", shopAdminHandler.CreateItem)
+			shopAdmin.GET("/items/:itemId", shopAdminHandler.GetItem)
+			shopAdmin.PATCH("/items/:itemId", shopAdminHandler.UpdateItem)
+			shopAdmin.POST("/items/:itemId/deactivate", shopAdminHandler.DeactivateItem)
+			shopAdmin.PUT("/order", shopAdminHandler.ReorderItems)
+			shopAdmin.GET("/sales", shopAdminHandler.ListSales)
+			shopAdmin.POST("/sales", shopAdminHandler.CreateSale)
+			shopAdmin.DELETE("/sales/:saleId", shopAdminHandler.EndSale)
+			shopAdmin.GET("/audit", shopAdminHandler.ListAudit)
+		}
+
+		// Real-time events (Server-Sent Events)
+		api.GET("/events/stream", eventsHandler.StreamEvents)
 	}
 
 	// Start server
@@ -174,6 +307,13 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopHub()
+	stopRelay()
+	<-outboxRelay.Done()
+	stopArchiver()
+	<-leaderboardArchiver.Done()
+	stopLeagues()
+	<-leagueScheduler.Done()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,92 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ================================================================
+// MEDIA ASSET CHECKS
+// ================================================================
+// Uploaded catalogue assets are stored by the media service under
+// images/<file> and served at /media/images/<file>. Before an admin saves
+// a shop item that refers to one, we ask the media service whether it
+// exists. MEDIA_SERVICE_URL points at the media service.
+// ================================================================
+
+const (
+	DefaultServiceURL = "http://media-service:3004"
+
+	imagePrefix = "images/"
+)
+
+// ErrAssetNotFound is returned for asset keys the media service does not have
+var ErrAssetNotFound = errors.New("asset not found in media service")
+
+// AssetChecker looks up uploaded assets in the media service
+type AssetChecker struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAssetChecker creates a checker for MEDIA_SERVICE_URL
+func NewAssetChecker() *AssetChecker {
+	baseURL := os.Getenv("MEDIA_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = DefaultServiceURL
+	}
+	return NewAssetCheckerWithURL(baseURL)
+}
+
+// NewAssetCheckerWithURL creates a checker for a media service at baseURL
+func NewAssetCheckerWithURL(baseURL string) *AssetChecker {
+	return &AssetChecker{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// AssetURL returns the media service URL serving an images/ key
+func (a *AssetChecker) AssetURL(key string) (string, error) {
+	name := strings.TrimPrefix(key, imagePrefix)
+	if name == key || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("%w: %s", ErrAssetNotFound, key)
+	}
+	return a.baseURL + "/media/images/" + url.PathEscape(name), nil
+}
+
+// Check returns ErrAssetNotFound if the media service does not serve key.
+// Other errors mean the media service could not be asked.
+func (a *AssetChecker) Check(ctx context.Context, key string) error {
+	assetURL, err := a.AssetURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, assetURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create media request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach media service: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrAssetNotFound, key)
+	default:
+		return fmt.Errorf("media service returned %d for %s", resp.StatusCode, key)
+	}
+}
@@ -0,0 +1,49 @@
+package media
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetURL(t *testing.T) {
+	checker := NewAssetCheckerWithURL("http://media:3004/")
+
+	u, err := checker.AssetURL("images/sprite_hat_crown.png")
+	require.NoError(t, err)
+	assert.Equal(t, "http://media:3004/media/images/sprite_hat_crown.png", u)
+
+	for _, key := range []string{"assets/hat.png", "images/", "images/a/b.png", "sprite.png"} {
+		_, err := checker.AssetURL(key)
+		assert.ErrorIs(t, err, ErrAssetNotFound, key)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/media/images/ok.png":
+			w.Write([]byte("png"))
+		case "/media/images/broken.png":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewAssetCheckerWithURL(server.URL)
+	ctx := context.Background()
+
+	assert.NoError(t, checker.Check(ctx, "images/ok.png"))
+	assert.ErrorIs(t, checker.Check(ctx, "images/missing.png"), ErrAssetNotFound)
+
+	// A failing media service is not reported as a missing asset
+	err := checker.Check(ctx, "images/broken.png")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrAssetNotFound)
+}
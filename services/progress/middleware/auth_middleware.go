@@ -95,6 +95,7 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		c.Set("userId", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("subscriptionType", claims.SubscriptionType)
+		c.Set("token", tokenString)
 
 		c.Next()
 	}
@@ -156,3 +157,18 @@ func GetEmail(c *gin.Context) (string, error) {
 
 	return emailStr, nil
 }
+
+// GetToken returns the bearer token the request was authenticated with
+func GetToken(c *gin.Context) (string, error) {
+	token, exists := c.Get("token")
+	if !exists {
+		return "", fmt.Errorf("token not found in context")
+	}
+
+	tokenStr, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid token type")
+	}
+
+	return tokenStr, nil
+}
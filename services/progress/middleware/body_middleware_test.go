@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func serveBody(bm *BodyMiddleware, body []byte, encoding string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/sync", bm.LimitAndDecompress(), func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if IsBodyTooLarge(err) {
+				c.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", data)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/sync", bytes.NewReader(body))
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLimitAndDecompressGzip(t *testing.T) {
+	bm := &BodyMiddleware{maxBodyBytes: 1024, maxDecompressedBytes: 1024}
+	payload := []byte(`{"user_id":1,"sync_items":[]}`)
+
+	w := serveBody(bm, gzipBytes(t, payload), "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, payload, w.Body.Bytes())
+}
+
+func TestLimitAndDecompressRejectsExpansion(t *testing.T) {
+	bm := &BodyMiddleware{maxBodyBytes: 1024, maxDecompressedBytes: 1024}
+	bomb := gzipBytes(t, bytes.Repeat([]byte("a"), 64*1024))
+	assert.Less(t, len(bomb), 1024)
+
+	w := serveBody(bm, bomb, "gzip")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestLimitAndDecompressRejectsLargeBody(t *testing.T) {
+	bm := &BodyMiddleware{maxBodyBytes: 16, maxDecompressedBytes: 1024}
+
+	w := serveBody(bm, bytes.Repeat([]byte("a"), 32), "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestLimitAndDecompressUnsupportedEncoding(t *testing.T) {
+	bm := &BodyMiddleware{maxBodyBytes: 1024, maxDecompressedBytes: 1024}
+
+	w := serveBody(bm, []byte("data"), "br")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Ad reward grant statuses
+const (
+	AdGrantAvailable = "available"
+	AdGrantConsumed  = "consumed"
+	AdGrantCapped    = "capped"
+)
+
+// NewAdGrantStatus returns the status of a new grant for a user who already
+// received usedToday uncapped grants today. Grants past the daily cap are
+// still recorded, so the callback stays idempotent, but cannot be used.
+func NewAdGrantStatus(usedToday, dailyCap int) string {
+	if usedToday >= dailyCap {
+		return AdGrantCapped
+	}
+	return AdGrantAvailable
+}
+
+// AdRewardGrant is a verified rewarded-ad completion
+type AdRewardGrant struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	TransactionID string     `json:"transaction_id"`
+	Status        string     `json:"status"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	ConsumedAt    *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// AdRewardStatus summarizes a user's grants for the app
+type AdRewardStatus struct {
+	Available     int        `json:"available"`
+	NextExpiresAt *time.Time `json:"next_expires_at,omitempty"`
+	UsedToday     int        `json:"used_today"`
+	DailyCap      int        `json:"daily_cap"`
+	Required      bool       `json:"required"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAdGrantStatus(t *testing.T) {
+	assert.Equal(t, AdGrantAvailable, NewAdGrantStatus(0, 3))
+	assert.Equal(t, AdGrantAvailable, NewAdGrantStatus(2, 3))
+	assert.Equal(t, AdGrantCapped, NewAdGrantStatus(3, 3))
+	assert.Equal(t, AdGrantCapped, NewAdGrantStatus(0, 0), "a zero cap grants nothing")
+}
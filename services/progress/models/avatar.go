@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// AvatarSprite locates the first (front-facing) frame of a spritesheet item
+type AvatarSprite struct {
+	Key         string `json:"key"`
+	FrameWidth  int    `json:"frame_width"`
+	FrameHeight int    `json:"frame_height"`
+}
+
+// AvatarLayer is one equipped item to draw. Tint layers are recoloured with
+// the skin colour.
+type AvatarLayer struct {
+	ItemID      int64         `json:"item_id"`
+	Category    string        `json:"category"`
+	AssetKey    string        `json:"asset_key"`
+	AssetType   string        `json:"asset_type"`
+	RenderOrder int           `json:"render_order"`
+	Sprite      *AvatarSprite `json:"sprite,omitempty"`
+	Tint        bool          `json:"tint"`
+}
+
+// Avatar is what the media service needs to render a character. Hash
+// changes whenever anything that affects the image changes.
+type Avatar struct {
+	UserID    int64         `json:"user_id"`
+	SkinColor string        `json:"skin_color"`
+	Layers    []AvatarLayer `json:"layers"`
+	Hash      string        `json:"hash"`
+}
+
+// NewAvatar orders layers back to front by render_order, marks the body for
+// skin tinting and computes the outfit hash
+func NewAvatar(userID int64, skinColor string, layers []AvatarLayer) Avatar {
+	sorted := make([]AvatarLayer, len(layers))
+	copy(sorted, layers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].RenderOrder != sorted[j].RenderOrder {
+			return sorted[i].RenderOrder < sorted[j].RenderOrder
+		}
+		return sorted[i].ItemID < sorted[j].ItemID
+	})
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", skinColor)
+	for i := range sorted {
+		l := &sorted[i]
+		l.Tint = l.Category == "body"
+		fmt.Fprintf(h, "%d|%s|%s|%d|%t", l.ItemID, l.AssetKey, l.AssetType, l.RenderOrder, l.Tint)
+		if l.Sprite != nil {
+			fmt.Fprintf(h, "|%s|%d|%d", l.Sprite.Key, l.Sprite.FrameWidth, l.Sprite.FrameHeight)
+		}
+		h.Write([]byte("\n"))
+	}
+
+	return Avatar{
+		UserID:    userID,
+		SkinColor: skinColor,
+		Layers:    sorted,
+		Hash:      hex.EncodeToString(h.Sum(nil))[:32],
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAvatar(t *testing.T) {
+	layers := []AvatarLayer{
+		{ItemID: 5, Category: "hair", AssetKey: "hair.png", AssetType: "png", RenderOrder: 40},
+		{ItemID: 1, Category: "body", AssetKey: "body.png", AssetType: "png", RenderOrder: 0},
+		{ItemID: 3, Category: "eyes", AssetKey: "eyes.png", AssetType: "png", RenderOrder: 20},
+	}
+
+	a := NewAvatar(7, "#FFDBB4", layers)
+	assert.Equal(t, int64(7), a.UserID)
+	assert.Equal(t, []int64{1, 3, 5}, []int64{a.Layers[0].ItemID, a.Layers[1].ItemID, a.Layers[2].ItemID})
+	assert.True(t, a.Layers[0].Tint)
+	assert.False(t, a.Layers[2].Tint)
+	assert.Len(t, a.Hash, 32)
+
+	// The hash ignores input order but not the look
+	reversed := []AvatarLayer{layers[2], layers[1], layers[0]}
+	assert.Equal(t, a.Hash, NewAvatar(7, "#FFDBB4", reversed).Hash)
+	assert.NotEqual(t, a.Hash, NewAvatar(7, "#8D5524", layers).Hash)
+
+	changed := append([]AvatarLayer{}, layers...)
+	changed[0].AssetKey = "hair_v2.png"
+	assert.NotEqual(t, a.Hash, NewAvatar(7, "#FFDBB4", changed).Hash)
+
+	// Different users with the same look share renders
+	assert.Equal(t, a.Hash, NewAvatar(8, "#FFDBB4", layers).Hash)
+}
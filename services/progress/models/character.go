@@ -0,0 +1,165 @@
+package models
+
+import "time"
+
+// CharacterSlots are the equipment slots of a character, in user_characters
+// column order. An item fits a slot when its category has the slot's name.
+var CharacterSlots = []string{
+	"body", "hair", "eyes", "eyebrows", "nose", "mouth",
+	"top", "bottom", "shoes", "hat", "accessory", "pet",
+	"wallpaper", "floor",
+}
+
+// RequiredSlots can never be empty: unequipping them puts back the default
+// item of the category
+var RequiredSlots = map[string]bool{
+	"body": true, "hair": true, "eyes": true, "eyebrows": true, "nose": true, "mouth": true,
+	"top": true, "bottom": true, "wallpaper": true, "floor": true,
+}
+
+// RoomSlots dress the user's room rather than the character, so they are
+// not drawn on the avatar
+var RoomSlots = map[string]bool{"wallpaper": true, "floor": true}
+
+// DefaultSkinColor is the skin colour of a new character
+const DefaultSkinColor = "#FFDBB4"
+
+// IsCharacterSlot reports whether slot is an equipment slot
+func IsCharacterSlot(slot string) bool {
+	for _, s := range CharacterSlots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// CharacterSlotColumn returns the user_characters column for a slot
+func CharacterSlotColumn(slot string) string {
+	return slot + "_item_id"
+}
+
+// Loadout maps equipment slots to the equipped item. Empty slots are absent.
+type Loadout map[string]int64
+
+// AvatarItemIDs returns the equipped items worn by the character, in slot
+// order, leaving out room slots
+func (l Loadout) AvatarItemIDs() []int64 {
+	ids := make([]int64, 0, len(l))
+	for _, slot := range CharacterSlots {
+		if id, ok := l[slot]; ok && !RoomSlots[slot] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Clone returns a copy of the loadout
+func (l Loadout) Clone() Loadout {
+	c := make(Loadout, len(l))
+	for slot, id := range l {
+		c[slot] = id
+	}
+	return c
+}
+
+// WithDefaults returns the loadout with empty required slots filled from
+// defaults (the is_default item per category)
+func (l Loadout) WithDefaults(defaults map[string]int64) Loadout {
+	c := l.Clone()
+	for slot := range RequiredSlots {
+		if _, ok := c[slot]; ok {
+			continue
+		}
+		if id, ok := defaults[slot]; ok {
+			c[slot] = id
+		}
+	}
+	return c
+}
+
+// ResolveExclusions clears the slots that conflict with the item just put in
+// slot: slots the item excludes, and slots holding items that exclude slot.
+// excludes lists the slots each item excludes (from its metadata). Cleared
+// required slots get their default item back. Returns the changed slots.
+func (l Loadout) ResolveExclusions(slot string, excludes map[int64][]string, defaults map[string]int64) []string {
+	clear := map[string]bool{}
+	if id, ok := l[slot]; ok {
+		for _, s := range excludes[id] {
+			if s != slot {
+				clear[s] = true
+			}
+		}
+	}
+	for s, id := range l {
+		if s == slot {
+			continue
+		}
+		for _, x := range excludes[id] {
+			if x == slot {
+				clear[s] = true
+			}
+		}
+	}
+
+	changed := []string{}
+	for _, s := range CharacterSlots {
+		if !clear[s] {
+			continue
+		}
+		if _, ok := l[s]; !ok {
+			continue
+		}
+		delete(l, s)
+		if RequiredSlots[s] {
+			if id, ok := defaults[s]; ok {
+				l[s] = id
+			}
+		}
+		changed = append(changed, s)
+	}
+	return changed
+}
+
+// MaxOutfitPresets is how many outfit presets a user can save
+const MaxOutfitPresets = 10
+
+// OutfitPreset is a saved look that can be applied in one step
+type OutfitPreset struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Slots     Loadout   `json:"slots"`
+	SkinColor string    `json:"skin_color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsSkinColor reports whether s is a #RRGGBB colour
+func IsSkinColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Conflict returns a pair of slots whose items cannot be worn together,
+// given the slots each item excludes
+func (l Loadout) Conflict(excludes map[int64][]string) (string, string, bool) {
+	for _, slot := range CharacterSlots {
+		id, ok := l[slot]
+		if !ok {
+			continue
+		}
+		for _, other := range excludes[id] {
+			if _, worn := l[other]; worn && other != slot {
+				return slot, other, true
+			}
+		}
+	}
+	return "", "", false
+}
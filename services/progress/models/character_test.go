@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadoutWithDefaults(t *testing.T) {
+	defaults := map[string]int64{"body": 1, "hair": 2, "hat": 9}
+
+	l := Loadout{"hair": 5, "pet": 7}
+	filled := l.WithDefaults(defaults)
+
+	assert.Equal(t, Loadout{"body": 1, "hair": 5, "pet": 7}, filled)
+	// Optional slots are never filled, and the original is untouched
+	assert.NotContains(t, filled, "hat")
+	assert.Equal(t, Loadout{"hair": 5, "pet": 7}, l)
+}
+
+func TestLoadoutResolveExclusions(t *testing.T) {
+	defaults := map[string]int64{"top": 10}
+	// 20 is a hooded top that excludes hats; 30 is a helmet that excludes hair
+	excludes := map[int64][]string{20: {"hat"}, 30: {"hair"}}
+
+	// Equipping the hooded top removes the hat
+	l := Loadout{"top": 20, "hat": 31, "hair": 2}
+	changed := l.ResolveExclusions("top", excludes, defaults)
+	assert.Equal(t, []string{"hat"}, changed)
+	assert.Equal(t, Loadout{"top": 20, "hair": 2}, l)
+
+	// Equipping a hat while wearing the hooded top swaps the top for the default
+	l = Loadout{"top": 20, "hat": 31}
+	changed = l.ResolveExclusions("hat", excludes, defaults)
+	assert.Equal(t, []string{"top"}, changed)
+	assert.Equal(t, Loadout{"top": 10, "hat": 31}, l)
+
+	// A required slot without a default is left empty
+	l = Loadout{"hat": 30, "hair": 2}
+	changed = l.ResolveExclusions("hat", excludes, defaults)
+	assert.Equal(t, []string{"hair"}, changed)
+	assert.Equal(t, Loadout{"hat": 30}, l)
+
+	// No conflicts, nothing changes
+	l = Loadout{"top": 10, "hat": 31}
+	assert.Empty(t, l.ResolveExclusions("hat", excludes, defaults))
+}
+
+func TestIsSkinColor(t *testing.T) {
+	assert.True(t, IsSkinColor("#FFDBB4"))
+	assert.True(t, IsSkinColor("#a0b1c2"))
+	assert.False(t, IsSkinColor("FFDBB4"))
+	assert.False(t, IsSkinColor("#FFDBB"))
+	assert.False(t, IsSkinColor("#GGDBB4"))
+}
+
+func TestLoadoutConflict(t *testing.T) {
+	excludes := map[int64][]string{20: {"hat"}}
+
+	slot, other, ok := Loadout{"top": 20, "hat": 31}.Conflict(excludes)
+	assert.True(t, ok)
+	assert.Equal(t, "top", slot)
+	assert.Equal(t, "hat", other)
+
+	_, _, ok = Loadout{"top": 20, "hair": 2}.Conflict(excludes)
+	assert.False(t, ok)
+}
+
+func TestLoadoutAvatarItemIDsSkipsRoomSlots(t *testing.T) {
+	l := Loadout{"wallpaper": 30, "hair": 5, "floor": 31, "body": 1, "pet": 7}
+	assert.Equal(t, []int64{1, 5, 7}, l.AvatarItemIDs())
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Progress event types pushed to a user's other devices
+const (
+	EventTypeProgress  = "progress"
+	EventTypeLemons    = "lemons"
+	EventTypeInventory = "inventory"
+	EventTypeStreak    = "streak"
+	EventTypeLeague    = "league"
+	EventTypeGift      = "gift"
+)
+
+// ProgressEvent is a change notification for one user
+type ProgressEvent struct {
+	Type         string                 `json:"type"`
+	UserID       int64                  `json:"user_id"`
+	SourceDevice string                 `json:"source_device,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	At           time.Time              `json:"at"`
+}
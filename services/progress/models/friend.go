@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Activity privacy levels: who sees a user's items in friend feeds
+const (
+	ActivityPrivacyPublic  = "public"  // friends and followers
+	ActivityPrivacyFriends = "friends" // friends only
+	ActivityPrivacyPrivate = "private" // nobody
+)
+
+// IsActivityPrivacy reports whether p is a known privacy level
+func IsActivityPrivacy(p string) bool {
+	switch p {
+	case ActivityPrivacyPublic, ActivityPrivacyFriends, ActivityPrivacyPrivate:
+		return true
+	}
+	return false
+}
+
+// Friend request statuses
+const (
+	FriendRequestPending   = "pending"
+	FriendRequestAccepted  = "accepted"
+	FriendRequestDeclined  = "declined"
+	FriendRequestCancelled = "cancelled"
+)
+
+// Activity feed item kinds
+const (
+	ActivityLessonCompleted = "lesson_completed"
+	ActivityStreakMilestone = "streak_milestone"
+	ActivityLevelUp         = "level_up"
+	ActivityLeaguePromoted  = "league_promoted"
+
+	// ActivityAchievementEarned items are recorded by a trigger on
+	// user_achievements (migration 042), whichever service awards the badge
+	ActivityAchievementEarned = "achievement_earned"
+)
+
+// StreakMilestones are the streak lengths (days) that appear in friend feeds
+var StreakMilestones = []int{3, 7, 14, 30, 50, 100, 150, 200, 365}
+
+// IsStreakMilestone reports whether a streak of days is worth sharing.
+// Every full year after the last listed milestone also counts.
+func IsStreakMilestone(days int) bool {
+	for _, m := range StreakMilestones {
+		if days == m {
+			return true
+		}
+	}
+	return days > 365 && days%365 == 0
+}
+
+// UserSummary is the public profile shown next to social entries
+type UserSummary struct {
+	UserID          int64  `json:"user_id"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url,omitempty"`
+}
+
+// Friend is one of the user's friends
+type Friend struct {
+	UserSummary
+	Since time.Time `json:"since"`
+}
+
+// FriendRequest is a pending or answered friend request. User is the other
+// party: the requester for incoming requests, the addressee for outgoing.
+type FriendRequest struct {
+	ID          int64       `json:"id"`
+	RequesterID int64       `json:"requester_id"`
+	AddresseeID int64       `json:"addressee_id"`
+	Status      string      `json:"status"`
+	User        UserSummary `json:"user"`
+	CreatedAt   time.Time   `json:"created_at"`
+	RespondedAt *time.Time  `json:"responded_at,omitempty"`
+}
+
+// ActivityItem is a feed entry about one user
+type ActivityItem struct {
+	ID        int64           `json:"id"`
+	User      UserSummary     `json:"user"`
+	Kind      string          `json:"kind"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RankLeaderboardEntries orders entries by score (ties by user ID) and
+// numbers them from 1
+func RankLeaderboardEntries(entries []LeaderboardEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].UserID < entries[j].UserID
+	})
+	for i := range entries {
+		entries[i].Rank = int64(i + 1)
+	}
+}
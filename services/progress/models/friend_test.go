@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStreakMilestone(t *testing.T) {
+	for _, days := range []int{3, 7, 30, 100, 365, 730, 1095} {
+		assert.True(t, IsStreakMilestone(days), days)
+	}
+	for _, days := range []int{0, 1, 2, 8, 99, 366, 400} {
+		assert.False(t, IsStreakMilestone(days), days)
+	}
+}
+
+func TestRankLeaderboardEntries(t *testing.T) {
+	entries := []LeaderboardEntry{
+		{UserID: 5, Score: 10},
+		{UserID: 2, Score: 30},
+		{UserID: 9, Score: 10},
+		{UserID: 1, Score: 0},
+	}
+
+	RankLeaderboardEntries(entries)
+
+	assert.Equal(t, []int64{2, 5, 9, 1}, []int64{entries[0].UserID, entries[1].UserID, entries[2].UserID, entries[3].UserID})
+	for i, e := range entries {
+		assert.Equal(t, int64(i+1), e.Rank)
+	}
+}
+
+func TestIsActivityPrivacy(t *testing.T) {
+	assert.True(t, IsActivityPrivacy(ActivityPrivacyPublic))
+	assert.True(t, IsActivityPrivacy(ActivityPrivacyFriends))
+	assert.True(t, IsActivityPrivacy(ActivityPrivacyPrivate))
+	assert.False(t, IsActivityPrivacy("everyone"))
+}
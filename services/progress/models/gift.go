@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Gift kinds
+const (
+	GiftKindLemons = "lemons"
+	GiftKindItem   = "item"
+)
+
+// GiftSettings are the anti-abuse limits from gamification_settings
+type GiftSettings struct {
+	DailyCount          int
+	DailyLemons         int
+	ReceivedDailyLemons int
+	MinAccountAgeDays   int
+}
+
+// GiftsSentToday is what a sender has given since the start of the UTC day
+type GiftsSentToday struct {
+	Count  int
+	Lemons int
+}
+
+// Allows reports whether another gift of lemons (0 for items) fits in the
+// daily limits
+func (s GiftSettings) Allows(sent GiftsSentToday, lemons int) bool {
+	if sent.Count+1 > s.DailyCount {
+		return false
+	}
+	return sent.Lemons+lemons <= s.DailyLemons
+}
+
+// AllowsReceiving reports whether a recipient who got received lemons in
+// gifts today can be given lemons more
+func (s GiftSettings) AllowsReceiving(received, lemons int) bool {
+	return received+lemons <= s.ReceivedDailyLemons
+}
+
+// AccountOldEnough reports whether an account created at createdAt may
+// send or receive gifts at now
+func (s GiftSettings) AccountOldEnough(createdAt, now time.Time) bool {
+	return !createdAt.Add(time.Duration(s.MinAccountAgeDays) * 24 * time.Hour).After(now)
+}
+
+// Gift is lemons or an item given by one user to a friend. User is the
+// other party: the sender for received gifts, the recipient for sent ones.
+type Gift struct {
+	ID          int64       `json:"id"`
+	SenderID    int64       `json:"sender_id"`
+	RecipientID int64       `json:"recipient_id"`
+	Kind        string      `json:"kind"`
+	Lemons      int         `json:"lemons,omitempty"`
+	ItemID      *int64      `json:"item_id,omitempty"`
+	ItemName    string      `json:"item_name,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	User        UserSummary `json:"user"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SeenAt      *time.Time  `json:"seen_at,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGiftSettingsAllows(t *testing.T) {
+	s := GiftSettings{DailyCount: 3, DailyLemons: 50}
+
+	assert.True(t, s.Allows(GiftsSentToday{}, 50))
+	assert.False(t, s.Allows(GiftsSentToday{}, 51))
+	assert.True(t, s.Allows(GiftsSentToday{Count: 2, Lemons: 40}, 10))
+	assert.False(t, s.Allows(GiftsSentToday{Count: 2, Lemons: 40}, 11))
+
+	// Item gifts only count towards the number of gifts
+	assert.True(t, s.Allows(GiftsSentToday{Count: 2, Lemons: 50}, 0))
+	assert.False(t, s.Allows(GiftsSentToday{Count: 3}, 0))
+}
+
+func TestGiftSettingsAllowsReceiving(t *testing.T) {
+	s := GiftSettings{ReceivedDailyLemons: 100}
+
+	assert.True(t, s.AllowsReceiving(0, 100))
+	assert.True(t, s.AllowsReceiving(90, 10))
+	assert.False(t, s.AllowsReceiving(90, 11))
+
+	// Item gifts are not limited on the receiving side
+	assert.True(t, s.AllowsReceiving(100, 0))
+}
+
+func TestGiftSettingsAccountOldEnough(t *testing.T) {
+	s := GiftSettings{MinAccountAgeDays: 7}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, s.AccountOldEnough(now.AddDate(0, 0, -7), now))
+	assert.False(t, s.AccountOldEnough(now.AddDate(0, 0, -7).Add(time.Second), now))
+	assert.True(t, GiftSettings{}.AccountOldEnough(now, now))
+}
@@ -0,0 +1,95 @@
+package models
+
+// Leaderboard metrics
+const (
+	LeaderboardLemons       = "lemons"        // lemons earned (spending does not lower it)
+	LeaderboardReviews      = "reviews"       // vocabulary and hangul answers
+	LeaderboardStudyMinutes = "study_minutes" // lesson time
+)
+
+// Leaderboard scopes
+const (
+	LeaderboardWeekly  = "weekly"
+	LeaderboardAllTime = "all_time"
+)
+
+// LeaderboardMetrics lists every leaderboard metric
+var LeaderboardMetrics = []string{LeaderboardLemons, LeaderboardReviews, LeaderboardStudyMinutes}
+
+// IsLeaderboardMetric reports whether m is a known leaderboard metric
+func IsLeaderboardMetric(m string) bool {
+	for _, metric := range LeaderboardMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaderboardScores are the increments from one committed write
+type LeaderboardScores struct {
+	Lemons       int
+	Reviews      int
+	StudyMinutes int
+}
+
+// ByMetric returns the non-zero increments keyed by metric
+func (s LeaderboardScores) ByMetric() map[string]int {
+	scores := map[string]int{}
+	if s.Lemons > 0 {
+		scores[LeaderboardLemons] = s.Lemons
+	}
+	if s.Reviews > 0 {
+		scores[LeaderboardReviews] = s.Reviews
+	}
+	if s.StudyMinutes > 0 {
+		scores[LeaderboardStudyMinutes] = s.StudyMinutes
+	}
+	return scores
+}
+
+// StudyMinuteCaps bound the client-reported lesson time that counts towards
+// the study_minutes boards
+type StudyMinuteCaps struct {
+	PerRequest int
+	PerDay     int
+}
+
+// DefaultStudyMinuteCaps are used when gamification_settings has no row
+func DefaultStudyMinuteCaps() StudyMinuteCaps {
+	return StudyMinuteCaps{PerRequest: 120, PerDay: 480}
+}
+
+// RequestAllowance returns how many of one write's minutes may count
+func (c StudyMinuteCaps) RequestAllowance(minutes int) int {
+	if minutes < 0 {
+		return 0
+	}
+	if minutes > c.PerRequest {
+		return c.PerRequest
+	}
+	return minutes
+}
+
+// DailyAllowance returns how many of minutes count once the user's daily
+// counter, incremented by minutes, reached totalToday. Concurrent writes
+// each see their own total, so together they never exceed PerDay.
+func (c StudyMinuteCaps) DailyAllowance(minutes, totalToday int) int {
+	before := totalToday - minutes
+	if before >= c.PerDay {
+		return 0
+	}
+	if totalToday > c.PerDay {
+		return c.PerDay - before
+	}
+	return minutes
+}
+
+// LeaderboardEntry is a ranked user on a leaderboard
+type LeaderboardEntry struct {
+	Rank            int64  `json:"rank"`
+	UserID          int64  `json:"user_id"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url,omitempty"`
+	Score           int64  `json:"score"`
+}
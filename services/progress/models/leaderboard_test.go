@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudyMinuteCapsRequestAllowance(t *testing.T) {
+	caps := StudyMinuteCaps{PerRequest: 120, PerDay: 480}
+
+	assert.Equal(t, 30, caps.RequestAllowance(30))
+	assert.Equal(t, 120, caps.RequestAllowance(120))
+	assert.Equal(t, 120, caps.RequestAllowance(1000000), "one request cannot top the boards")
+	assert.Equal(t, 0, caps.RequestAllowance(-5))
+}
+
+func TestStudyMinuteCapsDailyAllowance(t *testing.T) {
+	caps := StudyMinuteCaps{PerRequest: 120, PerDay: 480}
+
+	assert.Equal(t, 100, caps.DailyAllowance(100, 100))
+	assert.Equal(t, 100, caps.DailyAllowance(100, 480), "reaches the cap exactly")
+	assert.Equal(t, 60, caps.DailyAllowance(100, 520), "only the part under the cap counts")
+	assert.Equal(t, 0, caps.DailyAllowance(100, 580), "nothing counts once the cap is reached")
+
+	// Concurrent writes see consecutive totals and split the remainder
+	used := 0
+	total := 400
+	for _, minutes := range []int{50, 50, 50} {
+		total += minutes
+		used += caps.DailyAllowance(minutes, total)
+	}
+	assert.Equal(t, 80, used)
+}
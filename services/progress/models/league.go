@@ -0,0 +1,111 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// League results at the end of a week
+const (
+	LeaguePromoted = "promoted"
+	LeagueStayed   = "stayed"
+	LeagueDemoted  = "demoted"
+
+	// LeagueSkipped is the result of members who opted out of
+	// leaderboards: they are not ranked and keep their tier
+	LeagueSkipped = "skipped"
+)
+
+// League standing zones
+const (
+	LeagueZonePromotion = "promotion"
+	LeagueZoneDemotion  = "demotion"
+)
+
+// LeagueTier is one level of the league ladder. Tier 1 is the lowest.
+type LeagueTier struct {
+	Tier                  int    `json:"tier"`
+	Code                  string `json:"code"`
+	Name                  string `json:"name"`
+	PromoteCount          int    `json:"promote_count"`
+	DemoteCount           int    `json:"demote_count"`
+	PromotionRewardLemons int    `json:"promotion_reward_lemons"`
+}
+
+// Outcome returns the result for a member finishing at rank (1-based) out
+// of size with the given weekly lemons. Nobody is promoted out of the top
+// tier or demoted out of tier 1, and promotion needs at least one lemon.
+func (t LeagueTier) Outcome(rank, size int, lemons int64, topTier int) string {
+	if rank <= t.PromoteCount && t.Tier < topTier && lemons > 0 {
+		return LeaguePromoted
+	}
+	if rank > t.PromoteCount && rank > size-t.DemoteCount && t.Tier > 1 {
+		return LeagueDemoted
+	}
+	return LeagueStayed
+}
+
+// LeagueStanding is a cohort member's live or final position
+type LeagueStanding struct {
+	Rank            int    `json:"rank"`
+	UserID          int64  `json:"user_id"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url,omitempty"`
+	WeeklyLemons    int64  `json:"weekly_lemons"`
+	Zone            string `json:"zone,omitempty"`
+	memberID        int64
+}
+
+// NewLeagueStanding creates an unranked standing. memberID orders ties
+// (earlier joiners rank higher).
+func NewLeagueStanding(memberID, userID int64, name, imageURL string, lemons int64) LeagueStanding {
+	return LeagueStanding{UserID: userID, Name: name, ProfileImageURL: imageURL, WeeklyLemons: lemons, memberID: memberID}
+}
+
+// RankLeagueStandings orders a cohort by weekly lemons and sets each
+// member's rank and zone
+func RankLeagueStandings(standings []LeagueStanding, tier LeagueTier, topTier int) {
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].WeeklyLemons != standings[j].WeeklyLemons {
+			return standings[i].WeeklyLemons > standings[j].WeeklyLemons
+		}
+		return standings[i].memberID < standings[j].memberID
+	})
+
+	for i := range standings {
+		standings[i].Rank = i + 1
+		switch tier.Outcome(i+1, len(standings), standings[i].WeeklyLemons, topTier) {
+		case LeaguePromoted:
+			standings[i].Zone = LeagueZonePromotion
+		case LeagueDemoted:
+			standings[i].Zone = LeagueZoneDemotion
+		default:
+			standings[i].Zone = ""
+		}
+	}
+}
+
+// League is the user's cohort for the current week. Standings is empty
+// while last week's results are still being calculated.
+type League struct {
+	WeekStart time.Time        `json:"week_start"`
+	WeekEnd   time.Time        `json:"week_end"`
+	Tier      LeagueTier       `json:"tier"`
+	CohortID  int64            `json:"cohort_id,omitempty"`
+	Standings []LeagueStanding `json:"standings"`
+	MyRank    int              `json:"my_rank,omitempty"`
+	Pending   bool             `json:"pending"`
+}
+
+// LeagueResult is a user's finalized week
+type LeagueResult struct {
+	WeekStart    time.Time `json:"week_start"`
+	Tier         int       `json:"tier"`
+	TierName     string    `json:"tier_name"`
+	Rank         int       `json:"rank"`
+	CohortSize   int       `json:"cohort_size"`
+	WeeklyLemons int64     `json:"weekly_lemons"`
+	Result       string    `json:"result"`
+	NewTier      int       `json:"new_tier"`
+	RewardLemons int       `json:"reward_lemons"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeagueOutcome(t *testing.T) {
+	silver := LeagueTier{Tier: 2, PromoteCount: 7, DemoteCount: 5}
+
+	assert.Equal(t, LeaguePromoted, silver.Outcome(1, 30, 120, 10))
+	assert.Equal(t, LeaguePromoted, silver.Outcome(7, 30, 1, 10))
+	assert.Equal(t, LeagueStayed, silver.Outcome(8, 30, 50, 10))
+	assert.Equal(t, LeagueStayed, silver.Outcome(25, 30, 0, 10))
+	assert.Equal(t, LeagueDemoted, silver.Outcome(26, 30, 0, 10))
+
+	// No lemons, no promotion
+	assert.Equal(t, LeagueStayed, silver.Outcome(1, 30, 0, 10))
+
+	// Small cohorts: the promotion zone wins over the demotion zone
+	assert.Equal(t, LeaguePromoted, silver.Outcome(3, 8, 10, 10))
+	assert.Equal(t, LeagueDemoted, silver.Outcome(8, 8, 0, 10))
+
+	bronze := LeagueTier{Tier: 1, PromoteCount: 10, DemoteCount: 5}
+	assert.Equal(t, LeagueStayed, bronze.Outcome(30, 30, 0, 10))
+
+	diamond := LeagueTier{Tier: 10, PromoteCount: 5, DemoteCount: 5}
+	assert.Equal(t, LeagueStayed, diamond.Outcome(1, 30, 500, 10))
+}
+
+func TestRankLeagueStandings(t *testing.T) {
+	tier := LeagueTier{Tier: 2, PromoteCount: 1, DemoteCount: 1}
+	standings := []LeagueStanding{
+		NewLeagueStanding(1, 10, "a", "", 5),
+		NewLeagueStanding(2, 20, "b", "", 9),
+		NewLeagueStanding(3, 30, "c", "", 5),
+	}
+
+	RankLeagueStandings(standings, tier, 10)
+
+	assert.Equal(t, []int64{20, 10, 30}, []int64{standings[0].UserID, standings[1].UserID, standings[2].UserID})
+	assert.Equal(t, []int{1, 2, 3}, []int{standings[0].Rank, standings[1].Rank, standings[2].Rank})
+	assert.Equal(t, LeagueZonePromotion, standings[0].Zone)
+	assert.Equal(t, "", standings[1].Zone)
+	assert.Equal(t, LeagueZoneDemotion, standings[2].Zone)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Learning event types stored in the append-only learning_events table.
+// Current-state tables (user_progress, vocabulary_progress, hangul_progress,
+// lesson_rewards) are projections of these events and can be rebuilt from them.
+const (
+	LearningEventLessonCompleted    = "lesson_completed"
+	LearningEventProgressUpdated    = "progress_updated"
+	LearningEventProgressReset      = "progress_reset"
+	LearningEventVocabularyAnswered = "vocabulary_answered"
+	LearningEventVocabularyBatch    = "vocabulary_batch_answered"
+	LearningEventHangulPracticed    = "hangul_practiced"
+	LearningEventLessonRewarded     = "lesson_rewarded"
+	LearningEventBossQuizCompleted  = "boss_quiz_completed"
+	LearningEventItemPurchased      = "item_purchased"
+
+	// Snapshot events backfilled from state that existed before event sourcing
+	LearningEventProgressImported     = "progress_imported"
+	LearningEventVocabularyImported   = "vocabulary_imported"
+	LearningEventLessonRewardImported = "lesson_reward_imported"
+	LearningEventHangulImported       = "hangul_imported"
+)
+
+// LearningEvent is an immutable record of one learning action
+type LearningEvent struct {
+	ID           int64           `json:"id"`
+	UserID       int64           `json:"user_id"`
+	Type         string          `json:"type"`
+	Data         json.RawMessage `json:"data"`
+	SourceDevice string          `json:"source_device,omitempty"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+}
+
+// LessonCompletedData is the payload of a lesson_completed event
+type LessonCompletedData struct {
+	LessonID  int64 `json:"lesson_id"`
+	QuizScore int   `json:"quiz_score"`
+	TimeSpent int   `json:"time_spent"`
+}
+
+// ProgressUpdatedData is the payload of a progress_updated event
+type ProgressUpdatedData struct {
+	LessonID        int64          `json:"lesson_id"`
+	Status          ProgressStatus `json:"status"`
+	ProgressPercent int            `json:"progress_percent"`
+	TimeSpent       int            `json:"time_spent"`
+}
+
+// ProgressResetData is the payload of a progress_reset event
+type ProgressResetData struct {
+	LessonID int64 `json:"lesson_id"`
+}
+
+// VocabularyAnsweredData is the payload of a vocabulary_answered event.
+// The SRS outcome computed at answer time is stored with the answer so
+// replays reproduce the schedule the learner actually saw.
+type VocabularyAnsweredData struct {
+	VocabularyID    int64     `json:"vocabulary_id"`
+	IsCorrect       bool      `json:"is_correct"`
+	ResponseTime    int       `json:"response_time,omitempty"`
+	MasteryLevel    int       `json:"mastery_level"`
+	EasinessFactor  float64   `json:"easiness_factor"`
+	IntervalDays    int       `json:"interval_days"`
+	RepetitionCount int       `json:"repetition_count"`
+	NextReviewAt    time.Time `json:"next_review_at"`
+}
+
+// HangulPracticedData is the payload of a hangul_practiced event. Like
+// vocabulary answers, it carries the SRS outcome computed at answer time.
+type HangulPracticedData struct {
+	CharacterID     int64     `json:"character_id"`
+	IsCorrect       bool      `json:"is_correct"`
+	MasteryLevel    int       `json:"mastery_level"`
+	EasinessFactor  float64   `json:"easiness_factor"`
+	IntervalDays    int       `json:"interval_days"`
+	RepetitionCount int       `json:"repetition_count"`
+	NextReviewAt    time.Time `json:"next_review_at"`
+}
+
+// VocabularyBatchData is the payload of a vocabulary_batch_answered event
+type VocabularyBatchData struct {
+	LessonID int64              `json:"lesson_id"`
+	Results  []VocabularyResult `json:"results"`
+}
+
+// LessonRewardedData is the payload of a lesson_rewarded event.
+// LemonsEarned is the server-computed reward for this attempt and
+// LemonsCredited the part of it added to the balance.
+type LessonRewardedData struct {
+	LessonID       int64 `json:"lesson_id"`
+	LemonsEarned   int   `json:"lemons_earned"`
+	LemonsCredited int   `json:"lemons_credited"`
+	QuizScore      int   `json:"quiz_score"`
+}
+
+// BossQuizCompletedData is the payload of a boss_quiz_completed event
+type BossQuizCompletedData struct {
+	Level       int `json:"level"`
+	Week        int `json:"week"`
+	Score       int `json:"score"`
+	BonusLemons int `json:"bonus_lemons"`
+}
+
+// ItemPurchasedData is the payload of an item_purchased event
+type ItemPurchasedData struct {
+	ItemID int `json:"item_id"`
+	Price  int `json:"price"`
+}
+
+// ProgressImportedData is a backfilled user_progress row
+type ProgressImportedData struct {
+	LessonID         int64          `json:"lesson_id"`
+	Status           ProgressStatus `json:"status"`
+	ProgressPercent  int            `json:"progress_percent"`
+	QuizScore        *int           `json:"quiz_score"`
+	TimeSpentMinutes int            `json:"time_spent_minutes"`
+	LastAccessedAt   *time.Time     `json:"last_accessed_at"`
+	CompletedAt      *time.Time     `json:"completed_at"`
+}
+
+// VocabularyImportedData is a backfilled vocabulary_progress row
+type VocabularyImportedData struct {
+	VocabularyID    int64      `json:"vocabulary_id"`
+	MasteryLevel    int        `json:"mastery_level"`
+	CorrectCount    int        `json:"correct_count"`
+	IncorrectCount  int        `json:"incorrect_count"`
+	LastReviewedAt  *time.Time `json:"last_reviewed_at"`
+	NextReviewAt    *time.Time `json:"next_review_at"`
+	EasinessFactor  float64    `json:"easiness_factor"`
+	RepetitionCount int        `json:"repetition_count"`
+	IntervalDays    int        `json:"interval_days"`
+}
+
+// HangulImportedData is a backfilled hangul_progress row
+type HangulImportedData struct {
+	CharacterID     int64      `json:"character_id"`
+	MasteryLevel    int        `json:"mastery_level"`
+	CorrectCount    int        `json:"correct_count"`
+	WrongCount      int        `json:"wrong_count"`
+	StreakCount     int        `json:"streak_count"`
+	LastPracticed   *time.Time `json:"last_practiced"`
+	NextReview      *time.Time `json:"next_review"`
+	EasinessFactor  float64    `json:"easiness_factor"`
+	IntervalDays    int        `json:"interval_days"`
+	RepetitionCount int        `json:"repetition_count"`
+}
+
+// LessonRewardImportedData is a backfilled lesson_rewards row
+type LessonRewardImportedData struct {
+	LessonID      int64     `json:"lesson_id"`
+	LemonsEarned  int       `json:"lemons_earned"`
+	BestQuizScore *int      `json:"best_quiz_score"`
+	EarnedAt      time.Time `json:"earned_at"`
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lemon ledger accounts. User accounts mirror a lemon_currency column;
+// system accounts only exist in the ledger and absorb the other side of
+// each posting, so the legs of every posting sum to zero.
+const (
+	LedgerAccountWallet    = "wallet"    // lemon_currency.total_lemons
+	LedgerAccountTree      = "tree"      // lemon_currency.tree_lemons_available
+	LedgerAccountHarvested = "harvested" // lemon_currency.tree_lemons_harvested
+
+	LedgerAccountRewards    = "rewards"
+	LedgerAccountGrowth     = "growth"
+	LedgerAccountShop       = "shop"
+	LedgerAccountWithered   = "withered"
+	LedgerAccountOpening    = "opening"
+	LedgerAccountAdjustment = "adjustment"
+)
+
+// Lemon transaction types
+const (
+	LemonTxLesson     = "lesson"
+	LemonTxBoss       = "boss"
+	LemonTxHarvest    = "harvest"
+	LemonTxBonus      = "bonus"
+	LemonTxPurchase   = "purchase"
+	LemonTxWither     = "wither"
+	LemonTxOpening    = "opening"
+	LemonTxAdjustment = "adjustment"
+	LemonTxQuest      = "quest"
+	LemonTxLeague     = "league"
+	LemonTxGift       = "gift"
+	LemonTxRoomLike   = "room_like"
+)
+
+// IsUserLedgerAccount reports whether the account has a balance in lemon_currency
+func IsUserLedgerAccount(account string) bool {
+	switch account {
+	case LedgerAccountWallet, LedgerAccountTree, LedgerAccountHarvested:
+		return true
+	}
+	return false
+}
+
+// IsLedgerAccount reports whether the account is known to the ledger
+func IsLedgerAccount(account string) bool {
+	switch account {
+	case LedgerAccountRewards, LedgerAccountGrowth, LedgerAccountShop,
+		LedgerAccountWithered, LedgerAccountOpening, LedgerAccountAdjustment:
+		return true
+	}
+	return IsUserLedgerAccount(account)
+}
+
+// LedgerLeg is one side of a posting
+type LedgerLeg struct {
+	UserID  int64  `json:"user_id"`
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+}
+
+// LedgerEntry is a leg as written to lemon_transactions.
+// BalanceAfter is set for user accounts only.
+type LedgerEntry struct {
+	ID           int64     `json:"id"`
+	PostingID    int64     `json:"posting_id"`
+	UserID       int64     `json:"user_id"`
+	Account      string    `json:"account"`
+	Amount       int       `json:"amount"`
+	BalanceAfter *int      `json:"balance_after,omitempty"`
+	Type         string    `json:"type"`
+	SourceID     *int64    `json:"source_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// LemonBalances holds a user's balance per user account
+type LemonBalances struct {
+	TotalLemons         int `json:"total_lemons"`
+	TreeLemonsAvailable int `json:"tree_lemons_available"`
+	TreeLemonsHarvested int `json:"tree_lemons_harvested"`
+}
+
+// LemonBalanceMismatch is a user whose stored balances differ from the ledger
+type LemonBalanceMismatch struct {
+	UserID   int64         `json:"user_id"`
+	Stored   LemonBalances `json:"stored"`
+	Ledger   LemonBalances `json:"ledger"`
+	Repaired bool          `json:"repaired"`
+}
+
+// SumLedgerLegs returns the net amount of a set of legs
+func SumLedgerLegs(legs []LedgerLeg) int {
+	sum := 0
+	for _, leg := range legs {
+		sum += leg.Amount
+	}
+	return sum
+}
+
+var (
+	// ErrUnbalancedPosting is returned for postings whose legs do not sum to zero
+	ErrUnbalancedPosting = errors.New("unbalanced lemon posting")
+
+	// ErrUnknownLedgerAccount is returned for legs on an account the ledger
+	// does not know
+	ErrUnknownLedgerAccount = errors.New("unknown ledger account")
+)
+
+// PrepareLedgerPosting checks that legs form a valid posting and returns
+// its non-zero legs in the order they are applied: by user, then account,
+// so concurrent postings lock lemon_currency rows consistently. A posting
+// of zero legs only is empty.
+func PrepareLedgerPosting(legs []LedgerLeg) ([]LedgerLeg, error) {
+	if sum := SumLedgerLegs(legs); sum != 0 {
+		return nil, fmt.Errorf("%w: legs sum to %d", ErrUnbalancedPosting, sum)
+	}
+
+	ordered := make([]LedgerLeg, 0, len(legs))
+	for _, leg := range legs {
+		if !IsLedgerAccount(leg.Account) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownLedgerAccount, leg.Account)
+		}
+		if leg.Amount != 0 {
+			ordered = append(ordered, leg)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].UserID != ordered[j].UserID {
+			return ordered[i].UserID < ordered[j].UserID
+		}
+		return ordered[i].Account < ordered[j].Account
+	})
+	return ordered, nil
+}
+
+// Summary periods for lemon transaction history
+const (
+	SummaryPeriodDay   = "day"
+	SummaryPeriodWeek  = "week"
+	SummaryPeriodMonth = "month"
+)
+
+// LemonTransactionFilter selects a user's lemon history.
+// Only user account legs are listed; system legs stay internal.
+type LemonTransactionFilter struct {
+	UserID   int64
+	Types    []string
+	Accounts []string
+	From     *time.Time // inclusive
+	To       *time.Time // exclusive
+	BeforeID int64      // cursor: only entries with a smaller id
+	Limit    int
+}
+
+// LemonTransactionSummary totals one period of lemon history.
+// EarnedByType splits Earned by transaction type.
+type LemonTransactionSummary struct {
+	PeriodStart  time.Time      `json:"period_start"`
+	Earned       int            `json:"earned"`
+	Spent        int            `json:"spent"`
+	Harvested    int            `json:"harvested"`
+	Net          int            `json:"net"`
+	Transactions int            `json:"transactions"`
+	EarnedByType map[string]int `json:"earned_by_type"`
+}
+
+// IsLemonTransactionType reports whether t is a known transaction type
+func IsLemonTransactionType(t string) bool {
+	switch t {
+	case LemonTxLesson, LemonTxBoss, LemonTxHarvest, LemonTxBonus,
+		LemonTxPurchase, LemonTxWither, LemonTxOpening, LemonTxAdjustment, LemonTxQuest, LemonTxLeague, LemonTxGift,
+		LemonTxRoomLike:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareLedgerPosting(t *testing.T) {
+	legs := []LedgerLeg{
+		{UserID: 2, Account: LedgerAccountWallet, Amount: 10},
+		{Account: LedgerAccountShop, Amount: 0},
+		{UserID: 1, Account: LedgerAccountWallet, Amount: -10},
+	}
+
+	ordered, err := PrepareLedgerPosting(legs)
+	require.NoError(t, err)
+	assert.Equal(t, []LedgerLeg{
+		{UserID: 1, Account: LedgerAccountWallet, Amount: -10},
+		{UserID: 2, Account: LedgerAccountWallet, Amount: 10},
+	}, ordered, "zero legs dropped, ordered by user then account")
+
+	// Same user: ordered by account
+	ordered, err = PrepareLedgerPosting([]LedgerLeg{
+		{UserID: 1, Account: LedgerAccountWallet, Amount: 5},
+		{UserID: 1, Account: LedgerAccountHarvested, Amount: -5},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, LedgerAccountHarvested, ordered[0].Account)
+
+	ordered, err = PrepareLedgerPosting([]LedgerLeg{{UserID: 1, Account: LedgerAccountWallet}})
+	require.NoError(t, err)
+	assert.Empty(t, ordered)
+}
+
+func TestPrepareLedgerPostingRejects(t *testing.T) {
+	_, err := PrepareLedgerPosting([]LedgerLeg{
+		{UserID: 1, Account: LedgerAccountWallet, Amount: 10},
+		{Account: LedgerAccountRewards, Amount: -9},
+	})
+	assert.ErrorIs(t, err, ErrUnbalancedPosting)
+
+	// A one-sided credit is unbalanced
+	_, err = PrepareLedgerPosting([]LedgerLeg{{UserID: 1, Account: LedgerAccountWallet, Amount: 10}})
+	assert.ErrorIs(t, err, ErrUnbalancedPosting)
+
+	_, err = PrepareLedgerPosting([]LedgerLeg{
+		{UserID: 1, Account: LedgerAccountWallet, Amount: 10},
+		{Account: "mint", Amount: -10},
+	})
+	assert.ErrorIs(t, err, ErrUnknownLedgerAccount)
+	assert.Contains(t, err.Error(), `"mint"`)
+}
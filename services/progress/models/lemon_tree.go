@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Lemon tree fruit statuses
+const (
+	FruitOnTree    = "on_tree"
+	FruitHarvested = "harvested"
+	FruitWithered  = "withered"
+)
+
+// TreeSettings are the lemon tree simulation and harvest parameters
+// (gamification_settings, editable by admins). While AdsEnabled is set,
+// each harvest needs a verified ad reward grant.
+type TreeSettings struct {
+	Capacity        int
+	RipenDuration   time.Duration
+	StudyBoost      time.Duration
+	WitherAfter     time.Duration
+	WitherInterval  time.Duration
+	HarvestCooldown time.Duration
+	AdsEnabled      bool
+	AdDailyCap      int
+	AdGrantTTL      time.Duration
+}
+
+// DefaultTreeSettings match the defaults of migrations 009, 026 and 027
+func DefaultTreeSettings() TreeSettings {
+	return TreeSettings{
+		Capacity:        10,
+		RipenDuration:   240 * time.Minute,
+		StudyBoost:      30 * time.Minute,
+		WitherAfter:     72 * time.Hour,
+		WitherInterval:  24 * time.Hour,
+		HarvestCooldown: 60 * time.Second,
+		AdsEnabled:      true,
+		AdDailyCap:      10,
+		AdGrantTTL:      30 * time.Minute,
+	}
+}
+
+// LemonFruit is one lemon on the tree
+type LemonFruit struct {
+	ID       int64     `json:"id"`
+	GrownAt  time.Time `json:"grown_at"`
+	RipensAt time.Time `json:"ripens_at"`
+	Ripe     bool      `json:"ripe"`
+}
+
+// LemonTreeState is what the app renders for a user's tree
+type LemonTreeState struct {
+	Capacity            int          `json:"capacity"`
+	RipeLemons          int          `json:"ripe_lemons"`
+	GrowingLemons       int          `json:"growing_lemons"`
+	Fruits              []LemonFruit `json:"fruits"`
+	NextRipenAt         *time.Time   `json:"next_ripen_at,omitempty"`
+	TreeLemonsHarvested int          `json:"tree_lemons_harvested"`
+	LastActivityAt      time.Time    `json:"last_activity_at"`
+	NextWitherAt        *time.Time   `json:"next_wither_at,omitempty"`
+	LastHarvestAt       *time.Time   `json:"last_harvest_at,omitempty"`
+	HarvestAvailableAt  *time.Time   `json:"harvest_available_at,omitempty"`
+	AdRequired          bool         `json:"ad_required"`
+	AdGrants            int          `json:"ad_grants"`
+	CanHarvest          bool         `json:"can_harvest"`
+	ServerTime          time.Time    `json:"server_time"`
+}
+
+// NextWitherAt returns when the next ripe lemon withers if the user stays
+// inactive: WitherAfter the last study activity, or the stored schedule
+// once withering has started
+func (s TreeSettings) NextWitherAt(lastActivity time.Time, scheduled *time.Time) time.Time {
+	next := lastActivity.Add(s.WitherAfter)
+	if scheduled != nil && scheduled.After(next) {
+		next = *scheduled
+	}
+	return next
+}
+
+// WitherDue returns how many ripe lemons should have withered by now (one
+// per WitherInterval from the next wither time) and the following wither time
+func (s TreeSettings) WitherDue(lastActivity time.Time, scheduled *time.Time, now time.Time) (int, time.Time) {
+	next := s.NextWitherAt(lastActivity, scheduled)
+	if s.WitherInterval <= 0 || now.Before(next) {
+		return 0, next
+	}
+
+	due := int(now.Sub(next)/s.WitherInterval) + 1
+	return due, next.Add(time.Duration(due) * s.WitherInterval)
+}
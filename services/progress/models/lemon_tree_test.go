@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWitherDue(t *testing.T) {
+	s := DefaultTreeSettings()
+	lastActivity := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Within the grace period nothing withers
+	due, next := s.WitherDue(lastActivity, nil, lastActivity.Add(71*time.Hour))
+	assert.Equal(t, 0, due)
+	assert.Equal(t, lastActivity.Add(72*time.Hour), next)
+
+	// One lemon as soon as the grace period ends, then one per interval
+	due, next = s.WitherDue(lastActivity, nil, lastActivity.Add(72*time.Hour))
+	assert.Equal(t, 1, due)
+	assert.Equal(t, lastActivity.Add(96*time.Hour), next)
+
+	due, next = s.WitherDue(lastActivity, nil, lastActivity.Add(121*time.Hour))
+	assert.Equal(t, 3, due)
+	assert.Equal(t, lastActivity.Add(144*time.Hour), next)
+}
+
+func TestWitherDueResumesFromSchedule(t *testing.T) {
+	s := DefaultTreeSettings()
+	lastActivity := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := lastActivity.Add(96 * time.Hour)
+
+	due, _ := s.WitherDue(lastActivity, &scheduled, lastActivity.Add(90*time.Hour))
+	assert.Equal(t, 0, due)
+
+	due, next := s.WitherDue(lastActivity, &scheduled, lastActivity.Add(100*time.Hour))
+	assert.Equal(t, 1, due)
+	assert.Equal(t, lastActivity.Add(120*time.Hour), next)
+}
@@ -101,18 +101,28 @@ type VocabularyBatchRequest struct {
 }
 
 // SyncProgressRequest represents a sync request from offline client
+// When Atomic is set the whole request is applied in one transaction;
+// otherwise items sharing a Group are applied together and the rest individually.
 type SyncProgressRequest struct {
 	UserID       int64        `json:"user_id" binding:"required"`
 	DeviceID     string       `json:"device_id" binding:"required"`
 	SyncItems    []SyncItem   `json:"sync_items" binding:"required"`
 	LastSyncedAt *time.Time   `json:"last_synced_at,omitempty"`
+	Atomic       bool         `json:"atomic,omitempty"`
+	Platform     string       `json:"platform,omitempty"`
+	AppVersion   string       `json:"app_version,omitempty"`
+	PendingItems int          `json:"pending_items,omitempty"` // items still queued on the device after this request
 }
 
 // SyncItem represents a single item to sync
+// Data is decoded into a typed payload by DecodeSyncItem (see sync.go)
 type SyncItem struct {
-	Type      string                 `json:"type"` // lesson_complete, progress_update, vocabulary_practice
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
+	ID        string          `json:"id,omitempty"` // client queue ID, echoed back in results
+	Type      string          `json:"type"`         // lesson_complete, progress_update, vocabulary_practice, vocabulary_batch
+	Version   int             `json:"version,omitempty"`
+	Group     string          `json:"group,omitempty"` // client-defined all-or-nothing group
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
 }
 
 // JSONMap is a custom type for JSONB columns
@@ -155,6 +165,7 @@ type UserStats struct {
 	VocabularyMastered  int       `json:"vocabulary_mastered"`
 	VocabularyLearning  int       `json:"vocabulary_learning"`
 	LastStudiedAt       *time.Time `json:"last_studied_at,omitempty"`
+	LevelProgress
 }
 
 // WeeklyStats represents weekly learning statistics
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// Quest periods
+const (
+	QuestPeriodDaily  = "daily"
+	QuestPeriodWeekly = "weekly"
+)
+
+// Quest metrics, advanced by learning activity
+const (
+	QuestMetricLessonsCompleted   = "lessons_completed"
+	QuestMetricVocabularyReviewed = "vocabulary_reviewed"
+	QuestMetricVocabularyCorrect  = "vocabulary_correct"
+	QuestMetricHangulPracticed    = "hangul_practiced"
+	QuestMetricHangulCorrect      = "hangul_correct"
+)
+
+// QuestPeriods lists the periods quests are assigned for
+var QuestPeriods = []string{QuestPeriodDaily, QuestPeriodWeekly}
+
+// QuestTemplate is a quest in the rotation pool
+type QuestTemplate struct {
+	ID           int64  `json:"id"`
+	Code         string `json:"code"`
+	Period       string `json:"period"`
+	Metric       string `json:"metric"`
+	Target       int    `json:"target"`
+	RewardLemons int    `json:"reward_lemons"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+}
+
+// UserQuest is a template assigned to a user for one period
+type UserQuest struct {
+	ID           int64      `json:"id"`
+	TemplateID   int64      `json:"template_id"`
+	Code         string     `json:"code"`
+	Period       string     `json:"period"`
+	Metric       string     `json:"metric"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	Progress     int        `json:"progress"`
+	Target       int        `json:"target"`
+	RewardLemons int        `json:"reward_lemons"`
+	PeriodStart  time.Time  `json:"period_start"`
+	PeriodEnd    time.Time  `json:"period_end"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ClaimedAt    *time.Time `json:"claimed_at,omitempty"`
+	Claimable    bool       `json:"claimable"`
+}
+
+// QuestPeriodBounds returns the UTC period containing t: a calendar day for
+// daily quests, an ISO week starting Monday for weekly quests
+func QuestPeriodBounds(period string, t time.Time) (time.Time, time.Time) {
+	if period == QuestPeriodWeekly {
+		start := WeekStart(t)
+		return start, start.AddDate(0, 0, 7)
+	}
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day, day.AddDate(0, 0, 1)
+}
+
+// WeekStart returns midnight UTC of the Monday starting t's ISO week
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// SelectQuestTemplates picks up to n templates for a user's period. The
+// choice is a stable hash of (user, period start, template code), so it is
+// the same on every call within a period and rotates between periods.
+func SelectQuestTemplates(templates []QuestTemplate, userID int64, periodStart time.Time, n int) []QuestTemplate {
+	type ranked struct {
+		template QuestTemplate
+		rank     uint64
+	}
+
+	pool := make([]ranked, len(templates))
+	for i, t := range templates {
+		h := fnv.New64a()
+		var buf [16]byte
+		binary.BigEndian.PutUint64(buf[:8], uint64(userID))
+		binary.BigEndian.PutUint64(buf[8:], uint64(periodStart.Unix()))
+		h.Write(buf[:])
+		h.Write([]byte(t.Code))
+		pool[i] = ranked{template: t, rank: h.Sum64()}
+	}
+
+	sort.Slice(pool, func(i, j int) bool {
+		if pool[i].rank != pool[j].rank {
+			return pool[i].rank < pool[j].rank
+		}
+		return pool[i].template.Code < pool[j].template.Code
+	})
+
+	if n > len(pool) {
+		n = len(pool)
+	}
+	selected := make([]QuestTemplate, 0, n)
+	for _, p := range pool[:n] {
+		selected = append(selected, p.template)
+	}
+	return selected
+}
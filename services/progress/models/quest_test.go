@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuestPeriodBounds(t *testing.T) {
+	// Thursday afternoon
+	at := time.Date(2026, 3, 5, 15, 30, 0, 0, time.UTC)
+
+	start, end := QuestPeriodBounds(QuestPeriodDaily, at)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), end)
+
+	start, end = QuestPeriodBounds(QuestPeriodWeekly, at)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), end)
+
+	// Sunday belongs to the week that started the previous Monday
+	start, _ = QuestPeriodBounds(QuestPeriodWeekly, time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), start)
+}
+
+func TestSelectQuestTemplates(t *testing.T) {
+	templates := []QuestTemplate{
+		{Code: "a"}, {Code: "b"}, {Code: "c"}, {Code: "d"}, {Code: "e"}, {Code: "f"},
+	}
+	day := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	first := SelectQuestTemplates(templates, 42, day, 3)
+	assert.Len(t, first, 3)
+	assert.Equal(t, first, SelectQuestTemplates(templates, 42, day, 3))
+
+	// Order of the pool does not matter
+	reversed := make([]QuestTemplate, len(templates))
+	for i, tmpl := range templates {
+		reversed[len(templates)-1-i] = tmpl
+	}
+	assert.Equal(t, first, SelectQuestTemplates(reversed, 42, day, 3))
+
+	// The rotation changes over a week of days
+	rotated := false
+	for i := 1; i <= 7; i++ {
+		if !assert.ObjectsAreEqual(first, SelectQuestTemplates(templates, 42, day.AddDate(0, 0, i), 3)) {
+			rotated = true
+		}
+	}
+	assert.True(t, rotated)
+
+	assert.Len(t, SelectQuestTemplates(templates[:2], 42, day, 3), 2)
+}
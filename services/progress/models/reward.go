@@ -0,0 +1,112 @@
+package models
+
+import "time"
+
+// Reward rule types
+const (
+	RewardTypeLesson = "lesson"
+	RewardTypeBoss   = "boss"
+)
+
+// Lesson completion kinds a rule applies to
+const (
+	CompletionFirst  = "first"
+	CompletionReplay = "replay"
+	CompletionAny    = "any"
+)
+
+// RewardRule is one row of the server-side reward table.
+// The matching rule with the highest MinScore wins; for boss rules a rule
+// targeting the exact level/week beats a level-only rule, which beats a
+// global one.
+type RewardRule struct {
+	ID          int64     `json:"id"`
+	RewardType  string    `json:"reward_type"`
+	Completion  string    `json:"completion"`
+	Level       *int      `json:"level,omitempty"`
+	Week        *int      `json:"week,omitempty"`
+	MinScore    int       `json:"min_score"`
+	Lemons      int       `json:"lemons"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LessonRewardResult is the outcome of a server-computed lesson reward
+type LessonRewardResult struct {
+	LemonsEarned    int  `json:"lemons_earned"`   // best reward recorded for the lesson
+	LemonsCredited  int  `json:"lemons_credited"` // lemons added to the balance by this call
+	FirstCompletion bool `json:"first_completion"`
+}
+
+// BossQuizResult is the outcome of a server-computed boss quiz reward
+type BossQuizResult struct {
+	Passed           bool `json:"passed"`
+	AlreadyCompleted bool `json:"already_completed"`
+	BonusLemons      int  `json:"bonus_lemons"`
+}
+
+// ResolveLessonReward returns the lemons a lesson completion is worth.
+// Returns 0 if no rule matches.
+func ResolveLessonReward(rules []RewardRule, quizScore int, firstCompletion bool) int {
+	completion := CompletionReplay
+	if firstCompletion {
+		completion = CompletionFirst
+	}
+
+	var best *RewardRule
+	for i := range rules {
+		rule := &rules[i]
+		if rule.RewardType != RewardTypeLesson || quizScore < rule.MinScore {
+			continue
+		}
+		if rule.Completion != CompletionAny && rule.Completion != completion {
+			continue
+		}
+		if best == nil || rule.MinScore > best.MinScore {
+			best = rule
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+	return best.Lemons
+}
+
+// ResolveBossReward returns the bonus lemons for a boss quiz and whether
+// the score passed any rule
+func ResolveBossReward(rules []RewardRule, level, week, score int) (int, bool) {
+	var best *RewardRule
+	bestSpecificity := -1
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.RewardType != RewardTypeBoss || score < rule.MinScore {
+			continue
+		}
+		if rule.Level != nil && *rule.Level != level {
+			continue
+		}
+		if rule.Week != nil && (rule.Level == nil || *rule.Week != week) {
+			continue
+		}
+
+		specificity := 0
+		if rule.Level != nil {
+			specificity++
+			if rule.Week != nil {
+				specificity++
+			}
+		}
+
+		if specificity > bestSpecificity || (specificity == bestSpecificity && rule.MinScore > best.MinScore) {
+			best = rule
+			bestSpecificity = specificity
+		}
+	}
+
+	if best == nil {
+		return 0, false
+	}
+	return best.Lemons, true
+}
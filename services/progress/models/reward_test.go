@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestResolveLessonReward(t *testing.T) {
+	rules := []RewardRule{
+		{RewardType: RewardTypeLesson, Completion: CompletionAny, MinScore: 0, Lemons: 1},
+		{RewardType: RewardTypeLesson, Completion: CompletionAny, MinScore: 80, Lemons: 2},
+		{RewardType: RewardTypeLesson, Completion: CompletionFirst, MinScore: 95, Lemons: 3},
+		{RewardType: RewardTypeBoss, Completion: CompletionAny, MinScore: 0, Lemons: 50},
+	}
+
+	assert.Equal(t, 1, ResolveLessonReward(rules, 10, true))
+	assert.Equal(t, 2, ResolveLessonReward(rules, 80, true))
+	assert.Equal(t, 3, ResolveLessonReward(rules, 100, true))
+	// The 3-lemon tier only applies to first completions
+	assert.Equal(t, 2, ResolveLessonReward(rules, 100, false))
+	assert.Equal(t, 0, ResolveLessonReward(nil, 100, true))
+}
+
+func TestResolveBossReward(t *testing.T) {
+	rules := []RewardRule{
+		{RewardType: RewardTypeBoss, Completion: CompletionAny, MinScore: 70, Lemons: 5},
+		{RewardType: RewardTypeBoss, Completion: CompletionAny, Level: intPtr(2), MinScore: 70, Lemons: 8},
+		{RewardType: RewardTypeBoss, Completion: CompletionAny, Level: intPtr(2), Week: intPtr(4), MinScore: 80, Lemons: 12},
+	}
+
+	lemons, passed := ResolveBossReward(rules, 1, 1, 69)
+	assert.False(t, passed)
+	assert.Equal(t, 0, lemons)
+
+	lemons, passed = ResolveBossReward(rules, 1, 1, 70)
+	assert.True(t, passed)
+	assert.Equal(t, 5, lemons)
+
+	lemons, _ = ResolveBossReward(rules, 2, 1, 90)
+	assert.Equal(t, 8, lemons)
+
+	lemons, _ = ResolveBossReward(rules, 2, 4, 90)
+	assert.Equal(t, 12, lemons)
+
+	// Below the week rule's threshold the level rule still applies
+	lemons, _ = ResolveBossReward(rules, 2, 4, 75)
+	assert.Equal(t, 8, lemons)
+}
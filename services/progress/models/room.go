@@ -0,0 +1,298 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ================================================================
+// ROOM LAYOUT
+// ================================================================
+// Furniture positions are stored as 0-1 ratios of the room, which is how
+// the app places them. For validation a position is the centre of the
+// item's footprint on a RoomGridWidth x RoomGridDepth floor grid. Items on
+// the same layer may not overlap; an item above the floor must be
+// stackable and rest entirely on a surface item one layer below.
+// Footprints and stacking come from the item's metadata:
+//
+//	{"footprint": {"w": 2, "h": 1}, "surface": true, "stackable": false}
+// ================================================================
+
+// Room limits
+const (
+	RoomGridWidth    = 10
+	RoomGridDepth    = 10
+	MaxRoomLayer     = 2
+	MaxRoomFurniture = 40
+	MaxRooms         = 5
+	DefaultRoomName  = "My Room"
+)
+
+// Room layout error codes (machine-readable)
+const (
+	RoomErrTooMany       = "too_many_items"
+	RoomErrNotOwned      = "not_owned"
+	RoomErrDuplicate     = "duplicate_item"
+	RoomErrInvalidValue  = "invalid_value"
+	RoomErrOutOfBounds   = "out_of_bounds"
+	RoomErrCollision     = "collision"
+	RoomErrNotStackable  = "not_stackable"
+	RoomErrNoSurface     = "no_surface"
+	RoomErrInvalidLayer  = "invalid_layer"
+	RoomErrInvalidRotate = "invalid_rotation"
+)
+
+// Room visibility levels: who may visit a user's active room
+const (
+	RoomVisibilityPublic  = "public"  // anyone who is not blocked
+	RoomVisibilityFriends = "friends" // friends only
+	RoomVisibilityPrivate = "private" // the owner only
+)
+
+// IsRoomVisibility reports whether v is a known visibility level
+func IsRoomVisibility(v string) bool {
+	switch v {
+	case RoomVisibilityPublic, RoomVisibilityFriends, RoomVisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// RoomVisibleTo reports whether a room with the given visibility may be
+// seen by a visitor (blocks are checked separately)
+func RoomVisibleTo(visibility string, isOwner, isFriend bool) bool {
+	if isOwner {
+		return true
+	}
+	switch visibility {
+	case RoomVisibilityPublic:
+		return true
+	case RoomVisibilityFriends:
+		return isFriend
+	}
+	return false
+}
+
+// Room is one of a user's rooms. Visitors see the active room. LikedByMe is
+// whether the requesting user likes it.
+type Room struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	Name           string    `json:"name"`
+	IsActive       bool      `json:"is_active"`
+	Visibility     string    `json:"visibility"`
+	FurnitureCount int       `json:"furniture_count"`
+	VisitCount     int       `json:"visit_count"`
+	LikeCount      int       `json:"like_count"`
+	LikedByMe      bool      `json:"liked_by_me"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PopularRoom is an entry of the most liked rooms of the week
+type PopularRoom struct {
+	RoomID      int64       `json:"room_id"`
+	Name        string      `json:"name"`
+	Owner       UserSummary `json:"owner"`
+	WeeklyLikes int         `json:"weekly_likes"`
+	LikeCount   int         `json:"like_count"`
+	VisitCount  int         `json:"visit_count"`
+}
+
+// RoomLikeSettings are the owner rewards for room likes
+type RoomLikeSettings struct {
+	RewardLemons  int // lemons per like
+	DailyLemonCap int // reward lemons per owner per UTC day
+}
+
+// Reward returns the lemons for one more like, given what the owner has
+// already earned from likes today
+func (s RoomLikeSettings) Reward(earnedToday int) int {
+	reward := s.RewardLemons
+	if left := s.DailyLemonCap - earnedToday; reward > left {
+		reward = left
+	}
+	if reward < 0 {
+		return 0
+	}
+	return reward
+}
+
+// FurniturePlacement is one item placed in a room. Rotation is clockwise in
+// degrees; layer 0 is the floor.
+type FurniturePlacement struct {
+	ItemID    int64   `json:"item_id"`
+	PositionX float64 `json:"position_x"`
+	PositionY float64 `json:"position_y"`
+	Rotation  int     `json:"rotation"`
+	Layer     int     `json:"layer"`
+}
+
+// RoomFurniture is a placed item with its catalog details
+type RoomFurniture struct {
+	ID        int64   `json:"id"`
+	ItemID    int64   `json:"item_id"`
+	Name      string  `json:"name"`
+	AssetKey  string  `json:"asset_key"`
+	AssetType string  `json:"asset_type"`
+	PositionX float64 `json:"position_x"`
+	PositionY float64 `json:"position_y"`
+	Rotation  int     `json:"rotation"`
+	Layer     int     `json:"layer"`
+}
+
+// FurnitureSpec is the placement metadata of a furniture item
+type FurnitureSpec struct {
+	Width     int  // footprint columns at rotation 0
+	Depth     int  // footprint rows at rotation 0
+	Surface   bool // stackable items can be put on it
+	Stackable bool // can be put on a surface
+}
+
+// ParseFurnitureSpec reads a furniture item's metadata. Missing or invalid
+// fields fall back to a 1x1 item that is neither a surface nor stackable.
+func ParseFurnitureSpec(metadata []byte) FurnitureSpec {
+	var m struct {
+		Footprint struct {
+			W int `json:"w"`
+			H int `json:"h"`
+		} `json:"footprint"`
+		Surface   bool `json:"surface"`
+		Stackable bool `json:"stackable"`
+	}
+	// Malformed metadata gives the defaults rather than blocking saves
+	_ = json.Unmarshal(metadata, &m)
+
+	spec := FurnitureSpec{Width: 1, Depth: 1, Surface: m.Surface, Stackable: m.Stackable}
+	if m.Footprint.W > 0 && m.Footprint.W <= RoomGridWidth {
+		spec.Width = m.Footprint.W
+	}
+	if m.Footprint.H > 0 && m.Footprint.H <= RoomGridDepth {
+		spec.Depth = m.Footprint.H
+	}
+	return spec
+}
+
+// RoomCells is the grid rectangle an item covers
+type RoomCells struct {
+	Col, Row, Width, Depth int
+}
+
+// Contains reports whether o lies entirely inside c
+func (c RoomCells) Contains(o RoomCells) bool {
+	return o.Col >= c.Col && o.Row >= c.Row &&
+		o.Col+o.Width <= c.Col+c.Width && o.Row+o.Depth <= c.Row+c.Depth
+}
+
+// Overlaps reports whether c and o share a cell
+func (c RoomCells) Overlaps(o RoomCells) bool {
+	return c.Col < o.Col+o.Width && o.Col < c.Col+c.Width &&
+		c.Row < o.Row+o.Depth && o.Row < c.Row+c.Depth
+}
+
+// Cells returns the grid rectangle covered by the placement. A quarter turn
+// swaps the footprint's width and depth.
+func (p FurniturePlacement) Cells(spec FurnitureSpec) RoomCells {
+	w, d := spec.Width, spec.Depth
+	if p.Rotation == 90 || p.Rotation == 270 {
+		w, d = d, w
+	}
+	return RoomCells{
+		Col:   int(math.Round(p.PositionX*RoomGridWidth - float64(w)/2)),
+		Row:   int(math.Round(p.PositionY*RoomGridDepth - float64(d)/2)),
+		Width: w,
+		Depth: d,
+	}
+}
+
+// RoomLayoutError describes why a room layout was rejected. Index is the
+// offending placement, or -1 for the layout as a whole.
+type RoomLayoutError struct {
+	Index   int    `json:"index"`
+	ItemID  int64  `json:"item_id,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RoomLayoutError) Error() string {
+	if e.Index < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("furniture %d: %s", e.Index, e.Message)
+}
+
+func layoutError(i int, p FurniturePlacement, code, format string, args ...interface{}) *RoomLayoutError {
+	return &RoomLayoutError{Index: i, ItemID: p.ItemID, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidateRoomLayout checks a full room layout. specs holds the user's
+// owned furniture; placing anything else is an error. Each item can be
+// placed once per room.
+func ValidateRoomLayout(placements []FurniturePlacement, specs map[int64]FurnitureSpec) error {
+	if len(placements) > MaxRoomFurniture {
+		return &RoomLayoutError{
+			Index:   -1,
+			Code:    RoomErrTooMany,
+			Message: fmt.Sprintf("a room holds at most %d items", MaxRoomFurniture),
+		}
+	}
+
+	cells := make([]RoomCells, len(placements))
+	seen := map[int64]bool{}
+	for i, p := range placements {
+		spec, ok := specs[p.ItemID]
+		if !ok {
+			return layoutError(i, p, RoomErrNotOwned, "item %d is not owned furniture", p.ItemID)
+		}
+		if seen[p.ItemID] {
+			return layoutError(i, p, RoomErrDuplicate, "item %d is placed more than once", p.ItemID)
+		}
+		seen[p.ItemID] = true
+
+		if math.IsNaN(p.PositionX) || math.IsNaN(p.PositionY) ||
+			p.PositionX < 0 || p.PositionX > 1 || p.PositionY < 0 || p.PositionY > 1 {
+			return layoutError(i, p, RoomErrInvalidValue, "position must be between 0 and 1")
+		}
+		if p.Rotation != 0 && p.Rotation != 90 && p.Rotation != 180 && p.Rotation != 270 {
+			return layoutError(i, p, RoomErrInvalidRotate, "rotation must be 0, 90, 180 or 270")
+		}
+		if p.Layer < 0 || p.Layer > MaxRoomLayer {
+			return layoutError(i, p, RoomErrInvalidLayer, "layer must be between 0 and %d", MaxRoomLayer)
+		}
+		if p.Layer > 0 && !spec.Stackable {
+			return layoutError(i, p, RoomErrNotStackable, "item %d cannot be stacked", p.ItemID)
+		}
+
+		cells[i] = p.Cells(spec)
+		room := RoomCells{Width: RoomGridWidth, Depth: RoomGridDepth}
+		if !room.Contains(cells[i]) {
+			return layoutError(i, p, RoomErrOutOfBounds, "item %d does not fit inside the room", p.ItemID)
+		}
+	}
+
+	for i, p := range placements {
+		for j := 0; j < i; j++ {
+			if placements[j].Layer == p.Layer && cells[j].Overlaps(cells[i]) {
+				return layoutError(i, p, RoomErrCollision, "item %d overlaps item %d", p.ItemID, placements[j].ItemID)
+			}
+		}
+
+		if p.Layer == 0 {
+			continue
+		}
+		supported := false
+		for j, below := range placements {
+			if below.Layer == p.Layer-1 && specs[below.ItemID].Surface && cells[j].Contains(cells[i]) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return layoutError(i, p, RoomErrNoSurface, "item %d must rest on a surface", p.ItemID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFurnitureSpec(t *testing.T) {
+	assert.Equal(t, FurnitureSpec{Width: 2, Depth: 3, Surface: true},
+		ParseFurnitureSpec([]byte(`{"footprint": {"w": 2, "h": 3}, "surface": true}`)))
+
+	// Missing, oversized or malformed metadata falls back to 1x1
+	assert.Equal(t, FurnitureSpec{Width: 1, Depth: 1, Stackable: true},
+		ParseFurnitureSpec([]byte(`{"stackable": true}`)))
+	assert.Equal(t, FurnitureSpec{Width: 1, Depth: 1},
+		ParseFurnitureSpec([]byte(`{"footprint": {"w": 50, "h": -1}}`)))
+	assert.Equal(t, FurnitureSpec{Width: 1, Depth: 1}, ParseFurnitureSpec([]byte(`not json`)))
+	assert.Equal(t, FurnitureSpec{Width: 1, Depth: 1}, ParseFurnitureSpec(nil))
+}
+
+func TestFurniturePlacementCells(t *testing.T) {
+	bed := FurnitureSpec{Width: 2, Depth: 3}
+
+	p := FurniturePlacement{PositionX: 0.9, PositionY: 0.5}
+	assert.Equal(t, RoomCells{Col: 8, Row: 4, Width: 2, Depth: 3}, p.Cells(bed))
+
+	// A quarter turn swaps width and depth; a half turn does not
+	p.Rotation = 90
+	assert.Equal(t, RoomCells{Col: 8, Row: 4, Width: 3, Depth: 2}, p.Cells(bed))
+	p.Rotation = 180
+	assert.Equal(t, RoomCells{Col: 8, Row: 4, Width: 2, Depth: 3}, p.Cells(bed))
+}
+
+func TestValidateRoomLayout(t *testing.T) {
+	specs := map[int64]FurnitureSpec{
+		1: {Width: 2, Depth: 1, Surface: true},   // table
+		2: {Width: 1, Depth: 1, Stackable: true}, // lamp
+		3: {Width: 2, Depth: 3},                  // bed
+		4: {Width: 1, Depth: 1},                  // chair
+	}
+	table := FurniturePlacement{ItemID: 1, PositionX: 0.5, PositionY: 0.5}
+	lamp := FurniturePlacement{ItemID: 2, PositionX: 0.45, PositionY: 0.55, Layer: 1}
+	bed := FurniturePlacement{ItemID: 3, PositionX: 0.9, PositionY: 0.5}
+
+	assert.NoError(t, ValidateRoomLayout([]FurniturePlacement{table, lamp, bed}, specs))
+	// Order does not matter for stacking
+	assert.NoError(t, ValidateRoomLayout([]FurniturePlacement{lamp, bed, table}, specs))
+	assert.NoError(t, ValidateRoomLayout(nil, specs))
+
+	code := func(err error) string {
+		var layoutErr *RoomLayoutError
+		require.ErrorAs(t, err, &layoutErr)
+		return layoutErr.Code
+	}
+
+	// Unowned and duplicate items
+	assert.Equal(t, RoomErrNotOwned, code(ValidateRoomLayout([]FurniturePlacement{{ItemID: 9}}, specs)))
+	assert.Equal(t, RoomErrDuplicate, code(ValidateRoomLayout([]FurniturePlacement{table, table}, specs)))
+
+	// Bounds, rotation and layer values
+	off := bed
+	off.PositionX = 0.05
+	assert.Equal(t, RoomErrOutOfBounds, code(ValidateRoomLayout([]FurniturePlacement{off}, specs)))
+	turned := bed
+	turned.Rotation = 90
+	assert.Equal(t, RoomErrOutOfBounds, code(ValidateRoomLayout([]FurniturePlacement{turned}, specs)))
+	turned.Rotation = 45
+	assert.Equal(t, RoomErrInvalidRotate, code(ValidateRoomLayout([]FurniturePlacement{turned}, specs)))
+	outside := table
+	outside.PositionY = 1.5
+	assert.Equal(t, RoomErrInvalidValue, code(ValidateRoomLayout([]FurniturePlacement{outside}, specs)))
+	high := lamp
+	high.Layer = MaxRoomLayer + 1
+	assert.Equal(t, RoomErrInvalidLayer, code(ValidateRoomLayout([]FurniturePlacement{table, high}, specs)))
+
+	// Items on the same layer collide
+	chair := FurniturePlacement{ItemID: 4, PositionX: 0.55, PositionY: 0.55}
+	err := ValidateRoomLayout([]FurniturePlacement{table, chair}, specs)
+	assert.Equal(t, RoomErrCollision, code(err))
+	assert.EqualError(t, err, "furniture 1: item 4 overlaps item 1")
+
+	// Stacking needs a stackable item fully on a surface
+	onChair := FurniturePlacement{ItemID: 1, PositionX: 0.5, PositionY: 0.5, Layer: 1}
+	assert.Equal(t, RoomErrNotStackable, code(ValidateRoomLayout([]FurniturePlacement{chair, onChair}, specs)))
+	floating := lamp
+	floating.PositionX = 0.15
+	assert.Equal(t, RoomErrNoSurface, code(ValidateRoomLayout([]FurniturePlacement{table, floating}, specs)))
+	onBed := FurniturePlacement{ItemID: 2, PositionX: 0.9, PositionY: 0.5, Layer: 1}
+	assert.Equal(t, RoomErrNoSurface, code(ValidateRoomLayout([]FurniturePlacement{bed, onBed}, specs)))
+
+	// Too many items
+	many := make([]FurniturePlacement, MaxRoomFurniture+1)
+	err = ValidateRoomLayout(many, specs)
+	assert.Equal(t, RoomErrTooMany, code(err))
+	assert.Equal(t, -1, err.(*RoomLayoutError).Index)
+}
+
+func TestRoomVisibleTo(t *testing.T) {
+	assert.True(t, RoomVisibleTo(RoomVisibilityPublic, false, false))
+	assert.False(t, RoomVisibleTo(RoomVisibilityFriends, false, false))
+	assert.True(t, RoomVisibleTo(RoomVisibilityFriends, false, true))
+	assert.False(t, RoomVisibleTo(RoomVisibilityPrivate, false, true))
+	assert.True(t, RoomVisibleTo(RoomVisibilityPrivate, true, false))
+	assert.False(t, RoomVisibleTo("unknown", false, true))
+
+	assert.True(t, IsRoomVisibility(RoomVisibilityFriends))
+	assert.False(t, IsRoomVisibility(""))
+}
+
+func TestRoomLikeSettingsReward(t *testing.T) {
+	s := RoomLikeSettings{RewardLemons: 2, DailyLemonCap: 5}
+
+	assert.Equal(t, 2, s.Reward(0))
+	assert.Equal(t, 2, s.Reward(3))
+	// The last like of the day is cut to the cap
+	assert.Equal(t, 1, s.Reward(4))
+	assert.Equal(t, 0, s.Reward(5))
+	// A lowered cap never goes negative
+	assert.Equal(t, 0, s.Reward(9))
+	assert.Equal(t, 0, RoomLikeSettings{RewardLemons: 0, DailyLemonCap: 5}.Reward(0))
+}
+
+func TestRoomLikeIsLemonTransactionType(t *testing.T) {
+	assert.True(t, IsLemonTransactionType(LemonTxRoomLike))
+	assert.False(t, IsLemonTransactionType("refund"))
+}
@@ -0,0 +1,599 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ShopItemCategories are the character_items categories
+var ShopItemCategories = []string{
+	"body", "skin_color", "hair", "eyes", "eyebrows", "nose", "mouth",
+	"top", "bottom", "shoes", "hat", "accessory",
+	"pet", "wallpaper", "floor", "furniture",
+}
+
+// Catalogue limits and asset locations
+const (
+	MaxShopItemName     = 100
+	MaxShopAssetKey     = 200
+	MaxShopItemPrice    = 100000
+	BundledAssetPrefix  = "assets/"
+	UploadedAssetPrefix = "images/"
+)
+
+// Shop audit actions
+const (
+	ShopAuditCreate     = "create"
+	ShopAuditUpdate     = "update"
+	ShopAuditDeactivate = "deactivate"
+	ShopAuditReorder    = "reorder"
+	ShopAuditSaleCreate = "sale_create"
+	ShopAuditSaleEnd    = "sale_end"
+)
+
+var (
+	shopAssetTypes = map[string]bool{"svg": true, "png": true, "spritesheet": true}
+	shopRarities   = map[string]bool{"common": true, "rare": true, "epic": true, "legendary": true}
+)
+
+// IsShopItemCategory reports whether category is a catalogue category
+func IsShopItemCategory(category string) bool {
+	for _, c := range ShopItemCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// ShopItem is a character_items catalogue row as admins see it. The item is
+// on sale while it is active and inside its availability window.
+type ShopItem struct {
+	ID             int64           `json:"id"`
+	Category       string          `json:"category"`
+	Name           string          `json:"name"`
+	Description    *string         `json:"description"`
+	AssetKey       string          `json:"asset_key"`
+	AssetType      string          `json:"asset_type"`
+	IsBundled      bool            `json:"is_bundled"`
+	RenderOrder    int             `json:"render_order"`
+	SortOrder      int             `json:"sort_order"`
+	Price          int             `json:"price"`
+	Rarity         string          `json:"rarity"`
+	IsDefault      bool            `json:"is_default"`
+	IsActive       bool            `json:"is_active"`
+	Metadata       json.RawMessage `json:"metadata"`
+	AvailableFrom  *time.Time      `json:"available_from"`
+	AvailableUntil *time.Time      `json:"available_until"`
+	Stock          *int            `json:"stock"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// AvailableAt reports whether the item is on sale at t
+func (i ShopItem) AvailableAt(t time.Time) bool {
+	if !i.IsActive {
+		return false
+	}
+	if i.AvailableFrom != nil && t.Before(*i.AvailableFrom) {
+		return false
+	}
+	return i.AvailableUntil == nil || t.Before(*i.AvailableUntil)
+}
+
+// spritesheetKey returns the metadata's spritesheet_key, if any
+func (i ShopItem) spritesheetKey() string {
+	var meta struct {
+		SpritesheetKey string `json:"spritesheet_key"`
+	}
+	json.Unmarshal(i.Metadata, &meta)
+	return meta.SpritesheetKey
+}
+
+// MediaAssetKeys returns the uploaded (non-bundled) assets the item refers
+// to. These must exist in the media service.
+func (i ShopItem) MediaAssetKeys() []string {
+	keys := []string{}
+	for _, key := range []string{i.AssetKey, i.spritesheetKey()} {
+		if strings.HasPrefix(key, UploadedAssetPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ShopItemError describes why a catalogue item was rejected
+type ShopItemError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ShopItemError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+func shopItemError(field, format string, args ...interface{}) *ShopItemError {
+	return &ShopItemError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// validAssetKey checks an asset key's location: bundled assets live under
+// assets/, uploaded ones directly under images/ (as served by
+// /media/images/:key)
+func validAssetKey(key string, bundled bool) bool {
+	if strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return false
+	}
+	if bundled {
+		return len(key) > len(BundledAssetPrefix) && strings.HasPrefix(key, BundledAssetPrefix)
+	}
+	name := strings.TrimPrefix(key, UploadedAssetPrefix)
+	return name != key && name != "" && !strings.Contains(name, "/")
+}
+
+// ValidateShopItemUpdate checks an edit of an existing item. The category
+// is fixed: users may own or wear the item, and equipment slots are
+// checked against it.
+func ValidateShopItemUpdate(before, after ShopItem) error {
+	if after.Category != before.Category {
+		return shopItemError("category", "cannot be changed; create a new item instead")
+	}
+	return ValidateShopItem(after)
+}
+
+// ValidateShopItem checks a catalogue item before it is saved
+func ValidateShopItem(i ShopItem) error {
+	if !IsShopItemCategory(i.Category) {
+		return shopItemError("category", "unknown category %q", i.Category)
+	}
+	if n := utf8.RuneCountInString(i.Name); strings.TrimSpace(i.Name) == "" || n > MaxShopItemName {
+		return shopItemError("name", "must be 1-%d characters", MaxShopItemName)
+	}
+	if !shopAssetTypes[i.AssetType] {
+		return shopItemError("asset_type", "must be svg, png or spritesheet")
+	}
+	if len(i.AssetKey) > MaxShopAssetKey || !validAssetKey(i.AssetKey, i.IsBundled) {
+		if i.IsBundled {
+			return shopItemError("asset_key", "bundled assets must be under %s", BundledAssetPrefix)
+		}
+		return shopItemError("asset_key", "uploaded assets must be %s<file>", UploadedAssetPrefix)
+	}
+	if !shopRarities[i.Rarity] {
+		return shopItemError("rarity", "must be common, rare, epic or legendary")
+	}
+	if i.Price < 0 || i.Price > MaxShopItemPrice {
+		return shopItemError("price", "must be 0-%d", MaxShopItemPrice)
+	}
+	if i.RenderOrder < 0 {
+		return shopItemError("render_order", "must not be negative")
+	}
+	if i.SortOrder < 0 {
+		return shopItemError("sort_order", "must not be negative")
+	}
+	if i.Stock != nil && *i.Stock < 0 {
+		return shopItemError("stock", "must not be negative")
+	}
+	if i.IsDefault && !i.IsActive {
+		// Default items fill empty required slots of every character
+		return shopItemError("is_active", "default items cannot be deactivated")
+	}
+
+	trimmed := bytes.TrimSpace(i.Metadata)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return shopItemError("metadata", "must be a JSON object")
+	}
+	if key := i.spritesheetKey(); key != "" && !validAssetKey(key, strings.HasPrefix(key, BundledAssetPrefix)) {
+		return shopItemError("metadata", "invalid spritesheet_key %q", key)
+	}
+	if i.AssetType == "spritesheet" && i.spritesheetKey() == "" {
+		return shopItemError("metadata", "spritesheet items need a spritesheet_key")
+	}
+
+	if i.AvailableFrom != nil && i.AvailableUntil != nil && !i.AvailableUntil.After(*i.AvailableFrom) {
+		return shopItemError("available_until", "must be after available_from")
+	}
+	return nil
+}
+
+// OptionalTime is a nullable time in a partial update: Set is true when the
+// field was present, with Time nil for an explicit null
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is only called for fields
+// present in the input, including null ones.
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Time = nil
+		return nil
+	}
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	o.Time = &t
+	return nil
+}
+
+// OptionalInt is a nullable integer in a partial update, like OptionalTime
+type OptionalInt struct {
+	Set   bool
+	Value *int
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *OptionalInt) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var v int
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
+// ShopItemPatch is a partial update of a catalogue item. Nil fields are
+// left unchanged.
+type ShopItemPatch struct {
+	Category       *string         `json:"category"`
+	Name           *string         `json:"name"`
+	Description    *string         `json:"description"`
+	AssetKey       *string         `json:"asset_key"`
+	AssetType      *string         `json:"asset_type"`
+	IsBundled      *bool           `json:"is_bundled"`
+	RenderOrder    *int            `json:"render_order"`
+	SortOrder      *int            `json:"sort_order"`
+	Price          *int            `json:"price"`
+	Rarity         *string         `json:"rarity"`
+	IsDefault      *bool           `json:"is_default"`
+	IsActive       *bool           `json:"is_active"`
+	Metadata       json.RawMessage `json:"metadata"`
+	AvailableFrom  OptionalTime    `json:"available_from"`
+	AvailableUntil OptionalTime    `json:"available_until"`
+	Stock          OptionalInt     `json:"stock"`
+}
+
+// Apply returns the item with the patch applied. An empty description
+// clears it.
+func (p ShopItemPatch) Apply(i ShopItem) ShopItem {
+	if p.Category != nil {
+		i.Category = *p.Category
+	}
+	if p.Name != nil {
+		i.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.Description != nil {
+		if *p.Description == "" {
+			i.Description = nil
+		} else {
+			d := *p.Description
+			i.Description = &d
+		}
+	}
+	if p.AssetKey != nil {
+		i.AssetKey = *p.AssetKey
+	}
+	if p.AssetType != nil {
+		i.AssetType = *p.AssetType
+	}
+	if p.IsBundled != nil {
+		i.IsBundled = *p.IsBundled
+	}
+	if p.RenderOrder != nil {
+		i.RenderOrder = *p.RenderOrder
+	}
+	if p.SortOrder != nil {
+		i.SortOrder = *p.SortOrder
+	}
+	if p.Price != nil {
+		i.Price = *p.Price
+	}
+	if p.Rarity != nil {
+		i.Rarity = *p.Rarity
+	}
+	if p.IsDefault != nil {
+		i.IsDefault = *p.IsDefault
+	}
+	if p.IsActive != nil {
+		i.IsActive = *p.IsActive
+	}
+	if p.Metadata != nil {
+		i.Metadata = p.Metadata
+	}
+	if p.AvailableFrom.Set {
+		i.AvailableFrom = p.AvailableFrom.Time
+	}
+	if p.AvailableUntil.Set {
+		i.AvailableUntil = p.AvailableUntil.Time
+	}
+	if p.Stock.Set {
+		i.Stock = p.Stock.Value
+	}
+	return i
+}
+
+// AuditChange is the old and new value of a changed field
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// DiffShopItems returns the editable fields that differ between two
+// versions of an item, keyed by their JSON name
+func DiffShopItems(before, after ShopItem) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	add := func(field string, from, to interface{}, changed bool) {
+		if changed {
+			changes[field] = AuditChange{Old: from, New: to}
+		}
+	}
+
+	add("category", before.Category, after.Category, before.Category != after.Category)
+	add("name", before.Name, after.Name, before.Name != after.Name)
+	add("description", before.Description, after.Description, !equalStringPtr(before.Description, after.Description))
+	add("asset_key", before.AssetKey, after.AssetKey, before.AssetKey != after.AssetKey)
+	add("asset_type", before.AssetType, after.AssetType, before.AssetType != after.AssetType)
+	add("is_bundled", before.IsBundled, after.IsBundled, before.IsBundled != after.IsBundled)
+	add("render_order", before.RenderOrder, after.RenderOrder, before.RenderOrder != after.RenderOrder)
+	add("sort_order", before.SortOrder, after.SortOrder, before.SortOrder != after.SortOrder)
+	add("price", before.Price, after.Price, before.Price != after.Price)
+	add("rarity", before.Rarity, after.Rarity, before.Rarity != after.Rarity)
+	add("is_default", before.IsDefault, after.IsDefault, before.IsDefault != after.IsDefault)
+	add("is_active", before.IsActive, after.IsActive, before.IsActive != after.IsActive)
+	add("metadata", before.Metadata, after.Metadata, !equalJSON(before.Metadata, after.Metadata))
+	add("available_from", before.AvailableFrom, after.AvailableFrom, !equalTimePtr(before.AvailableFrom, after.AvailableFrom))
+	add("available_until", before.AvailableUntil, after.AvailableUntil, !equalTimePtr(before.AvailableUntil, after.AvailableUntil))
+	add("stock", before.Stock, after.Stock, !equalIntPtr(before.Stock, after.Stock))
+	return changes
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// equalJSON compares two JSON documents ignoring formatting and key order
+func equalJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// ShopAuditEntry is one recorded catalogue change
+type ShopAuditEntry struct {
+	ID        int64                  `json:"id"`
+	ItemID    *int64                 `json:"item_id"`
+	ItemName  string                 `json:"item_name,omitempty"`
+	AdminID   *int64                 `json:"admin_id"`
+	AdminName string                 `json:"admin_name,omitempty"`
+	Action    string                 `json:"action"`
+	Changes   map[string]AuditChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// ================================================================
+// DAILY SHOP
+// ================================================================
+
+// Featured selection scopes
+const (
+	ShopFeaturedGlobal = "global"
+	ShopFeaturedUser   = "user"
+)
+
+// Sale limits
+const (
+	MinSaleDiscount = 1
+	MaxSaleDiscount = 90
+)
+
+// ShopSettings configure the daily featured selection
+type ShopSettings struct {
+	FeaturedCount int
+	FeaturedScope string
+}
+
+// FeaturedSeed returns the seed of a user's featured selection: 0 when
+// everyone sees the same items
+func (s ShopSettings) FeaturedSeed(userID int64) int64 {
+	if s.FeaturedScope == ShopFeaturedUser {
+		return userID
+	}
+	return 0
+}
+
+// ShopDay returns the start of t's UTC day. The featured selection changes
+// at the end of it.
+func ShopDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// featuredScore ranks an item for a day and seed. Each item's score is
+// independent of the others, so adding or removing an item changes the
+// selection by at most that item.
+func featuredScore(day time.Time, seed, itemID int64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(day.Format("2006-01-02")))
+	h.Write([]byte{':'})
+	h.Write([]byte(strconv.FormatInt(seed, 10)))
+	h.Write([]byte{':'})
+	h.Write([]byte(strconv.FormatInt(itemID, 10)))
+	return h.Sum64()
+}
+
+// FeaturedItemIDs picks count items of pool for the UTC day of t. The same
+// pool, day and seed always give the same items, in the same order.
+func FeaturedItemIDs(pool []int64, t time.Time, seed int64, count int) []int64 {
+	day := ShopDay(t)
+	ids := append([]int64{}, pool...)
+	scores := make(map[int64]uint64, len(ids))
+	for _, id := range ids {
+		scores[id] = featuredScore(day, seed, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		if scores[ids[a]] != scores[ids[b]] {
+			return scores[ids[a]] < scores[ids[b]]
+		}
+		return ids[a] < ids[b]
+	})
+	if count < 0 {
+		count = 0
+	}
+	if len(ids) > count {
+		ids = ids[:count]
+	}
+	return ids
+}
+
+// ShopSale is a time-boxed discount on an item
+type ShopSale struct {
+	ID              int64     `json:"id"`
+	ItemID          int64     `json:"item_id"`
+	ItemName        string    `json:"item_name,omitempty"`
+	DiscountPercent int       `json:"discount_percent"`
+	StartsAt        time.Time `json:"starts_at"`
+	EndsAt          time.Time `json:"ends_at"`
+	CreatedBy       *int64    `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ActiveAt reports whether the sale applies at t
+func (s ShopSale) ActiveAt(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// ValidateShopSale checks a new sale
+func ValidateShopSale(s ShopSale, now time.Time) error {
+	if s.DiscountPercent < MinSaleDiscount || s.DiscountPercent > MaxSaleDiscount {
+		return shopItemError("discount_percent", "must be %d-%d", MinSaleDiscount, MaxSaleDiscount)
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return shopItemError("ends_at", "must be after starts_at")
+	}
+	if !s.EndsAt.After(now) {
+		return shopItemError("ends_at", "must be in the future")
+	}
+	return nil
+}
+
+// SalePrice returns price with discountPercent off, rounded up so a
+// discount never makes a paid item free
+func SalePrice(price, discountPercent int) int {
+	if discountPercent <= 0 {
+		return price
+	}
+	if discountPercent > 100 {
+		discountPercent = 100
+	}
+	return (price*(100-discountPercent) + 99) / 100
+}
+
+// ShopOffer is an item as sold right now: Price is what checkout charges,
+// OriginalPrice the catalogue price. ExpiresAt is when this offer ends:
+// the earlier of the sale ending and the item leaving the shop. Stock is
+// omitted for unlimited items.
+type ShopOffer struct {
+	ID              int64           `json:"id"`
+	Category        string          `json:"category"`
+	Name            string          `json:"name"`
+	Description     *string         `json:"description,omitempty"`
+	AssetKey        string          `json:"asset_key"`
+	AssetType       string          `json:"asset_type"`
+	IsBundled       bool            `json:"is_bundled"`
+	RenderOrder     int             `json:"render_order"`
+	Rarity          string          `json:"rarity"`
+	IsDefault       bool            `json:"is_default"`
+	Metadata        json.RawMessage `json:"metadata"`
+	Price           int             `json:"price"`
+	OriginalPrice   int             `json:"original_price"`
+	DiscountPercent int             `json:"discount_percent"`
+	SaleEndsAt      *time.Time      `json:"sale_ends_at,omitempty"`
+	AvailableUntil  *time.Time      `json:"available_until,omitempty"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`
+	Stock           *int            `json:"stock,omitempty"`
+}
+
+// NewShopOffer prices an item with its current sale (nil for none)
+func NewShopOffer(i ShopItem, sale *ShopSale) ShopOffer {
+	o := ShopOffer{
+		ID:             i.ID,
+		Category:       i.Category,
+		Name:           i.Name,
+		Description:    i.Description,
+		AssetKey:       i.AssetKey,
+		AssetType:      i.AssetType,
+		IsBundled:      i.IsBundled,
+		RenderOrder:    i.RenderOrder,
+		Rarity:         i.Rarity,
+		IsDefault:      i.IsDefault,
+		Metadata:       i.Metadata,
+		Price:          i.Price,
+		OriginalPrice:  i.Price,
+		AvailableUntil: i.AvailableUntil,
+		ExpiresAt:      i.AvailableUntil,
+		Stock:          i.Stock,
+	}
+	if sale != nil {
+		o.Price = SalePrice(i.Price, sale.DiscountPercent)
+		o.DiscountPercent = sale.DiscountPercent
+		ends := sale.EndsAt
+		o.SaleEndsAt = &ends
+		if o.ExpiresAt == nil || ends.Before(*o.ExpiresAt) {
+			o.ExpiresAt = &ends
+		}
+	}
+	return o
+}
+
+// SoldOut reports whether a limited item has no stock left
+func (o ShopOffer) SoldOut() bool {
+	return o.Stock != nil && *o.Stock <= 0
+}
+
+// Featurable reports whether an offer may be picked for the featured
+// selection: bought items only, and not sold out
+func (o ShopOffer) Featurable() bool {
+	return !o.IsDefault && o.OriginalPrice > 0 && !o.SoldOut()
+}
+
+// ShopListing is the shop as one user sees it
+type ShopListing struct {
+	Items             []ShopOffer `json:"items"`
+	Featured          []ShopOffer `json:"featured"`
+	FeaturedScope     string      `json:"featured_scope"`
+	FeaturedExpiresAt time.Time   `json:"featured_expires_at"`
+}
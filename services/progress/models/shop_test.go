@@ -0,0 +1,265 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validShopItem() ShopItem {
+	return ShopItem{
+		Category:  "hat",
+		Name:      "Crown",
+		AssetKey:  "images/sprite_hat_crown.png",
+		AssetType: "png",
+		Rarity:    "epic",
+		Price:     500,
+		IsActive:  true,
+		Metadata:  json.RawMessage(`{}`),
+	}
+}
+
+func TestShopItemAvailableAt(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	from, until := now.Add(-time.Hour), now.Add(time.Hour)
+
+	item := validShopItem()
+	assert.True(t, item.AvailableAt(now))
+
+	item.AvailableFrom, item.AvailableUntil = &from, &until
+	assert.True(t, item.AvailableAt(now))
+	assert.True(t, item.AvailableAt(from), "window start is inclusive")
+	assert.False(t, item.AvailableAt(until), "window end is exclusive")
+	assert.False(t, item.AvailableAt(from.Add(-time.Second)))
+
+	item.IsActive = false
+	assert.False(t, item.AvailableAt(now))
+}
+
+func TestValidateShopItemUpdate(t *testing.T) {
+	before := validShopItem()
+	after := before
+	after.Price = 300
+	require.NoError(t, ValidateShopItemUpdate(before, after))
+
+	after.Category = "pet"
+	err := ValidateShopItemUpdate(before, after)
+	var itemErr *ShopItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, "category", itemErr.Field)
+}
+
+func TestValidateShopItem(t *testing.T) {
+	require.NoError(t, ValidateShopItem(validShopItem()))
+
+	bundled := validShopItem()
+	bundled.IsBundled = true
+	bundled.AssetKey = "assets/character/hat/crown.svg"
+	assert.NoError(t, ValidateShopItem(bundled))
+
+	cases := map[string]func(i *ShopItem){
+		"category":        func(i *ShopItem) { i.Category = "cape" },
+		"name":            func(i *ShopItem) { i.Name = "  " },
+		"asset_type":      func(i *ShopItem) { i.AssetType = "gif" },
+		"rarity":          func(i *ShopItem) { i.Rarity = "mythic" },
+		"price":           func(i *ShopItem) { i.Price = -1 },
+		"metadata":        func(i *ShopItem) { i.Metadata = json.RawMessage(`[1]`) },
+		"is_active":       func(i *ShopItem) { i.IsDefault, i.IsActive = true, false },
+		"available_until": func(i *ShopItem) { now := time.Now(); i.AvailableFrom, i.AvailableUntil = &now, &now },
+	}
+	for field, mutate := range cases {
+		item := validShopItem()
+		mutate(&item)
+		var itemErr *ShopItemError
+		require.ErrorAs(t, ValidateShopItem(item), &itemErr, field)
+		assert.Equal(t, field, itemErr.Field)
+	}
+
+	// Uploaded assets sit directly under images/; bundled ones under assets/
+	for _, key := range []string{"images/", "images/a/b.png", "sprite.png", "images/../secret.png", "assets/hat.png"} {
+		item := validShopItem()
+		item.AssetKey = key
+		assert.Error(t, ValidateShopItem(item), key)
+	}
+	assert.Empty(t, bundled.MediaAssetKeys())
+	bundled.AssetKey = "images/crown.png"
+	assert.Error(t, ValidateShopItem(bundled))
+
+	sprite := validShopItem()
+	sprite.AssetType = "spritesheet"
+	assert.Error(t, ValidateShopItem(sprite))
+	sprite.Metadata = json.RawMessage(`{"spritesheet_key": "images/crown_sheet.png", "frameWidth": 32}`)
+	assert.NoError(t, ValidateShopItem(sprite))
+	assert.Equal(t, []string{"images/sprite_hat_crown.png", "images/crown_sheet.png"}, sprite.MediaAssetKeys())
+}
+
+func TestShopItemPatch(t *testing.T) {
+	until := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	item := validShopItem()
+	item.AvailableUntil = &until
+
+	var patch ShopItemPatch
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 300, "name": " Gold Crown ", "available_until": null}`), &patch))
+	assert.False(t, patch.AvailableFrom.Set)
+
+	next := patch.Apply(item)
+	assert.Equal(t, 300, next.Price)
+	assert.Equal(t, "Gold Crown", next.Name)
+	assert.Nil(t, next.AvailableUntil)
+	assert.Equal(t, "epic", next.Rarity)
+
+	changes := DiffShopItems(item, next)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, AuditChange{Old: 500, New: 300}, changes["price"])
+	assert.Contains(t, changes, "available_until")
+
+	// Reformatted metadata is not a change
+	next.Metadata = json.RawMessage(`{ }`)
+	assert.NotContains(t, DiffShopItems(item, next), "metadata")
+}
+
+func TestShopItemPatchStock(t *testing.T) {
+	item := validShopItem()
+
+	var patch ShopItemPatch
+	require.NoError(t, json.Unmarshal([]byte(`{"stock": 20}`), &patch))
+	limited := patch.Apply(item)
+	require.NotNil(t, limited.Stock)
+	assert.Equal(t, 20, *limited.Stock)
+	assert.Contains(t, DiffShopItems(item, limited), "stock")
+
+	// null makes the item unlimited again; omitting stock leaves it alone
+	require.NoError(t, json.Unmarshal([]byte(`{"stock": null}`), &patch))
+	assert.Nil(t, patch.Apply(limited).Stock)
+	assert.Equal(t, limited.Stock, ShopItemPatch{}.Apply(limited).Stock)
+
+	negative := -1
+	limited.Stock = &negative
+	assert.Error(t, ValidateShopItem(limited))
+}
+
+func TestFeaturedItemIDs(t *testing.T) {
+	pool := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	morning := time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC)
+	evening := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	today := FeaturedItemIDs(pool, morning, 0, 4)
+	require.Len(t, today, 4)
+	assert.Equal(t, today, FeaturedItemIDs(pool, evening, 0, 4), "same selection all day")
+
+	// The pool's order does not matter
+	reversed := []int64{12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	assert.Equal(t, today, FeaturedItemIDs(reversed, morning, 0, 4))
+
+	// Removing an item that is not featured keeps the selection
+	for _, id := range pool {
+		if !containsID(today, id) {
+			assert.Equal(t, today, FeaturedItemIDs(removeID(pool, id), morning, 0, 4))
+			break
+		}
+	}
+
+	changed := false
+	for day := 1; day <= 7 && !changed; day++ {
+		changed = !assert.ObjectsAreEqual(today, FeaturedItemIDs(pool, morning.AddDate(0, 0, day), 0, 4))
+	}
+	assert.True(t, changed, "selection rotates between days")
+
+	changed = false
+	for seed := int64(1); seed <= 7 && !changed; seed++ {
+		changed = !assert.ObjectsAreEqual(today, FeaturedItemIDs(pool, morning, seed, 4))
+	}
+	assert.True(t, changed, "per-user seeds give different selections")
+
+	assert.Len(t, FeaturedItemIDs(pool[:2], morning, 0, 4), 2)
+	assert.Empty(t, FeaturedItemIDs(pool, morning, 0, 0))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, pool, "pool is not modified")
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeID(ids []int64, id int64) []int64 {
+	out := []int64{}
+	for _, x := range ids {
+		if x != id {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func TestShopSettingsFeaturedSeed(t *testing.T) {
+	assert.Equal(t, int64(0), ShopSettings{FeaturedScope: ShopFeaturedGlobal}.FeaturedSeed(42))
+	assert.Equal(t, int64(42), ShopSettings{FeaturedScope: ShopFeaturedUser}.FeaturedSeed(42))
+}
+
+func TestSalePrice(t *testing.T) {
+	assert.Equal(t, 500, SalePrice(500, 0))
+	assert.Equal(t, 400, SalePrice(500, 20))
+	assert.Equal(t, 67, SalePrice(133, 50), "rounded up")
+	assert.Equal(t, 1, SalePrice(1, 90), "a paid item never becomes free")
+	assert.Equal(t, 0, SalePrice(0, 50))
+}
+
+func TestNewShopOffer(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	item := validShopItem()
+
+	offer := NewShopOffer(item, nil)
+	assert.Equal(t, 500, offer.Price)
+	assert.Equal(t, 500, offer.OriginalPrice)
+	assert.Nil(t, offer.ExpiresAt)
+	assert.True(t, offer.Featurable())
+
+	// The offer expires with whichever ends first: the sale or the item
+	until := now.Add(48 * time.Hour)
+	item.AvailableUntil = &until
+	sale := &ShopSale{DiscountPercent: 30, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(24 * time.Hour)}
+	offer = NewShopOffer(item, sale)
+	assert.Equal(t, 350, offer.Price)
+	assert.Equal(t, 500, offer.OriginalPrice)
+	assert.Equal(t, 30, offer.DiscountPercent)
+	assert.Equal(t, sale.EndsAt, *offer.SaleEndsAt)
+	assert.Equal(t, sale.EndsAt, *offer.ExpiresAt)
+
+	sale.EndsAt = now.Add(72 * time.Hour)
+	assert.Equal(t, until, *NewShopOffer(item, sale).ExpiresAt)
+
+	zero := 0
+	item.Stock = &zero
+	assert.True(t, NewShopOffer(item, nil).SoldOut())
+	assert.False(t, NewShopOffer(item, nil).Featurable())
+
+	item.Stock = nil
+	item.Price = 0
+	assert.False(t, NewShopOffer(item, nil).Featurable(), "free items are not featured")
+}
+
+func TestShopSale(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	sale := ShopSale{ItemID: 1, DiscountPercent: 25, StartsAt: now, EndsAt: now.Add(time.Hour)}
+
+	assert.True(t, sale.ActiveAt(now), "start is inclusive")
+	assert.False(t, sale.ActiveAt(sale.EndsAt), "end is exclusive")
+	require.NoError(t, ValidateShopSale(sale, now))
+
+	for _, d := range []int{0, 91} {
+		bad := sale
+		bad.DiscountPercent = d
+		assert.Error(t, ValidateShopSale(bad, now))
+	}
+	bad := sale
+	bad.EndsAt = bad.StartsAt
+	assert.Error(t, ValidateShopSale(bad, now))
+	assert.Error(t, ValidateShopSale(sale, sale.EndsAt), "already over")
+}
@@ -0,0 +1,374 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ================================================================
+// SYNC PAYLOAD SCHEMA
+// ================================================================
+// Every offline sync item carries a type and a schema version.
+// The raw `data` object is decoded into the typed payload registered
+// for that (type, version) pair and validated before it is applied,
+// so malformed items are rejected instead of silently counted.
+// ================================================================
+
+// Sync item types
+const (
+	SyncTypeLessonComplete     = "lesson_complete"
+	SyncTypeProgressUpdate     = "progress_update"
+	SyncTypeVocabularyPractice = "vocabulary_practice"
+	SyncTypeVocabularyBatch    = "vocabulary_batch"
+	SyncTypeLessonReward       = "lesson_reward"
+)
+
+// CurrentSyncPayloadVersion is the payload schema version assumed when
+// a client omits the version field
+const CurrentSyncPayloadVersion = 1
+
+// Sync item error codes (machine-readable)
+const (
+	SyncErrUnknownType        = "unknown_type"
+	SyncErrUnsupportedVersion = "unsupported_version"
+	SyncErrMalformedPayload   = "malformed_payload"
+	SyncErrMissingField       = "missing_field"
+	SyncErrInvalidField       = "invalid_field"
+	SyncErrApplyFailed        = "apply_failed"
+	SyncErrRolledBack         = "rolled_back"
+)
+
+// Client actions for a failed sync item
+const (
+	SyncActionDrop  = "drop"  // item can never succeed, remove it from the queue
+	SyncActionRetry = "retry" // transient failure, keep the item queued
+)
+
+// SyncItemError describes why a single sync item was not applied
+type SyncItemError struct {
+	Index   int    `json:"index"`
+	ItemID  string `json:"item_id,omitempty"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Action  string `json:"action"`
+}
+
+// Error implements the error interface
+func (e *SyncItemError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("item %d (%s): %s: %s", e.Index, e.Type, e.Field, e.Message)
+	}
+	return fmt.Sprintf("item %d (%s): %s", e.Index, e.Type, e.Message)
+}
+
+// NewSyncApplyError wraps a failure that happened while applying a valid item.
+// Apply failures are transient from the client's point of view.
+func NewSyncApplyError(index int, item *SyncItem, err error) *SyncItemError {
+	return &SyncItemError{
+		Index:   index,
+		ItemID:  item.ID,
+		Type:    item.Type,
+		Code:    SyncErrApplyFailed,
+		Message: err.Error(),
+		Action:  SyncActionRetry,
+	}
+}
+
+// NewSyncRolledBackError marks an item that was valid but not applied because
+// another item in the same atomic unit failed
+func NewSyncRolledBackError(index int, item *SyncItem, culprit int) *SyncItemError {
+	return &SyncItemError{
+		Index:   index,
+		ItemID:  item.ID,
+		Type:    item.Type,
+		Code:    SyncErrRolledBack,
+		Message: fmt.Sprintf("rolled back because item %d failed", culprit),
+		Action:  SyncActionRetry,
+	}
+}
+
+// SyncPayload is implemented by every typed sync payload
+type SyncPayload interface {
+	Validate() error
+}
+
+// syncFieldError is returned by payload validation
+type syncFieldError struct {
+	code    string
+	field   string
+	message string
+}
+
+func (e *syncFieldError) Error() string {
+	return e.field + ": " + e.message
+}
+
+func missingField(field string) error {
+	return &syncFieldError{code: SyncErrMissingField, field: field, message: "is required"}
+}
+
+func invalidField(field, message string) error {
+	return &syncFieldError{code: SyncErrInvalidField, field: field, message: message}
+}
+
+// LessonCompletePayload is the v1 payload for lesson_complete
+type LessonCompletePayload struct {
+	LessonID  int64 `json:"lesson_id"`
+	QuizScore int   `json:"quiz_score"`
+	TimeSpent int   `json:"time_spent"`
+}
+
+// Validate checks the lesson completion payload
+func (p *LessonCompletePayload) Validate() error {
+	if p.LessonID == 0 {
+		return missingField("lesson_id")
+	}
+	if p.LessonID < 0 {
+		return invalidField("lesson_id", "must be positive")
+	}
+	if p.QuizScore < 0 || p.QuizScore > 100 {
+		return invalidField("quiz_score", "must be between 0 and 100")
+	}
+	if p.TimeSpent < 0 {
+		return invalidField("time_spent", "must not be negative")
+	}
+	return nil
+}
+
+// ProgressUpdatePayload is the v1 payload for progress_update
+type ProgressUpdatePayload struct {
+	LessonID        int64          `json:"lesson_id"`
+	Status          ProgressStatus `json:"status"`
+	ProgressPercent int            `json:"progress_percent"`
+	TimeSpent       int            `json:"time_spent"`
+}
+
+// Validate checks the progress update payload
+func (p *ProgressUpdatePayload) Validate() error {
+	if p.LessonID == 0 {
+		return missingField("lesson_id")
+	}
+	if p.LessonID < 0 {
+		return invalidField("lesson_id", "must be positive")
+	}
+	switch p.Status {
+	case "", StatusNotStarted, StatusInProgress, StatusCompleted, StatusReviewing:
+	default:
+		return invalidField("status", fmt.Sprintf("unknown status %q", p.Status))
+	}
+	if p.ProgressPercent < 0 || p.ProgressPercent > 100 {
+		return invalidField("progress_percent", "must be between 0 and 100")
+	}
+	if p.TimeSpent < 0 {
+		return invalidField("time_spent", "must not be negative")
+	}
+	return nil
+}
+
+// VocabularyPracticePayload is the v1 payload for vocabulary_practice
+type VocabularyPracticePayload struct {
+	VocabularyID int64 `json:"vocabulary_id"`
+	IsCorrect    *bool `json:"is_correct"`
+	ResponseTime int   `json:"response_time"` // milliseconds
+}
+
+// Validate checks the vocabulary practice payload
+func (p *VocabularyPracticePayload) Validate() error {
+	if p.VocabularyID == 0 {
+		return missingField("vocabulary_id")
+	}
+	if p.VocabularyID < 0 {
+		return invalidField("vocabulary_id", "must be positive")
+	}
+	if p.IsCorrect == nil {
+		return missingField("is_correct")
+	}
+	if p.ResponseTime < 0 {
+		return invalidField("response_time", "must not be negative")
+	}
+	return nil
+}
+
+// VocabularyBatchPayload is the v1 payload for vocabulary_batch
+type VocabularyBatchPayload struct {
+	LessonID          int64              `json:"lesson_id"`
+	VocabularyResults []VocabularyResult `json:"vocabulary_results"`
+}
+
+// Validate checks the vocabulary batch payload
+func (p *VocabularyBatchPayload) Validate() error {
+	if p.LessonID == 0 {
+		return missingField("lesson_id")
+	}
+	if p.LessonID < 0 {
+		return invalidField("lesson_id", "must be positive")
+	}
+	if len(p.VocabularyResults) == 0 {
+		return missingField("vocabulary_results")
+	}
+	for i, r := range p.VocabularyResults {
+		if r.VocabularyID <= 0 {
+			return invalidField(fmt.Sprintf("vocabulary_results[%d].vocabulary_id", i), "must be positive")
+		}
+	}
+	return nil
+}
+
+// LessonRewardPayload is the v1 payload for lesson_reward.
+// LemonsEarned and QuizScore are accepted for compatibility but ignored:
+// the server computes the reward from the quiz score stored with the
+// lesson's completion, which must already be recorded.
+type LessonRewardPayload struct {
+	LessonID     int64 `json:"lesson_id"`
+	LemonsEarned int   `json:"lemons_earned,omitempty"`
+	QuizScore    int   `json:"quiz_score"`
+}
+
+// Validate checks the lesson reward payload
+func (p *LessonRewardPayload) Validate() error {
+	if p.LessonID == 0 {
+		return missingField("lesson_id")
+	}
+	if p.LessonID < 0 {
+		return invalidField("lesson_id", "must be positive")
+	}
+	if p.QuizScore < 0 || p.QuizScore > 100 {
+		return invalidField("quiz_score", "must be between 0 and 100")
+	}
+	return nil
+}
+
+// syncPayloadSchemas maps type -> version -> payload constructor
+var syncPayloadSchemas = map[string]map[int]func() SyncPayload{
+	SyncTypeLessonComplete: {
+		1: func() SyncPayload { return &LessonCompletePayload{} },
+	},
+	SyncTypeProgressUpdate: {
+		1: func() SyncPayload { return &ProgressUpdatePayload{} },
+	},
+	SyncTypeVocabularyPractice: {
+		1: func() SyncPayload { return &VocabularyPracticePayload{} },
+	},
+	SyncTypeVocabularyBatch: {
+		1: func() SyncPayload { return &VocabularyBatchPayload{} },
+	},
+	SyncTypeLessonReward: {
+		1: func() SyncPayload { return &LessonRewardPayload{} },
+	},
+}
+
+// DecodeSyncItem decodes and validates the payload of a sync item.
+// index is the item's position in the request and is echoed in errors.
+// Fields the schema does not define are ignored, so clients that send
+// extra fields are not rejected; known fields are still checked strictly.
+func DecodeSyncItem(index int, item *SyncItem) (SyncPayload, *SyncItemError) {
+	reject := func(code, field, message string) *SyncItemError {
+		return &SyncItemError{
+			Index:   index,
+			ItemID:  item.ID,
+			Type:    item.Type,
+			Code:    code,
+			Field:   field,
+			Message: message,
+			Action:  SyncActionDrop,
+		}
+	}
+
+	versions, ok := syncPayloadSchemas[item.Type]
+	if !ok {
+		return nil, reject(SyncErrUnknownType, "type", fmt.Sprintf("unknown sync type %q", item.Type))
+	}
+
+	version := item.Version
+	if version == 0 {
+		version = CurrentSyncPayloadVersion
+	}
+	newPayload, ok := versions[version]
+	if !ok {
+		return nil, reject(SyncErrUnsupportedVersion, "version", fmt.Sprintf("unsupported payload version %d", version))
+	}
+
+	if len(item.Data) == 0 || bytes.Equal(bytes.TrimSpace(item.Data), []byte("null")) {
+		return nil, reject(SyncErrMissingField, "data", "is required")
+	}
+
+	payload := newPayload()
+	dec := json.NewDecoder(bytes.NewReader(item.Data))
+	if err := dec.Decode(payload); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, reject(SyncErrInvalidField, typeErr.Field,
+				fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value))
+		}
+		return nil, reject(SyncErrMalformedPayload, "data", err.Error())
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, reject(SyncErrMalformedPayload, "data", "unexpected trailing data")
+	}
+
+	if err := payload.Validate(); err != nil {
+		var fieldErr *syncFieldError
+		if errors.As(err, &fieldErr) {
+			return nil, reject(fieldErr.code, fieldErr.field, fieldErr.message)
+		}
+		return nil, reject(SyncErrInvalidField, "", err.Error())
+	}
+
+	return payload, nil
+}
+
+// SyncItemResult reports the outcome of a single sync item
+type SyncItemResult struct {
+	Index  int    `json:"index"`
+	ItemID string `json:"item_id,omitempty"`
+	Type   string `json:"type"`
+	Status string `json:"status"` // applied, rejected, failed, rolled_back
+}
+
+// Sync item result statuses
+const (
+	SyncStatusApplied    = "applied"
+	SyncStatusRejected   = "rejected"
+	SyncStatusFailed     = "failed"
+	SyncStatusRolledBack = "rolled_back"
+)
+
+// ================================================================
+// SYNC DEVICE REGISTRY
+// ================================================================
+
+// SyncDevice is a device that has synced progress for a user
+type SyncDevice struct {
+	DeviceID          string     `json:"device_id"`
+	Platform          string     `json:"platform,omitempty"`
+	AppVersion        string     `json:"app_version,omitempty"`
+	LastSyncAt        *time.Time `json:"last_sync_at,omitempty"`
+	LastItemsApplied  int        `json:"last_items_applied"`
+	LastItemsFailed   int        `json:"last_items_failed"`
+	TotalItemsApplied int        `json:"total_items_applied"`
+	TotalItemsFailed  int        `json:"total_items_failed"`
+	PendingItems      int        `json:"pending_items"`
+	RetryItems        int        `json:"retry_items"`
+	LastError         *string    `json:"last_error,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// SyncDeviceReport is what one sync request contributes to the registry
+type SyncDeviceReport struct {
+	DeviceID     string
+	Platform     string
+	AppVersion   string
+	ItemsApplied int
+	ItemsFailed  int
+	PendingItems int
+	RetryItems   int
+	LastError    string
+	SessionID    string
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSyncItemValid(t *testing.T) {
+	item := &SyncItem{
+		ID:   "q-1",
+		Type: SyncTypeLessonComplete,
+		Data: json.RawMessage(`{"lesson_id": 12, "quiz_score": 80, "time_spent": 5}`),
+	}
+
+	payload, itemErr := DecodeSyncItem(0, item)
+	assert.Nil(t, itemErr)
+
+	p, ok := payload.(*LessonCompletePayload)
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), p.LessonID)
+	assert.Equal(t, 80, p.QuizScore)
+}
+
+func TestDecodeSyncItemIgnoresUnknownFields(t *testing.T) {
+	item := &SyncItem{
+		Type: SyncTypeLessonComplete,
+		Data: json.RawMessage(`{"lesson_id": 12, "quiz_score": 80, "lemons": 99, "client": {"build": 7}}`),
+	}
+
+	payload, itemErr := DecodeSyncItem(0, item)
+	assert.Nil(t, itemErr)
+	assert.Equal(t, int64(12), payload.(*LessonCompletePayload).LessonID)
+
+	// Known fields are still validated
+	item.Data = json.RawMessage(`{"lesson_id": 12, "quiz_score": 101, "lemons": 99}`)
+	_, itemErr = DecodeSyncItem(0, item)
+	if assert.NotNil(t, itemErr) {
+		assert.Equal(t, SyncErrInvalidField, itemErr.Code)
+		assert.Equal(t, "quiz_score", itemErr.Field)
+	}
+}
+
+func TestDecodeSyncItemRejections(t *testing.T) {
+	cases := []struct {
+		name  string
+		item  SyncItem
+		code  string
+		field string
+	}{
+		{
+			name:  "missing lesson_id",
+			item:  SyncItem{Type: SyncTypeLessonComplete, Data: json.RawMessage(`{"quiz_score": 80}`)},
+			code:  SyncErrMissingField,
+			field: "lesson_id",
+		},
+		{
+			name:  "mistyped lesson_id",
+			item:  SyncItem{Type: SyncTypeProgressUpdate, Data: json.RawMessage(`{"lesson_id": "12"}`)},
+			code:  SyncErrInvalidField,
+			field: "lesson_id",
+		},
+		{
+			name:  "out of range score",
+			item:  SyncItem{Type: SyncTypeLessonComplete, Data: json.RawMessage(`{"lesson_id": 1, "quiz_score": 101}`)},
+			code:  SyncErrInvalidField,
+			field: "quiz_score",
+		},
+		{
+			name:  "missing is_correct",
+			item:  SyncItem{Type: SyncTypeVocabularyPractice, Data: json.RawMessage(`{"vocabulary_id": 3}`)},
+			code:  SyncErrMissingField,
+			field: "is_correct",
+		},
+		{
+			name:  "unknown type",
+			item:  SyncItem{Type: "teleport", Data: json.RawMessage(`{}`)},
+			code:  SyncErrUnknownType,
+			field: "type",
+		},
+		{
+			name:  "unsupported version",
+			item:  SyncItem{Type: SyncTypeLessonComplete, Version: 9, Data: json.RawMessage(`{"lesson_id": 1}`)},
+			code:  SyncErrUnsupportedVersion,
+			field: "version",
+		},
+		{
+			name:  "missing data",
+			item:  SyncItem{Type: SyncTypeVocabularyBatch},
+			code:  SyncErrMissingField,
+			field: "data",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			item := tc.item
+			payload, itemErr := DecodeSyncItem(i, &item)
+			assert.Nil(t, payload)
+			if assert.NotNil(t, itemErr) {
+				assert.Equal(t, i, itemErr.Index)
+				assert.Equal(t, tc.code, itemErr.Code)
+				assert.Equal(t, SyncActionDrop, itemErr.Action)
+				if tc.field != "" {
+					assert.Equal(t, tc.field, itemErr.Field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+// XP sources
+const (
+	XPSourceLesson   = "lesson"
+	XPSourceReview   = "review"
+	XPSourceHangul   = "hangul"
+	XPSourceBossQuiz = "boss_quiz"
+)
+
+// DomainEventLevelUp is the outbox event type written when a user levels up
+const DomainEventLevelUp = "level_up"
+
+// XPSettings are the XP amounts per activity from gamification_settings
+type XPSettings struct {
+	PerLesson   int
+	PerReview   int
+	PerHangul   int
+	PerBossQuiz int
+}
+
+// Amount returns the XP for one activity from source
+func (s XPSettings) Amount(source string) int {
+	switch source {
+	case XPSourceLesson:
+		return s.PerLesson
+	case XPSourceReview:
+		return s.PerReview
+	case XPSourceHangul:
+		return s.PerHangul
+	case XPSourceBossQuiz:
+		return s.PerBossQuiz
+	}
+	return 0
+}
+
+// XPLevel is one step of the level curve
+type XPLevel struct {
+	Level        int    `json:"level"`
+	XPRequired   int64  `json:"xp_required"`
+	UnlockItemID *int64 `json:"unlock_item_id,omitempty"`
+}
+
+// LevelProgress is a user's level and XP towards the next one
+type LevelProgress struct {
+	Level       int    `json:"level"`
+	TotalXP     int64  `json:"total_xp"`
+	LevelXP     int64  `json:"level_xp"`      // total XP at which the current level starts
+	NextLevelXP *int64 `json:"next_level_xp"` // nil at the highest level
+}
+
+// NewLevelProgress places totalXP on the level curve. levels must be
+// ordered by level; the user is at the highest level whose requirement
+// they meet.
+func NewLevelProgress(levels []XPLevel, totalXP int64) LevelProgress {
+	p := LevelProgress{Level: 1, TotalXP: totalXP}
+	for i, l := range levels {
+		if l.XPRequired > totalXP {
+			next := l.XPRequired
+			p.NextLevelXP = &next
+			break
+		}
+		p.Level = l.Level
+		p.LevelXP = levels[i].XPRequired
+	}
+	return p
+}
+
+// LevelUp describes a level change caused by an XP award
+type LevelUp struct {
+	FromLevel       int     `json:"from_level"`
+	ToLevel         int     `json:"to_level"`
+	TotalXP         int64   `json:"total_xp"`
+	Source          string  `json:"source"`
+	UnlockedItemIDs []int64 `json:"unlocked_item_ids"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLevelProgress(t *testing.T) {
+	levels := []XPLevel{
+		{Level: 1, XPRequired: 0},
+		{Level: 2, XPRequired: 100},
+		{Level: 3, XPRequired: 300},
+	}
+
+	p := NewLevelProgress(levels, 0)
+	assert.Equal(t, 1, p.Level)
+	assert.Equal(t, int64(0), p.LevelXP)
+	assert.Equal(t, int64(100), *p.NextLevelXP)
+
+	// Reaching the requirement exactly levels up
+	p = NewLevelProgress(levels, 100)
+	assert.Equal(t, 2, p.Level)
+	assert.Equal(t, int64(100), p.LevelXP)
+	assert.Equal(t, int64(300), *p.NextLevelXP)
+
+	p = NewLevelProgress(levels, 299)
+	assert.Equal(t, 2, p.Level)
+
+	// No next level at the top of the curve
+	p = NewLevelProgress(levels, 5000)
+	assert.Equal(t, 3, p.Level)
+	assert.Equal(t, int64(300), p.LevelXP)
+	assert.Nil(t, p.NextLevelXP)
+	assert.Equal(t, int64(5000), p.TotalXP)
+
+	// An empty curve leaves everyone at level 1
+	p = NewLevelProgress(nil, 50)
+	assert.Equal(t, 1, p.Level)
+	assert.Nil(t, p.NextLevelXP)
+}
@@ -0,0 +1,309 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ================================================================
+// TRANSACTIONAL OUTBOX
+// ================================================================
+// Domain events are inserted into event_outbox in the same transaction
+// as the change they describe. A relay goroutine on every replica claims
+// pending rows (FOR UPDATE SKIP LOCKED) and appends them to a Redis
+// Stream. Delivery is at-least-once: consumers should dedupe on "id".
+// Messages that keep failing are moved to a dead-letter stream.
+// ================================================================
+
+const (
+	DefaultStream           = "stream:progress:events"
+	DefaultDeadLetterStream = "stream:progress:events:dead"
+	DefaultMaxAttempts      = 10
+
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	streamMaxLen        = 100000
+	maxRetryBackoff     = 5 * time.Minute
+	publishedRetention  = 7 * 24 * time.Hour
+	pruneInterval       = time.Hour
+)
+
+// Execer is satisfied by *sql.DB and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Message is one domain event waiting to be relayed
+type Message struct {
+	ID         int64
+	EventID    int64
+	Type       string
+	UserID     int64
+	Payload    json.RawMessage
+	OccurredAt time.Time
+	Attempts   int
+}
+
+// Enqueue writes a message to the outbox using the caller's transaction
+func Enqueue(ctx context.Context, q Execer, msg *Message) error {
+	query := `
+		INSERT INTO event_outbox (event_id, event_type, user_id, payload, occurred_at)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5)
+	`
+
+	_, err := q.ExecContext(ctx, query, msg.EventID, msg.Type, msg.UserID, []byte(msg.Payload), msg.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// Relay publishes outbox messages to Redis Streams
+type Relay struct {
+	db    *sql.DB
+	redis *redis.Client
+
+	stream           string
+	deadLetterStream string
+	maxAttempts      int
+	pollInterval     time.Duration
+	batchSize        int
+
+	done chan struct{}
+}
+
+// NewRelay creates a relay configured from OUTBOX_STREAM,
+// OUTBOX_DEAD_LETTER_STREAM, OUTBOX_MAX_ATTEMPTS and OUTBOX_POLL_INTERVAL_MS
+func NewRelay(db *sql.DB, redisClient *redis.Client) *Relay {
+	pollInterval := defaultPollInterval
+	if ms := envInt("OUTBOX_POLL_INTERVAL_MS", 0); ms > 0 {
+		pollInterval = time.Duration(ms) * time.Millisecond
+	}
+
+	return &Relay{
+		db:               db,
+		redis:            redisClient,
+		stream:           envString("OUTBOX_STREAM", DefaultStream),
+		deadLetterStream: envString("OUTBOX_DEAD_LETTER_STREAM", DefaultDeadLetterStream),
+		maxAttempts:      envInt("OUTBOX_MAX_ATTEMPTS", DefaultMaxAttempts),
+		pollInterval:     pollInterval,
+		batchSize:        defaultBatchSize,
+		done:             make(chan struct{}),
+	}
+}
+
+// Run relays pending messages until ctx is cancelled
+func (rl *Relay) Run(ctx context.Context) {
+	defer close(rl.done)
+
+	poll := time.NewTicker(rl.pollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	log.Printf("[OUTBOX] Relaying to %s (dead letters: %s)", rl.stream, rl.deadLetterStream)
+
+	for {
+		relayed, err := rl.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[OUTBOX] Relay failed: %v", err)
+		}
+
+		// Keep draining while full batches are available
+		if err == nil && relayed == rl.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-prune.C:
+			rl.prunePublished(ctx)
+		}
+	}
+}
+
+// Done is closed once Run has returned
+func (rl *Relay) Done() <-chan struct{} {
+	return rl.done
+}
+
+// relayBatch claims a batch of due messages and publishes them.
+// Returns the number of messages claimed.
+func (rl *Relay) relayBatch(ctx context.Context) (int, error) {
+	tx, err := rl.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	messages, err := claimMessages(ctx, tx, rl.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, msg := range messages {
+		if publishErr := rl.publish(ctx, rl.stream, msg, ""); publishErr != nil {
+			if err := rl.recordFailure(ctx, tx, msg, publishErr); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`UPDATE event_outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`,
+			msg.ID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to mark message %d published: %w", msg.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(messages), nil
+}
+
+// recordFailure schedules a retry, or dead-letters the message once it
+// has used up its attempts
+func (rl *Relay) recordFailure(ctx context.Context, tx *sql.Tx, msg *Message, publishErr error) error {
+	attempts := msg.Attempts + 1
+
+	if attempts >= rl.maxAttempts {
+		if err := rl.publish(ctx, rl.deadLetterStream, msg, publishErr.Error()); err == nil {
+			log.Printf("[OUTBOX] Message %d (%s) dead-lettered after %d attempts: %v", msg.ID, msg.Type, attempts, publishErr)
+			_, err = tx.ExecContext(ctx,
+				`UPDATE event_outbox SET dead_lettered_at = NOW(), attempts = $2, last_error = $3 WHERE id = $1`,
+				msg.ID, attempts, publishErr.Error(),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to mark message %d dead-lettered: %w", msg.ID, err)
+			}
+			return nil
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`UPDATE event_outbox SET attempts = $2, last_error = $3, next_attempt_at = NOW() + make_interval(secs => $4) WHERE id = $1`,
+		msg.ID, attempts, publishErr.Error(), RetryBackoff(attempts).Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule message %d: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+// publish appends a message to a stream; reason is set for dead letters
+func (rl *Relay) publish(ctx context.Context, stream string, msg *Message, reason string) error {
+	values := map[string]interface{}{
+		"id":          msg.ID,
+		"event_id":    msg.EventID,
+		"type":        msg.Type,
+		"user_id":     msg.UserID,
+		"occurred_at": msg.OccurredAt.Format(time.RFC3339Nano),
+		"payload":     string(msg.Payload),
+		"source":      "progress",
+	}
+	if reason != "" {
+		values["error"] = reason
+		values["attempts"] = msg.Attempts + 1
+	}
+
+	return rl.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+// prunePublished deletes delivered messages past the retention period
+func (rl *Relay) prunePublished(ctx context.Context) {
+	result, err := rl.db.ExecContext(ctx,
+		`DELETE FROM event_outbox WHERE published_at < NOW() - make_interval(secs => $1)`,
+		publishedRetention.Seconds(),
+	)
+	if err != nil {
+		log.Printf("[OUTBOX] Prune failed: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("[OUTBOX] Pruned %d published messages", n)
+	}
+}
+
+// claimMessages locks due messages so concurrent relays skip them
+func claimMessages(ctx context.Context, tx *sql.Tx, limit int) ([]*Message, error) {
+	query := `
+		SELECT id, COALESCE(event_id, 0), event_type, user_id, payload, occurred_at, attempts
+		FROM event_outbox
+		WHERE published_at IS NULL AND dead_lettered_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		msg := &Message{}
+		var payload []byte
+		if err := rows.Scan(&msg.ID, &msg.EventID, &msg.Type, &msg.UserID, &payload, &msg.OccurredAt, &msg.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.Payload = payload
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// RetryBackoff returns the delay before the given retry attempt:
+// exponential from one second, capped at five minutes
+func RetryBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 9 {
+		return maxRetryBackoff
+	}
+	backoff := time.Duration(1<<(attempt-1)) * time.Second
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
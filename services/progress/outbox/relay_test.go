@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, RetryBackoff(0))
+	assert.Equal(t, time.Second, RetryBackoff(1))
+	assert.Equal(t, 2*time.Second, RetryBackoff(2))
+	assert.Equal(t, 4*time.Second, RetryBackoff(3))
+	assert.Equal(t, 256*time.Second, RetryBackoff(9))
+	assert.Equal(t, maxRetryBackoff, RetryBackoff(10))
+	assert.Equal(t, maxRetryBackoff, RetryBackoff(100))
+}
+
+// streamServer is a minimal Redis that accepts XADD, rejecting appends to
+// the streams listed in failing
+type streamServer struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	added   []string // stream of each accepted XADD
+}
+
+func startStreamServer(t *testing.T, failing ...string) (*streamServer, *redis.Client) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &streamServer{failing: map[string]bool{}}
+	for _, stream := range failing {
+		s.failing[stream] = true
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return s, client
+}
+
+func (s *streamServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		reply := "+OK\r\n"
+		if strings.EqualFold(args[0], "xadd") {
+			s.mu.Lock()
+			if s.failing[args[1]] {
+				reply = "-ERR stream unavailable\r\n"
+			} else {
+				s.added = append(s.added, args[1])
+				reply = "$3\r\n1-0\r\n"
+			}
+			s.mu.Unlock()
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *streamServer) streams() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.added...)
+}
+
+// readCommand reads one RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// outboxRow returns a claimed event_outbox row with the given attempts
+func outboxRow(id int64, attempts int) []driver.Value {
+	return []driver.Value{id, int64(0), "lesson_completed", int64(1), []byte(`{"lesson_id":12}`), time.Now(), int64(attempts)}
+}
+
+func newTestRelay(t *testing.T, client *redis.Client, rows ...[]driver.Value) (*Relay, *dbtest.Driver) {
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		return &dbtest.Rows{
+			Columns: []string{"id", "event_id", "event_type", "user_id", "payload", "occurred_at", "attempts"},
+			Values:  rows,
+		}, nil
+	}}
+	return &Relay{
+		db:               dbtest.Open(t, d),
+		redis:            client,
+		stream:           DefaultStream,
+		deadLetterStream: DefaultDeadLetterStream,
+		maxAttempts:      3,
+		batchSize:        defaultBatchSize,
+	}, d
+}
+
+func TestRelayBatchMarksPublished(t *testing.T) {
+	server, client := startStreamServer(t)
+	relay, d := newTestRelay(t, client, outboxRow(1, 0), outboxRow(2, 1))
+
+	relayed, err := relay.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	assert.Equal(t, []string{DefaultStream, DefaultStream}, server.streams())
+
+	applied := d.Applied()
+	require.Len(t, applied, 2)
+	for i, s := range applied {
+		assert.Contains(t, s.Query, "SET published_at = NOW()")
+		assert.Equal(t, []driver.Value{int64(i + 1)}, s.Args)
+	}
+}
+
+func TestRelayBatchReschedulesFailedPublish(t *testing.T) {
+	server, client := startStreamServer(t, DefaultStream)
+	relay, d := newTestRelay(t, client, outboxRow(1, 0))
+
+	relayed, err := relay.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	assert.Empty(t, server.streams())
+
+	applied := d.Applied()
+	require.Len(t, applied, 1)
+	assert.Contains(t, applied[0].Query, "next_attempt_at")
+	assert.NotContains(t, applied[0].Query, "published_at")
+	assert.Equal(t, int64(1), applied[0].Args[1], "attempts")
+	assert.Equal(t, RetryBackoff(1).Seconds(), applied[0].Args[3], "backoff")
+}
+
+func TestRelayBatchDeadLettersAfterMaxAttempts(t *testing.T) {
+	server, client := startStreamServer(t, DefaultStream)
+	relay, d := newTestRelay(t, client, outboxRow(1, 2))
+
+	_, err := relay.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultDeadLetterStream}, server.streams())
+
+	applied := d.Applied()
+	require.Len(t, applied, 1)
+	assert.Contains(t, applied[0].Query, "SET dead_lettered_at = NOW()")
+	assert.Equal(t, int64(3), applied[0].Args[1], "attempts")
+}
+
+func TestRecordFailureReschedulesWhenDeadLetterFails(t *testing.T) {
+	_, client := startStreamServer(t, DefaultStream, DefaultDeadLetterStream)
+	relay, d := newTestRelay(t, client)
+
+	tx, err := relay.db.Begin()
+	require.NoError(t, err)
+	err = relay.recordFailure(context.Background(), tx, &Message{ID: 1, Attempts: 2}, fmt.Errorf("stream unavailable"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	applied := d.Applied()
+	require.Len(t, applied, 1)
+	assert.Contains(t, applied[0].Query, "next_attempt_at", "kept for retry until the dead letter is written")
+	assert.Equal(t, RetryBackoff(3).Seconds(), applied[0].Args[3])
+}
@@ -0,0 +1,164 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ================================================================
+// REAL-TIME PROGRESS EVENTS
+// ================================================================
+// Writes publish change notifications to a per-user Redis channel.
+// Every replica runs one Hub holding a single pattern subscription
+// and fans events out to the SSE streams connected to it, so a
+// change made through any replica reaches every signed-in device.
+// ================================================================
+
+const (
+	channelPrefix  = "progress:events:"
+	channelPattern = channelPrefix + "*"
+
+	// subscriberBuffer is the number of events queued per stream before drops
+	subscriberBuffer = 16
+)
+
+// Channel returns the Redis channel for a user's events
+func Channel(userID int64) string {
+	return channelPrefix + strconv.FormatInt(userID, 10)
+}
+
+type sourceDeviceKey struct{}
+
+// WithSourceDevice tags writes made with ctx as originating from deviceID,
+// so the originating device can ignore its own events
+func WithSourceDevice(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, sourceDeviceKey{}, deviceID)
+}
+
+// SourceDevice returns the device ID set by WithSourceDevice, if any
+func SourceDevice(ctx context.Context) string {
+	deviceID, _ := ctx.Value(sourceDeviceKey{}).(string)
+	return deviceID
+}
+
+// Publish sends an event to every replica subscribed to the user's channel
+func Publish(ctx context.Context, client *redis.Client, event *models.ProgressEvent) error {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return client.Publish(ctx, Channel(event.UserID), data).Err()
+}
+
+// Hub dispatches Redis events to local subscribers by user ID
+type Hub struct {
+	redis *redis.Client
+
+	mu          sync.RWMutex
+	subscribers map[int64]map[chan *models.ProgressEvent]struct{}
+
+	done chan struct{}
+}
+
+// NewHub creates a new event hub
+func NewHub(redisClient *redis.Client) *Hub {
+	return &Hub{
+		redis:       redisClient,
+		subscribers: make(map[int64]map[chan *models.ProgressEvent]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Done is closed once the hub stops, so open streams can end before shutdown
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Run subscribes to all user channels and dispatches events until ctx is done
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+
+	pubsub := h.redis.PSubscribe(ctx, channelPattern)
+	defer pubsub.Close()
+
+	log.Println("[REALTIME] Subscribed to progress events")
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			h.dispatch(msg)
+		}
+	}
+}
+
+func (h *Hub) dispatch(msg *redis.Message) {
+	userID, err := strconv.ParseInt(strings.TrimPrefix(msg.Channel, channelPrefix), 10, 64)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	subs := h.subscribers[userID]
+	if len(subs) == 0 {
+		return
+	}
+
+	var event models.ProgressEvent
+	if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+		log.Printf("[REALTIME] Dropping malformed event on %s: %v", msg.Channel, err)
+		return
+	}
+
+	for ch := range subs {
+		select {
+		case ch <- &event:
+		default:
+			// Slow stream, drop rather than block other users
+		}
+	}
+}
+
+// Subscribe registers a stream for a user's events.
+// The returned function must be called to unsubscribe.
+func (h *Hub) Subscribe(userID int64) (<-chan *models.ProgressEvent, func()) {
+	ch := make(chan *models.ProgressEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan *models.ProgressEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// ACTIVITY FEED
+// ================================================================
+// Notable progress is written to activity_feed alongside the change that
+// caused it. Visibility is applied when friends read the feed, using the
+// author's current activity_privacy and blocks.
+// ================================================================
+
+// recordActivity adds an item to the user's activity. Items with the same
+// non-empty dedupeKey (per user and kind) are recorded once.
+func recordActivity(ctx context.Context, q DBTX, userID int64, kind, dedupeKey string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode activity: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO activity_feed (user_id, kind, dedupe_key, data)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		ON CONFLICT (user_id, kind, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING
+	`, userID, kind, dedupeKey, []byte(payload))
+	if err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// recordActivityForEvent records the feed items of a learning event. A
+// lesson appears at most once per day however often it is repeated.
+func recordActivityForEvent(ctx context.Context, q DBTX, event *models.LearningEvent) error {
+	if event.Type != models.LearningEventLessonCompleted {
+		return nil
+	}
+
+	var d models.LessonCompletedData
+	if err := decodeLearningEvent(event, &d); err != nil {
+		return err
+	}
+
+	key := strconv.FormatInt(d.LessonID, 10) + ":" + event.OccurredAt.UTC().Format("2006-01-02")
+	return recordActivity(ctx, q, event.UserID, models.ActivityLessonCompleted, key, map[string]interface{}{
+		"lesson_id":  d.LessonID,
+		"quiz_score": d.QuizScore,
+	})
+}
+
+// recordStreakMilestone adds a milestone streak to the user's activity.
+// Failures are logged and never fail the write that extended the streak.
+func (r *ProgressRepository) recordStreakMilestone(ctx context.Context, userID int64, streak int) {
+	if !models.IsStreakMilestone(streak) {
+		return
+	}
+
+	key := strconv.Itoa(streak) + ":" + time.Now().UTC().Format("2006-01-02")
+	err := recordActivity(ctx, r.db, userID, models.ActivityStreakMilestone, key, map[string]interface{}{
+		"streak_days": streak,
+	})
+	if err != nil {
+		log.Printf("[FRIENDS] Failed to record streak milestone for user %d: %v", userID, err)
+	}
+}
+
+// GetFriendFeed returns activity visible to the user, newest first: items of
+// friends who share with friends or publicly, and of followed users who
+// share publicly. Returns the cursor for the next page (0 when there are no
+// more items).
+func (r *ProgressRepository) GetFriendFeed(ctx context.Context, userID, beforeID int64, limit int) ([]models.ActivityItem, int64, error) {
+	query := `
+		SELECT a.id, a.user_id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, ''), a.kind, a.data, a.created_at
+		FROM activity_feed a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.user_id IN (
+			SELECT f.friend_id FROM friendships f
+			WHERE f.user_id = $1
+			UNION
+			SELECT uf.following_id FROM user_follows uf
+			WHERE uf.follower_id = $1
+		)
+		  AND ($2::bigint = 0 OR a.id < $2)
+		  AND (
+			COALESCE(u.activity_privacy, 'friends') = 'public'
+			OR (
+				COALESCE(u.activity_privacy, 'friends') = 'friends'
+				AND EXISTS (SELECT 1 FROM friendships f WHERE f.user_id = $1 AND f.friend_id = a.user_id)
+			)
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = a.user_id)
+			   OR (b.blocker_id = a.user_id AND b.blocked_id = $1)
+		  )
+		ORDER BY a.id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, beforeID, limit+1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query activity feed: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.ActivityItem{}
+	for rows.Next() {
+		var item models.ActivityItem
+		var data []byte
+		err := rows.Scan(&item.ID, &item.User.UserID, &item.User.Name, &item.User.ProfileImageURL,
+			&item.Kind, &data, &item.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		item.Data = data
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query activity feed: %w", err)
+	}
+
+	var nextCursor int64
+	if len(items) > limit {
+		items = items[:limit]
+		nextCursor = items[len(items)-1].ID
+	}
+
+	return items, nextCursor, nil
+}
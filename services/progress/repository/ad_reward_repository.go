@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/ads"
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// AD REWARD GRANTS
+// ================================================================
+// Verified rewarded-ad callbacks become single-use grants that a harvest
+// consumes. Callbacks are idempotent on transaction_id and capped per
+// user per UTC day.
+// ================================================================
+
+// ErrUnknownAdUser is returned for callbacks whose user_id is not a user
+var ErrUnknownAdUser = errors.New("unknown ad reward user")
+
+// RecordAdReward stores a verified callback as a harvest grant. Returns the
+// existing grant if the transaction was already recorded.
+func (r *ProgressRepository) RecordAdReward(ctx context.Context, cb *ads.RewardCallback) (*models.AdRewardGrant, error) {
+	userID, err := strconv.ParseInt(cb.UserID, 10, 64)
+	if err != nil || userID <= 0 {
+		return nil, ErrUnknownAdUser
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return nil, ErrUnknownAdUser
+	}
+
+	// Serialize the user's callbacks so the daily cap holds
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('ad_reward_grants'), $1::int)`, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock ad rewards: %w", err)
+	}
+
+	grant, err := getAdRewardGrant(ctx, tx, cb.TransactionID)
+	if err == nil {
+		return grant, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	s, err := loadTreeSettings(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var usedToday int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM ad_reward_grants
+		WHERE user_id = $1 AND status <> 'capped'
+		  AND created_at >= date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+	`, userID).Scan(&usedToday)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count ad rewards: %w", err)
+	}
+
+	status := models.NewAdGrantStatus(usedToday, s.AdDailyCap)
+
+	var rewardedAt *time.Time
+	if !cb.Timestamp.IsZero() {
+		rewardedAt = &cb.Timestamp
+	}
+
+	query := `
+		INSERT INTO ad_reward_grants (
+			user_id, transaction_id, ad_network, ad_unit, reward_item, reward_amount,
+			custom_data, status, expires_at, rewarded_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW() + make_interval(secs => $9), $10)
+		RETURNING id, user_id, transaction_id, status, expires_at, consumed_at, created_at
+	`
+	grant = &models.AdRewardGrant{}
+	err = tx.QueryRowContext(ctx, query,
+		userID, cb.TransactionID, cb.AdNetwork, cb.AdUnit, cb.RewardItem, cb.RewardAmount,
+		cb.CustomData, status, s.AdGrantTTL.Seconds(), rewardedAt,
+	).Scan(&grant.ID, &grant.UserID, &grant.TransactionID, &grant.Status, &grant.ExpiresAt, &grant.ConsumedAt, &grant.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record ad reward: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if grant.Status == models.AdGrantAvailable {
+		r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+			"reason":     "ad_reward",
+			"expires_at": grant.ExpiresAt,
+		})
+	}
+
+	return grant, nil
+}
+
+// GetAdRewardStatus returns the user's unused grants and today's usage
+func (r *ProgressRepository) GetAdRewardStatus(ctx context.Context, userID int64) (*models.AdRewardStatus, error) {
+	s, err := loadTreeSettings(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.AdRewardStatus{DailyCap: s.AdDailyCap, Required: s.AdsEnabled}
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE status = 'available' AND expires_at > NOW()),
+		       MIN(expires_at) FILTER (WHERE status = 'available' AND expires_at > NOW()),
+		       COUNT(*) FILTER (WHERE status <> 'capped'
+		                          AND created_at >= date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC')
+		FROM ad_reward_grants
+		WHERE user_id = $1
+	`, userID).Scan(&status.Available, &status.NextExpiresAt, &status.UsedToday)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ad reward status: %w", err)
+	}
+
+	return status, nil
+}
+
+// consumeAdRewardGrant uses the user's grant closest to expiry for a
+// harvest. Returns false if the user has no unexpired grant.
+func consumeAdRewardGrant(ctx context.Context, q DBTX, userID, fruitID int64, now time.Time) (bool, error) {
+	query := `
+		UPDATE ad_reward_grants
+		SET status = 'consumed', consumed_at = $2, fruit_id = $3
+		WHERE id = (
+			SELECT id FROM ad_reward_grants
+			WHERE user_id = $1 AND status = 'available' AND expires_at > $2
+			ORDER BY expires_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+
+	var id int64
+	err := q.QueryRowContext(ctx, query, userID, now, fruitID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to consume ad reward: %w", err)
+	}
+
+	return true, nil
+}
+
+// getAdRewardGrant looks up a grant by AdMob transaction ID
+func getAdRewardGrant(ctx context.Context, q DBTX, transactionID string) (*models.AdRewardGrant, error) {
+	grant := &models.AdRewardGrant{}
+	err := q.QueryRowContext(ctx, `
+		SELECT id, user_id, transaction_id, status, expires_at, consumed_at, created_at
+		FROM ad_reward_grants
+		WHERE transaction_id = $1
+	`, transactionID).Scan(&grant.ID, &grant.UserID, &grant.TransactionID, &grant.Status, &grant.ExpiresAt, &grant.ConsumedAt, &grant.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ad reward: %w", err)
+	}
+
+	return grant, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/ads"
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adGrantTable answers the ad reward queries from an in-memory
+// ad_reward_grants table. Every grant counts as created today.
+type adGrantTable struct {
+	mu       sync.Mutex
+	dailyCap int
+	grants   []models.AdRewardGrant
+	inserts  int
+}
+
+var adGrantColumns = []string{"id", "user_id", "transaction_id", "status", "expires_at", "consumed_at", "created_at"}
+
+func adGrantRow(g models.AdRewardGrant) []driver.Value {
+	var consumedAt driver.Value
+	if g.ConsumedAt != nil {
+		consumedAt = *g.ConsumedAt
+	}
+	return []driver.Value{g.ID, g.UserID, g.TransactionID, g.Status, g.ExpiresAt, consumedAt, g.CreatedAt}
+}
+
+func (tbl *adGrantTable) query(query string, args []driver.Value) (*dbtest.Rows, error) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "FROM users"):
+		return &dbtest.Rows{Columns: []string{"exists"}, Values: [][]driver.Value{{true}}}, nil
+
+	case strings.Contains(query, "FROM gamification_settings"):
+		return &dbtest.Rows{Columns: make([]string, 9), Values: [][]driver.Value{{
+			int64(10), int64(240), int64(30), int64(72), int64(24), int64(60), true, int64(tbl.dailyCap), int64(30),
+		}}}, nil
+
+	case strings.Contains(query, "WHERE transaction_id = $1"):
+		for _, g := range tbl.grants {
+			if g.TransactionID == args[0] {
+				return &dbtest.Rows{Columns: adGrantColumns, Values: [][]driver.Value{adGrantRow(g)}}, nil
+			}
+		}
+		return nil, nil
+
+	case strings.Contains(query, "SELECT COUNT(*) FROM ad_reward_grants"):
+		n := int64(0)
+		for _, g := range tbl.grants {
+			if g.UserID == args[0] && g.Status != models.AdGrantCapped {
+				n++
+			}
+		}
+		return &dbtest.Rows{Columns: []string{"count"}, Values: [][]driver.Value{{n}}}, nil
+
+	case strings.Contains(query, "INSERT INTO ad_reward_grants"):
+		tbl.inserts++
+		now := time.Now()
+		g := models.AdRewardGrant{
+			ID:            int64(len(tbl.grants) + 1),
+			UserID:        args[0].(int64),
+			TransactionID: args[1].(string),
+			Status:        args[7].(string),
+			ExpiresAt:     now.Add(time.Duration(args[8].(float64) * float64(time.Second))),
+			CreatedAt:     now,
+		}
+		tbl.grants = append(tbl.grants, g)
+		return &dbtest.Rows{Columns: adGrantColumns, Values: [][]driver.Value{adGrantRow(g)}}, nil
+
+	case strings.Contains(query, "UPDATE ad_reward_grants"):
+		// status = 'available' AND expires_at > now, closest to expiry first
+		now := args[1].(time.Time)
+		candidates := []int{}
+		for i, g := range tbl.grants {
+			if g.UserID == args[0] && g.Status == models.AdGrantAvailable && g.ExpiresAt.After(now) {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+		sort.Slice(candidates, func(a, b int) bool {
+			return tbl.grants[candidates[a]].ExpiresAt.Before(tbl.grants[candidates[b]].ExpiresAt)
+		})
+		g := &tbl.grants[candidates[0]]
+		g.Status, g.ConsumedAt = models.AdGrantConsumed, &now
+		return &dbtest.Rows{Columns: []string{"id"}, Values: [][]driver.Value{{g.ID}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+func newAdGrantRepository(t *testing.T, dailyCap int) (*ProgressRepository, *adGrantTable) {
+	tbl := &adGrantTable{dailyCap: dailyCap}
+	return newTestRepository(t, &dbtest.Driver{Query: tbl.query}), tbl
+}
+
+func adCallback(txID string) *ads.RewardCallback {
+	return &ads.RewardCallback{UserID: "7", TransactionID: txID, RewardItem: "harvest", RewardAmount: 1}
+}
+
+func TestRecordAdRewardCapsAtDailyCap(t *testing.T) {
+	repo, _ := newAdGrantRepository(t, 3)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		grant, err := repo.RecordAdReward(ctx, adCallback(fmt.Sprintf("tx-%d", i)))
+		require.NoError(t, err)
+		assert.Equal(t, models.AdGrantAvailable, grant.Status, "grant %d", i)
+	}
+
+	for i := 4; i <= 5; i++ {
+		grant, err := repo.RecordAdReward(ctx, adCallback(fmt.Sprintf("tx-%d", i)))
+		require.NoError(t, err)
+		assert.Equal(t, models.AdGrantCapped, grant.Status, "grant %d is past the cap", i)
+	}
+}
+
+func TestRecordAdRewardIsIdempotent(t *testing.T) {
+	repo, tbl := newAdGrantRepository(t, 3)
+	ctx := context.Background()
+
+	first, err := repo.RecordAdReward(ctx, adCallback("tx-1"))
+	require.NoError(t, err)
+
+	again, err := repo.RecordAdReward(ctx, adCallback("tx-1"))
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.Equal(t, 1, tbl.inserts, "a replayed callback is not recorded twice")
+
+	// Replays do not count towards the cap either
+	for i := 2; i <= 3; i++ {
+		grant, err := repo.RecordAdReward(ctx, adCallback(fmt.Sprintf("tx-%d", i)))
+		require.NoError(t, err)
+		assert.Equal(t, models.AdGrantAvailable, grant.Status)
+	}
+}
+
+func TestRecordAdRewardRejectsUnknownUser(t *testing.T) {
+	repo, _ := newAdGrantRepository(t, 3)
+
+	cb := adCallback("tx-1")
+	cb.UserID = "not-a-user"
+	_, err := repo.RecordAdReward(context.Background(), cb)
+	assert.ErrorIs(t, err, ErrUnknownAdUser)
+}
+
+func TestConsumeAdRewardGrantSkipsExpired(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tbl := &adGrantTable{grants: []models.AdRewardGrant{
+		{ID: 1, UserID: 7, TransactionID: "expired", Status: models.AdGrantAvailable, ExpiresAt: now.Add(-time.Minute)},
+		{ID: 2, UserID: 7, TransactionID: "capped", Status: models.AdGrantCapped, ExpiresAt: now.Add(time.Hour)},
+	}}
+	db := dbtest.Open(t, &dbtest.Driver{Query: tbl.query})
+	ctx := context.Background()
+
+	consumed, err := consumeAdRewardGrant(ctx, db, 7, 1, now)
+	require.NoError(t, err)
+	assert.False(t, consumed, "expired and capped grants cannot be used")
+	assert.Equal(t, models.AdGrantAvailable, tbl.grants[0].Status)
+
+	tbl.grants = append(tbl.grants, models.AdRewardGrant{
+		ID: 3, UserID: 7, TransactionID: "fresh", Status: models.AdGrantAvailable, ExpiresAt: now.Add(time.Minute),
+	})
+	consumed, err = consumeAdRewardGrant(ctx, db, 7, 1, now)
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	assert.Equal(t, models.AdGrantConsumed, tbl.grants[2].Status)
+
+	consumed, err = consumeAdRewardGrant(ctx, db, 7, 2, now)
+	require.NoError(t, err)
+	assert.False(t, consumed, "a grant is single-use")
+}
@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// CHARACTER EQUIPMENT
+// ================================================================
+// An item may only go into the slot named by its category. Required slots
+// fall back to the category's is_default item instead of being emptied,
+// and default items can be worn without being in the inventory. An item
+// whose metadata has "excludes": ["hat", ...] cannot be worn together with
+// items in those slots; the last equipped item wins.
+// ================================================================
+
+var (
+	// ErrInvalidSlot is returned for unknown equipment slots
+	ErrInvalidSlot = errors.New("invalid category")
+
+	// ErrSlotMismatch is returned when an item's category does not match the slot
+	ErrSlotMismatch = errors.New("item does not fit this slot")
+
+	// ErrSlotRequired is returned when unequipping a required slot that has
+	// no default item
+	ErrSlotRequired = errors.New("slot cannot be empty")
+
+	// ErrItemNotOwned is returned for items that are not in the user's inventory
+	ErrItemNotOwned = errors.New("item not owned")
+)
+
+// characterColumns lists the user_characters slot columns in CharacterSlots order
+func characterColumns() string {
+	columns := make([]string, len(models.CharacterSlots))
+	for i, slot := range models.CharacterSlots {
+		columns[i] = models.CharacterSlotColumn(slot)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// loadCharacter reads the user's stored loadout and skin colour. With lock,
+// the character row is created if missing and locked until the transaction
+// ends.
+func loadCharacter(ctx context.Context, q DBTX, userID int64, lock bool) (models.Loadout, string, error) {
+	query := `SELECT ` + characterColumns() + `, COALESCE(skin_color, '` + models.DefaultSkinColor + `')
+		FROM user_characters WHERE user_id = $1`
+	if lock {
+		_, err := q.ExecContext(ctx,
+			`INSERT INTO user_characters (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create character: %w", err)
+		}
+		query += ` FOR UPDATE`
+	}
+
+	ids := make([]sql.NullInt64, len(models.CharacterSlots))
+	dest := make([]interface{}, 0, len(ids)+1)
+	for i := range ids {
+		dest = append(dest, &ids[i])
+	}
+	var skinColor string
+	dest = append(dest, &skinColor)
+
+	err := q.QueryRowContext(ctx, query, userID).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return models.Loadout{}, models.DefaultSkinColor, nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get character: %w", err)
+	}
+
+	loadout := models.Loadout{}
+	for i, slot := range models.CharacterSlots {
+		if ids[i].Valid {
+			loadout[slot] = ids[i].Int64
+		}
+	}
+	return loadout, skinColor, nil
+}
+
+// saveLoadout writes every slot of the user's (locked) character row
+func saveLoadout(ctx context.Context, q DBTX, userID int64, loadout models.Loadout) error {
+	sets := make([]string, len(models.CharacterSlots))
+	args := make([]interface{}, 0, len(models.CharacterSlots)+1)
+	args = append(args, userID)
+	for i, slot := range models.CharacterSlots {
+		sets[i] = fmt.Sprintf("%s = $%d", models.CharacterSlotColumn(slot), i+2)
+		var id *int64
+		if v, ok := loadout[slot]; ok {
+			id = &v
+		}
+		args = append(args, id)
+	}
+
+	query := `UPDATE user_characters SET ` + strings.Join(sets, ", ") + `, updated_at = NOW() WHERE user_id = $1`
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save character: %w", err)
+	}
+	return nil
+}
+
+// getDefaultItems returns the default item of each category
+func getDefaultItems(ctx context.Context, q DBTX) (map[string]int64, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT DISTINCT ON (category) category, id
+		FROM character_items
+		WHERE is_default = true AND is_active = true
+		ORDER BY category, render_order, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query default items: %w", err)
+	}
+	defer rows.Close()
+
+	defaults := map[string]int64{}
+	for rows.Next() {
+		var category string
+		var id int64
+		if err := rows.Scan(&category, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan default item: %w", err)
+		}
+		defaults[category] = id
+	}
+	return defaults, rows.Err()
+}
+
+// getItemExcludes returns the slots each of the loadout's items excludes
+func getItemExcludes(ctx context.Context, q DBTX, loadout models.Loadout) (map[int64][]string, error) {
+	ids := make([]int64, 0, len(loadout))
+	for _, id := range loadout {
+		ids = append(ids, id)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, metadata->'excludes'
+		FROM character_items
+		WHERE id = ANY($1) AND jsonb_typeof(metadata->'excludes') = 'array'
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item exclusions: %w", err)
+	}
+	defer rows.Close()
+
+	excludes := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, fmt.Errorf("failed to scan item exclusions: %w", err)
+		}
+		var slots []string
+		if err := json.Unmarshal(raw, &slots); err != nil {
+			// Malformed metadata is ignored rather than blocking equips
+			continue
+		}
+		excludes[id] = slots
+	}
+	return excludes, rows.Err()
+}
+
+// checkEquippable validates that the user may wear itemID in slot. Owned
+// items stay wearable after they leave the shop; unowned default items
+// must still be active.
+func checkEquippable(ctx context.Context, q DBTX, userID int64, slot string, itemID int64) error {
+	var category string
+	var isDefault, isActive, owned bool
+	err := q.QueryRowContext(ctx, `
+		SELECT ci.category, COALESCE(ci.is_default, false), COALESCE(ci.is_active, true),
+		       EXISTS(SELECT 1 FROM user_inventory ui WHERE ui.user_id = $1 AND ui.item_id = ci.id)
+		FROM character_items ci
+		WHERE ci.id = $2
+	`, userID, itemID).Scan(&category, &isDefault, &isActive, &owned)
+	if err == sql.ErrNoRows {
+		return ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get item: %w", err)
+	}
+
+	if category != slot {
+		return ErrSlotMismatch
+	}
+	if owned {
+		return nil
+	}
+	if !isDefault {
+		return ErrItemNotOwned
+	}
+	if !isActive {
+		return ErrItemUnavailable
+	}
+	return nil
+}
+
+// GetCharacter returns the user's equipped items, with defaults in empty
+// required slots, and skin colour
+func (r *ProgressRepository) GetCharacter(ctx context.Context, userID int64) (models.Loadout, string, error) {
+	loadout, skinColor, err := loadCharacter(ctx, r.db, userID, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defaults, err := getDefaultItems(ctx, r.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return loadout.WithDefaults(defaults), skinColor, nil
+}
+
+// EquipItem puts itemID into slot, or empties the slot when itemID is nil.
+// Items excluded by the new item are taken off. Returns the full loadout.
+func (r *ProgressRepository) EquipItem(ctx context.Context, userID int64, slot string, itemID *int64) (models.Loadout, error) {
+	if !models.IsCharacterSlot(slot) {
+		return nil, ErrInvalidSlot
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	loadout, _, err := loadCharacter(ctx, tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := getDefaultItems(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case itemID != nil:
+		if err := checkEquippable(ctx, tx, userID, slot, *itemID); err != nil {
+			return nil, err
+		}
+		loadout[slot] = *itemID
+	case models.RequiredSlots[slot]:
+		def, ok := defaults[slot]
+		if !ok {
+			return nil, ErrSlotRequired
+		}
+		loadout[slot] = def
+	default:
+		delete(loadout, slot)
+	}
+
+	excludes, err := getItemExcludes(ctx, tx, loadout)
+	if err != nil {
+		return nil, err
+	}
+	loadout.ResolveExclusions(slot, excludes, defaults)
+
+	if err := saveLoadout(ctx, tx, userID, loadout); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit equip: %w", err)
+	}
+
+	return loadout.WithDefaults(defaults), nil
+}
+
+// GetAvatar returns the user's worn items (not the room's wallpaper and
+// floor) as render layers for the media service
+func (r *ProgressRepository) GetAvatar(ctx context.Context, userID int64) (*models.Avatar, error) {
+	loadout, skinColor, err := r.GetCharacter(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := loadout.AvatarItemIDs()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, category, asset_key, COALESCE(asset_type, 'svg'), COALESCE(render_order, 0),
+		       COALESCE(metadata->>'spritesheet_key', ''),
+		       CASE WHEN jsonb_typeof(metadata->'frameWidth') = 'number' THEN (metadata->>'frameWidth')::int ELSE 0 END,
+		       CASE WHEN jsonb_typeof(metadata->'frameHeight') = 'number' THEN (metadata->>'frameHeight')::int ELSE 0 END
+		FROM character_items
+		WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query avatar items: %w", err)
+	}
+	defer rows.Close()
+
+	layers := make([]models.AvatarLayer, 0, len(ids))
+	for rows.Next() {
+		var l models.AvatarLayer
+		var sprite models.AvatarSprite
+		err := rows.Scan(&l.ItemID, &l.Category, &l.AssetKey, &l.AssetType, &l.RenderOrder,
+			&sprite.Key, &sprite.FrameWidth, &sprite.FrameHeight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan avatar item: %w", err)
+		}
+		if sprite.Key != "" && sprite.FrameWidth > 0 && sprite.FrameHeight > 0 {
+			l.Sprite = &sprite
+		}
+		layers = append(layers, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query avatar items: %w", err)
+	}
+
+	avatar := models.NewAvatar(userID, skinColor, layers)
+	return &avatar, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEquippable(t *testing.T) {
+	cases := []struct {
+		name                     string
+		isDefault, active, owned bool
+		want                     error
+	}{
+		{"owned", false, true, true, nil},
+		{"owned after leaving the shop", false, false, true, nil},
+		{"not owned", false, true, false, ErrItemNotOwned},
+		{"default", true, true, false, nil},
+		{"inactive default", true, false, false, ErrItemUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &dbtest.Driver{Query: func(string, []driver.Value) (*dbtest.Rows, error) {
+				return &dbtest.Rows{
+					Columns: []string{"category", "is_default", "is_active", "owned"},
+					Values:  [][]driver.Value{{"hat", tc.isDefault, tc.active, tc.owned}},
+				}, nil
+			}}
+			err := checkEquippable(context.Background(), dbtest.Open(t, d), 1, "hat", 12)
+			assert.Equal(t, tc.want, err)
+		})
+	}
+
+	d := &dbtest.Driver{Query: func(string, []driver.Value) (*dbtest.Rows, error) {
+		return &dbtest.Rows{
+			Columns: []string{"category", "is_default", "is_active", "owned"},
+			Values:  [][]driver.Value{{"pet", false, true, true}},
+		}, nil
+	}}
+	assert.Equal(t, ErrSlotMismatch, checkEquippable(context.Background(), dbtest.Open(t, d), 1, "hat", 12))
+}
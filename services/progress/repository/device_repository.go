@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// SYNC DEVICE REGISTRY
+// ================================================================
+
+// A sync is made with an auth session (a sessions row, whose token column
+// holds the session's current access token). The device ID only names the
+// device in the registry; revoking a device ends the session it last synced
+// with, so it cannot keep syncing by reporting a different ID.
+
+var (
+	// ErrDeviceRevoked is returned when a revoked device attempts to sync
+	ErrDeviceRevoked = errors.New("device has been revoked")
+
+	// ErrSyncSessionEnded is returned when the request's token no longer
+	// belongs to a live session, e.g. because its device was revoked
+	ErrSyncSessionEnded = errors.New("session has ended")
+)
+
+// AuthorizeSyncDevice checks that token belongs to a live session of the
+// user and that deviceID has not been revoked. Returns the session ID.
+func (r *ProgressRepository) AuthorizeSyncDevice(ctx context.Context, userID int64, token, deviceID string) (string, error) {
+	query := `
+		SELECT s.id, COALESCE(d.revoked_at IS NOT NULL, false)
+		FROM sessions s
+		LEFT JOIN sync_devices d ON d.user_id = s.user_id AND d.device_id = $3
+		WHERE s.user_id = $1 AND s.token = $2 AND s.expires_at > NOW()
+	`
+
+	var sessionID string
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, query, userID, token, deviceID).Scan(&sessionID, &revoked)
+	if err == sql.ErrNoRows {
+		return "", ErrSyncSessionEnded
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check device: %w", err)
+	}
+	if revoked {
+		return "", ErrDeviceRevoked
+	}
+
+	return sessionID, nil
+}
+
+// RecordDeviceSync registers a device (if new) and records the outcome of a sync.
+// Revoked devices are never updated and yield ErrDeviceRevoked.
+func (r *ProgressRepository) RecordDeviceSync(ctx context.Context, userID int64, report *models.SyncDeviceReport) error {
+	query := `
+		INSERT INTO sync_devices (
+			user_id, device_id, platform, app_version, last_sync_at,
+			last_items_applied, last_items_failed, total_items_applied, total_items_failed,
+			pending_items, retry_items, last_error, session_id, created_at, updated_at
+		) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NOW(), $5, $6, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, '')::uuid, NOW(), NOW())
+		ON CONFLICT (user_id, device_id) DO UPDATE SET
+			platform = COALESCE(EXCLUDED.platform, sync_devices.platform),
+			app_version = COALESCE(EXCLUDED.app_version, sync_devices.app_version),
+			last_sync_at = EXCLUDED.last_sync_at,
+			last_items_applied = EXCLUDED.last_items_applied,
+			last_items_failed = EXCLUDED.last_items_failed,
+			total_items_applied = sync_devices.total_items_applied + EXCLUDED.last_items_applied,
+			total_items_failed = sync_devices.total_items_failed + EXCLUDED.last_items_failed,
+			pending_items = EXCLUDED.pending_items,
+			retry_items = EXCLUDED.retry_items,
+			last_error = COALESCE(EXCLUDED.last_error, sync_devices.last_error),
+			session_id = COALESCE(EXCLUDED.session_id, sync_devices.session_id),
+			updated_at = NOW()
+		WHERE sync_devices.revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		userID, report.DeviceID, report.Platform, report.AppVersion,
+		report.ItemsApplied, report.ItemsFailed, report.PendingItems, report.RetryItems,
+		report.LastError, report.SessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record device sync: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrDeviceRevoked
+	}
+
+	r.invalidateSyncStatusCache(ctx, userID)
+
+	return nil
+}
+
+// GetSyncDevices lists all devices that have synced for a user, most recent first
+func (r *ProgressRepository) GetSyncDevices(ctx context.Context, userID int64) ([]models.SyncDevice, error) {
+	query := `
+		SELECT device_id, COALESCE(platform, ''), COALESCE(app_version, ''), last_sync_at,
+		       last_items_applied, last_items_failed, total_items_applied, total_items_failed,
+		       pending_items, retry_items, last_error, revoked_at, created_at
+		FROM sync_devices
+		WHERE user_id = $1
+		ORDER BY last_sync_at DESC NULLS LAST, created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := []models.SyncDevice{}
+	for rows.Next() {
+		var d models.SyncDevice
+		err := rows.Scan(
+			&d.DeviceID, &d.Platform, &d.AppVersion, &d.LastSyncAt,
+			&d.LastItemsApplied, &d.LastItemsFailed, &d.TotalItemsApplied, &d.TotalItemsFailed,
+			&d.PendingItems, &d.RetryItems, &d.LastError, &d.RevokedAt, &d.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync device: %w", err)
+		}
+		devices = append(devices, d)
+	}
+
+	return devices, nil
+}
+
+// RevokeSyncDevice revokes a device so it can no longer sync, and ends the
+// session it last synced with.
+// Returns false if the device does not exist or is already revoked.
+func (r *ProgressRepository) RevokeSyncDevice(ctx context.Context, userID int64, deviceID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sessionID sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		UPDATE sync_devices
+		SET revoked_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+		RETURNING session_id
+	`, userID, deviceID).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke device: %w", err)
+	}
+
+	if sessionID.Valid {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM sessions WHERE id = $1 AND user_id = $2
+		`, sessionID.String, userID)
+		if err != nil {
+			return false, fmt.Errorf("failed to end device session: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit revocation: %w", err)
+	}
+
+	r.invalidateSyncStatusCache(ctx, userID)
+
+	return true, nil
+}
+
+func (r *ProgressRepository) invalidateSyncStatusCache(ctx context.Context, userID int64) {
+	cacheKey := fmt.Sprintf("sync:status:%d", userID)
+	r.redis.Del(ctx, cacheKey)
+}
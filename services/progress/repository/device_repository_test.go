@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository returns a repository on d whose Redis is unreachable;
+// cache invalidation errors are ignored by the repository
+func newTestRepository(t *testing.T, d *dbtest.Driver) *ProgressRepository {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return NewProgressRepository(dbtest.Open(t, d), rdb)
+}
+
+// newTestRepositoryWithEmptyRedis returns a repository on d whose Redis
+// holds no keys: every read, including inside MULTI/EXEC, replies nil
+func newTestRepositoryWithEmptyRedis(t *testing.T, d *dbtest.Driver) *ProgressRepository {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveEmptyRedis(conn)
+		}
+	}()
+
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return NewProgressRepository(dbtest.Open(t, d), rdb)
+}
+
+func serveEmptyRedis(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	queued := -1 // commands queued since MULTI, -1 outside a transaction
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+		var name string
+		for i := 0; i < n; i++ {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			arg, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				name = strings.ToUpper(strings.TrimSpace(arg))
+			}
+		}
+
+		reply := "$-1\r\n"
+		switch {
+		case name == "MULTI":
+			queued, reply = 0, "+OK\r\n"
+		case name == "EXEC":
+			reply = "*" + strconv.Itoa(queued) + "\r\n" + strings.Repeat("$-1\r\n", queued)
+			queued = -1
+		case queued >= 0:
+			queued, reply = queued+1, "+QUEUED\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func TestAuthorizeSyncDevice(t *testing.T) {
+	ctx := context.Background()
+	var row []driver.Value
+	var queryErr error
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		rows := &dbtest.Rows{Columns: []string{"id", "revoked"}}
+		if row != nil {
+			rows.Values = [][]driver.Value{row}
+		}
+		return rows, queryErr
+	}}
+	repo := newTestRepository(t, d)
+
+	row = []driver.Value{"5f0c", false}
+	sessionID, err := repo.AuthorizeSyncDevice(ctx, 1, "tok", "phone")
+	require.NoError(t, err)
+	assert.Equal(t, "5f0c", sessionID)
+
+	row = []driver.Value{"5f0c", true}
+	_, err = repo.AuthorizeSyncDevice(ctx, 1, "tok", "phone")
+	assert.ErrorIs(t, err, ErrDeviceRevoked)
+
+	row = nil
+	_, err = repo.AuthorizeSyncDevice(ctx, 1, "tok", "other-id")
+	assert.ErrorIs(t, err, ErrSyncSessionEnded)
+
+	queryErr = errors.New("connection reset")
+	_, err = repo.AuthorizeSyncDevice(ctx, 1, "tok", "phone")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrDeviceRevoked)
+	assert.NotErrorIs(t, err, ErrSyncSessionEnded)
+}
+
+func TestRevokeSyncDeviceEndsSession(t *testing.T) {
+	var sessionRow []driver.Value
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		rows := &dbtest.Rows{Columns: []string{"session_id"}}
+		if sessionRow != nil {
+			rows.Values = [][]driver.Value{sessionRow}
+		}
+		return rows, nil
+	}}
+	repo := newTestRepository(t, d)
+
+	sessionRow = []driver.Value{"5f0c"}
+	revoked, err := repo.RevokeSyncDevice(context.Background(), 1, "phone")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	applied := d.Applied()
+	require.Len(t, applied, 1)
+	assert.Contains(t, applied[0].Query, "DELETE FROM sessions")
+	assert.Equal(t, []driver.Value{"5f0c", int64(1)}, applied[0].Args)
+
+	// Unknown or already revoked devices end nothing
+	sessionRow = nil
+	revoked, err = repo.RevokeSyncDevice(context.Background(), 1, "phone")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Len(t, d.Applied(), 1)
+}
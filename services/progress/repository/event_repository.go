@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/realtime"
+)
+
+// ================================================================
+// REAL-TIME EVENTS
+// ================================================================
+
+// PublishEvent notifies the user's other devices of a change.
+// Failures are logged and never fail the write that triggered them.
+func (r *ProgressRepository) PublishEvent(ctx context.Context, userID int64, eventType string, data map[string]interface{}) {
+	event := &models.ProgressEvent{
+		Type:         eventType,
+		UserID:       userID,
+		SourceDevice: realtime.SourceDevice(ctx),
+		Data:         data,
+	}
+
+	if err := realtime.Publish(ctx, r.redis, event); err != nil {
+		log.Printf("[REALTIME] Failed to publish %s event for user %d: %v", eventType, userID, err)
+	}
+}
+
+// publishStreak pushes the user's current streak after study activity and
+// shares milestone streaks with friends
+func (r *ProgressRepository) publishStreak(ctx context.Context, userID int64) {
+	streak := r.calculateCurrentStreak(ctx, userID)
+	r.PublishEvent(ctx, userID, models.EventTypeStreak, map[string]interface{}{
+		"current_streak": streak,
+	})
+	r.recordStreakMilestone(ctx, userID, streak)
+}
@@ -0,0 +1,569 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// FRIENDS, FOLLOWS AND BLOCKS
+// ================================================================
+// Friendships are mutual and stored in both directions. Follows and blocks
+// share user_follows / user_blocks (and the users follower counters) with
+// the SNS service. Changes to a pair of users are serialized with an
+// advisory lock so requests, accepts and blocks cannot interleave.
+// ================================================================
+
+var (
+	// ErrSelfRelation is returned when a user targets themselves
+	ErrSelfRelation = errors.New("cannot target yourself")
+
+	// ErrUserNotFound is returned when the other user does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserBlocked is returned when either user has blocked the other
+	ErrUserBlocked = errors.New("user is blocked")
+
+	// ErrAlreadyFriends is returned for friend requests between friends
+	ErrAlreadyFriends = errors.New("already friends")
+
+	// ErrFriendRequestNotFound is returned for unknown or answered requests
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+
+	// ErrNotFriends is returned when removing someone who is not a friend
+	ErrNotFriends = errors.New("not friends")
+)
+
+// lockUserPair serializes relationship changes between two users until the
+// transaction ends
+func lockUserPair(ctx context.Context, q DBTX, a, b int64) error {
+	if a > b {
+		a, b = b, a
+	}
+	_, err := q.ExecContext(ctx,
+		`SELECT pg_advisory_xact_lock(hashtext('user_pair'), hashtext($1::text || ':' || $2::text))`, a, b,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock users: %w", err)
+	}
+	return nil
+}
+
+// checkRelationTarget validates that target is another, existing user who
+// has not blocked (or been blocked by) userID
+func checkRelationTarget(ctx context.Context, q DBTX, userID, targetID int64) error {
+	if userID == targetID {
+		return ErrSelfRelation
+	}
+
+	var exists, blocked bool
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			EXISTS(SELECT 1 FROM users WHERE id = $2),
+			EXISTS(
+				SELECT 1 FROM user_blocks
+				WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+			)
+	`, userID, targetID).Scan(&exists, &blocked)
+	if err != nil {
+		return fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+	return nil
+}
+
+// SendFriendRequest asks targetID to be friends. If targetID already asked
+// userID, that request is accepted instead. Returns the request and whether
+// the users are now friends.
+func (r *ProgressRepository) SendFriendRequest(ctx context.Context, userID, targetID int64) (*models.FriendRequest, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if userID != targetID {
+		if err := lockUserPair(ctx, tx, userID, targetID); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := checkRelationTarget(ctx, tx, userID, targetID); err != nil {
+		return nil, false, err
+	}
+
+	var friends bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM friendships WHERE user_id = $1 AND friend_id = $2)`, userID, targetID,
+	).Scan(&friends)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check friendship: %w", err)
+	}
+	if friends {
+		return nil, false, ErrAlreadyFriends
+	}
+
+	var requestID, requesterID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, requester_id FROM friend_requests
+		WHERE status = 'pending'
+		  AND ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+	`, userID, targetID).Scan(&requestID, &requesterID)
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO friend_requests (requester_id, addressee_id) VALUES ($1, $2) RETURNING id`,
+			userID, targetID,
+		).Scan(&requestID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create friend request: %w", err)
+		}
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to get friend request: %w", err)
+	case requesterID == targetID:
+		// They asked first: accept
+		if err := acceptFriendRequest(ctx, tx, requestID, targetID, userID); err != nil {
+			return nil, false, err
+		}
+		friends = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	request, err := r.getFriendRequest(ctx, requestID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	return request, friends, nil
+}
+
+// RespondFriendRequest accepts or declines a pending request sent to userID
+func (r *ProgressRepository) RespondFriendRequest(ctx context.Context, userID, requestID int64, accept bool) (*models.FriendRequest, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var requesterID int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT requester_id FROM friend_requests WHERE id = $1 AND addressee_id = $2`, requestID, userID,
+	).Scan(&requesterID)
+	if err == sql.ErrNoRows {
+		return nil, ErrFriendRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friend request: %w", err)
+	}
+
+	if err := lockUserPair(ctx, tx, userID, requesterID); err != nil {
+		return nil, err
+	}
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM friend_requests WHERE id = $1`, requestID).Scan(&status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friend request: %w", err)
+	}
+	if status != models.FriendRequestPending {
+		return nil, ErrFriendRequestNotFound
+	}
+
+	if accept {
+		err = acceptFriendRequest(ctx, tx, requestID, requesterID, userID)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE friend_requests SET status = 'declined', responded_at = NOW() WHERE id = $1`, requestID,
+		)
+		if err != nil {
+			err = fmt.Errorf("failed to decline friend request: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.getFriendRequest(ctx, requestID, userID)
+}
+
+// CancelFriendRequest withdraws a pending request sent by userID
+func (r *ProgressRepository) CancelFriendRequest(ctx context.Context, userID, requestID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE friend_requests SET status = 'cancelled', responded_at = NOW()
+		WHERE id = $1 AND requester_id = $2 AND status = 'pending'
+	`, requestID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel friend request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrFriendRequestNotFound
+	}
+	return nil
+}
+
+// acceptFriendRequest marks a request accepted and stores the friendship
+// in both directions. The caller holds the pair lock.
+func acceptFriendRequest(ctx context.Context, q DBTX, requestID, requesterID, addresseeID int64) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE friend_requests SET status = 'accepted', responded_at = NOW() WHERE id = $1`, requestID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to accept friend request: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO friendships (user_id, friend_id) VALUES ($1, $2), ($2, $1)
+		ON CONFLICT (user_id, friend_id) DO NOTHING
+	`, requesterID, addresseeID)
+	if err != nil {
+		return fmt.Errorf("failed to create friendship: %w", err)
+	}
+	return nil
+}
+
+// getFriendRequest loads a request as seen by viewerID
+func (r *ProgressRepository) getFriendRequest(ctx context.Context, requestID, viewerID int64) (*models.FriendRequest, error) {
+	rows, err := r.db.QueryContext(ctx, friendRequestSelect+` WHERE fr.id = $2`, viewerID, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friend request: %w", err)
+	}
+	requests, err := scanFriendRequests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrFriendRequestNotFound
+	}
+	return &requests[0], nil
+}
+
+// ListFriendRequests returns the pending requests sent to and by the user
+func (r *ProgressRepository) ListFriendRequests(ctx context.Context, userID int64) ([]models.FriendRequest, []models.FriendRequest, error) {
+	rows, err := r.db.QueryContext(ctx, friendRequestSelect+`
+		WHERE fr.status = 'pending' AND (fr.addressee_id = $1 OR fr.requester_id = $1)
+		ORDER BY fr.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query friend requests: %w", err)
+	}
+	requests, err := scanFriendRequests(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	incoming, outgoing := []models.FriendRequest{}, []models.FriendRequest{}
+	for _, fr := range requests {
+		if fr.AddresseeID == userID {
+			incoming = append(incoming, fr)
+		} else {
+			outgoing = append(outgoing, fr)
+		}
+	}
+	return incoming, outgoing, nil
+}
+
+// friendRequestSelect selects requests with the profile of the party other
+// than $1
+const friendRequestSelect = `
+	SELECT fr.id, fr.requester_id, fr.addressee_id, fr.status, fr.created_at, fr.responded_at,
+	       u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, '')
+	FROM friend_requests fr
+	JOIN users u ON u.id = CASE WHEN fr.requester_id = $1 THEN fr.addressee_id ELSE fr.requester_id END
+`
+
+// scanFriendRequests reads and closes rows from friendRequestSelect
+func scanFriendRequests(rows *sql.Rows) ([]models.FriendRequest, error) {
+	defer rows.Close()
+
+	requests := []models.FriendRequest{}
+	for rows.Next() {
+		var fr models.FriendRequest
+		err := rows.Scan(&fr.ID, &fr.RequesterID, &fr.AddresseeID, &fr.Status, &fr.CreatedAt, &fr.RespondedAt,
+			&fr.User.UserID, &fr.User.Name, &fr.User.ProfileImageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan friend request: %w", err)
+		}
+		requests = append(requests, fr)
+	}
+	return requests, rows.Err()
+}
+
+// ListFriends returns the user's friends, most recent first
+func (r *ProgressRepository) ListFriends(ctx context.Context, userID int64) ([]models.Friend, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, ''), f.created_at
+		FROM friendships f
+		JOIN users u ON u.id = f.friend_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friends: %w", err)
+	}
+	defer rows.Close()
+
+	friends := []models.Friend{}
+	for rows.Next() {
+		var f models.Friend
+		if err := rows.Scan(&f.UserID, &f.Name, &f.ProfileImageURL, &f.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan friend: %w", err)
+		}
+		friends = append(friends, f)
+	}
+	return friends, rows.Err()
+}
+
+// RemoveFriend ends a friendship in both directions
+func (r *ProgressRepository) RemoveFriend(ctx context.Context, userID, friendID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM friendships
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+	`, userID, friendID)
+	if err != nil {
+		return fmt.Errorf("failed to remove friend: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFriends
+	}
+	return nil
+}
+
+// Follow makes userID follow targetID. Following twice is a no-op.
+func (r *ProgressRepository) Follow(ctx context.Context, userID, targetID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if userID != targetID {
+		if err := lockUserPair(ctx, tx, userID, targetID); err != nil {
+			return err
+		}
+	}
+	if err := checkRelationTarget(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO user_follows (follower_id, following_id) VALUES ($1, $2)
+		ON CONFLICT (follower_id, following_id) DO NOTHING
+	`, userID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		if err := adjustFollowCounts(ctx, tx, userID, targetID, 1); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Unfollow stops userID following targetID
+func (r *ProgressRepository) Unfollow(ctx context.Context, userID, targetID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteFollow(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListFollowing returns the users userID follows
+func (r *ProgressRepository) ListFollowing(ctx context.Context, userID int64) ([]models.UserSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, '')
+		FROM user_follows uf
+		JOIN users u ON u.id = uf.following_id
+		WHERE uf.follower_id = $1
+		ORDER BY uf.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query follows: %w", err)
+	}
+	return scanUserSummaries(rows)
+}
+
+// deleteFollow removes a follow and its counters, if present
+func deleteFollow(ctx context.Context, q DBTX, followerID, followingID int64) error {
+	result, err := q.ExecContext(ctx,
+		`DELETE FROM user_follows WHERE follower_id = $1 AND following_id = $2`, followerID, followingID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unfollow user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return adjustFollowCounts(ctx, q, followerID, followingID, -1)
+	}
+	return nil
+}
+
+// adjustFollowCounts keeps the users follower/following counters in step
+// with user_follows, as the SNS service does
+func adjustFollowCounts(ctx context.Context, q DBTX, followerID, followingID int64, delta int) error {
+	_, err := q.ExecContext(ctx,
+		`UPDATE users SET following_count = GREATEST(COALESCE(following_count, 0) + $2, 0) WHERE id = $1`,
+		followerID, delta,
+	)
+	if err == nil {
+		_, err = q.ExecContext(ctx,
+			`UPDATE users SET follower_count = GREATEST(COALESCE(follower_count, 0) + $2, 0) WHERE id = $1`,
+			followingID, delta,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update follow counts: %w", err)
+	}
+	return nil
+}
+
+// Block blocks targetID and removes every friendship, follow and pending
+// friend request between the two users
+func (r *ProgressRepository) Block(ctx context.Context, userID, targetID int64) error {
+	if userID == targetID {
+		return ErrSelfRelation
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUserPair(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, userID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM friendships
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+	`, userID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to remove friend: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE friend_requests SET status = 'cancelled', responded_at = NOW()
+		WHERE status = 'pending'
+		  AND ((requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1))
+	`, userID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel friend requests: %w", err)
+	}
+
+	if err := deleteFollow(ctx, tx, userID, targetID); err != nil {
+		return err
+	}
+	if err := deleteFollow(ctx, tx, targetID, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Unblock removes a block. Friendships and follows are not restored.
+func (r *ProgressRepository) Unblock(ctx context.Context, userID, targetID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, userID, targetID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	return nil
+}
+
+// ListBlocked returns the users userID has blocked
+func (r *ProgressRepository) ListBlocked(ctx context.Context, userID int64) ([]models.UserSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, '')
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+	return scanUserSummaries(rows)
+}
+
+// scanUserSummaries reads and closes rows of (id, name, profile_image_url)
+func scanUserSummaries(rows *sql.Rows) ([]models.UserSummary, error) {
+	defer rows.Close()
+
+	users := []models.UserSummary{}
+	for rows.Next() {
+		var u models.UserSummary
+		if err := rows.Scan(&u.UserID, &u.Name, &u.ProfileImageURL); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetActivityPrivacy returns who may see the user's activity
+func (r *ProgressRepository) GetActivityPrivacy(ctx context.Context, userID int64) (string, error) {
+	privacy := models.ActivityPrivacyFriends
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(activity_privacy, 'friends') FROM users WHERE id = $1`, userID,
+	).Scan(&privacy)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get activity privacy: %w", err)
+	}
+	return privacy, nil
+}
+
+// SetActivityPrivacy updates who may see the user's activity. It applies
+// to existing feed items too.
+func (r *ProgressRepository) SetActivityPrivacy(ctx context.Context, userID int64, privacy string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET activity_privacy = $2 WHERE id = $1`, userID, privacy)
+	if err != nil {
+		return fmt.Errorf("failed to update activity privacy: %w", err)
+	}
+	return nil
+}
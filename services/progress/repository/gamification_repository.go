@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// GAMIFICATION (LEMON REWARDS)
+// ================================================================
+
+// ErrLessonNotCompleted is returned when a reward is claimed for a lesson the
+// user has not completed
+var ErrLessonNotCompleted = errors.New("lesson not completed")
+
+// SaveLessonReward records a lesson completion reward computed from the
+// reward rules and the quiz score stored with the user's completion. Only
+// improvements over the best previous reward are credited.
+func (r *ProgressRepository) SaveLessonReward(ctx context.Context, userID, lessonID int64) (*models.LessonRewardResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := r.saveLessonReward(ctx, tx, userID, lessonID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if result.LemonsCredited > 0 {
+		r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+			"reason":    "lesson",
+			"lesson_id": lessonID,
+			"delta":     result.LemonsCredited,
+		})
+		r.recordLeaderboardScores(ctx, userID, models.LeaderboardScores{Lemons: result.LemonsCredited})
+	}
+
+	return result, nil
+}
+
+// completedQuizScore returns the quiz score stored with the user's
+// completion of a lesson, or ErrLessonNotCompleted
+func completedQuizScore(ctx context.Context, q DBTX, userID, lessonID int64) (int, error) {
+	var score int
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(quiz_score, 0)
+		FROM user_progress
+		WHERE user_id = $1 AND lesson_id = $2 AND completed_at IS NOT NULL
+	`, userID, lessonID).Scan(&score)
+	if err == sql.ErrNoRows {
+		return 0, ErrLessonNotCompleted
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get lesson completion: %w", err)
+	}
+	return score, nil
+}
+
+// saveLessonReward computes the reward, records a lesson_rewarded event,
+// projects it onto lesson_rewards and credits the improvement
+func (r *ProgressRepository) saveLessonReward(ctx context.Context, q DBTX, userID, lessonID int64) (*models.LessonRewardResult, error) {
+	rules, err := r.GetRewardRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serialize with the user's other writes before reading the previous reward
+	if err := lockLearningEvents(ctx, q, userID); err != nil {
+		return nil, err
+	}
+
+	// The score comes from the server's record of the completion, never
+	// from the reward claim
+	quizScore, err := completedQuizScore(ctx, q, userID, lessonID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := 0
+	firstCompletion := false
+	err = q.QueryRowContext(ctx,
+		`SELECT lemons_earned FROM lesson_rewards WHERE user_id = $1 AND lesson_id = $2`,
+		userID, lessonID,
+	).Scan(&previous)
+	if err == sql.ErrNoRows {
+		firstCompletion = true
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get lesson reward: %w", err)
+	}
+
+	lemons := models.ResolveLessonReward(rules, quizScore, firstCompletion)
+	credited := lemons - previous
+	if credited < 0 {
+		credited = 0
+	}
+
+	data := &models.LessonRewardedData{
+		LessonID:       lessonID,
+		LemonsEarned:   lemons,
+		LemonsCredited: credited,
+		QuizScore:      quizScore,
+	}
+
+	event, err := r.appendLearningEvent(ctx, q, userID, models.LearningEventLessonRewarded, data)
+	if err != nil {
+		return nil, err
+	}
+
+	best, err := r.projectLessonReward(ctx, q, userID, data, event.OccurredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := creditLemons(ctx, q, userID, credited, models.LemonTxLesson, &lessonID); err != nil {
+		return nil, err
+	}
+
+	return &models.LessonRewardResult{
+		LemonsEarned:    best,
+		LemonsCredited:  credited,
+		FirstCompletion: firstCompletion,
+	}, nil
+}
+
+// CompleteBossQuiz records a boss quiz completion and credits the bonus
+// computed from the reward rules. Failing scores are not recorded.
+func (r *ProgressRepository) CompleteBossQuiz(ctx context.Context, userID int64, level, week, score int) (*models.BossQuizResult, error) {
+	rules, err := r.GetRewardRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bonus, passed := models.ResolveBossReward(rules, level, week, score)
+	if !passed {
+		return &models.BossQuizResult{Passed: false}, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Insert boss quiz completion (ignore if already done)
+	query := `
+		INSERT INTO boss_quiz_completions (user_id, level, week, score, bonus_lemons)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, level, week) DO NOTHING
+		RETURNING id
+	`
+
+	var id int64
+	err = tx.QueryRowContext(ctx, query, userID, level, week, score, bonus).Scan(&id)
+	if err == sql.ErrNoRows {
+		return &models.BossQuizResult{Passed: true, AlreadyCompleted: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record boss quiz: %w", err)
+	}
+
+	err = r.AppendLearningEvent(ctx, tx, userID, models.LearningEventBossQuizCompleted, &models.BossQuizCompletedData{
+		Level:       level,
+		Week:        week,
+		Score:       score,
+		BonusLemons: bonus,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := creditLemons(ctx, tx, userID, bonus, models.LemonTxBoss, nil); err != nil {
+		return nil, err
+	}
+
+	if err := awardXP(ctx, tx, userID, models.XPSourceBossQuiz, 1, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if bonus > 0 {
+		r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+			"reason": "boss",
+			"delta":  bonus,
+		})
+		r.recordLeaderboardScores(ctx, userID, models.LeaderboardScores{Lemons: bonus})
+	}
+
+	return &models.BossQuizResult{Passed: true, BonusLemons: bonus}, nil
+}
+
+// projectLessonReward projects a lesson_rewarded event onto lesson_rewards.
+// Only the best result per lesson is kept. Returns the recorded lemons_earned.
+func (r *ProgressRepository) projectLessonReward(ctx context.Context, q DBTX, userID int64, d *models.LessonRewardedData, at time.Time) (int, error) {
+	query := `
+		INSERT INTO lesson_rewards (user_id, lesson_id, lemons_earned, best_quiz_score, earned_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (user_id, lesson_id) DO UPDATE
+		SET lemons_earned = GREATEST(lesson_rewards.lemons_earned, EXCLUDED.lemons_earned),
+		    best_quiz_score = GREATEST(lesson_rewards.best_quiz_score, EXCLUDED.best_quiz_score),
+		    updated_at = EXCLUDED.updated_at
+		RETURNING lemons_earned
+	`
+
+	var actualLemons int
+	err := q.QueryRowContext(ctx, query, userID, d.LessonID, d.LemonsEarned, d.QuizScore, at).Scan(&actualLemons)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save lesson reward: %w", err)
+	}
+
+	return actualLemons, nil
+}
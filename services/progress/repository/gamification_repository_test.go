@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLessonRewardRequiresCompletion(t *testing.T) {
+	var progressArgs []driver.Value
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		if strings.Contains(query, "FROM user_progress") {
+			progressArgs = args
+		}
+		return nil, nil
+	}}
+	repo := newTestRepository(t, d)
+
+	_, err := repo.SaveLessonReward(context.Background(), 1, 999)
+	require.ErrorIs(t, err, ErrLessonNotCompleted)
+	assert.Equal(t, []driver.Value{int64(1), int64(999)}, progressArgs)
+
+	for _, st := range d.Applied() {
+		assert.NotContains(t, st.Query, "lemon", "nothing is credited for a made-up lesson")
+	}
+	assert.Equal(t, 1, d.Rollbacks())
+}
@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// GIFTS
+// ================================================================
+// Friends can give each other lemons or shop items. The transfer, the
+// ledger postings and the gift row (which is also the recipient's
+// notification) are written in one transaction. Gifts are serialized per
+// sender and per recipient with advisory locks so the daily limits cannot
+// be raced.
+// ================================================================
+
+var (
+	// ErrAccountTooNew is returned when either account is younger than
+	// the minimum age for gifting
+	ErrAccountTooNew = errors.New("account too new to send or receive gifts")
+
+	// ErrGiftLimitReached is returned when a gift would exceed the sender's
+	// daily limits
+	ErrGiftLimitReached = errors.New("daily gift limit reached")
+
+	// ErrGiftReceiveLimitReached is returned when a lemon gift would exceed
+	// the recipient's daily limit
+	ErrGiftReceiveLimitReached = errors.New("recipient's daily gift limit reached")
+
+	// ErrItemNotFound is returned for unknown shop items
+	ErrItemNotFound = errors.New("item not found")
+
+	// ErrItemUnavailable is returned for items that are not for sale
+	ErrItemUnavailable = errors.New("item not available")
+
+	// ErrItemAlreadyOwned is returned when the recipient already owns the item
+	ErrItemAlreadyOwned = errors.New("item already owned")
+)
+
+// getGiftSettings loads the gifting limits
+func getGiftSettings(ctx context.Context, q DBTX) (models.GiftSettings, error) {
+	s := models.GiftSettings{DailyCount: 5, DailyLemons: 50, ReceivedDailyLemons: 100, MinAccountAgeDays: 7}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(gift_daily_count_limit, 5), COALESCE(gift_daily_lemon_limit, 50),
+		       COALESCE(gift_received_daily_lemon_limit, 100), COALESCE(gift_min_account_age_days, 7)
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&s.DailyCount, &s.DailyLemons, &s.ReceivedDailyLemons, &s.MinAccountAgeDays)
+	if err != nil && err != sql.ErrNoRows {
+		return s, fmt.Errorf("failed to get gift settings: %w", err)
+	}
+
+	return s, nil
+}
+
+// SendGift gives a friend lemons, or buys them the shop item itemID when
+// lemons is 0. Returns the gift and the sender's remaining lemons.
+func (r *ProgressRepository) SendGift(ctx context.Context, senderID, recipientID int64, lemons int, itemID int64, message string) (*models.Gift, int, error) {
+	if senderID == recipientID {
+		return nil, 0, ErrSelfRelation
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('gifts'), $1::int)`, senderID); err != nil {
+		return nil, 0, fmt.Errorf("failed to lock sender: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('gifts_received'), $1::int)`, recipientID); err != nil {
+		return nil, 0, fmt.Errorf("failed to lock recipient: %w", err)
+	}
+	if err := checkRelationTarget(ctx, tx, senderID, recipientID); err != nil {
+		return nil, 0, err
+	}
+
+	var friends bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM friendships WHERE user_id = $1 AND friend_id = $2)`, senderID, recipientID,
+	).Scan(&friends)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check friendship: %w", err)
+	}
+	if !friends {
+		return nil, 0, ErrNotFriends
+	}
+
+	settings, err := getGiftSettings(ctx, tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now().UTC()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, COALESCE(name, ''), COALESCE(profile_image_url, ''), created_at
+		FROM users
+		WHERE id = ANY($1)
+	`, pq.Array([]int64{senderID, recipientID}))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query users: %w", err)
+	}
+	var recipient models.UserSummary
+	for rows.Next() {
+		var u models.UserSummary
+		var createdAt time.Time
+		if err := rows.Scan(&u.UserID, &u.Name, &u.ProfileImageURL, &createdAt); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		if !settings.AccountOldEnough(createdAt, now) {
+			rows.Close()
+			return nil, 0, ErrAccountTooNew
+		}
+		if u.UserID == recipientID {
+			recipient = u
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query users: %w", err)
+	}
+
+	var sent models.GiftsSentToday
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(lemons), 0) FROM gifts
+		WHERE sender_id = $1 AND created_at >= $2
+	`, senderID, now.Truncate(24*time.Hour)).Scan(&sent.Count, &sent.Lemons)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count gifts: %w", err)
+	}
+	if !settings.Allows(sent, lemons) {
+		return nil, 0, ErrGiftLimitReached
+	}
+
+	if lemons > 0 {
+		var received int
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(lemons), 0) FROM gifts
+			WHERE recipient_id = $1 AND created_at >= $2
+		`, recipientID, now.Truncate(24*time.Hour)).Scan(&received)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to count received gifts: %w", err)
+		}
+		if !settings.AllowsReceiving(received, lemons) {
+			return nil, 0, ErrGiftReceiveLimitReached
+		}
+	}
+
+	gift := &models.Gift{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Kind:        models.GiftKindLemons,
+		Lemons:      lemons,
+		Message:     message,
+		User:        recipient,
+	}
+
+	price := 0
+	if lemons == 0 {
+		// Item gifts are bought at the current shop price and take stock
+		offer, err := r.CheckoutShopItem(ctx, tx, itemID)
+		if err != nil {
+			return nil, 0, err
+		}
+		gift.ItemName = offer.Name
+		price = offer.Price
+
+		var owned bool
+		err = tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM user_inventory WHERE user_id = $1 AND item_id = $2)`, recipientID, itemID,
+		).Scan(&owned)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to check ownership: %w", err)
+		}
+		if owned {
+			return nil, 0, ErrItemAlreadyOwned
+		}
+
+		gift.Kind = models.GiftKindItem
+		gift.ItemID = &itemID
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO gifts (sender_id, recipient_id, kind, lemons, item_id, message)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING id, created_at
+	`, senderID, recipientID, gift.Kind, lemons, gift.ItemID, message).Scan(&gift.ID, &gift.CreatedAt)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create gift: %w", err)
+	}
+
+	// Lemon gifts move straight between wallets; item gifts are a purchase
+	// paid by the sender
+	legs := []models.LedgerLeg{
+		{UserID: senderID, Account: models.LedgerAccountWallet, Amount: -lemons},
+		{UserID: recipientID, Account: models.LedgerAccountWallet, Amount: lemons},
+	}
+	if gift.Kind == models.GiftKindItem {
+		legs = []models.LedgerLeg{
+			{UserID: senderID, Account: models.LedgerAccountWallet, Amount: -price},
+			{UserID: senderID, Account: models.LedgerAccountShop, Amount: price},
+		}
+	}
+	entries, err := postLemons(ctx, tx, models.LemonTxGift, &gift.ID, legs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if gift.Kind == models.GiftKindItem {
+		// The recipient may have bought the item since the ownership check
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO user_inventory (user_id, item_id) VALUES ($1, $2)
+			ON CONFLICT (user_id, item_id) DO NOTHING
+		`, recipientID, itemID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to add to inventory: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, 0, fmt.Errorf("failed to add to inventory: %w", err)
+		} else if n == 0 {
+			return nil, 0, ErrItemAlreadyOwned
+		}
+	}
+
+	senderBalance, err := walletBalance(ctx, tx, entries, senderID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit gift: %w", err)
+	}
+
+	spent := lemons + price
+	if spent > 0 {
+		r.PublishEvent(ctx, senderID, models.EventTypeLemons, map[string]interface{}{
+			"reason":       "gift",
+			"delta":        -spent,
+			"total_lemons": senderBalance,
+		})
+	}
+	if lemons > 0 {
+		r.PublishEvent(ctx, recipientID, models.EventTypeLemons, map[string]interface{}{
+			"reason":       "gift",
+			"delta":        lemons,
+			"total_lemons": ledgerBalance(entries, recipientID, models.LedgerAccountWallet),
+		})
+	}
+	if gift.ItemID != nil {
+		r.PublishEvent(ctx, recipientID, models.EventTypeInventory, map[string]interface{}{
+			"item_id": itemID,
+			"action":  "gifted",
+		})
+	}
+	r.PublishEvent(ctx, recipientID, models.EventTypeGift, map[string]interface{}{
+		"gift_id":   gift.ID,
+		"sender_id": senderID,
+		"kind":      gift.Kind,
+	})
+
+	return gift, senderBalance, nil
+}
+
+// walletBalance returns the user's wallet after a posting, reading it when
+// the posting did not touch the wallet (a free item)
+func walletBalance(ctx context.Context, q DBTX, entries []models.LedgerEntry, userID int64) (int, error) {
+	for _, entry := range entries {
+		if entry.UserID == userID && entry.Account == models.LedgerAccountWallet && entry.BalanceAfter != nil {
+			return *entry.BalanceAfter, nil
+		}
+	}
+
+	var balance int
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(total_lemons, 0) FROM lemon_currency WHERE user_id = $1`, userID,
+	).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get lemon balance: %w", err)
+	}
+	return balance, nil
+}
+
+// ListGifts returns the user's received (or sent) gifts, newest first,
+// starting before beforeID (0 for the newest). The next cursor is 0 on
+// the last page.
+func (r *ProgressRepository) ListGifts(ctx context.Context, userID int64, received bool, beforeID int64, limit int) ([]models.Gift, int64, error) {
+	userColumn, otherColumn := "g.sender_id", "g.recipient_id"
+	if received {
+		userColumn, otherColumn = "g.recipient_id", "g.sender_id"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT g.id, g.sender_id, g.recipient_id, g.kind, g.lemons, g.item_id, COALESCE(ci.name, ''),
+		       COALESCE(g.message, ''), u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, ''),
+		       g.created_at, g.seen_at
+		FROM gifts g
+		JOIN users u ON u.id = %[2]s
+		LEFT JOIN character_items ci ON ci.id = g.item_id
+		WHERE %[1]s = $1
+		  AND ($2::bigint = 0 OR g.id < $2)
+		ORDER BY g.id DESC
+		LIMIT $3
+	`, userColumn, otherColumn)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, beforeID, limit+1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query gifts: %w", err)
+	}
+	defer rows.Close()
+
+	gifts := []models.Gift{}
+	for rows.Next() {
+		var g models.Gift
+		var itemID sql.NullInt64
+		var seenAt sql.NullTime
+		err := rows.Scan(&g.ID, &g.SenderID, &g.RecipientID, &g.Kind, &g.Lemons, &itemID, &g.ItemName,
+			&g.Message, &g.User.UserID, &g.User.Name, &g.User.ProfileImageURL, &g.CreatedAt, &seenAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan gift: %w", err)
+		}
+		if itemID.Valid {
+			g.ItemID = &itemID.Int64
+		}
+		if seenAt.Valid {
+			g.SeenAt = &seenAt.Time
+		}
+		gifts = append(gifts, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query gifts: %w", err)
+	}
+
+	var nextCursor int64
+	if len(gifts) > limit {
+		gifts = gifts[:limit]
+		nextCursor = gifts[len(gifts)-1].ID
+	}
+
+	return gifts, nextCursor, nil
+}
+
+// CountUnseenGifts returns how many received gifts the user has not opened
+func (r *ProgressRepository) CountUnseenGifts(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM gifts WHERE recipient_id = $1 AND seen_at IS NULL`, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unseen gifts: %w", err)
+	}
+	return count, nil
+}
+
+// MarkGiftsSeen marks the user's received gifts as seen: the listed ones,
+// or all of them when giftIDs is empty. Returns how many were newly seen.
+func (r *ProgressRepository) MarkGiftsSeen(ctx context.Context, userID int64, giftIDs []int64) (int64, error) {
+	if giftIDs == nil {
+		giftIDs = []int64{}
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE gifts SET seen_at = NOW()
+		WHERE recipient_id = $1 AND seen_at IS NULL
+		  AND (cardinality($2::bigint[]) = 0 OR id = ANY($2))
+	`, userID, pq.Array(giftIDs))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark gifts seen: %w", err)
+	}
+	return result.RowsAffected()
+}
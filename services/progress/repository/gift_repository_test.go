@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shopItemRowColumns name the columns of shopItemRow
+var shopItemRowColumns = []string{"id", "category", "name", "description", "asset_key", "asset_type",
+	"is_bundled", "render_order", "sort_order", "price", "rarity", "is_default", "is_active",
+	"metadata", "available_from", "available_until", "stock", "created_at", "updated_at"}
+
+// shopItemRow returns a character_items row for getShopItem. stock is
+// nil for an unlimited item.
+func shopItemRow(id int64, price int, stock interface{}) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id, "hat", "Hat", nil, "hat.svg", "svg", false, int64(0), int64(0), int64(price),
+		"common", false, true, []byte(`{}`), nil, nil, stock, now, now}
+}
+
+// giftWorld is the database state SendGift sees
+type giftWorld struct {
+	book       *lemonBook
+	friends    bool
+	sent       models.GiftsSentToday
+	received   int
+	owned      bool // recipient owns the item before the ownership check
+	ownedLater bool // recipient buys the item before the inventory insert
+}
+
+func (w *giftWorld) driver() *dbtest.Driver {
+	boolRow := func(v bool) *dbtest.Rows {
+		return &dbtest.Rows{Columns: []string{"exists"}, Values: [][]driver.Value{{v}}}
+	}
+	return &dbtest.Driver{
+		Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+			if rows, ok := w.book.query(query, args); ok {
+				return rows, nil
+			}
+			switch {
+			case strings.Contains(query, "FROM user_blocks"):
+				return &dbtest.Rows{Columns: []string{"exists", "blocked"}, Values: [][]driver.Value{{true, false}}}, nil
+			case strings.Contains(query, "FROM friendships"):
+				return boolRow(w.friends), nil
+			case strings.Contains(query, "FROM users"):
+				joined := time.Now().AddDate(0, -1, 0)
+				return &dbtest.Rows{
+					Columns: []string{"id", "name", "profile_image_url", "created_at"},
+					Values:  [][]driver.Value{{int64(1), "A", "", joined}, {int64(2), "B", "", joined}},
+				}, nil
+			case strings.Contains(query, "WHERE sender_id = $1"):
+				return &dbtest.Rows{Columns: []string{"count", "sum"}, Values: [][]driver.Value{{int64(w.sent.Count), int64(w.sent.Lemons)}}}, nil
+			case strings.Contains(query, "WHERE recipient_id = $1"):
+				return &dbtest.Rows{Columns: []string{"sum"}, Values: [][]driver.Value{{int64(w.received)}}}, nil
+			case strings.Contains(query, "FROM character_items"):
+				return &dbtest.Rows{Columns: shopItemRowColumns, Values: [][]driver.Value{shopItemRow(4, 30, nil)}}, nil
+			case strings.Contains(query, "FROM user_inventory"):
+				return boolRow(w.owned), nil
+			case strings.Contains(query, "INSERT INTO gifts"):
+				return &dbtest.Rows{Columns: []string{"id", "created_at"}, Values: [][]driver.Value{{int64(1), time.Now()}}}, nil
+			}
+			return nil, nil
+		},
+		Exec: func(query string, args []driver.Value) (int64, error) {
+			if strings.Contains(query, "INSERT INTO user_inventory") && w.ownedLater {
+				return 0, nil
+			}
+			return 1, nil
+		},
+	}
+}
+
+func TestSendGiftLemons(t *testing.T) {
+	w := &giftWorld{book: newLemonBook(map[int64]int64{1: 100}), friends: true}
+	repo := newTestRepository(t, w.driver())
+
+	gift, balance, err := repo.SendGift(context.Background(), 1, 2, 30, 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.GiftKindLemons, gift.Kind)
+	assert.Equal(t, 70, balance)
+	assert.Equal(t, map[int64]int64{1: 70, 2: 30}, w.book.wallets)
+}
+
+func TestSendGiftRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		world  giftWorld
+		lemons int
+		itemID int64
+		want   error
+	}{
+		{"not friends", giftWorld{}, 10, 0, ErrNotFriends},
+		{"daily count", giftWorld{friends: true, sent: models.GiftsSentToday{Count: 5}}, 10, 0, ErrGiftLimitReached},
+		{"daily lemons", giftWorld{friends: true, sent: models.GiftsSentToday{Count: 1, Lemons: 40}}, 20, 0, ErrGiftLimitReached},
+		{"recipient daily lemons", giftWorld{friends: true, received: 90}, 20, 0, ErrGiftReceiveLimitReached},
+		{"item already owned", giftWorld{friends: true, owned: true}, 0, 4, ErrItemAlreadyOwned},
+		{"item bought meanwhile", giftWorld{friends: true, ownedLater: true}, 0, 4, ErrItemAlreadyOwned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.world
+			w.book = newLemonBook(map[int64]int64{1: 100})
+			d := w.driver()
+			repo := newTestRepository(t, d)
+
+			_, _, err := repo.SendGift(context.Background(), 1, 2, tt.lemons, tt.itemID, "")
+			assert.ErrorIs(t, err, tt.want)
+			assert.Empty(t, d.Applied(), "nothing is committed")
+		})
+	}
+}
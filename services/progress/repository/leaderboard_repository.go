@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"lemonkorean/progress/leaderboard"
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// LEADERBOARDS
+// ================================================================
+
+// recordLeaderboardScores adds a committed write's scores to the user's
+// leaderboards. Failures are logged and never fail the write.
+func (r *ProgressRepository) recordLeaderboardScores(ctx context.Context, userID int64, scores models.LeaderboardScores) {
+	if len(scores.ByMetric()) == 0 {
+		return
+	}
+
+	optedOut, err := r.GetLeaderboardOptOut(ctx, userID)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Failed to check opt-out for user %d: %v", userID, err)
+		return
+	}
+	if optedOut {
+		return
+	}
+
+	now := time.Now()
+	if scores.StudyMinutes > 0 {
+		scores.StudyMinutes = r.countStudyMinutes(ctx, userID, scores.StudyMinutes, now)
+	}
+
+	if err := leaderboard.Add(ctx, r.redis, userID, scores.ByMetric(), now); err != nil {
+		log.Printf("[LEADERBOARD] Failed to record scores for user %d: %v", userID, err)
+	}
+
+	// Earning lemons enters the user into this week's league
+	if scores.Lemons > 0 {
+		if err := r.JoinLeague(ctx, userID); err != nil {
+			log.Printf("[LEAGUE] Failed to join league for user %d: %v", userID, err)
+		}
+	}
+}
+
+// getStudyMinuteCaps loads the study minute caps
+func getStudyMinuteCaps(ctx context.Context, q DBTX) (models.StudyMinuteCaps, error) {
+	caps := models.DefaultStudyMinuteCaps()
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(study_minutes_request_cap, 120), COALESCE(study_minutes_daily_cap, 480)
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&caps.PerRequest, &caps.PerDay)
+	if err != nil && err != sql.ErrNoRows {
+		return caps, fmt.Errorf("failed to get study minute caps: %w", err)
+	}
+
+	return caps, nil
+}
+
+// countStudyMinutes returns how many of one write's client-reported
+// minutes count towards the boards under the per-request and daily caps.
+// Nothing counts if the daily counter cannot be updated.
+func (r *ProgressRepository) countStudyMinutes(ctx context.Context, userID int64, minutes int, now time.Time) int {
+	caps, err := getStudyMinuteCaps(ctx, r.db)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Using default study minute caps: %v", err)
+	}
+
+	minutes = caps.RequestAllowance(minutes)
+	if minutes == 0 {
+		return 0
+	}
+
+	total, err := leaderboard.AddDaily(ctx, r.redis, models.LeaderboardStudyMinutes, userID, minutes, now)
+	if err != nil {
+		log.Printf("[LEADERBOARD] Failed to count study minutes for user %d: %v", userID, err)
+		return 0
+	}
+
+	return caps.DailyAllowance(minutes, total)
+}
+
+// GetLeaderboard returns the top n users of a live board
+func (r *ProgressRepository) GetLeaderboard(ctx context.Context, scope, metric string, n int) ([]models.LeaderboardEntry, error) {
+	members, err := leaderboard.Top(ctx, r.redis, leaderboard.Key(scope, metric, time.Now()), n)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.leaderboardEntries(ctx, members)
+}
+
+// GetLeaderboardPosition returns the user's entry on a live board and the
+// entries up to radius places around it. The entry is nil if the user has
+// no score yet or has opted out.
+func (r *ProgressRepository) GetLeaderboardPosition(ctx context.Context, scope, metric string, userID int64, radius int) (*models.LeaderboardEntry, []models.LeaderboardEntry, error) {
+	me, members, err := leaderboard.Around(ctx, r.redis, leaderboard.Key(scope, metric, time.Now()), userID, radius)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := r.leaderboardEntries(ctx, members)
+	if err != nil {
+		return nil, nil, err
+	}
+	if me == nil {
+		return nil, entries, nil
+	}
+
+	for i := range entries {
+		if entries[i].UserID == userID {
+			return &entries[i], entries, nil
+		}
+	}
+	return &models.LeaderboardEntry{Rank: me.Rank, UserID: userID, Score: me.Score}, entries, nil
+}
+
+// GetFriendLeaderboard ranks the user and their friends on a live board.
+// Users who have opted out of leaderboards are left out.
+func (r *ProgressRepository) GetFriendLeaderboard(ctx context.Context, scope, metric string, userID int64) ([]models.LeaderboardEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, '')
+		FROM users u
+		WHERE (u.id = $1 OR u.id IN (SELECT friend_id FROM friendships WHERE user_id = $1))
+		  AND COALESCE(u.leaderboard_opt_out, false) = false
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friends: %w", err)
+	}
+	users, err := scanUserSummaries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		ids[i] = u.UserID
+	}
+	scores, _, err := leaderboard.Scores(ctx, r.redis, leaderboard.Key(scope, metric, time.Now()), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.LeaderboardEntry, 0, len(users))
+	for _, u := range users {
+		entries = append(entries, models.LeaderboardEntry{
+			UserID:          u.UserID,
+			Name:            u.Name,
+			ProfileImageURL: u.ProfileImageURL,
+			Score:           scores[u.UserID],
+		})
+	}
+	models.RankLeaderboardEntries(entries)
+
+	return entries, nil
+}
+
+// GetArchivedLeaderboard returns the top n users of a finished week
+func (r *ProgressRepository) GetArchivedLeaderboard(ctx context.Context, metric string, weekStart time.Time, n int) ([]models.LeaderboardEntry, error) {
+	query := `
+		SELECT la.rank, la.user_id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, ''), la.score
+		FROM leaderboard_archives la
+		JOIN users u ON u.id = la.user_id
+		WHERE la.week_start = $1 AND la.metric = $2
+		  AND COALESCE(u.leaderboard_opt_out, false) = false
+		ORDER BY la.rank
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, weekStart, metric, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard archive: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Name, &e.ProfileImageURL, &e.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard archive: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetLeaderboardOptOut reports whether the user has hidden themselves from leaderboards
+func (r *ProgressRepository) GetLeaderboardOptOut(ctx context.Context, userID int64) (bool, error) {
+	var optOut bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(leaderboard_opt_out, false) FROM users WHERE id = $1`, userID,
+	).Scan(&optOut)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get leaderboard opt-out: %w", err)
+	}
+	return optOut, nil
+}
+
+// SetLeaderboardOptOut updates the user's leaderboard privacy setting.
+// Opting out removes the user from the live boards; activity while opted
+// out is not counted.
+func (r *ProgressRepository) SetLeaderboardOptOut(ctx context.Context, userID int64, optOut bool) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET leaderboard_opt_out = $2 WHERE id = $1`, userID, optOut)
+	if err != nil {
+		return fmt.Errorf("failed to update leaderboard opt-out: %w", err)
+	}
+
+	if optOut {
+		return leaderboard.Remove(ctx, r.redis, userID, time.Now())
+	}
+	return nil
+}
+
+// leaderboardEntries adds names to board members, keeping their order
+func (r *ProgressRepository) leaderboardEntries(ctx context.Context, members []leaderboard.Member) ([]models.LeaderboardEntry, error) {
+	entries := make([]models.LeaderboardEntry, 0, len(members))
+	if len(members) == 0 {
+		return entries, nil
+	}
+
+	ids := make([]int64, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, COALESCE(name, ''), COALESCE(profile_image_url, '')
+		FROM users
+		WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard users: %w", err)
+	}
+	defer rows.Close()
+
+	type profile struct{ name, imageURL string }
+	profiles := make(map[int64]profile, len(members))
+	for rows.Next() {
+		var id int64
+		var p profile
+		if err := rows.Scan(&id, &p.name, &p.imageURL); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard user: %w", err)
+		}
+		profiles[id] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard users: %w", err)
+	}
+
+	for _, m := range members {
+		p := profiles[m.UserID]
+		entries = append(entries, models.LeaderboardEntry{
+			Rank:            m.Rank,
+			UserID:          m.UserID,
+			Name:            p.name,
+			ProfileImageURL: p.imageURL,
+			Score:           m.Score,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,525 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/leaderboard"
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// LEAGUES
+// ================================================================
+// Users join a cohort in their tier the first time they earn lemons in a
+// week. Cohorts are ranked live from the weekly lemons leaderboard, and
+// finalized after the week ends by the league scheduler.
+// ================================================================
+
+// ErrLeagueOptedOut is returned for users who have hidden themselves from leaderboards
+var ErrLeagueOptedOut = errors.New("leagues are unavailable while leaderboards are turned off")
+
+// loadLeagueTiers returns the league ladder, lowest tier first
+func loadLeagueTiers(ctx context.Context, q DBTX) ([]models.LeagueTier, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT tier, code, name, promote_count, demote_count, promotion_reward_lemons
+		FROM league_tiers
+		ORDER BY tier
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query league tiers: %w", err)
+	}
+	defer rows.Close()
+
+	var tiers []models.LeagueTier
+	for rows.Next() {
+		var t models.LeagueTier
+		if err := rows.Scan(&t.Tier, &t.Code, &t.Name, &t.PromoteCount, &t.DemoteCount, &t.PromotionRewardLemons); err != nil {
+			return nil, fmt.Errorf("failed to scan league tier: %w", err)
+		}
+		tiers = append(tiers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query league tiers: %w", err)
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("no league tiers configured")
+	}
+
+	return tiers, nil
+}
+
+// findLeagueTier returns the tier with the given number, or the lowest tier
+func findLeagueTier(tiers []models.LeagueTier, tier int) models.LeagueTier {
+	for _, t := range tiers {
+		if t.Tier == tier {
+			return t
+		}
+	}
+	return tiers[0]
+}
+
+// joinLeague puts the user in a cohort for the week containing now, unless
+// they already have one. Returns the cohort ID, or 0 while the user's
+// previous week has not been finalized. q must be a transaction.
+func joinLeague(ctx context.Context, q DBTX, userID int64, now time.Time) (int64, error) {
+	weekStart := models.WeekStart(now)
+
+	var cohortID int64
+	err := q.QueryRowContext(ctx,
+		`SELECT cohort_id FROM league_members WHERE user_id = $1 AND week_start = $2`,
+		userID, weekStart,
+	).Scan(&cohortID)
+	if err == nil {
+		return cohortID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get league membership: %w", err)
+	}
+
+	// Wait for last week's results so the user joins in their new tier
+	var pending bool
+	err = q.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM league_members m
+			JOIN league_cohorts c ON c.id = m.cohort_id
+			WHERE m.user_id = $1 AND m.week_start < $2 AND c.finalized_at IS NULL
+		)
+	`, userID, weekStart).Scan(&pending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check league results: %w", err)
+	}
+	if pending {
+		return 0, nil
+	}
+
+	var tier int
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO user_leagues (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING tier
+	`, userID).Scan(&tier)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get league tier: %w", err)
+	}
+
+	size := 30
+	err = q.QueryRowContext(ctx, `SELECT COALESCE(league_cohort_size, 30) FROM gamification_settings WHERE id = 1`).Scan(&size)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get league settings: %w", err)
+	}
+
+	// Serialize joins per tier so cohorts fill up one at a time
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('league_cohorts'), $1::int)`, tier); err != nil {
+		return 0, fmt.Errorf("failed to lock league cohorts: %w", err)
+	}
+
+	err = q.QueryRowContext(ctx, `
+		SELECT id FROM league_cohorts
+		WHERE week_start = $1 AND tier = $2 AND finalized_at IS NULL AND member_count < $3
+		ORDER BY id
+		LIMIT 1
+	`, weekStart, tier, size).Scan(&cohortID)
+	if err == sql.ErrNoRows {
+		err = q.QueryRowContext(ctx,
+			`INSERT INTO league_cohorts (week_start, tier) VALUES ($1, $2) RETURNING id`,
+			weekStart, tier,
+		).Scan(&cohortID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find league cohort: %w", err)
+	}
+
+	result, err := q.ExecContext(ctx, `
+		INSERT INTO league_members (cohort_id, user_id, week_start, tier)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, week_start) DO NOTHING
+	`, cohortID, userID, weekStart, tier)
+	if err != nil {
+		return 0, fmt.Errorf("failed to join league: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// Joined concurrently
+		err = q.QueryRowContext(ctx,
+			`SELECT cohort_id FROM league_members WHERE user_id = $1 AND week_start = $2`,
+			userID, weekStart,
+		).Scan(&cohortID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get league membership: %w", err)
+		}
+		return cohortID, nil
+	}
+
+	if _, err := q.ExecContext(ctx, `UPDATE league_cohorts SET member_count = member_count + 1 WHERE id = $1`, cohortID); err != nil {
+		return 0, fmt.Errorf("failed to update league cohort: %w", err)
+	}
+
+	return cohortID, nil
+}
+
+// JoinLeague puts the user in this week's cohort if they are not in one yet
+func (r *ProgressRepository) JoinLeague(ctx context.Context, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := joinLeague(ctx, tx, userID, time.Now()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLeague returns the user's cohort for this week with live standings,
+// joining one if needed
+func (r *ProgressRepository) GetLeague(ctx context.Context, userID int64) (*models.League, error) {
+	optedOut, err := r.GetLeaderboardOptOut(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if optedOut {
+		return nil, ErrLeagueOptedOut
+	}
+
+	now := time.Now().UTC()
+	weekStart := models.WeekStart(now)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cohortID, err := joinLeague(ctx, tx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	tiers, err := loadLeagueTiers(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	league := &models.League{
+		WeekStart: weekStart,
+		WeekEnd:   weekStart.AddDate(0, 0, 7),
+		CohortID:  cohortID,
+		Standings: []models.LeagueStanding{},
+	}
+
+	var tier int
+	if cohortID == 0 {
+		league.Pending = true
+		err = r.db.QueryRowContext(ctx, `SELECT tier FROM user_leagues WHERE user_id = $1`, userID).Scan(&tier)
+	} else {
+		err = r.db.QueryRowContext(ctx, `SELECT tier FROM league_cohorts WHERE id = $1`, cohortID).Scan(&tier)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get league tier: %w", err)
+	}
+	league.Tier = findLeagueTier(tiers, tier)
+
+	if cohortID == 0 {
+		return league, nil
+	}
+
+	standings, err := r.leagueStandings(ctx, r.db, cohortID, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	models.RankLeagueStandings(standings, league.Tier, tiers[len(tiers)-1].Tier)
+	league.Standings = standings
+
+	for _, s := range standings {
+		if s.UserID == userID {
+			league.MyRank = s.Rank
+		}
+	}
+
+	return league, nil
+}
+
+// GetLeagueHistory returns the user's finalized weeks, newest first
+func (r *ProgressRepository) GetLeagueHistory(ctx context.Context, userID int64, limit int) ([]models.LeagueResult, error) {
+	query := `
+		SELECT m.week_start, m.tier, t.name, COALESCE(m.final_rank, 0), c.member_count,
+		       COALESCE(m.weekly_lemons, 0), m.result, m.new_tier, m.reward_lemons
+		FROM league_members m
+		JOIN league_cohorts c ON c.id = m.cohort_id
+		JOIN league_tiers t ON t.tier = m.tier
+		WHERE m.user_id = $1 AND m.result IS NOT NULL
+		ORDER BY m.week_start DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query league history: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.LeagueResult{}
+	for rows.Next() {
+		var lr models.LeagueResult
+		err := rows.Scan(&lr.WeekStart, &lr.Tier, &lr.TierName, &lr.Rank, &lr.CohortSize,
+			&lr.WeeklyLemons, &lr.Result, &lr.NewTier, &lr.RewardLemons)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan league result: %w", err)
+		}
+		results = append(results, lr)
+	}
+
+	return results, rows.Err()
+}
+
+// FinalizeLeagues settles every cohort of a finished week, one cohort per
+// transaction. Safe to run on every replica. Returns the cohorts finalized.
+func (r *ProgressRepository) FinalizeLeagues(ctx context.Context, now time.Time) (int, error) {
+	tiers, err := loadLeagueTiers(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+
+	finalized := 0
+	for {
+		ok, err := r.finalizeNextCohort(ctx, models.WeekStart(now), tiers)
+		if err != nil {
+			return finalized, err
+		}
+		if !ok {
+			return finalized, nil
+		}
+		finalized++
+	}
+}
+
+// finalizeNextCohort ranks one unfinalized cohort from before currentWeek,
+// moves its members between tiers and pays promotion rewards. Returns false
+// when no cohort is left.
+func (r *ProgressRepository) finalizeNextCohort(ctx context.Context, currentWeek time.Time, tiers []models.LeagueTier) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cohortID int64
+	var weekStart time.Time
+	var tierNumber int
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, week_start, tier FROM league_cohorts
+		WHERE finalized_at IS NULL AND week_start < $1
+		ORDER BY week_start, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, currentWeek).Scan(&cohortID, &weekStart, &tierNumber)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get league cohort: %w", err)
+	}
+
+	// Members who opted out of leaderboards are not ranked and keep their tier
+	_, err = tx.ExecContext(ctx, `
+		UPDATE league_members m
+		SET result = $2, new_tier = m.tier
+		FROM users u
+		WHERE m.cohort_id = $1 AND u.id = m.user_id AND COALESCE(u.leaderboard_opt_out, false)
+	`, cohortID, models.LeagueSkipped)
+	if err != nil {
+		return false, fmt.Errorf("failed to skip opted-out league members: %w", err)
+	}
+
+	standings, err := r.leagueStandings(ctx, tx, cohortID, weekStart)
+	if err != nil {
+		return false, err
+	}
+	tier := findLeagueTier(tiers, tierNumber)
+	topTier := tiers[len(tiers)-1].Tier
+	models.RankLeagueStandings(standings, tier, topTier)
+
+	results := make([]models.LeagueResult, 0, len(standings))
+	balances := make(map[int64]int)
+	for _, s := range standings {
+		lr := models.LeagueResult{
+			WeekStart:    weekStart,
+			Tier:         tier.Tier,
+			TierName:     tier.Name,
+			Rank:         s.Rank,
+			CohortSize:   len(standings),
+			WeeklyLemons: s.WeeklyLemons,
+			Result:       tier.Outcome(s.Rank, len(standings), s.WeeklyLemons, topTier),
+			NewTier:      tier.Tier,
+		}
+		switch lr.Result {
+		case models.LeaguePromoted:
+			newTier := findLeagueTier(tiers, tier.Tier+1)
+			lr.NewTier = newTier.Tier
+			lr.RewardLemons = tier.PromotionRewardLemons
+
+			err := recordActivity(ctx, tx, s.UserID, models.ActivityLeaguePromoted, strconv.FormatInt(cohortID, 10), map[string]interface{}{
+				"tier":      newTier.Tier,
+				"tier_name": newTier.Name,
+				"rank":      s.Rank,
+			})
+			if err != nil {
+				return false, err
+			}
+		case models.LeagueDemoted:
+			lr.NewTier = findLeagueTier(tiers, tier.Tier-1).Tier
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE league_members
+			SET final_rank = $3, weekly_lemons = $4, result = $5, new_tier = $6, reward_lemons = $7
+			WHERE cohort_id = $1 AND user_id = $2
+		`, cohortID, s.UserID, lr.Rank, lr.WeeklyLemons, lr.Result, lr.NewTier, lr.RewardLemons)
+		if err != nil {
+			return false, fmt.Errorf("failed to record league result: %w", err)
+		}
+
+		if lr.NewTier != tier.Tier {
+			_, err := tx.ExecContext(ctx,
+				`UPDATE user_leagues SET tier = $2, updated_at = NOW() WHERE user_id = $1`,
+				s.UserID, lr.NewTier,
+			)
+			if err != nil {
+				return false, fmt.Errorf("failed to update league tier: %w", err)
+			}
+		}
+
+		if lr.RewardLemons > 0 {
+			entries, err := postLemons(ctx, tx, models.LemonTxLeague, &cohortID, []models.LedgerLeg{
+				{UserID: s.UserID, Account: models.LedgerAccountRewards, Amount: -lr.RewardLemons},
+				{UserID: s.UserID, Account: models.LedgerAccountWallet, Amount: lr.RewardLemons},
+			})
+			if err != nil {
+				return false, err
+			}
+			balances[s.UserID] = ledgerBalance(entries, s.UserID, models.LedgerAccountWallet)
+		}
+
+		results = append(results, lr)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE league_cohorts SET finalized_at = NOW() WHERE id = $1`, cohortID); err != nil {
+		return false, fmt.Errorf("failed to finalize league cohort: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for i, lr := range results {
+		userID := standings[i].UserID
+		r.PublishEvent(ctx, userID, models.EventTypeLeague, map[string]interface{}{
+			"week_start":    lr.WeekStart.Format("2006-01-02"),
+			"rank":          lr.Rank,
+			"result":        lr.Result,
+			"new_tier":      lr.NewTier,
+			"reward_lemons": lr.RewardLemons,
+		})
+		if lr.RewardLemons > 0 {
+			r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+				"reason":       "league",
+				"lemons":       lr.RewardLemons,
+				"total_lemons": balances[userID],
+			})
+		}
+	}
+
+	return true, nil
+}
+
+// leagueStandings returns a cohort's members with their lemons for the
+// week, unranked. Members who opted out of leaderboards are left out.
+func (r *ProgressRepository) leagueStandings(ctx context.Context, q DBTX, cohortID int64, weekStart time.Time) ([]models.LeagueStanding, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT m.id, m.user_id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, '')
+		FROM league_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.cohort_id = $1 AND COALESCE(u.leaderboard_opt_out, false) = false
+	`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query league members: %w", err)
+	}
+
+	type member struct {
+		id, userID     int64
+		name, imageURL string
+	}
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.id, &m.userID, &m.name, &m.imageURL); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan league member: %w", err)
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query league members: %w", err)
+	}
+
+	userIDs := make([]int64, len(members))
+	for i, m := range members {
+		userIDs[i] = m.userID
+	}
+	lemons, err := r.weeklyLemons(ctx, q, weekStart, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := make([]models.LeagueStanding, 0, len(members))
+	for _, m := range members {
+		standings = append(standings, models.NewLeagueStanding(m.id, m.userID, m.name, m.imageURL, lemons[m.userID]))
+	}
+	return standings, nil
+}
+
+// weeklyLemons returns the users' lemons earned in a week: from the live
+// weekly board, or from the archive once the board has been archived
+func (r *ProgressRepository) weeklyLemons(ctx context.Context, q DBTX, weekStart time.Time, userIDs []int64) (map[int64]int64, error) {
+	scores, exists, err := leaderboard.Scores(ctx, r.redis, leaderboard.WeeklyKey(models.LeaderboardLemons, weekStart), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return scores, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT user_id, score FROM leaderboard_archives
+		WHERE week_start = $1 AND metric = $2 AND user_id = ANY($3)
+	`, weekStart, models.LeaderboardLemons, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard archive: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, score int64
+		if err := rows.Scan(&userID, &score); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard archive: %w", err)
+		}
+		scores[userID] = score
+	}
+
+	return scores, rows.Err()
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinalizeNextCohort(t *testing.T) {
+	ctx := context.Background()
+	book := newLemonBook(nil)
+	weekStart := models.WeekStart(time.Now()).AddDate(0, 0, -7)
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		if rows, ok := book.query(query, args); ok {
+			return rows, nil
+		}
+		switch {
+		case strings.Contains(query, "FROM league_cohorts"):
+			return &dbtest.Rows{Columns: []string{"id", "week_start", "tier"}, Values: [][]driver.Value{{int64(9), weekStart, int64(1)}}}, nil
+		case strings.Contains(query, "FROM league_members m"):
+			// User 3 opted out of leaderboards and is not ranked
+			return &dbtest.Rows{
+				Columns: []string{"id", "user_id", "name", "profile_image_url"},
+				Values:  [][]driver.Value{{int64(1), int64(1), "A", ""}, {int64(2), int64(2), "B", ""}},
+			}, nil
+		case strings.Contains(query, "FROM leaderboard_archives"):
+			return &dbtest.Rows{Columns: []string{"user_id", "score"}, Values: [][]driver.Value{{int64(1), int64(10)}, {int64(2), int64(30)}}}, nil
+		}
+		return nil, nil
+	}}
+	repo := newTestRepositoryWithEmptyRedis(t, d)
+
+	tiers := []models.LeagueTier{
+		{Tier: 1, Name: "Bronze", PromoteCount: 1, PromotionRewardLemons: 50},
+		{Tier: 2, Name: "Silver", DemoteCount: 1},
+	}
+	ok, err := repo.finalizeNextCohort(ctx, models.WeekStart(time.Now()), tiers)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	results := map[int64][]driver.Value{}
+	var skipped, tierChanges []driver.Value
+	for _, s := range d.Applied() {
+		switch {
+		case strings.Contains(s.Query, "FROM users u"):
+			skipped = s.Args
+		case strings.Contains(s.Query, "SET final_rank"):
+			results[s.Args[1].(int64)] = s.Args[2:]
+		case strings.Contains(s.Query, "UPDATE user_leagues"):
+			tierChanges = s.Args
+		}
+	}
+
+	assert.Equal(t, []driver.Value{int64(9), models.LeagueSkipped}, skipped)
+	assert.Len(t, results, 2, "opted-out members get no rank")
+	assert.Equal(t, []driver.Value{int64(1), int64(30), models.LeaguePromoted, int64(2), int64(50)}, results[2])
+	assert.Equal(t, []driver.Value{int64(2), int64(10), models.LeagueStayed, int64(1), int64(0)}, results[1])
+	assert.Equal(t, []driver.Value{int64(2), int64(2)}, tierChanges)
+	assert.Equal(t, map[int64]int64{2: 50}, book.wallets, "only the promoted member is rewarded")
+}
@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/outbox"
+	"lemonkorean/progress/realtime"
+)
+
+// ================================================================
+// LEARNING EVENT STORE
+// ================================================================
+// Every learning action is appended to learning_events and then applied
+// to the current-state tables, which are projections of the event log.
+// Projections can be dropped and rebuilt from the events at any time.
+// ================================================================
+
+// projectionTables are rebuilt from learning_events
+var projectionTables = []string{"user_progress", "vocabulary_progress", "hangul_progress", "lesson_rewards"}
+
+// rebuildBatchSize is the number of events loaded per query during a rebuild
+const rebuildBatchSize = 500
+
+// AppendLearningEvent appends an event that has no projection in this
+// repository (e.g. purchases), using the caller's transaction
+func (r *ProgressRepository) AppendLearningEvent(ctx context.Context, q DBTX, userID int64, eventType string, data interface{}) error {
+	_, err := r.appendLearningEvent(ctx, q, userID, eventType, data)
+	return err
+}
+
+// recordLearningEvent appends an event, applies it to the projections and
+// advances the user's quests, XP and activity feed
+func (r *ProgressRepository) recordLearningEvent(ctx context.Context, q DBTX, userID int64, eventType string, data interface{}) error {
+	event, err := r.appendLearningEvent(ctx, q, userID, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	if err := r.projectLearningEvent(ctx, q, event); err != nil {
+		return err
+	}
+
+	if err := advanceQuestsForEvent(ctx, q, event); err != nil {
+		return err
+	}
+
+	if err := awardXPForEvent(ctx, q, event); err != nil {
+		return err
+	}
+
+	return recordActivityForEvent(ctx, q, event)
+}
+
+// appendLearningEvent inserts an event into the log.
+// q must be a transaction: the per-user lock is held until it ends.
+func (r *ProgressRepository) appendLearningEvent(ctx context.Context, q DBTX, userID int64, eventType string, data interface{}) (*models.LearningEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode learning event: %w", err)
+	}
+
+	if err := lockLearningEvents(ctx, q, userID); err != nil {
+		return nil, err
+	}
+
+	event := &models.LearningEvent{
+		UserID:       userID,
+		Type:         eventType,
+		Data:         payload,
+		SourceDevice: realtime.SourceDevice(ctx),
+	}
+
+	query := `
+		INSERT INTO learning_events (user_id, event_type, data, source_device)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, occurred_at
+	`
+	err = q.QueryRowContext(ctx, query, userID, eventType, []byte(payload), event.SourceDevice).
+		Scan(&event.ID, &event.OccurredAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append learning event: %w", err)
+	}
+
+	// Publish to other services through the outbox, atomically with the event
+	err = outbox.Enqueue(ctx, q, &outbox.Message{
+		EventID:    event.ID,
+		Type:       eventType,
+		UserID:     userID,
+		Payload:    payload,
+		OccurredAt: event.OccurredAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// lockLearningEvents serializes event writes and rebuilds for one user
+func lockLearningEvents(ctx context.Context, q DBTX, userID int64) error {
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('learning_events'), $1::int)`, userID); err != nil {
+		return fmt.Errorf("failed to lock learning events: %w", err)
+	}
+	return nil
+}
+
+// projectLearningEvent applies one event to the projection tables
+func (r *ProgressRepository) projectLearningEvent(ctx context.Context, q DBTX, event *models.LearningEvent) error {
+	switch event.Type {
+	case models.LearningEventLessonCompleted:
+		var d models.LessonCompletedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.completeLesson(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventProgressUpdated:
+		var d models.ProgressUpdatedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.updateProgress(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventProgressReset:
+		var d models.ProgressResetData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.resetLessonProgress(ctx, q, event.UserID, &d)
+
+	case models.LearningEventVocabularyAnswered:
+		var d models.VocabularyAnsweredData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.recordVocabularyPractice(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventVocabularyBatch:
+		var d models.VocabularyBatchData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.recordVocabularyBatch(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventHangulPracticed:
+		var d models.HangulPracticedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.recordHangulPractice(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventLessonRewarded:
+		var d models.LessonRewardedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		_, err := r.projectLessonReward(ctx, q, event.UserID, &d, event.OccurredAt)
+		return err
+
+	case models.LearningEventProgressImported:
+		var d models.ProgressImportedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.importProgress(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventVocabularyImported:
+		var d models.VocabularyImportedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.importVocabulary(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventHangulImported:
+		var d models.HangulImportedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.importHangul(ctx, q, event.UserID, &d, event.OccurredAt)
+
+	case models.LearningEventLessonRewardImported:
+		var d models.LessonRewardImportedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		return r.importLessonReward(ctx, q, event.UserID, &d)
+
+	case models.LearningEventItemPurchased, models.LearningEventBossQuizCompleted:
+		// Inventory, boss completions and lemon balances are not projected from learning events
+		return nil
+
+	default:
+		return fmt.Errorf("unknown learning event type %q (event %d)", event.Type, event.ID)
+	}
+}
+
+// isFirstLessonCompletion reports whether a lesson_completed event is the
+// user's first for its lesson. Repeating a lesson earns no XP or quest
+// progress.
+func isFirstLessonCompletion(ctx context.Context, q DBTX, event *models.LearningEvent) (bool, error) {
+	var d models.LessonCompletedData
+	if err := decodeLearningEvent(event, &d); err != nil {
+		return false, err
+	}
+
+	var repeated bool
+	err := q.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM learning_events
+			WHERE user_id = $1 AND id < $2 AND event_type = $3 AND (data->>'lesson_id')::bigint = $4
+		)
+	`, event.UserID, event.ID, models.LearningEventLessonCompleted, d.LessonID).Scan(&repeated)
+	if err != nil {
+		return false, fmt.Errorf("failed to check earlier lesson completions: %w", err)
+	}
+	return !repeated, nil
+}
+
+func decodeLearningEvent(event *models.LearningEvent, v interface{}) error {
+	if err := json.Unmarshal(event.Data, v); err != nil {
+		return fmt.Errorf("failed to decode %s event %d: %w", event.Type, event.ID, err)
+	}
+	return nil
+}
+
+// ================================================================
+// SNAPSHOT PROJECTIONS
+// ================================================================
+
+// importProgress restores a user_progress row from a snapshot event
+func (r *ProgressRepository) importProgress(ctx context.Context, q DBTX, userID int64, d *models.ProgressImportedData, at time.Time) error {
+	query := `
+		INSERT INTO user_progress (
+			user_id, lesson_id, status, progress_percent, quiz_score,
+			time_spent_minutes, last_accessed_at, completed_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		ON CONFLICT (user_id, lesson_id)
+		DO UPDATE SET
+			status = EXCLUDED.status,
+			progress_percent = EXCLUDED.progress_percent,
+			quiz_score = EXCLUDED.quiz_score,
+			time_spent_minutes = EXCLUDED.time_spent_minutes,
+			last_accessed_at = EXCLUDED.last_accessed_at,
+			completed_at = EXCLUDED.completed_at,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := q.ExecContext(ctx, query,
+		userID, d.LessonID, d.Status, d.ProgressPercent, d.QuizScore,
+		d.TimeSpentMinutes, d.LastAccessedAt, d.CompletedAt, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to import progress: %w", err)
+	}
+
+	return nil
+}
+
+// importVocabulary restores a vocabulary_progress row from a snapshot event
+func (r *ProgressRepository) importVocabulary(ctx context.Context, q DBTX, userID int64, d *models.VocabularyImportedData, at time.Time) error {
+	query := `
+		INSERT INTO vocabulary_progress (
+			user_id, vocabulary_id, mastery_level, correct_count, incorrect_count,
+			last_reviewed_at, next_review_at, easiness_factor, repetition_count,
+			interval_days, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (user_id, vocabulary_id)
+		DO UPDATE SET
+			mastery_level = EXCLUDED.mastery_level,
+			correct_count = EXCLUDED.correct_count,
+			incorrect_count = EXCLUDED.incorrect_count,
+			last_reviewed_at = EXCLUDED.last_reviewed_at,
+			next_review_at = EXCLUDED.next_review_at,
+			easiness_factor = EXCLUDED.easiness_factor,
+			repetition_count = EXCLUDED.repetition_count,
+			interval_days = EXCLUDED.interval_days,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := q.ExecContext(ctx, query,
+		userID, d.VocabularyID, d.MasteryLevel, d.CorrectCount, d.IncorrectCount,
+		d.LastReviewedAt, d.NextReviewAt, d.EasinessFactor, d.RepetitionCount,
+		d.IntervalDays, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to import vocabulary progress: %w", err)
+	}
+
+	return nil
+}
+
+// importHangul restores a hangul_progress row from a snapshot event
+func (r *ProgressRepository) importHangul(ctx context.Context, q DBTX, userID int64, d *models.HangulImportedData, at time.Time) error {
+	query := `
+		INSERT INTO hangul_progress (
+			user_id, character_id, mastery_level, correct_count, wrong_count,
+			streak_count, last_practiced, next_review, ease_factor, interval_days,
+			repetition_count, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		ON CONFLICT (user_id, character_id)
+		DO UPDATE SET
+			mastery_level = EXCLUDED.mastery_level,
+			correct_count = EXCLUDED.correct_count,
+			wrong_count = EXCLUDED.wrong_count,
+			streak_count = EXCLUDED.streak_count,
+			last_practiced = EXCLUDED.last_practiced,
+			next_review = EXCLUDED.next_review,
+			ease_factor = EXCLUDED.ease_factor,
+			interval_days = EXCLUDED.interval_days,
+			repetition_count = EXCLUDED.repetition_count,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := q.ExecContext(ctx, query,
+		userID, d.CharacterID, d.MasteryLevel, d.CorrectCount, d.WrongCount,
+		d.StreakCount, d.LastPracticed, d.NextReview, d.EasinessFactor, d.IntervalDays,
+		d.RepetitionCount, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to import hangul progress: %w", err)
+	}
+
+	return nil
+}
+
+// importLessonReward restores a lesson_rewards row from a snapshot event
+func (r *ProgressRepository) importLessonReward(ctx context.Context, q DBTX, userID int64, d *models.LessonRewardImportedData) error {
+	query := `
+		INSERT INTO lesson_rewards (user_id, lesson_id, lemons_earned, best_quiz_score, earned_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (user_id, lesson_id) DO UPDATE
+		SET lemons_earned = EXCLUDED.lemons_earned,
+		    best_quiz_score = EXCLUDED.best_quiz_score,
+		    earned_at = EXCLUDED.earned_at,
+		    updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := q.ExecContext(ctx, query, userID, d.LessonID, d.LemonsEarned, d.BestQuizScore, d.EarnedAt)
+	if err != nil {
+		return fmt.Errorf("failed to import lesson reward: %w", err)
+	}
+
+	return nil
+}
+
+// ================================================================
+// PROJECTION REBUILD
+// ================================================================
+
+// RebuildProjections discards a user's projection rows and replays all of
+// their learning events in order. Returns the number of events replayed.
+func (r *ProgressRepository) RebuildProjections(ctx context.Context, userID int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockLearningEvents(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+
+	for _, table := range projectionTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return 0, fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	replayed := 0
+	var afterID int64
+	for {
+		events, err := loadLearningEvents(ctx, tx, userID, afterID, rebuildBatchSize)
+		if err != nil {
+			return replayed, err
+		}
+		for i := range events {
+			if err := r.projectLearningEvent(ctx, tx, &events[i]); err != nil {
+				return replayed, err
+			}
+			replayed++
+		}
+		if len(events) < rebuildBatchSize {
+			break
+		}
+		afterID = events[len(events)-1].ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return replayed, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.invalidateProgressCache(ctx, userID)
+	r.invalidateStatsCache(ctx, userID)
+
+	return replayed, nil
+}
+
+// RebuildAllProjections rebuilds projections for every user with events,
+// one transaction per user. onUser is called after each user is rebuilt.
+func (r *ProgressRepository) RebuildAllProjections(ctx context.Context, onUser func(userID int64, events int)) (int, int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT user_id FROM learning_events ORDER BY user_id`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query event users: %w", err)
+	}
+
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan event user: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to query event users: %w", err)
+	}
+
+	totalEvents := 0
+	for i, userID := range userIDs {
+		replayed, err := r.RebuildProjections(ctx, userID)
+		if err != nil {
+			return i, totalEvents, fmt.Errorf("user %d: %w", userID, err)
+		}
+		totalEvents += replayed
+		if onUser != nil {
+			onUser(userID, replayed)
+		}
+	}
+
+	return len(userIDs), totalEvents, nil
+}
+
+// loadLearningEvents reads a page of a user's events after the given ID
+func loadLearningEvents(ctx context.Context, q DBTX, userID, afterID int64, limit int) ([]models.LearningEvent, error) {
+	query := `
+		SELECT id, user_id, event_type, data, COALESCE(source_device, ''), occurred_at
+		FROM learning_events
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := q.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query learning events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.LearningEvent, 0, limit)
+	for rows.Next() {
+		var e models.LearningEvent
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Type, &data, &e.SourceDevice, &e.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan learning event: %w", err)
+		}
+		e.Data = data
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
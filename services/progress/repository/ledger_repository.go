@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// LEMON LEDGER
+// ================================================================
+// All lemon movements go through postLemons: a posting is a set of
+// lemon_transactions legs that sum to zero. User account legs update the
+// matching lemon_currency column in the same statement sequence and store
+// the resulting balance, so history and balances cannot drift apart.
+// ================================================================
+
+// ErrInsufficientLemons is returned when a posting would take a user
+// account below zero
+var ErrInsufficientLemons = errors.New("insufficient lemons")
+
+// ledgerBalanceColumns maps user accounts to their lemon_currency column
+var ledgerBalanceColumns = map[string]string{
+	models.LedgerAccountWallet:    "total_lemons",
+	models.LedgerAccountTree:      "tree_lemons_available",
+	models.LedgerAccountHarvested: "tree_lemons_harvested",
+}
+
+// postLemons writes a balanced posting and applies its user account legs
+// to lemon_currency. Must run inside the caller's transaction.
+func postLemons(ctx context.Context, q DBTX, txType string, sourceID *int64, legs []models.LedgerLeg) ([]models.LedgerEntry, error) {
+	ordered, err := models.PrepareLedgerPosting(legs)
+	if err != nil {
+		return nil, err
+	}
+	if len(ordered) == 0 {
+		return nil, nil
+	}
+
+	var postingID int64
+	if err := q.QueryRowContext(ctx, `SELECT nextval('lemon_posting_id_seq')`).Scan(&postingID); err != nil {
+		return nil, fmt.Errorf("failed to allocate lemon posting: %w", err)
+	}
+
+	entries := make([]models.LedgerEntry, 0, len(ordered))
+	for _, leg := range ordered {
+		entry := models.LedgerEntry{
+			PostingID: postingID,
+			UserID:    leg.UserID,
+			Account:   leg.Account,
+			Amount:    leg.Amount,
+			Type:      txType,
+			SourceID:  sourceID,
+		}
+
+		if column, ok := ledgerBalanceColumns[leg.Account]; ok {
+			balance, err := applyLedgerLeg(ctx, q, leg.UserID, column, leg.Amount)
+			if err != nil {
+				return nil, err
+			}
+			entry.BalanceAfter = &balance
+		}
+
+		query := `
+			INSERT INTO lemon_transactions (user_id, posting_id, account, amount, balance_after, type, source_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at
+		`
+		err := q.QueryRowContext(ctx, query,
+			entry.UserID, entry.PostingID, entry.Account, entry.Amount, entry.BalanceAfter, entry.Type, entry.SourceID,
+		).Scan(&entry.ID, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record lemon transaction: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// applyLedgerLeg adds amount to a lemon_currency column and returns the new
+// balance. Returns ErrInsufficientLemons if the balance would go negative.
+func applyLedgerLeg(ctx context.Context, q DBTX, userID int64, column string, amount int) (int, error) {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO lemon_currency (user_id, updated_at) VALUES ($1, NOW()) ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create lemon currency: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE lemon_currency
+		SET %[1]s = %[1]s + $2, updated_at = NOW()
+		WHERE user_id = $1 AND %[1]s + $2 >= 0
+		RETURNING %[1]s
+	`, column)
+
+	var balance int
+	err = q.QueryRowContext(ctx, query, userID, amount).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, ErrInsufficientLemons
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update lemon currency: %w", err)
+	}
+
+	return balance, nil
+}
+
+// creditLemons posts earned lemons to the wallet and grows as many on the
+// tree as it has room for. It counts as study activity for the tree even
+// when amount is zero.
+func creditLemons(ctx context.Context, q DBTX, userID int64, amount int, txType string, sourceID *int64) error {
+	grown, err := growLemonTree(ctx, q, userID, amount, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = postLemons(ctx, q, txType, sourceID, []models.LedgerLeg{
+		{UserID: userID, Account: models.LedgerAccountRewards, Amount: -amount},
+		{UserID: userID, Account: models.LedgerAccountWallet, Amount: amount},
+		{UserID: userID, Account: models.LedgerAccountGrowth, Amount: -grown},
+		{UserID: userID, Account: models.LedgerAccountTree, Amount: grown},
+	})
+	return err
+}
+
+// SpendLemons posts a wallet debit to the shop inside the caller's transaction.
+// Returns ErrInsufficientLemons if the wallet does not cover the amount.
+func (r *ProgressRepository) SpendLemons(ctx context.Context, q DBTX, userID int64, amount int, txType string, sourceID *int64) (int, error) {
+	entries, err := postLemons(ctx, q, txType, sourceID, []models.LedgerLeg{
+		{UserID: userID, Account: models.LedgerAccountWallet, Amount: -amount},
+		{UserID: userID, Account: models.LedgerAccountShop, Amount: amount},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return ledgerBalance(entries, userID, models.LedgerAccountWallet), nil
+}
+
+// ledgerBalance returns the balance after a posting for one user account
+func ledgerBalance(entries []models.LedgerEntry, userID int64, account string) int {
+	for _, entry := range entries {
+		if entry.UserID == userID && entry.Account == account && entry.BalanceAfter != nil {
+			return *entry.BalanceAfter
+		}
+	}
+	return 0
+}
+
+// ================================================================
+// RECONCILIATION
+// ================================================================
+
+// ReconcileLemons compares lemon_currency with balances recomputed from the
+// ledger. userID 0 checks every user. With repair, mismatched balances are
+// overwritten with the ledger values (the ledger is authoritative).
+func (r *ProgressRepository) ReconcileLemons(ctx context.Context, userID int64, repair bool) ([]models.LemonBalanceMismatch, error) {
+	query := `
+		WITH ledger AS (
+			SELECT user_id,
+			       COALESCE(SUM(amount) FILTER (WHERE account = 'wallet'), 0) AS wallet,
+			       COALESCE(SUM(amount) FILTER (WHERE account = 'tree'), 0) AS tree,
+			       COALESCE(SUM(amount) FILTER (WHERE account = 'harvested'), 0) AS harvested
+			FROM lemon_transactions
+			WHERE ($1 = 0 OR user_id = $1)
+			GROUP BY user_id
+		)
+		SELECT COALESCE(c.user_id, l.user_id),
+		       COALESCE(c.total_lemons, 0), COALESCE(c.tree_lemons_available, 0), COALESCE(c.tree_lemons_harvested, 0),
+		       COALESCE(l.wallet, 0), COALESCE(l.tree, 0), COALESCE(l.harvested, 0)
+		FROM (SELECT * FROM lemon_currency WHERE ($1 = 0 OR user_id = $1)) c
+		FULL OUTER JOIN ledger l ON l.user_id = c.user_id
+		WHERE COALESCE(c.total_lemons, 0) <> COALESCE(l.wallet, 0)
+		   OR COALESCE(c.tree_lemons_available, 0) <> COALESCE(l.tree, 0)
+		   OR COALESCE(c.tree_lemons_harvested, 0) <> COALESCE(l.harvested, 0)
+		ORDER BY 1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile lemon balances: %w", err)
+	}
+	defer rows.Close()
+
+	mismatches := []models.LemonBalanceMismatch{}
+	for rows.Next() {
+		var m models.LemonBalanceMismatch
+		err := rows.Scan(&m.UserID,
+			&m.Stored.TotalLemons, &m.Stored.TreeLemonsAvailable, &m.Stored.TreeLemonsHarvested,
+			&m.Ledger.TotalLemons, &m.Ledger.TreeLemonsAvailable, &m.Ledger.TreeLemonsHarvested,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lemon balance: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to reconcile lemon balances: %w", err)
+	}
+	rows.Close()
+
+	if repair {
+		for i := range mismatches {
+			ledger, err := r.repairLemonBalances(ctx, mismatches[i].UserID)
+			if err != nil {
+				return mismatches, err
+			}
+			mismatches[i].Ledger = *ledger
+			mismatches[i].Repaired = true
+		}
+	}
+
+	return mismatches, nil
+}
+
+// repairLemonBalances overwrites a user's lemon_currency row with the
+// ledger balances. The currency row is locked first so postings in flight
+// are either fully counted or wait for the repair.
+func (r *ProgressRepository) repairLemonBalances(ctx context.Context, userID int64) (*models.LemonBalances, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO lemon_currency (user_id, updated_at) VALUES ($1, NOW()) ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lemon currency: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM lemon_currency WHERE user_id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock lemon currency: %w", err)
+	}
+
+	query := `
+		UPDATE lemon_currency c
+		SET total_lemons = l.wallet,
+		    tree_lemons_available = l.tree,
+		    tree_lemons_harvested = l.harvested,
+		    updated_at = NOW()
+		FROM (
+			SELECT COALESCE(SUM(amount) FILTER (WHERE account = 'wallet'), 0) AS wallet,
+			       COALESCE(SUM(amount) FILTER (WHERE account = 'tree'), 0) AS tree,
+			       COALESCE(SUM(amount) FILTER (WHERE account = 'harvested'), 0) AS harvested
+			FROM lemon_transactions
+			WHERE user_id = $1
+		) l
+		WHERE c.user_id = $1
+		RETURNING c.total_lemons, c.tree_lemons_available, c.tree_lemons_harvested
+	`
+
+	balances := &models.LemonBalances{}
+	err = tx.QueryRowContext(ctx, query, userID).
+		Scan(&balances.TotalLemons, &balances.TreeLemonsAvailable, &balances.TreeLemonsHarvested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to repair lemon balances: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return balances, nil
+}
+
+// UnbalancedLemonPostings returns postings whose legs do not sum to zero.
+// These cannot be repaired automatically and need manual review.
+func (r *ProgressRepository) UnbalancedLemonPostings(ctx context.Context, userID int64) ([]int64, error) {
+	query := `
+		SELECT posting_id
+		FROM lemon_transactions
+		WHERE posting_id IN (
+			SELECT posting_id FROM lemon_transactions
+			WHERE posting_id IS NOT NULL AND ($1 = 0 OR user_id = $1)
+		)
+		GROUP BY posting_id
+		HAVING SUM(amount) <> 0
+		ORDER BY posting_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check lemon postings: %w", err)
+	}
+	defer rows.Close()
+
+	postings := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan lemon posting: %w", err)
+		}
+		postings = append(postings, id)
+	}
+
+	return postings, rows.Err()
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostLemonsOverdraft(t *testing.T) {
+	walletUpdates := 0
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		if strings.Contains(query, "nextval") {
+			return &dbtest.Rows{Columns: []string{"nextval"}, Values: [][]driver.Value{{int64(1)}}}, nil
+		}
+		if strings.Contains(query, "INSERT INTO lemon_transactions") {
+			return &dbtest.Rows{Columns: []string{"id", "created_at"}, Values: [][]driver.Value{{int64(1), time.Now()}}}, nil
+		}
+		if strings.Contains(query, "UPDATE lemon_currency") {
+			walletUpdates++
+		}
+		// The conditional balance update matches no row: it would go negative
+		return nil, nil
+	}}
+	db := dbtest.Open(t, d)
+
+	_, err := postLemons(context.Background(), db, models.LemonTxPurchase, nil, []models.LedgerLeg{
+		{UserID: 1, Account: models.LedgerAccountWallet, Amount: -500},
+		{Account: models.LedgerAccountShop, Amount: 500},
+	})
+	assert.ErrorIs(t, err, ErrInsufficientLemons)
+	assert.Equal(t, 1, walletUpdates)
+}
+
+func TestPostLemonsRejectsInvalidPostings(t *testing.T) {
+	d := &dbtest.Driver{}
+	db := dbtest.Open(t, d)
+
+	_, err := postLemons(context.Background(), db, models.LemonTxAdjustment, nil, []models.LedgerLeg{
+		{UserID: 1, Account: models.LedgerAccountWallet, Amount: 100},
+	})
+	assert.ErrorIs(t, err, models.ErrUnbalancedPosting)
+
+	_, err = postLemons(context.Background(), db, models.LemonTxAdjustment, nil, []models.LedgerLeg{
+		{UserID: 1, Account: models.LedgerAccountWallet, Amount: 100},
+		{Account: "mint", Amount: -100},
+	})
+	assert.ErrorIs(t, err, models.ErrUnknownLedgerAccount)
+	assert.Empty(t, d.Applied(), "nothing is written for an invalid posting")
+}
+
+// lemonBook answers the queries postLemons makes, keeping wallet balances
+// per user and refusing legs that would overdraw them like the database does.
+type lemonBook struct {
+	wallets map[int64]int64
+	posted  []int64 // amount of each lemon_transactions row
+}
+
+func newLemonBook(wallets map[int64]int64) *lemonBook {
+	if wallets == nil {
+		wallets = map[int64]int64{}
+	}
+	return &lemonBook{wallets: wallets}
+}
+
+// query answers a ledger query, reporting false for anything else.
+func (b *lemonBook) query(query string, args []driver.Value) (*dbtest.Rows, bool) {
+	switch {
+	case strings.Contains(query, "nextval('lemon_posting_id_seq')"):
+		return &dbtest.Rows{Columns: []string{"nextval"}, Values: [][]driver.Value{{int64(len(b.posted) + 1)}}}, true
+
+	case strings.Contains(query, "INSERT INTO lemon_transactions"):
+		b.posted = append(b.posted, args[3].(int64))
+		return &dbtest.Rows{Columns: []string{"id", "created_at"}, Values: [][]driver.Value{{int64(len(b.posted)), time.Now()}}}, true
+
+	case strings.Contains(query, "UPDATE lemon_currency"):
+		userID, amount := args[0].(int64), args[1].(int64)
+		if !strings.Contains(query, "SET total_lemons") {
+			return &dbtest.Rows{Columns: []string{"balance"}, Values: [][]driver.Value{{amount}}}, true
+		}
+		if b.wallets[userID]+amount < 0 {
+			return &dbtest.Rows{Columns: []string{"total_lemons"}}, true
+		}
+		b.wallets[userID] += amount
+		return &dbtest.Rows{Columns: []string{"total_lemons"}, Values: [][]driver.Value{{b.wallets[userID]}}}, true
+	}
+	return nil, false
+}
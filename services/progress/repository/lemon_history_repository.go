@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// LEMON TRANSACTION HISTORY
+// ================================================================
+// Read side of the lemon ledger: paginated history of a user's account
+// legs (newest first, keyset cursor on id) and per-period summaries.
+// ================================================================
+
+// userLedgerAccounts are the accounts shown in a user's history
+var userLedgerAccounts = []string{
+	models.LedgerAccountWallet,
+	models.LedgerAccountTree,
+	models.LedgerAccountHarvested,
+}
+
+// lemonHistoryWhere builds the WHERE clause shared by the history queries.
+// The cursor is only applied when withCursor is set.
+func lemonHistoryWhere(f *models.LemonTransactionFilter, withCursor bool) (string, []interface{}) {
+	accounts := f.Accounts
+	if len(accounts) == 0 {
+		accounts = userLedgerAccounts
+	}
+
+	conditions := []string{"user_id = $1", "account = ANY($2)"}
+	args := []interface{}{f.UserID, pq.Array(accounts)}
+
+	if len(f.Types) > 0 {
+		args = append(args, pq.Array(f.Types))
+		conditions = append(conditions, fmt.Sprintf("type = ANY($%d)", len(args)))
+	}
+	if f.From != nil {
+		args = append(args, *f.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if withCursor && f.BeforeID > 0 {
+		args = append(args, f.BeforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetLemonTransactions returns one page of a user's lemon history and the
+// cursor for the next page (0 when there are no more entries)
+func (r *ProgressRepository) GetLemonTransactions(ctx context.Context, f *models.LemonTransactionFilter) ([]models.LedgerEntry, int64, error) {
+	where, args := lemonHistoryWhere(f, true)
+	args = append(args, f.Limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(posting_id, 0), user_id, account, amount, balance_after, type, source_id, created_at
+		FROM lemon_transactions
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, where, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query lemon transactions: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LedgerEntry{}
+	for rows.Next() {
+		var e models.LedgerEntry
+		err := rows.Scan(&e.ID, &e.PostingID, &e.UserID, &e.Account, &e.Amount, &e.BalanceAfter, &e.Type, &e.SourceID, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan lemon transaction: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query lemon transactions: %w", err)
+	}
+
+	var nextCursor int64
+	if len(entries) > f.Limit {
+		entries = entries[:f.Limit]
+		nextCursor = entries[len(entries)-1].ID
+	}
+
+	return entries, nextCursor, nil
+}
+
+// GetLemonTransactionSummaries totals a user's lemon history per period
+// (UTC day, ISO week or month), newest first. The cursor is ignored so
+// every page reports the same totals.
+func (r *ProgressRepository) GetLemonTransactionSummaries(ctx context.Context, f *models.LemonTransactionFilter, period string) ([]models.LemonTransactionSummary, error) {
+	where, args := lemonHistoryWhere(f, false)
+	args = append(args, period)
+
+	query := fmt.Sprintf(`
+		SELECT date_trunc($%d, created_at AT TIME ZONE 'UTC') AS period_start,
+		       type,
+		       COALESCE(SUM(amount) FILTER (WHERE account = 'wallet' AND amount > 0), 0),
+		       COALESCE(-SUM(amount) FILTER (WHERE account = 'wallet' AND amount < 0), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE account = 'harvested'), 0),
+		       COUNT(DISTINCT COALESCE(posting_id, -id))
+		FROM lemon_transactions
+		WHERE %s
+		GROUP BY 1, 2
+		ORDER BY 1 DESC, 2
+	`, len(args), where)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize lemon transactions: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.LemonTransactionSummary{}
+	for rows.Next() {
+		var (
+			periodStart                                time.Time
+			txType                                     string
+			earned, spent, harvested, transactionCount int
+		)
+		if err := rows.Scan(&periodStart, &txType, &earned, &spent, &harvested, &transactionCount); err != nil {
+			return nil, fmt.Errorf("failed to scan lemon summary: %w", err)
+		}
+
+		periodStart = time.Date(periodStart.Year(), periodStart.Month(), periodStart.Day(), 0, 0, 0, 0, time.UTC)
+		if len(summaries) == 0 || !summaries[len(summaries)-1].PeriodStart.Equal(periodStart) {
+			summaries = append(summaries, models.LemonTransactionSummary{
+				PeriodStart:  periodStart,
+				EarnedByType: map[string]int{},
+			})
+		}
+
+		s := &summaries[len(summaries)-1]
+		s.Earned += earned
+		s.Spent += spent
+		s.Harvested += harvested
+		s.Net += earned - spent
+		s.Transactions += transactionCount
+		if earned > 0 {
+			s.EarnedByType[txType] += earned
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize lemon transactions: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// IsAdmin reports whether a user has an admin role
+// (users.role, managed by the admin service)
+func (r *ProgressRepository) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	var isAdmin bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(role, 'user') IN ('admin', 'super_admin') FROM users WHERE id = $1`,
+		userID,
+	).Scan(&isAdmin)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user role: %w", err)
+	}
+
+	return isAdmin, nil
+}
@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// LEMON TREE
+// ================================================================
+// The tree is simulated lazily: lemons bud when rewards are credited,
+// ripen at ripens_at, and withering during inactivity is applied whenever
+// the tree is read or changed. The lemon_trees row is locked for every
+// change so settlement is applied exactly once.
+// ================================================================
+
+var (
+	// ErrNoRipeLemons is returned when harvesting a tree with no ripe lemons
+	ErrNoRipeLemons = errors.New("no ripe lemons")
+
+	// ErrHarvestCooldown is returned when harvesting again too soon
+	ErrHarvestCooldown = errors.New("harvest on cooldown")
+
+	// ErrAdRewardRequired is returned when harvesting without a verified ad reward
+	ErrAdRewardRequired = errors.New("ad reward required")
+)
+
+// lemonTree is the locked lemon_trees row
+type lemonTree struct {
+	userID         int64
+	lastActivityAt time.Time
+	lastHarvestAt  *time.Time
+	nextWitherAt   *time.Time
+}
+
+// loadTreeSettings reads the simulation parameters from gamification_settings
+func loadTreeSettings(ctx context.Context, q DBTX) (models.TreeSettings, error) {
+	settings := models.DefaultTreeSettings()
+
+	query := `
+		SELECT COALESCE(max_tree_lemons, 10), COALESCE(tree_ripen_minutes, 240),
+		       COALESCE(tree_study_boost_minutes, 30), COALESCE(tree_wither_after_hours, 72),
+		       COALESCE(tree_wither_interval_hours, 24), COALESCE(tree_harvest_cooldown_seconds, 60),
+		       COALESCE(ads_enabled, true), COALESCE(ads_daily_reward_cap, 10), COALESCE(ad_grant_ttl_minutes, 30)
+		FROM gamification_settings
+		WHERE id = 1
+	`
+
+	var ripenMinutes, boostMinutes, witherAfterHours, witherIntervalHours, cooldownSeconds, grantTTLMinutes int
+	err := q.QueryRowContext(ctx, query).Scan(
+		&settings.Capacity, &ripenMinutes, &boostMinutes,
+		&witherAfterHours, &witherIntervalHours, &cooldownSeconds,
+		&settings.AdsEnabled, &settings.AdDailyCap, &grantTTLMinutes,
+	)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to get tree settings: %w", err)
+	}
+
+	settings.RipenDuration = time.Duration(ripenMinutes) * time.Minute
+	settings.StudyBoost = time.Duration(boostMinutes) * time.Minute
+	settings.WitherAfter = time.Duration(witherAfterHours) * time.Hour
+	settings.WitherInterval = time.Duration(witherIntervalHours) * time.Hour
+	settings.HarvestCooldown = time.Duration(cooldownSeconds) * time.Second
+	settings.AdGrantTTL = time.Duration(grantTTLMinutes) * time.Minute
+
+	return settings, nil
+}
+
+// lockLemonTree creates the user's tree if needed and locks it
+func lockLemonTree(ctx context.Context, q DBTX, userID int64) (*lemonTree, error) {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO lemon_trees (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lemon tree: %w", err)
+	}
+
+	tree := &lemonTree{userID: userID}
+	err = q.QueryRowContext(ctx,
+		`SELECT last_activity_at, last_harvest_at, next_wither_at FROM lemon_trees WHERE user_id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&tree.lastActivityAt, &tree.lastHarvestAt, &tree.nextWitherAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock lemon tree: %w", err)
+	}
+
+	return tree, nil
+}
+
+// settleLemonTree withers the ripe lemons due since the last settlement.
+// Returns the number of lemons withered.
+func settleLemonTree(ctx context.Context, q DBTX, tree *lemonTree, s models.TreeSettings, now time.Time) (int, error) {
+	due, next := s.WitherDue(tree.lastActivityAt, tree.nextWitherAt, now)
+	if due == 0 {
+		return 0, nil
+	}
+
+	query := `
+		UPDATE lemon_tree_fruits
+		SET status = 'withered', ended_at = $3
+		WHERE id IN (
+			SELECT id FROM lemon_tree_fruits
+			WHERE user_id = $1 AND status = 'on_tree' AND ripens_at <= $3
+			ORDER BY ripens_at, id
+			LIMIT $2
+		)
+	`
+	result, err := q.ExecContext(ctx, query, tree.userID, due, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to wither lemons: %w", err)
+	}
+	withered, _ := result.RowsAffected()
+
+	_, err = q.ExecContext(ctx,
+		`UPDATE lemon_trees SET next_wither_at = $2, updated_at = NOW() WHERE user_id = $1`,
+		tree.userID, next,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update lemon tree: %w", err)
+	}
+	tree.nextWitherAt = &next
+
+	if withered > 0 {
+		_, err := postLemons(ctx, q, models.LemonTxWither, nil, []models.LedgerLeg{
+			{UserID: tree.userID, Account: models.LedgerAccountTree, Amount: -int(withered)},
+			{UserID: tree.userID, Account: models.LedgerAccountWithered, Amount: int(withered)},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int(withered), nil
+}
+
+// growLemonTree records study activity on the tree: buds already growing
+// ripen sooner, withering is reset, and up to amount new buds grow within
+// capacity. Returns the number of buds grown.
+func growLemonTree(ctx context.Context, q DBTX, userID int64, amount int, now time.Time) (int, error) {
+	s, err := loadTreeSettings(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	tree, err := lockLemonTree(ctx, q, userID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := settleLemonTree(ctx, q, tree, s, now); err != nil {
+		return 0, err
+	}
+
+	if s.StudyBoost > 0 {
+		_, err := q.ExecContext(ctx, `
+			UPDATE lemon_tree_fruits
+			SET ripens_at = GREATEST($2, ripens_at - make_interval(secs => $3))
+			WHERE user_id = $1 AND status = 'on_tree' AND ripens_at > $2
+		`, userID, now, s.StudyBoost.Seconds())
+		if err != nil {
+			return 0, fmt.Errorf("failed to ripen lemons: %w", err)
+		}
+	}
+
+	_, err = q.ExecContext(ctx,
+		`UPDATE lemon_trees SET last_activity_at = $2, next_wither_at = NULL, updated_at = NOW() WHERE user_id = $1`,
+		userID, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update lemon tree: %w", err)
+	}
+
+	var onTree int
+	err = q.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM lemon_tree_fruits WHERE user_id = $1 AND status = 'on_tree'`,
+		userID,
+	).Scan(&onTree)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tree lemons: %w", err)
+	}
+
+	grow := amount
+	if room := s.Capacity - onTree; grow > room {
+		grow = room
+	}
+	if grow <= 0 {
+		return 0, nil
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO lemon_tree_fruits (user_id, grown_at, ripens_at)
+		SELECT $1, $2, $2 + make_interval(secs => $3)
+		FROM generate_series(1, $4)
+	`, userID, now, s.RipenDuration.Seconds(), grow)
+	if err != nil {
+		return 0, fmt.Errorf("failed to grow lemons: %w", err)
+	}
+
+	return grow, nil
+}
+
+// GetLemonTree settles and returns the user's tree
+func (r *ProgressRepository) GetLemonTree(ctx context.Context, userID int64) (*models.LemonTreeState, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	s, err := loadTreeSettings(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := lockLemonTree(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	withered, err := settleLemonTree(ctx, tx, tree, s, now)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := lemonTreeState(ctx, tx, tree, s, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if withered > 0 {
+		r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+			"reason":   "wither",
+			"withered": withered,
+		})
+	}
+
+	return state, nil
+}
+
+// HarvestLemon picks the oldest ripe lemon off the tree, consuming an ad
+// reward grant while ads are enabled. Returns ErrNoRipeLemons,
+// ErrHarvestCooldown or ErrAdRewardRequired when harvesting is not allowed.
+func (r *ProgressRepository) HarvestLemon(ctx context.Context, userID int64) (*models.LemonTreeState, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	s, err := loadTreeSettings(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := lockLemonTree(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := settleLemonTree(ctx, tx, tree, s, now); err != nil {
+		return nil, err
+	}
+
+	var harvestErr error
+	var fruitID int64
+	if tree.lastHarvestAt != nil && now.Before(tree.lastHarvestAt.Add(s.HarvestCooldown)) {
+		harvestErr = ErrHarvestCooldown
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM lemon_tree_fruits
+			WHERE user_id = $1 AND status = 'on_tree' AND ripens_at <= $2
+			ORDER BY ripens_at, id
+			LIMIT 1
+		`, userID, now).Scan(&fruitID)
+		if err == sql.ErrNoRows {
+			harvestErr = ErrNoRipeLemons
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to find ripe lemon: %w", err)
+		}
+	}
+
+	if harvestErr == nil && s.AdsEnabled {
+		consumed, err := consumeAdRewardGrant(ctx, tx, userID, fruitID, now)
+		if err != nil {
+			return nil, err
+		}
+		if !consumed {
+			harvestErr = ErrAdRewardRequired
+		}
+	}
+
+	// Settlement is kept even when the harvest is refused
+	if harvestErr != nil {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil, harvestErr
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE lemon_tree_fruits SET status = 'harvested', ended_at = $2 WHERE id = $1`,
+		fruitID, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to harvest lemon: %w", err)
+	}
+
+	_, err = postLemons(ctx, tx, models.LemonTxHarvest, &fruitID, []models.LedgerLeg{
+		{UserID: userID, Account: models.LedgerAccountTree, Amount: -1},
+		{UserID: userID, Account: models.LedgerAccountHarvested, Amount: 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE lemon_trees SET last_harvest_at = $2, updated_at = NOW() WHERE user_id = $1`,
+		userID, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update lemon tree: %w", err)
+	}
+	tree.lastHarvestAt = &now
+
+	state, err := lemonTreeState(ctx, tx, tree, s, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return state, nil
+}
+
+// lemonTreeState reads the fruits on a settled tree
+func lemonTreeState(ctx context.Context, q DBTX, tree *lemonTree, s models.TreeSettings, now time.Time) (*models.LemonTreeState, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, grown_at, ripens_at
+		FROM lemon_tree_fruits
+		WHERE user_id = $1 AND status = 'on_tree'
+		ORDER BY ripens_at, id
+	`, tree.userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree lemons: %w", err)
+	}
+	defer rows.Close()
+
+	state := &models.LemonTreeState{
+		Capacity:       s.Capacity,
+		Fruits:         []models.LemonFruit{},
+		LastActivityAt: tree.lastActivityAt,
+		LastHarvestAt:  tree.lastHarvestAt,
+		ServerTime:     now,
+	}
+
+	for rows.Next() {
+		var fruit models.LemonFruit
+		if err := rows.Scan(&fruit.ID, &fruit.GrownAt, &fruit.RipensAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tree lemon: %w", err)
+		}
+		fruit.Ripe = !fruit.RipensAt.After(now)
+		if fruit.Ripe {
+			state.RipeLemons++
+		} else {
+			state.GrowingLemons++
+			if state.NextRipenAt == nil {
+				ripensAt := fruit.RipensAt
+				state.NextRipenAt = &ripensAt
+			}
+		}
+		state.Fruits = append(state.Fruits, fruit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get tree lemons: %w", err)
+	}
+
+	err = q.QueryRowContext(ctx,
+		`SELECT COALESCE((SELECT tree_lemons_harvested FROM lemon_currency WHERE user_id = $1), 0)`,
+		tree.userID,
+	).Scan(&state.TreeLemonsHarvested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get harvested lemons: %w", err)
+	}
+
+	nextWither := s.NextWitherAt(tree.lastActivityAt, tree.nextWitherAt)
+	state.NextWitherAt = &nextWither
+
+	state.AdRequired = s.AdsEnabled
+	if s.AdsEnabled {
+		err := q.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM ad_reward_grants WHERE user_id = $1 AND status = 'available' AND expires_at > $2`,
+			tree.userID, now,
+		).Scan(&state.AdGrants)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count ad reward grants: %w", err)
+		}
+	}
+
+	state.CanHarvest = state.RipeLemons > 0 && (!s.AdsEnabled || state.AdGrants > 0)
+	if tree.lastHarvestAt != nil {
+		availableAt := tree.lastHarvestAt.Add(s.HarvestCooldown)
+		if now.Before(availableAt) {
+			state.HarvestAvailableAt = &availableAt
+			state.CanHarvest = false
+		}
+	}
+
+	return state, nil
+}
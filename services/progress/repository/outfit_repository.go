@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// OUTFIT PRESETS
+// ================================================================
+// Presets are checked against the equip rules when saved and again when
+// applied, since items can be deactivated in between. A user's preset
+// changes are serialized with an advisory lock so the per-user limit and
+// unique names hold.
+// ================================================================
+
+var (
+	// ErrOutfitNotFound is returned for unknown presets or presets of another user
+	ErrOutfitNotFound = errors.New("outfit not found")
+
+	// ErrOutfitLimitReached is returned when the user already has the maximum
+	// number of presets
+	ErrOutfitLimitReached = errors.New("outfit limit reached")
+
+	// ErrOutfitNameTaken is returned when the user has another preset with the name
+	ErrOutfitNameTaken = errors.New("outfit name already used")
+
+	// ErrOutfitConflict is returned when a preset holds items that cannot be
+	// worn together
+	ErrOutfitConflict = errors.New("outfit has items that cannot be worn together")
+)
+
+// lockOutfits serializes the user's preset changes until the transaction ends
+func lockOutfits(ctx context.Context, q DBTX, userID int64) error {
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('outfits'), $1::int)`, userID); err != nil {
+		return fmt.Errorf("failed to lock outfits: %w", err)
+	}
+	return nil
+}
+
+// checkOutfit validates every slot of an outfit against the equip rules
+func checkOutfit(ctx context.Context, q DBTX, userID int64, slots models.Loadout) error {
+	for slot := range slots {
+		if !models.IsCharacterSlot(slot) {
+			return ErrInvalidSlot
+		}
+	}
+	for _, slot := range models.CharacterSlots {
+		if id, ok := slots[slot]; ok {
+			if err := checkEquippable(ctx, q, userID, slot, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	excludes, err := getItemExcludes(ctx, q, slots)
+	if err != nil {
+		return err
+	}
+	if _, _, conflict := slots.Conflict(excludes); conflict {
+		return ErrOutfitConflict
+	}
+	return nil
+}
+
+// checkOutfitName returns ErrOutfitNameTaken if another of the user's
+// presets (other than exceptID) has the name
+func checkOutfitName(ctx context.Context, q DBTX, userID int64, name string, exceptID int64) error {
+	var taken bool
+	err := q.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM outfit_presets WHERE user_id = $1 AND name = $2 AND id != $3)`,
+		userID, name, exceptID,
+	).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to check outfit name: %w", err)
+	}
+	if taken {
+		return ErrOutfitNameTaken
+	}
+	return nil
+}
+
+// getOutfit returns one of the user's presets
+func getOutfit(ctx context.Context, q DBTX, userID, outfitID int64) (*models.OutfitPreset, error) {
+	var o models.OutfitPreset
+	var slots []byte
+	err := q.QueryRowContext(ctx, `
+		SELECT id, name, slots, skin_color, created_at, updated_at
+		FROM outfit_presets
+		WHERE id = $1 AND user_id = $2
+	`, outfitID, userID).Scan(&o.ID, &o.Name, &slots, &o.SkinColor, &o.CreatedAt, &o.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOutfitNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outfit: %w", err)
+	}
+	if err := json.Unmarshal(slots, &o.Slots); err != nil {
+		return nil, fmt.Errorf("failed to decode outfit slots: %w", err)
+	}
+	return &o, nil
+}
+
+// ListOutfits returns the user's presets, oldest first
+func (r *ProgressRepository) ListOutfits(ctx context.Context, userID int64) ([]models.OutfitPreset, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, slots, skin_color, created_at, updated_at
+		FROM outfit_presets
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outfits: %w", err)
+	}
+	defer rows.Close()
+
+	outfits := []models.OutfitPreset{}
+	for rows.Next() {
+		var o models.OutfitPreset
+		var slots []byte
+		if err := rows.Scan(&o.ID, &o.Name, &slots, &o.SkinColor, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outfit: %w", err)
+		}
+		if err := json.Unmarshal(slots, &o.Slots); err != nil {
+			return nil, fmt.Errorf("failed to decode outfit slots: %w", err)
+		}
+		outfits = append(outfits, o)
+	}
+	return outfits, rows.Err()
+}
+
+// CreateOutfit saves a new preset. An empty skin colour is taken from the
+// user's current character.
+func (r *ProgressRepository) CreateOutfit(ctx context.Context, userID int64, name string, slots models.Loadout, skinColor string) (*models.OutfitPreset, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOutfits(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM outfit_presets WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count outfits: %w", err)
+	}
+	if count >= models.MaxOutfitPresets {
+		return nil, ErrOutfitLimitReached
+	}
+	if err := checkOutfitName(ctx, tx, userID, name, 0); err != nil {
+		return nil, err
+	}
+
+	if skinColor == "" {
+		_, currentSkin, err := loadCharacter(ctx, tx, userID, false)
+		if err != nil {
+			return nil, err
+		}
+		skinColor = currentSkin
+	}
+	if err := checkOutfit(ctx, tx, userID, slots); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(slots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outfit slots: %w", err)
+	}
+
+	o := &models.OutfitPreset{Name: name, Slots: slots, SkinColor: skinColor}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO outfit_presets (user_id, name, slots, skin_color)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, userID, name, encoded, skinColor).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create outfit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit outfit: %w", err)
+	}
+	return o, nil
+}
+
+// UpdateOutfit replaces a preset's name, slots and skin colour
+func (r *ProgressRepository) UpdateOutfit(ctx context.Context, userID, outfitID int64, name string, slots models.Loadout, skinColor string) (*models.OutfitPreset, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOutfits(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	o, err := getOutfit(ctx, tx, userID, outfitID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutfitName(ctx, tx, userID, name, outfitID); err != nil {
+		return nil, err
+	}
+	if err := checkOutfit(ctx, tx, userID, slots); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(slots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outfit slots: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE outfit_presets
+		SET name = $3, slots = $4, skin_color = $5, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING updated_at
+	`, outfitID, userID, name, encoded, skinColor).Scan(&o.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update outfit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit outfit: %w", err)
+	}
+
+	o.Name, o.Slots, o.SkinColor = name, slots, skinColor
+	return o, nil
+}
+
+// DeleteOutfit removes one of the user's presets
+func (r *ProgressRepository) DeleteOutfit(ctx context.Context, userID, outfitID int64) error {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM outfit_presets WHERE id = $1 AND user_id = $2`, outfitID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete outfit: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOutfitNotFound
+	}
+	return nil
+}
+
+// ApplyOutfit replaces the user's whole character with a preset. Every item
+// is checked inside the transaction, so either the full look is applied or
+// nothing changes. Returns the new loadout and skin colour.
+func (r *ProgressRepository) ApplyOutfit(ctx context.Context, userID, outfitID int64) (models.Loadout, string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	o, err := getOutfit(ctx, tx, userID, outfitID)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, _, err := loadCharacter(ctx, tx, userID, true); err != nil {
+		return nil, "", err
+	}
+	if err := checkOutfit(ctx, tx, userID, o.Slots); err != nil {
+		return nil, "", err
+	}
+
+	if err := saveLoadout(ctx, tx, userID, o.Slots); err != nil {
+		return nil, "", err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE user_characters SET skin_color = $2 WHERE user_id = $1`, userID, o.SkinColor)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update skin color: %w", err)
+	}
+
+	defaults, err := getDefaultItems(ctx, tx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("failed to commit outfit: %w", err)
+	}
+
+	return o.Slots.WithDefaults(defaults), o.SkinColor, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOutfit(t *testing.T) {
+	// The outfit wears hair 1 and hat 2
+	categories := map[int64]string{1: "hair", 2: "hat"}
+	newDriver := func(hatExcludesHair bool) *dbtest.Driver {
+		return &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+			switch {
+			case strings.Contains(query, "FROM outfit_presets"):
+				return &dbtest.Rows{
+					Columns: []string{"id", "name", "slots", "skin_color", "created_at", "updated_at"},
+					Values:  [][]driver.Value{{int64(5), "Casual", []byte(`{"hair":1,"hat":2}`), "#f5d0b0", time.Now(), time.Now()}},
+				}, nil
+			case strings.Contains(query, "FROM character_items ci"):
+				return &dbtest.Rows{
+					Columns: []string{"category", "is_default", "is_active", "owned"},
+					Values:  [][]driver.Value{{categories[args[1].(int64)], false, true, true}},
+				}, nil
+			case strings.Contains(query, "metadata->'excludes'") && hatExcludesHair:
+				return &dbtest.Rows{Columns: []string{"id", "excludes"}, Values: [][]driver.Value{{int64(2), []byte(`["hair"]`)}}}, nil
+			}
+			return nil, nil
+		}}
+	}
+
+	d := newDriver(false)
+	loadout, skinColor, err := newTestRepository(t, d).ApplyOutfit(context.Background(), 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), loadout["hat"])
+	assert.Equal(t, "#f5d0b0", skinColor)
+	assert.NotEmpty(t, d.Applied())
+
+	d = newDriver(true)
+	_, _, err = newTestRepository(t, d).ApplyOutfit(context.Background(), 1, 5)
+	assert.ErrorIs(t, err, ErrOutfitConflict)
+	assert.Empty(t, d.Applied(), "a conflicting outfit changes nothing")
+	assert.Equal(t, 1, d.Rollbacks())
+}
@@ -13,6 +13,14 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// DBTX is the subset of *sql.DB and *sql.Tx used by write helpers,
+// so the same statements can run standalone or inside a shared transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 // ProgressRepository handles database operations for progress
 type ProgressRepository struct {
 	db    *sql.DB
@@ -108,8 +116,36 @@ func (r *ProgressRepository) CompleteLesson(ctx context.Context, req *models.Com
 	}
 	defer tx.Rollback()
 
-	now := time.Now()
+	err = r.recordLearningEvent(ctx, tx, req.UserID, models.LearningEventLessonCompleted, &models.LessonCompletedData{
+		LessonID:  req.LessonID,
+		QuizScore: req.QuizScore,
+		TimeSpent: req.TimeSpent,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
+	// Invalidate cache
+	r.invalidateProgressCache(ctx, req.UserID)
+	r.invalidateStatsCache(ctx, req.UserID)
+
+	r.PublishEvent(ctx, req.UserID, models.EventTypeProgress, map[string]interface{}{
+		"lesson_id":  req.LessonID,
+		"status":     models.StatusCompleted,
+		"quiz_score": req.QuizScore,
+	})
+	r.publishStreak(ctx, req.UserID)
+	r.recordLeaderboardScores(ctx, req.UserID, models.LeaderboardScores{StudyMinutes: req.TimeSpent})
+
+	return nil
+}
+
+// completeLesson projects a lesson_completed event onto user_progress
+func (r *ProgressRepository) completeLesson(ctx context.Context, q DBTX, userID int64, d *models.LessonCompletedData, at time.Time) error {
 	// Upsert user_progress
 	query := `
 		INSERT INTO user_progress (
@@ -127,14 +163,35 @@ func (r *ProgressRepository) CompleteLesson(ctx context.Context, req *models.Com
 			updated_at = $6
 	`
 
-	_, err = tx.ExecContext(ctx, query,
-		req.UserID, req.LessonID, models.StatusCompleted,
-		req.QuizScore, req.TimeSpent, now,
+	_, err := q.ExecContext(ctx, query,
+		userID, d.LessonID, models.StatusCompleted,
+		d.QuizScore, d.TimeSpent, at,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert/update progress: %w", err)
 	}
 
+	return nil
+}
+
+// UpdateProgress updates lesson progress
+func (r *ProgressRepository) UpdateProgress(ctx context.Context, req *models.UpdateProgressRequest) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = r.recordLearningEvent(ctx, tx, req.UserID, models.LearningEventProgressUpdated, &models.ProgressUpdatedData{
+		LessonID:        req.LessonID,
+		Status:          req.Status,
+		ProgressPercent: req.ProgressPercent,
+		TimeSpent:       req.TimeSpent,
+	})
+	if err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -143,13 +200,18 @@ func (r *ProgressRepository) CompleteLesson(ctx context.Context, req *models.Com
 	r.invalidateProgressCache(ctx, req.UserID)
 	r.invalidateStatsCache(ctx, req.UserID)
 
+	r.PublishEvent(ctx, req.UserID, models.EventTypeProgress, map[string]interface{}{
+		"lesson_id":        req.LessonID,
+		"status":           req.Status,
+		"progress_percent": req.ProgressPercent,
+	})
+	r.recordLeaderboardScores(ctx, req.UserID, models.LeaderboardScores{StudyMinutes: req.TimeSpent})
+
 	return nil
 }
 
-// UpdateProgress updates lesson progress
-func (r *ProgressRepository) UpdateProgress(ctx context.Context, req *models.UpdateProgressRequest) error {
-	now := time.Now()
-
+// updateProgress projects a progress_updated event onto user_progress
+func (r *ProgressRepository) updateProgress(ctx context.Context, q DBTX, userID int64, d *models.ProgressUpdatedData, at time.Time) error {
 	query := `
 		INSERT INTO user_progress (
 			user_id, lesson_id, status, progress_percent,
@@ -164,43 +226,61 @@ func (r *ProgressRepository) UpdateProgress(ctx context.Context, req *models.Upd
 			updated_at = $6
 	`
 
-	status := req.Status
+	status := d.Status
 	if status == "" {
 		status = models.StatusInProgress
 	}
 
-	_, err := r.db.ExecContext(ctx, query,
-		req.UserID, req.LessonID, status, req.ProgressPercent,
-		req.TimeSpent, now,
+	_, err := q.ExecContext(ctx, query,
+		userID, d.LessonID, status, d.ProgressPercent,
+		d.TimeSpent, at,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to update progress: %w", err)
 	}
 
-	// Invalidate cache
-	r.invalidateProgressCache(ctx, req.UserID)
-	r.invalidateStatsCache(ctx, req.UserID)
-
 	return nil
 }
 
 // ResetLessonProgress resets progress for a lesson
 func (r *ProgressRepository) ResetLessonProgress(ctx context.Context, userID, lessonID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = r.recordLearningEvent(ctx, tx, userID, models.LearningEventProgressReset, &models.ProgressResetData{
+		LessonID: lessonID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Invalidate cache
+	r.invalidateProgressCache(ctx, userID)
+	r.invalidateStatsCache(ctx, userID)
+
+	return nil
+}
+
+// resetLessonProgress projects a progress_reset event onto user_progress
+func (r *ProgressRepository) resetLessonProgress(ctx context.Context, q DBTX, userID int64, d *models.ProgressResetData) error {
 	query := `
 		DELETE FROM user_progress
 		WHERE user_id = $1 AND lesson_id = $2
 	`
 
-	_, err := r.db.ExecContext(ctx, query, userID, lessonID)
+	_, err := q.ExecContext(ctx, query, userID, d.LessonID)
 	if err != nil {
 		return fmt.Errorf("failed to reset progress: %w", err)
 	}
 
-	// Invalidate cache
-	r.invalidateProgressCache(ctx, userID)
-	r.invalidateStatsCache(ctx, userID)
-
 	return nil
 }
 
@@ -247,6 +327,10 @@ func (r *ProgressRepository) GetVocabularyProgress(ctx context.Context, userID i
 
 // GetVocabularyProgressByID retrieves vocabulary progress for a specific word
 func (r *ProgressRepository) GetVocabularyProgressByID(ctx context.Context, userID, vocabularyID int64) (*models.VocabularyProgress, error) {
+	return r.getVocabularyProgressByID(ctx, r.db, userID, vocabularyID)
+}
+
+func (r *ProgressRepository) getVocabularyProgressByID(ctx context.Context, q DBTX, userID, vocabularyID int64) (*models.VocabularyProgress, error) {
 	query := `
 		SELECT id, user_id, vocabulary_id, mastery_level, correct_count,
 		       incorrect_count, last_reviewed_at, next_review_at,
@@ -257,7 +341,7 @@ func (r *ProgressRepository) GetVocabularyProgressByID(ctx context.Context, user
 	`
 
 	var vp models.VocabularyProgress
-	err := r.db.QueryRowContext(ctx, query, userID, vocabularyID).Scan(
+	err := q.QueryRowContext(ctx, query, userID, vocabularyID).Scan(
 		&vp.ID, &vp.UserID, &vp.VocabularyID, &vp.MasteryLevel,
 		&vp.CorrectCount, &vp.IncorrectCount, &vp.LastReviewedAt,
 		&vp.NextReviewAt, &vp.EasinessFactor, &vp.RepetitionCount,
@@ -282,17 +366,33 @@ func (r *ProgressRepository) RecordVocabularyPractice(ctx context.Context, req *
 	}
 	defer tx.Rollback()
 
-	now := time.Now()
+	err = r.recordLearningEvent(ctx, tx, req.UserID, models.LearningEventVocabularyAnswered, &models.VocabularyAnsweredData{
+		VocabularyID:    req.VocabularyID,
+		IsCorrect:       req.IsCorrect,
+		ResponseTime:    req.ResponseTime,
+		MasteryLevel:    int(srsData["mastery_level"].(float64)),
+		EasinessFactor:  srsData["easiness_factor"].(float64),
+		IntervalDays:    int(srsData["interval_days"].(float64)),
+		RepetitionCount: int(srsData["repetition_count"].(float64)),
+		NextReviewAt:    srsData["next_review_at"].(time.Time),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-	// Extract SRS data
-	masteryLevel := int(srsData["mastery_level"].(float64))
-	easinessFactor := srsData["easiness_factor"].(float64)
-	intervalDays := int(srsData["interval_days"].(float64))
-	repetitionCount := int(srsData["repetition_count"].(float64))
-	nextReviewAt := srsData["next_review_at"].(time.Time)
+	r.recordLeaderboardScores(ctx, req.UserID, models.LeaderboardScores{Reviews: 1})
+
+	return nil
+}
 
+// recordVocabularyPractice projects a vocabulary_answered event onto vocabulary_progress
+func (r *ProgressRepository) recordVocabularyPractice(ctx context.Context, q DBTX, userID int64, d *models.VocabularyAnsweredData, at time.Time) error {
 	var correctIncrement, incorrectIncrement int
-	if req.IsCorrect {
+	if d.IsCorrect {
 		correctIncrement = 1
 	} else {
 		incorrectIncrement = 1
@@ -317,19 +417,15 @@ func (r *ProgressRepository) RecordVocabularyPractice(ctx context.Context, req *
 			updated_at = $6
 	`
 
-	_, err = tx.ExecContext(ctx, query,
-		req.UserID, req.VocabularyID, masteryLevel, correctIncrement, incorrectIncrement,
-		now, nextReviewAt, easinessFactor, repetitionCount, intervalDays,
+	_, err := q.ExecContext(ctx, query,
+		userID, d.VocabularyID, d.MasteryLevel, correctIncrement, incorrectIncrement,
+		at, d.NextReviewAt, d.EasinessFactor, d.RepetitionCount, d.IntervalDays,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to record vocabulary practice: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -341,11 +437,27 @@ func (r *ProgressRepository) RecordVocabularyBatch(ctx context.Context, req *mod
 	}
 	defer tx.Rollback()
 
-	now := time.Now()
-	successCount := 0
-	failCount := 0
+	err = r.recordLearningEvent(ctx, tx, req.UserID, models.LearningEventVocabularyBatch, &models.VocabularyBatchData{
+		LessonID: req.LessonID,
+		Results:  req.VocabularyResults,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
 
-	for _, result := range req.VocabularyResults {
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.recordLeaderboardScores(ctx, req.UserID, models.LeaderboardScores{Reviews: len(req.VocabularyResults)})
+
+	return len(req.VocabularyResults), 0, nil
+}
+
+// recordVocabularyBatch projects a vocabulary_batch_answered event onto vocabulary_progress.
+// A failed statement aborts the surrounding transaction, so the first error is returned.
+func (r *ProgressRepository) recordVocabularyBatch(ctx context.Context, q DBTX, userID int64, d *models.VocabularyBatchData, at time.Time) error {
+	for _, result := range d.Results {
 		// Determine mastery level and SRS values based on correctness
 		var masteryLevel int
 		var correctIncrement, incorrectIncrement int
@@ -362,7 +474,7 @@ func (r *ProgressRepository) RecordVocabularyBatch(ctx context.Context, req *mod
 		easinessFactor := 2.5
 		intervalDays := 1
 		repetitionCount := 1
-		nextReviewAt := now.Add(24 * time.Hour)
+		nextReviewAt := at.Add(24 * time.Hour)
 
 		query := `
 			INSERT INTO vocabulary_progress (
@@ -383,23 +495,17 @@ func (r *ProgressRepository) RecordVocabularyBatch(ctx context.Context, req *mod
 				updated_at = $6
 		`
 
-		_, err := tx.ExecContext(ctx, query,
-			req.UserID, result.VocabularyID, masteryLevel, correctIncrement, incorrectIncrement,
-			now, nextReviewAt, easinessFactor, repetitionCount, intervalDays,
+		_, err := q.ExecContext(ctx, query,
+			userID, result.VocabularyID, masteryLevel, correctIncrement, incorrectIncrement,
+			at, nextReviewAt, easinessFactor, repetitionCount, intervalDays,
 		)
 
 		if err != nil {
-			failCount++
-		} else {
-			successCount++
+			return fmt.Errorf("failed to record vocabulary %d: %w", result.VocabularyID, err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return successCount, failCount, nil
+	return nil
 }
 
 // GetReviewSchedule retrieves vocabulary items due for review
@@ -616,7 +722,7 @@ func (r *ProgressRepository) GetUserStats(ctx context.Context, userID int64) (*m
 	// Check cache first
 	cachedStats, err := r.getCachedStats(ctx, userID)
 	if err == nil && cachedStats != nil {
-		return cachedStats, nil
+		return r.withLevelProgress(ctx, cachedStats)
 	}
 
 	query := `
@@ -674,7 +780,7 @@ func (r *ProgressRepository) GetUserStats(ctx context.Context, userID int64) (*m
 	// Cache the stats
 	_ = r.cacheStats(ctx, userID, &stats)
 
-	return &stats, nil
+	return r.withLevelProgress(ctx, &stats)
 }
 
 // GetWeeklyStats retrieves weekly statistics
@@ -820,21 +926,14 @@ func (r *ProgressRepository) SyncOfflineData(ctx context.Context, req *models.Sy
 	successCount := 0
 	failCount := 0
 
-	for _, item := range req.SyncItems {
-		var err error
-
-		switch item.Type {
-		case "lesson_complete":
-			err = r.syncLessonComplete(ctx, &item, req.UserID)
-		case "progress_update":
-			err = r.syncProgressUpdate(ctx, &item, req.UserID)
-		case "vocabulary_practice":
-			err = r.syncVocabularyPractice(ctx, &item, req.UserID)
-		default:
-			continue // Skip unknown types
+	for i := range req.SyncItems {
+		payload, itemErr := models.DecodeSyncItem(i, &req.SyncItems[i])
+		if itemErr != nil {
+			failCount++
+			continue
 		}
 
-		if err != nil {
+		if err := r.ApplySyncPayload(ctx, req.UserID, payload); err != nil {
 			failCount++
 		} else {
 			successCount++
@@ -847,71 +946,178 @@ func (r *ProgressRepository) SyncOfflineData(ctx context.Context, req *models.Sy
 	return successCount, failCount, nil
 }
 
-// Helper functions for sync operations
-func (r *ProgressRepository) syncLessonComplete(ctx context.Context, item *models.SyncItem, userID int64) error {
-	lessonID, ok := item.Data["lesson_id"].(float64)
-	if !ok {
-		return fmt.Errorf("invalid lesson_id")
+// ApplySyncPayload applies a decoded and validated sync payload for a user
+func (r *ProgressRepository) ApplySyncPayload(ctx context.Context, userID int64, payload models.SyncPayload) error {
+	_, err := r.ApplySyncPayloadsAtomic(ctx, userID, []models.SyncPayload{payload})
+	return err
+}
+
+// ApplySyncPayloadsAtomic applies all payloads in a single transaction.
+// On failure nothing is committed and the index of the failing payload is returned.
+func (r *ProgressRepository) ApplySyncPayloadsAtomic(ctx context.Context, userID int64, payloads []models.SyncPayload) (int, error) {
+	var scores models.LeaderboardScores
+	failedAt, err := applyAtomic(ctx, r.db, len(payloads), func(tx DBTX, i int) error {
+		s, err := r.applySyncPayload(ctx, tx, userID, payloads[i])
+		if err != nil {
+			return err
+		}
+		scores.Lemons += s.Lemons
+		scores.Reviews += s.Reviews
+		scores.StudyMinutes += s.StudyMinutes
+		return nil
+	})
+	if err != nil {
+		return failedAt, err
 	}
 
-	quizScore, _ := item.Data["quiz_score"].(float64)
-	timeSpent, _ := item.Data["time_spent"].(float64)
+	// Invalidate caches
+	r.invalidateProgressCache(ctx, userID)
+	r.invalidateStatsCache(ctx, userID)
 
-	req := &models.CompleteProgressRequest{
-		UserID:    userID,
-		LessonID:  int64(lessonID),
-		QuizScore: int(quizScore),
-		TimeSpent: int(timeSpent),
-	}
+	r.publishSyncEvents(ctx, userID, payloads)
+	r.recordLeaderboardScores(ctx, userID, scores)
 
-	return r.CompleteLesson(ctx, req)
+	return -1, nil
 }
 
-func (r *ProgressRepository) syncProgressUpdate(ctx context.Context, item *models.SyncItem, userID int64) error {
-	lessonID, ok := item.Data["lesson_id"].(float64)
-	if !ok {
-		return fmt.Errorf("invalid lesson_id")
+// applyAtomic calls apply for items 0..n-1 in one transaction and commits
+// only if every call succeeds. On failure it returns the failing index, or
+// -1 when the transaction itself failed.
+func applyAtomic(ctx context.Context, db *sql.DB, n int, apply func(tx DBTX, i int) error) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < n; i++ {
+		if err := apply(tx, i); err != nil {
+			return i, err
+		}
 	}
 
-	progressPercent, _ := item.Data["progress_percent"].(float64)
-	timeSpent, _ := item.Data["time_spent"].(float64)
-	status, _ := item.Data["status"].(string)
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return -1, nil
+}
 
-	req := &models.UpdateProgressRequest{
-		UserID:          userID,
-		LessonID:        int64(lessonID),
-		Status:          models.ProgressStatus(status),
-		ProgressPercent: int(progressPercent),
-		TimeSpent:       int(timeSpent),
+// publishSyncEvents notifies other devices about a committed sync unit
+func (r *ProgressRepository) publishSyncEvents(ctx context.Context, userID int64, payloads []models.SyncPayload) {
+	lessonIDs := []int64{}
+	lessonCompleted := false
+	rewardLessonIDs := []int64{}
+
+	for _, payload := range payloads {
+		switch p := payload.(type) {
+		case *models.LessonCompletePayload:
+			lessonIDs = append(lessonIDs, p.LessonID)
+			lessonCompleted = true
+		case *models.ProgressUpdatePayload:
+			lessonIDs = append(lessonIDs, p.LessonID)
+		case *models.LessonRewardPayload:
+			rewardLessonIDs = append(rewardLessonIDs, p.LessonID)
+		}
 	}
 
-	return r.UpdateProgress(ctx, req)
+	r.PublishEvent(ctx, userID, models.EventTypeProgress, map[string]interface{}{
+		"source":     "sync",
+		"items":      len(payloads),
+		"lesson_ids": lessonIDs,
+	})
+	if len(rewardLessonIDs) > 0 {
+		// Amounts are computed server-side; clients refetch the balance
+		r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+			"reason":     "lesson",
+			"lesson_ids": rewardLessonIDs,
+		})
+	}
+	if lessonCompleted {
+		r.publishStreak(ctx, userID)
+	}
 }
 
-func (r *ProgressRepository) syncVocabularyPractice(ctx context.Context, item *models.SyncItem, userID int64) error {
-	vocabID, ok := item.Data["vocabulary_id"].(float64)
-	if !ok {
-		return fmt.Errorf("invalid vocabulary_id")
+// applySyncPayload dispatches a payload to the matching write helper.
+// Returns the leaderboard scores to record once the sync commits.
+func (r *ProgressRepository) applySyncPayload(ctx context.Context, q DBTX, userID int64, payload models.SyncPayload) (models.LeaderboardScores, error) {
+	switch p := payload.(type) {
+	case *models.LessonCompletePayload:
+		return models.LeaderboardScores{StudyMinutes: p.TimeSpent},
+			r.recordLearningEvent(ctx, q, userID, models.LearningEventLessonCompleted, &models.LessonCompletedData{
+				LessonID:  p.LessonID,
+				QuizScore: p.QuizScore,
+				TimeSpent: p.TimeSpent,
+			})
+
+	case *models.ProgressUpdatePayload:
+		return models.LeaderboardScores{StudyMinutes: p.TimeSpent},
+			r.recordLearningEvent(ctx, q, userID, models.LearningEventProgressUpdated, &models.ProgressUpdatedData{
+				LessonID:        p.LessonID,
+				Status:          p.Status,
+				ProgressPercent: p.ProgressPercent,
+				TimeSpent:       p.TimeSpent,
+			})
+
+	case *models.VocabularyPracticePayload:
+		return models.LeaderboardScores{Reviews: 1}, r.syncVocabularyPractice(ctx, q, userID, p)
+
+	case *models.VocabularyBatchPayload:
+		return models.LeaderboardScores{Reviews: len(p.VocabularyResults)},
+			r.recordLearningEvent(ctx, q, userID, models.LearningEventVocabularyBatch, &models.VocabularyBatchData{
+				LessonID: p.LessonID,
+				Results:  p.VocabularyResults,
+			})
+
+	case *models.LessonRewardPayload:
+		result, err := r.saveLessonReward(ctx, q, userID, p.LessonID)
+		if err != nil {
+			return models.LeaderboardScores{}, err
+		}
+		return models.LeaderboardScores{Lemons: result.LemonsCredited}, nil
+
+	default:
+		return models.LeaderboardScores{}, fmt.Errorf("unsupported sync payload %T", payload)
 	}
+}
 
-	isCorrect, _ := item.Data["is_correct"].(bool)
+// syncVocabularyPractice applies an offline vocabulary practice using SM-2
+func (r *ProgressRepository) syncVocabularyPractice(ctx context.Context, q DBTX, userID int64, p *models.VocabularyPracticePayload) error {
+	// Initialize SRS values from existing progress or use defaults
+	currentEasiness := utils.InitialEasinessFactor
+	currentInterval := 0
+	currentRepetitions := 0
+	currentMastery := utils.MasteryLevelNew
 
-	req := &models.VocabularyPracticeRequest{
-		UserID:       userID,
-		VocabularyID: int64(vocabID),
-		IsCorrect:    isCorrect,
+	currentProgress, err := r.getVocabularyProgressByID(ctx, q, userID, p.VocabularyID)
+	if err != nil {
+		return err
 	}
-
-	// Simple SRS calculation for sync
-	srsData := map[string]interface{}{
-		"mastery_level":    float64(1),
-		"easiness_factor":  2.5,
-		"interval_days":    float64(1),
-		"repetition_count": float64(1),
-		"next_review_at":   time.Now().Add(24 * time.Hour),
+	if currentProgress != nil {
+		currentEasiness = currentProgress.EasinessFactor
+		currentInterval = currentProgress.IntervalDays
+		currentRepetitions = currentProgress.RepetitionCount
+		currentMastery = currentProgress.MasteryLevel
 	}
 
-	return r.RecordVocabularyPractice(ctx, req, srsData)
+	quality := utils.CalculateQualityFromResponseTime(*p.IsCorrect, p.ResponseTime)
+	srsResult := utils.CalculateNextReview(
+		quality,
+		currentEasiness,
+		currentInterval,
+		currentRepetitions,
+		currentMastery,
+	)
+
+	return r.recordLearningEvent(ctx, q, userID, models.LearningEventVocabularyAnswered, &models.VocabularyAnsweredData{
+		VocabularyID:    p.VocabularyID,
+		IsCorrect:       *p.IsCorrect,
+		ResponseTime:    p.ResponseTime,
+		MasteryLevel:    srsResult.MasteryLevel,
+		EasinessFactor:  srsResult.EasinessFactor,
+		IntervalDays:    srsResult.IntervalDays,
+		RepetitionCount: srsResult.RepetitionCount,
+		NextReviewAt:    srsResult.NextReviewAt,
+	})
 }
 
 // ================================================================
@@ -1055,11 +1261,41 @@ func (r *ProgressRepository) UpdateHangulProgress(ctx context.Context, userID, c
 		}
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = r.recordLearningEvent(ctx, tx, userID, models.LearningEventHangulPracticed, &models.HangulPracticedData{
+		CharacterID:     characterID,
+		IsCorrect:       isCorrect,
+		MasteryLevel:    srsResult.MasteryLevel,
+		EasinessFactor:  srsResult.EasinessFactor,
+		IntervalDays:    srsResult.IntervalDays,
+		RepetitionCount: srsResult.RepetitionCount,
+		NextReviewAt:    srsResult.NextReviewAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.recordLeaderboardScores(ctx, userID, models.LeaderboardScores{Reviews: 1})
+
+	return nil
+}
+
+// recordHangulPractice projects a hangul_practiced event onto hangul_progress
+func (r *ProgressRepository) recordHangulPractice(ctx context.Context, q DBTX, userID int64, d *models.HangulPracticedData, at time.Time) error {
 	correctIncr := 0
 	wrongIncr := 0
 	streakReset := "streak_count + 1"
 	streakInitial := 0
-	if isCorrect {
+	if d.IsCorrect {
 		correctIncr = 1
 		streakInitial = 1
 	} else {
@@ -1072,7 +1308,7 @@ func (r *ProgressRepository) UpdateHangulProgress(ctx context.Context, userID, c
 			user_id, character_id, mastery_level, correct_count, wrong_count,
 			streak_count, last_practiced, next_review, ease_factor, interval_days,
 			repetition_count, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $7, $7)
 		ON CONFLICT (user_id, character_id)
 		DO UPDATE SET
 			mastery_level = $3,
@@ -1084,15 +1320,14 @@ func (r *ProgressRepository) UpdateHangulProgress(ctx context.Context, userID, c
 			ease_factor = $9,
 			interval_days = $10,
 			repetition_count = $11,
-			updated_at = $12
+			updated_at = $7
 	`, streakReset)
 
-	_, err := r.db.ExecContext(ctx, query,
-		userID, characterID, srsResult.MasteryLevel, correctIncr, wrongIncr,
-		streakInitial, now, srsResult.NextReviewAt, srsResult.EasinessFactor, srsResult.IntervalDays,
-		srsResult.RepetitionCount, now,
+	_, err := q.ExecContext(ctx, query,
+		userID, d.CharacterID, d.MasteryLevel, correctIncr, wrongIncr,
+		streakInitial, at, d.NextReviewAt, d.EasinessFactor, d.IntervalDays,
+		d.RepetitionCount,
 	)
-
 	if err != nil {
 		return fmt.Errorf("failed to update hangul progress: %w", err)
 	}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAtomicCommitsAllItems(t *testing.T) {
+	d := &dbtest.Driver{}
+	db := dbtest.Open(t, d)
+
+	failedAt, err := applyAtomic(context.Background(), db, 3, func(tx DBTX, i int) error {
+		_, err := tx.ExecContext(context.Background(), "INSERT item", i)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, -1, failedAt)
+	assert.Len(t, d.Applied(), 3)
+}
+
+func TestApplyAtomicRollsBackOnFailure(t *testing.T) {
+	d := &dbtest.Driver{}
+	db := dbtest.Open(t, d)
+	boom := errors.New("lesson not found")
+
+	calls := 0
+	failedAt, err := applyAtomic(context.Background(), db, 5, func(tx DBTX, i int) error {
+		calls++
+		if i == 2 {
+			return boom
+		}
+		_, err := tx.ExecContext(context.Background(), "INSERT item", i)
+		return err
+	})
+
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 2, failedAt, "the failing item is reported")
+	assert.Equal(t, 3, calls, "items after the failure are not attempted")
+	assert.Empty(t, d.Applied(), "items before the failure are not applied")
+	assert.Equal(t, 1, d.Rollbacks())
+}
+
+func TestUpdateHangulProgressRecordsEvent(t *testing.T) {
+	log := &learningEventLog{}
+	d := &dbtest.Driver{Query: log.query}
+	repo := newTestRepository(t, d)
+
+	err := repo.UpdateHangulProgress(context.Background(), 1, 3, true, utils.SRSResult{MasteryLevel: 2, EasinessFactor: 2.5, IntervalDays: 1})
+	require.NoError(t, err)
+
+	var events, projections []string
+	var metrics []interface{}
+	for _, s := range d.Applied() {
+		switch {
+		case strings.Contains(s.Query, "INSERT INTO event_outbox"):
+			events = append(events, s.Args[1].(string))
+		case strings.Contains(s.Query, "INSERT INTO hangul_progress"):
+			projections = append(projections, s.Query)
+		case strings.Contains(s.Query, "UPDATE user_quests"):
+			metrics = append(metrics, s.Args[1])
+		}
+	}
+	assert.Equal(t, []string{models.LearningEventHangulPracticed}, events)
+	assert.Len(t, projections, 1)
+	assert.Equal(t, []interface{}{models.QuestMetricHangulPracticed, models.QuestMetricHangulCorrect}, metrics)
+	assert.Equal(t, 1, log.xpAwards)
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// QUESTS
+// ================================================================
+// Each user gets a rotating set of daily and weekly quests, assigned on
+// first use in a period. Learning activity advances the quests whose metric
+// it matches, inside the transaction that records it; completed quests are
+// claimed for lemons through the ledger.
+// ================================================================
+
+var (
+	ErrQuestNotFound       = errors.New("quest not found")
+	ErrQuestNotCompleted   = errors.New("quest not completed")
+	ErrQuestAlreadyClaimed = errors.New("quest already claimed")
+)
+
+// questCounts returns how many quests are assigned per period
+func questCounts(ctx context.Context, q DBTX) (map[string]int, error) {
+	counts := map[string]int{models.QuestPeriodDaily: 3, models.QuestPeriodWeekly: 2}
+
+	var daily, weekly int
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(daily_quest_count, 3), COALESCE(weekly_quest_count, 2)
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&daily, &weekly)
+	if err == sql.ErrNoRows {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quest settings: %w", err)
+	}
+
+	counts[models.QuestPeriodDaily] = daily
+	counts[models.QuestPeriodWeekly] = weekly
+	return counts, nil
+}
+
+// ensureUserQuests assigns the user's quests for the periods containing now,
+// if they have none yet. The rotation is deterministic, so concurrent calls
+// insert the same rows.
+func ensureUserQuests(ctx context.Context, q DBTX, userID int64, now time.Time) error {
+	var counts map[string]int
+
+	for _, period := range models.QuestPeriods {
+		start, end := models.QuestPeriodBounds(period, now)
+
+		var assigned bool
+		err := q.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM user_quests WHERE user_id = $1 AND period = $2 AND period_start = $3)
+		`, userID, period, start).Scan(&assigned)
+		if err != nil {
+			return fmt.Errorf("failed to check quests: %w", err)
+		}
+		if assigned {
+			continue
+		}
+
+		if counts == nil {
+			if counts, err = questCounts(ctx, q); err != nil {
+				return err
+			}
+		}
+
+		templates, err := getQuestTemplates(ctx, q, period)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range models.SelectQuestTemplates(templates, userID, start, counts[period]) {
+			_, err := q.ExecContext(ctx, `
+				INSERT INTO user_quests (user_id, template_id, period, metric, period_start, period_end, target, reward_lemons)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (user_id, template_id, period_start) DO NOTHING
+			`, userID, t.ID, period, t.Metric, start, end, t.Target, t.RewardLemons)
+			if err != nil {
+				return fmt.Errorf("failed to assign quest %s: %w", t.Code, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getQuestTemplates returns the active templates of a period
+func getQuestTemplates(ctx context.Context, q DBTX, period string) ([]models.QuestTemplate, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, code, period, metric, target, reward_lemons, title, COALESCE(description, '')
+		FROM quest_templates
+		WHERE period = $1 AND is_active = true
+		ORDER BY id
+	`, period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quest templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.QuestTemplate
+	for rows.Next() {
+		var t models.QuestTemplate
+		if err := rows.Scan(&t.ID, &t.Code, &t.Period, &t.Metric, &t.Target, &t.RewardLemons, &t.Title, &t.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan quest template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+// advanceQuests adds amount to the user's open quests for metric
+func advanceQuests(ctx context.Context, q DBTX, userID int64, metric string, amount int, now time.Time) error {
+	if amount <= 0 {
+		return nil
+	}
+
+	if err := ensureUserQuests(ctx, q, userID, now); err != nil {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, `
+		UPDATE user_quests
+		SET progress = LEAST(target, progress + $3),
+		    completed_at = CASE WHEN progress + $3 >= target THEN $4::timestamptz END
+		WHERE user_id = $1 AND metric = $2 AND completed_at IS NULL
+		  AND period_start <= $4 AND period_end > $4
+	`, userID, metric, amount, now)
+	if err != nil {
+		return fmt.Errorf("failed to advance quests: %w", err)
+	}
+
+	return nil
+}
+
+// advanceQuestsForEvent advances quests from a newly recorded learning event.
+// Not called during projection rebuilds, so replayed events are not counted twice.
+// Repeating a lesson does not count towards lessons_completed.
+func advanceQuestsForEvent(ctx context.Context, q DBTX, event *models.LearningEvent) error {
+	switch event.Type {
+	case models.LearningEventLessonCompleted:
+		first, err := isFirstLessonCompletion(ctx, q, event)
+		if err != nil || !first {
+			return err
+		}
+		return advanceQuests(ctx, q, event.UserID, models.QuestMetricLessonsCompleted, 1, event.OccurredAt)
+
+	case models.LearningEventVocabularyAnswered:
+		var d models.VocabularyAnsweredData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		if err := advanceQuests(ctx, q, event.UserID, models.QuestMetricVocabularyReviewed, 1, event.OccurredAt); err != nil {
+			return err
+		}
+		if d.IsCorrect {
+			return advanceQuests(ctx, q, event.UserID, models.QuestMetricVocabularyCorrect, 1, event.OccurredAt)
+		}
+
+	case models.LearningEventVocabularyBatch:
+		var d models.VocabularyBatchData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		correct := 0
+		for _, result := range d.Results {
+			if result.IsCorrect {
+				correct++
+			}
+		}
+		if err := advanceQuests(ctx, q, event.UserID, models.QuestMetricVocabularyReviewed, len(d.Results), event.OccurredAt); err != nil {
+			return err
+		}
+		return advanceQuests(ctx, q, event.UserID, models.QuestMetricVocabularyCorrect, correct, event.OccurredAt)
+
+	case models.LearningEventHangulPracticed:
+		var d models.HangulPracticedData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		if err := advanceQuests(ctx, q, event.UserID, models.QuestMetricHangulPracticed, 1, event.OccurredAt); err != nil {
+			return err
+		}
+		if d.IsCorrect {
+			return advanceQuests(ctx, q, event.UserID, models.QuestMetricHangulCorrect, 1, event.OccurredAt)
+		}
+	}
+
+	return nil
+}
+
+// GetActiveQuests returns the user's quests for the current periods, plus
+// completed quests from earlier periods that have not been claimed
+func (r *ProgressRepository) GetActiveQuests(ctx context.Context, userID int64) ([]models.UserQuest, error) {
+	now := time.Now().UTC()
+
+	if err := ensureUserQuests(ctx, r.db, userID, now); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, userQuestSelect+`
+		WHERE uq.user_id = $1
+		  AND ((uq.period_start <= $2 AND uq.period_end > $2)
+		       OR (uq.completed_at IS NOT NULL AND uq.claimed_at IS NULL))
+		ORDER BY uq.period, uq.period_start, uq.id
+	`, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quests: %w", err)
+	}
+	defer rows.Close()
+
+	quests := []models.UserQuest{}
+	for rows.Next() {
+		quest, err := scanUserQuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		quests = append(quests, *quest)
+	}
+
+	return quests, rows.Err()
+}
+
+// ClaimQuest pays out a completed quest. Returns the claimed quest and the
+// new wallet balance.
+func (r *ProgressRepository) ClaimQuest(ctx context.Context, userID, questID int64) (*models.UserQuest, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	quest, err := scanUserQuest(tx.QueryRowContext(ctx, userQuestSelect+`
+		WHERE uq.id = $1 AND uq.user_id = $2
+		FOR UPDATE OF uq
+	`, questID, userID))
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrQuestNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if quest.ClaimedAt != nil {
+		return nil, 0, ErrQuestAlreadyClaimed
+	}
+	if quest.CompletedAt == nil {
+		return nil, 0, ErrQuestNotCompleted
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `UPDATE user_quests SET claimed_at = $2 WHERE id = $1`, questID, now); err != nil {
+		return nil, 0, fmt.Errorf("failed to claim quest: %w", err)
+	}
+	quest.ClaimedAt = &now
+	quest.Claimable = false
+
+	entries, err := postLemons(ctx, tx, models.LemonTxQuest, &questID, []models.LedgerLeg{
+		{UserID: userID, Account: models.LedgerAccountRewards, Amount: -quest.RewardLemons},
+		{UserID: userID, Account: models.LedgerAccountWallet, Amount: quest.RewardLemons},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	balance := ledgerBalance(entries, userID, models.LedgerAccountWallet)
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.PublishEvent(ctx, userID, models.EventTypeLemons, map[string]interface{}{
+		"reason":       "quest",
+		"quest_id":     questID,
+		"lemons":       quest.RewardLemons,
+		"total_lemons": balance,
+	})
+	r.recordLeaderboardScores(ctx, userID, models.LeaderboardScores{Lemons: quest.RewardLemons})
+
+	return quest, balance, nil
+}
+
+const userQuestSelect = `
+	SELECT uq.id, uq.template_id, qt.code, uq.period, uq.metric, qt.title, COALESCE(qt.description, ''),
+	       uq.progress, uq.target, uq.reward_lemons, uq.period_start, uq.period_end,
+	       uq.completed_at, uq.claimed_at
+	FROM user_quests uq
+	JOIN quest_templates qt ON qt.id = uq.template_id
+`
+
+func scanUserQuest(row interface{ Scan(...interface{}) error }) (*models.UserQuest, error) {
+	var quest models.UserQuest
+	err := row.Scan(
+		&quest.ID, &quest.TemplateID, &quest.Code, &quest.Period, &quest.Metric, &quest.Title, &quest.Description,
+		&quest.Progress, &quest.Target, &quest.RewardLemons, &quest.PeriodStart, &quest.PeriodEnd,
+		&quest.CompletedAt, &quest.ClaimedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan quest: %w", err)
+	}
+	quest.Claimable = quest.CompletedAt != nil && quest.ClaimedAt == nil
+	return &quest, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteLessonCountsQuestProgressOnce(t *testing.T) {
+	ctx := context.Background()
+	log := &learningEventLog{}
+	d := &dbtest.Driver{Query: log.query}
+	repo := newTestRepository(t, d)
+
+	for _, lessonID := range []int64{12, 12, 13} {
+		err := repo.CompleteLesson(ctx, &models.CompleteProgressRequest{UserID: 1, LessonID: lessonID, QuizScore: 90, TimeSpent: 5})
+		require.NoError(t, err)
+	}
+
+	advanced := 0
+	for _, s := range d.Applied() {
+		if len(s.Args) > 1 && s.Args[1] == models.QuestMetricLessonsCompleted {
+			advanced++
+		}
+	}
+	assert.Equal(t, 2, advanced, "repeating a lesson does not count towards lessons_completed")
+}
+
+func TestClaimQuestPaysOnce(t *testing.T) {
+	ctx := context.Background()
+	book := newLemonBook(nil)
+	now := time.Now()
+	completedAt := now.Add(-time.Hour)
+	var claimedAt interface{}
+	d := &dbtest.Driver{
+		Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+			if rows, ok := book.query(query, args); ok {
+				return rows, nil
+			}
+			if strings.Contains(query, "FROM user_quests uq") {
+				return &dbtest.Rows{
+					Columns: []string{"id", "template_id", "code", "period", "metric", "title", "description",
+						"progress", "target", "reward_lemons", "period_start", "period_end", "completed_at", "claimed_at"},
+					Values: [][]driver.Value{{int64(7), int64(1), "daily_lessons", models.QuestPeriodDaily,
+						models.QuestMetricLessonsCompleted, "Lessons", "", int64(3), int64(3), int64(15),
+						now.Add(-2 * time.Hour), now.Add(time.Hour), completedAt, claimedAt}},
+				}, nil
+			}
+			return nil, nil
+		},
+		Exec: func(query string, args []driver.Value) (int64, error) {
+			if strings.Contains(query, "SET claimed_at") {
+				claimedAt = args[1]
+			}
+			return 1, nil
+		},
+	}
+	repo := newTestRepository(t, d)
+
+	quest, balance, err := repo.ClaimQuest(ctx, 1, 7)
+	require.NoError(t, err)
+	assert.False(t, quest.Claimable)
+	assert.Equal(t, 15, balance)
+
+	_, _, err = repo.ClaimQuest(ctx, 1, 7)
+	assert.ErrorIs(t, err, ErrQuestAlreadyClaimed)
+	assert.Equal(t, int64(15), book.wallets[1], "a quest pays out once")
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// REWARD RULES
+// ================================================================
+// Reward amounts are computed from the reward_rules table. Rules are
+// cached briefly in Redis so edits take effect without a redeploy.
+// ================================================================
+
+const (
+	rewardRulesCacheKey = "reward:rules"
+	rewardRulesCacheTTL = time.Minute
+)
+
+// GetRewardRules returns all active reward rules
+func (r *ProgressRepository) GetRewardRules(ctx context.Context) ([]models.RewardRule, error) {
+	if cached, err := r.redis.Get(ctx, rewardRulesCacheKey).Bytes(); err == nil {
+		var rules []models.RewardRule
+		if err := json.Unmarshal(cached, &rules); err == nil {
+			return rules, nil
+		}
+	}
+
+	query := `
+		SELECT id, reward_type, completion, level, week, min_score, lemons,
+		       COALESCE(description, ''), updated_at
+		FROM reward_rules
+		WHERE is_active = true
+		ORDER BY reward_type, min_score
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reward rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.RewardRule{}
+	for rows.Next() {
+		var rule models.RewardRule
+		err := rows.Scan(
+			&rule.ID, &rule.RewardType, &rule.Completion, &rule.Level, &rule.Week,
+			&rule.MinScore, &rule.Lemons, &rule.Description, &rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reward rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query reward rules: %w", err)
+	}
+
+	if data, err := json.Marshal(rules); err == nil {
+		r.redis.Set(ctx, rewardRulesCacheKey, data, rewardRulesCacheTTL)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// ROOMS
+// ================================================================
+// A user has up to models.MaxRooms named rooms, exactly one of them
+// active. The first room is created on demand. A user's room changes are
+// serialized with an advisory lock so the limits, unique names and the
+// single active room hold. Layouts are validated as a whole (see
+// models.ValidateRoomLayout) and replace the room's furniture in one
+// transaction.
+// ================================================================
+
+var (
+	// ErrRoomNotFound is returned for unknown rooms or rooms of another user
+	ErrRoomNotFound = errors.New("room not found")
+
+	// ErrRoomLimitReached is returned when the user already has the maximum
+	// number of rooms
+	ErrRoomLimitReached = errors.New("room limit reached")
+
+	// ErrRoomNameTaken is returned when the user has another room with the name
+	ErrRoomNameTaken = errors.New("room name already used")
+
+	// ErrRoomActive is returned when deleting the active room
+	ErrRoomActive = errors.New("cannot delete the active room")
+)
+
+// roomColumns selects a user_rooms row (aliased r) as models.Room
+const roomColumns = `r.id, r.user_id, r.name, r.is_active, r.visibility,
+	(SELECT COUNT(*) FROM user_room_furniture f WHERE f.room_id = r.id),
+	r.visit_count, r.like_count, r.created_at, r.updated_at`
+
+func scanRoom(row interface{ Scan(...interface{}) error }) (*models.Room, error) {
+	var room models.Room
+	err := row.Scan(&room.ID, &room.UserID, &room.Name, &room.IsActive, &room.Visibility,
+		&room.FurnitureCount, &room.VisitCount, &room.LikeCount, &room.CreatedAt, &room.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// lockRooms serializes the user's room changes until the transaction ends
+func lockRooms(ctx context.Context, q DBTX, userID int64) error {
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('rooms'), $1::int)`, userID); err != nil {
+		return fmt.Errorf("failed to lock rooms: %w", err)
+	}
+	return nil
+}
+
+// ensureActiveRoom returns the user's active room, creating the default room
+// for users without one. The caller must hold the rooms lock.
+func ensureActiveRoom(ctx context.Context, q DBTX, userID int64) (int64, error) {
+	var roomID int64
+	err := q.QueryRowContext(ctx,
+		`SELECT id FROM user_rooms WHERE user_id = $1 AND is_active`, userID,
+	).Scan(&roomID)
+	if err == nil {
+		return roomID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get active room: %w", err)
+	}
+
+	// Rooms always have an active one, but recover if it went missing
+	err = q.QueryRowContext(ctx, `
+		UPDATE user_rooms SET is_active = true, updated_at = NOW()
+		WHERE id = (SELECT id FROM user_rooms WHERE user_id = $1 ORDER BY id LIMIT 1)
+		RETURNING id
+	`, userID).Scan(&roomID)
+	if err == nil {
+		return roomID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to activate room: %w", err)
+	}
+
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO user_rooms (user_id, name, is_active) VALUES ($1, $2, true)
+		RETURNING id
+	`, userID, models.DefaultRoomName).Scan(&roomID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create room: %w", err)
+	}
+	return roomID, nil
+}
+
+// getRoom returns one of the user's rooms
+func getRoom(ctx context.Context, q DBTX, userID, roomID int64) (*models.Room, error) {
+	room, err := scanRoom(q.QueryRowContext(ctx,
+		`SELECT `+roomColumns+` FROM user_rooms r WHERE r.id = $1 AND r.user_id = $2`, roomID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	return room, nil
+}
+
+// checkRoomName returns ErrRoomNameTaken if another of the user's rooms
+// (other than exceptID) has the name
+func checkRoomName(ctx context.Context, q DBTX, userID int64, name string, exceptID int64) error {
+	var taken bool
+	err := q.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM user_rooms WHERE user_id = $1 AND name = $2 AND id != $3)`,
+		userID, name, exceptID,
+	).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("failed to check room name: %w", err)
+	}
+	if taken {
+		return ErrRoomNameTaken
+	}
+	return nil
+}
+
+// listRoomFurniture returns a room's furniture in drawing order: by layer,
+// then back to front
+func listRoomFurniture(ctx context.Context, q DBTX, roomID int64) ([]models.RoomFurniture, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT f.id, f.item_id, ci.name, ci.asset_key, COALESCE(ci.asset_type, 'svg'),
+		       f.position_x, f.position_y, f.rotation, f.layer
+		FROM user_room_furniture f
+		JOIN character_items ci ON ci.id = f.item_id
+		WHERE f.room_id = $1
+		ORDER BY f.layer, f.position_y, f.id
+	`, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room furniture: %w", err)
+	}
+	defer rows.Close()
+
+	furniture := []models.RoomFurniture{}
+	for rows.Next() {
+		var f models.RoomFurniture
+		err := rows.Scan(&f.ID, &f.ItemID, &f.Name, &f.AssetKey, &f.AssetType,
+			&f.PositionX, &f.PositionY, &f.Rotation, &f.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room furniture: %w", err)
+		}
+		furniture = append(furniture, f)
+	}
+	return furniture, rows.Err()
+}
+
+// getFurnitureSpecs returns the placement metadata of the given items that
+// the user owns and that are furniture
+func getFurnitureSpecs(ctx context.Context, q DBTX, userID int64, itemIDs []int64) (map[int64]models.FurnitureSpec, error) {
+	if itemIDs == nil {
+		itemIDs = []int64{}
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT ci.id, COALESCE(ci.metadata, '{}')
+		FROM character_items ci
+		JOIN user_inventory ui ON ui.item_id = ci.id AND ui.user_id = $1
+		WHERE ci.id = ANY($2) AND ci.category = 'furniture'
+	`, userID, pq.Array(itemIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query furniture: %w", err)
+	}
+	defer rows.Close()
+
+	specs := map[int64]models.FurnitureSpec{}
+	for rows.Next() {
+		var id int64
+		var metadata []byte
+		if err := rows.Scan(&id, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan furniture: %w", err)
+		}
+		specs[id] = models.ParseFurnitureSpec(metadata)
+	}
+	return specs, rows.Err()
+}
+
+// ListRooms returns the user's rooms, oldest first
+func (r *ProgressRepository) ListRooms(ctx context.Context, userID int64) ([]models.Room, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	if _, err := ensureActiveRoom(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+roomColumns+` FROM user_rooms r WHERE r.user_id = $1 ORDER BY r.created_at, r.id`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rooms: %w", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.Room{}
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan room: %w", err)
+		}
+		rooms = append(rooms, *room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query rooms: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rooms: %w", err)
+	}
+	return rooms, nil
+}
+
+// CreateRoom adds an empty, inactive room
+func (r *ProgressRepository) CreateRoom(ctx context.Context, userID int64, name string) (*models.Room, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	if _, err := ensureActiveRoom(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_rooms WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count rooms: %w", err)
+	}
+	if count >= models.MaxRooms {
+		return nil, ErrRoomLimitReached
+	}
+	if err := checkRoomName(ctx, tx, userID, name, 0); err != nil {
+		return nil, err
+	}
+
+	room := &models.Room{UserID: userID, Name: name}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO user_rooms (user_id, name) VALUES ($1, $2)
+		RETURNING id, visibility, created_at, updated_at
+	`, userID, name).Scan(&room.ID, &room.Visibility, &room.CreatedAt, &room.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit room: %w", err)
+	}
+	return room, nil
+}
+
+// RenameRoom changes the name of one of the user's rooms
+func (r *ProgressRepository) RenameRoom(ctx context.Context, userID, roomID int64, name string) (*models.Room, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	room, err := getRoom(ctx, tx, userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRoomName(ctx, tx, userID, name, roomID); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE user_rooms SET name = $2, updated_at = NOW() WHERE id = $1 RETURNING updated_at`, roomID, name,
+	).Scan(&room.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename room: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit room: %w", err)
+	}
+	room.Name = name
+	return room, nil
+}
+
+// DeleteRoom removes an inactive room and its furniture placements. The
+// furniture stays in the inventory.
+func (r *ProgressRepository) DeleteRoom(ctx context.Context, userID, roomID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return err
+	}
+	room, err := getRoom(ctx, tx, userID, roomID)
+	if err != nil {
+		return err
+	}
+	if room.IsActive {
+		return ErrRoomActive
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_rooms WHERE id = $1`, roomID); err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit room: %w", err)
+	}
+	return nil
+}
+
+// ActivateRoom makes one of the user's rooms the one visitors see
+func (r *ProgressRepository) ActivateRoom(ctx context.Context, userID, roomID int64) (*models.Room, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	room, err := getRoom(ctx, tx, userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.IsActive {
+		return room, nil
+	}
+
+	// Two statements: the one-active-room index is checked row by row
+	_, err = tx.ExecContext(ctx,
+		`UPDATE user_rooms SET is_active = false, updated_at = NOW() WHERE user_id = $1 AND is_active`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate room: %w", err)
+	}
+	err = tx.QueryRowContext(ctx,
+		`UPDATE user_rooms SET is_active = true, updated_at = NOW() WHERE id = $1 RETURNING updated_at`, roomID,
+	).Scan(&room.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate room: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit room: %w", err)
+	}
+	room.IsActive = true
+	return room, nil
+}
+
+// GetRoom returns one of the user's own rooms and its furniture
+func (r *ProgressRepository) GetRoom(ctx context.Context, userID, roomID int64) (*models.Room, []models.RoomFurniture, error) {
+	room, err := getRoom(ctx, r.db, userID, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	furniture, err := listRoomFurniture(ctx, r.db, room.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, furniture, nil
+}
+
+// SaveRoomFurniture replaces the furniture of one of the user's rooms, or of
+// the active room when roomID is 0. The whole layout is validated first; a
+// *models.RoomLayoutError says which placement was rejected.
+func (r *ProgressRepository) SaveRoomFurniture(ctx context.Context, userID, roomID int64, placements []models.FurniturePlacement) (*models.Room, []models.RoomFurniture, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockRooms(ctx, tx, userID); err != nil {
+		return nil, nil, err
+	}
+	if roomID == 0 {
+		if roomID, err = ensureActiveRoom(ctx, tx, userID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := getRoom(ctx, tx, userID, roomID); err != nil {
+		return nil, nil, err
+	}
+
+	itemIDs := make([]int64, len(placements))
+	for i, p := range placements {
+		itemIDs[i] = p.ItemID
+	}
+	specs, err := getFurnitureSpecs(ctx, tx, userID, itemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := models.ValidateRoomLayout(placements, specs); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_room_furniture WHERE room_id = $1`, roomID); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear room furniture: %w", err)
+	}
+	for _, p := range placements {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_room_furniture (user_id, room_id, item_id, position_x, position_y, rotation, layer)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, userID, roomID, p.ItemID, p.PositionX, p.PositionY, p.Rotation, p.Layer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to place furniture: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE user_rooms SET updated_at = NOW() WHERE id = $1`, roomID); err != nil {
+		return nil, nil, fmt.Errorf("failed to update room: %w", err)
+	}
+
+	room, err := getRoom(ctx, tx, userID, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	furniture, err := listRoomFurniture(ctx, tx, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit room: %w", err)
+	}
+	return room, furniture, nil
+}
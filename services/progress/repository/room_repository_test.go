@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveRoomFurniture(t *testing.T) {
+	// User 1 owns room 7 and furniture items 1 (2x1) and 2 (1x1)
+	newDriver := func() *dbtest.Driver {
+		return &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+			switch {
+			case strings.Contains(query, "FROM user_rooms r WHERE r.id = $1 AND r.user_id = $2"):
+				rows := &dbtest.Rows{Columns: []string{"id", "user_id", "name", "is_active", "visibility",
+					"furniture_count", "visit_count", "like_count", "created_at", "updated_at"}}
+				if args[0] == int64(7) && args[1] == int64(1) {
+					rows.Values = [][]driver.Value{{int64(7), int64(1), models.DefaultRoomName, true, "friends",
+						int64(0), int64(0), int64(0), time.Now(), time.Now()}}
+				}
+				return rows, nil
+			case strings.Contains(query, "JOIN user_inventory ui"):
+				return &dbtest.Rows{Columns: []string{"id", "metadata"}, Values: [][]driver.Value{
+					{int64(1), []byte(`{"footprint": {"w": 2, "h": 1}}`)},
+					{int64(2), []byte(`{}`)},
+				}}, nil
+			}
+			return nil, nil
+		}}
+	}
+
+	d := newDriver()
+	room, _, err := newTestRepository(t, d).SaveRoomFurniture(context.Background(), 1, 7, []models.FurniturePlacement{
+		{ItemID: 1, PositionX: 0.5, PositionY: 0.5},
+		{ItemID: 2, PositionX: 0.15, PositionY: 0.15},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), room.ID)
+	assert.Len(t, d.Applied(), 5, "lock, clear, two placements and the room update")
+
+	d = newDriver()
+	_, _, err = newTestRepository(t, d).SaveRoomFurniture(context.Background(), 2, 7, nil)
+	assert.ErrorIs(t, err, ErrRoomNotFound, "another user's room")
+	assert.Empty(t, d.Applied())
+
+	rejected := []struct {
+		name       string
+		placements []models.FurniturePlacement
+		code       string
+	}{
+		{"not owned", []models.FurniturePlacement{{ItemID: 3, PositionX: 0.5, PositionY: 0.5}}, models.RoomErrNotOwned},
+		{"off the grid", []models.FurniturePlacement{{ItemID: 1, PositionX: 1, PositionY: 0.5}}, models.RoomErrOutOfBounds},
+		{"overlapping", []models.FurniturePlacement{
+			{ItemID: 1, PositionX: 0.5, PositionY: 0.55},
+			{ItemID: 2, PositionX: 0.55, PositionY: 0.55},
+		}, models.RoomErrCollision},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDriver()
+			_, _, err := newTestRepository(t, d).SaveRoomFurniture(context.Background(), 1, 7, tt.placements)
+			var layoutErr *models.RoomLayoutError
+			require.ErrorAs(t, err, &layoutErr)
+			assert.Equal(t, tt.code, layoutErr.Code)
+			assert.Empty(t, d.Applied(), "a rejected layout changes nothing")
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+)
+
+// ================================================================
+// ROOM VISITS AND LIKES
+// ================================================================
+// Visitors see the owner's active room if its visibility allows and
+// neither user has blocked the other. A visit counts once per visitor per
+// room per UTC day. Likes are one per user and room; the owner is paid
+// for the first like from each user, up to a daily cap.
+// ================================================================
+
+var (
+	// ErrRoomPrivate is returned when the room's visibility hides it from the user
+	ErrRoomPrivate = errors.New("room is not visible to you")
+
+	// ErrRoomAlreadyLiked is returned when liking a room twice
+	ErrRoomAlreadyLiked = errors.New("room already liked")
+
+	// ErrRoomNotLiked is returned when removing a like that does not exist
+	ErrRoomNotLiked = errors.New("room not liked")
+)
+
+// getRoomLikeSettings loads the owner rewards for likes
+func getRoomLikeSettings(ctx context.Context, q DBTX) (models.RoomLikeSettings, error) {
+	s := models.RoomLikeSettings{RewardLemons: 1, DailyLemonCap: 10}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(room_like_reward_lemons, 1), COALESCE(room_like_daily_lemon_cap, 10)
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&s.RewardLemons, &s.DailyLemonCap)
+	if err != nil && err != sql.ErrNoRows {
+		return s, fmt.Errorf("failed to get room like settings: %w", err)
+	}
+
+	return s, nil
+}
+
+// checkRoomAccess validates that viewerID may see the room
+func checkRoomAccess(ctx context.Context, q DBTX, viewerID int64, room *models.Room) error {
+	if viewerID == room.UserID {
+		return nil
+	}
+	if err := checkRelationTarget(ctx, q, viewerID, room.UserID); err != nil {
+		return err
+	}
+
+	friends := false
+	if room.Visibility == models.RoomVisibilityFriends {
+		err := q.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM friendships WHERE user_id = $1 AND friend_id = $2)`, viewerID, room.UserID,
+		).Scan(&friends)
+		if err != nil {
+			return fmt.Errorf("failed to check friendship: %w", err)
+		}
+	}
+	if !models.RoomVisibleTo(room.Visibility, false, friends) {
+		return ErrRoomPrivate
+	}
+	return nil
+}
+
+// getVisitableRoom returns a room other users can reach: the active one.
+// With lock the row is locked until the transaction ends.
+func getVisitableRoom(ctx context.Context, q DBTX, roomID int64, lock bool) (*models.Room, error) {
+	query := `SELECT ` + roomColumns + ` FROM user_rooms r WHERE r.id = $1 AND r.is_active`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	room, err := scanRoom(q.QueryRowContext(ctx, query, roomID))
+	if err == sql.ErrNoRows {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	return room, nil
+}
+
+// VisitRoom returns a user's active room and its furniture as seen by
+// viewerID, counting the visit. The room is nil for users who have never
+// set one up.
+func (r *ProgressRepository) VisitRoom(ctx context.Context, ownerID, viewerID int64) (*models.Room, []models.RoomFurniture, error) {
+	room, err := scanRoom(r.db.QueryRowContext(ctx,
+		`SELECT `+roomColumns+` FROM user_rooms r WHERE r.user_id = $1 AND r.is_active`, ownerID,
+	))
+	if err == sql.ErrNoRows {
+		if ownerID != viewerID {
+			if err := checkRelationTarget(ctx, r.db, viewerID, ownerID); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, []models.RoomFurniture{}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get active room: %w", err)
+	}
+	if err := checkRoomAccess(ctx, r.db, viewerID, room); err != nil {
+		return nil, nil, err
+	}
+
+	if viewerID != ownerID {
+		err := r.db.QueryRowContext(ctx, `
+			WITH visit AS (
+				INSERT INTO room_visits (room_id, visitor_id, visit_date)
+				VALUES ($1, $2, (NOW() AT TIME ZONE 'UTC')::date)
+				ON CONFLICT DO NOTHING
+				RETURNING room_id
+			)
+			UPDATE user_rooms SET visit_count = visit_count + 1
+			WHERE id IN (SELECT room_id FROM visit)
+			RETURNING visit_count
+		`, room.ID, viewerID).Scan(&room.VisitCount)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("failed to record visit: %w", err)
+		}
+
+		err = r.db.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM room_likes WHERE room_id = $1 AND user_id = $2)`, room.ID, viewerID,
+		).Scan(&room.LikedByMe)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check like: %w", err)
+		}
+	}
+
+	furniture, err := listRoomFurniture(ctx, r.db, room.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, furniture, nil
+}
+
+// SetRoomVisibility changes who may visit one of the user's rooms
+func (r *ProgressRepository) SetRoomVisibility(ctx context.Context, userID, roomID int64, visibility string) (*models.Room, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_rooms SET visibility = $3, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+	`, roomID, userID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update room visibility: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrRoomNotFound
+	}
+	return getRoom(ctx, r.db, userID, roomID)
+}
+
+// LikeRoom likes another user's active room. The first like from each user
+// earns the owner lemons, within the daily cap. Returns the room's like
+// count and the owner's reward.
+func (r *ProgressRepository) LikeRoom(ctx context.Context, userID, roomID int64) (int, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	room, err := getVisitableRoom(ctx, tx, roomID, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	if room.UserID == userID {
+		return 0, 0, ErrSelfRelation
+	}
+	if err := checkRoomAccess(ctx, tx, userID, room); err != nil {
+		return 0, 0, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO room_likes (room_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, roomID, userID,
+	)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to like room: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, 0, ErrRoomAlreadyLiked
+	}
+
+	var likes int
+	err = tx.QueryRowContext(ctx,
+		`UPDATE user_rooms SET like_count = like_count + 1 WHERE id = $1 RETURNING like_count`, roomID,
+	).Scan(&likes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count like: %w", err)
+	}
+
+	// The owner's rewards are serialized so the daily cap holds across rooms
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('room_likes'), $1::int)`, room.UserID); err != nil {
+		return 0, 0, fmt.Errorf("failed to lock room owner: %w", err)
+	}
+
+	var rewarded bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM room_like_rewards WHERE room_id = $1 AND liker_id = $2)`, roomID, userID,
+	).Scan(&rewarded)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check like reward: %w", err)
+	}
+
+	reward, balance := 0, 0
+	if !rewarded {
+		settings, err := getRoomLikeSettings(ctx, tx)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var earned int
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(lemons), 0) FROM room_like_rewards
+			WHERE owner_id = $1 AND created_at >= $2
+		`, room.UserID, time.Now().UTC().Truncate(24*time.Hour)).Scan(&earned)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to sum like rewards: %w", err)
+		}
+		reward = settings.Reward(earned)
+
+		// Recorded even when capped, so unliking and liking again pays nothing
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO room_like_rewards (room_id, liker_id, owner_id, lemons)
+			VALUES ($1, $2, $3, $4)
+		`, roomID, userID, room.UserID, reward)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to record like reward: %w", err)
+		}
+
+		if reward > 0 {
+			entries, err := postLemons(ctx, tx, models.LemonTxRoomLike, &roomID, []models.LedgerLeg{
+				{UserID: room.UserID, Account: models.LedgerAccountRewards, Amount: -reward},
+				{UserID: room.UserID, Account: models.LedgerAccountWallet, Amount: reward},
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+			balance = ledgerBalance(entries, room.UserID, models.LedgerAccountWallet)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit like: %w", err)
+	}
+
+	if reward > 0 {
+		r.PublishEvent(ctx, room.UserID, models.EventTypeLemons, map[string]interface{}{
+			"reason":       "room_like",
+			"room_id":      roomID,
+			"liker_id":     userID,
+			"lemons":       reward,
+			"total_lemons": balance,
+		})
+	}
+
+	return likes, reward, nil
+}
+
+// UnlikeRoom removes the user's like. Returns the room's like count.
+func (r *ProgressRepository) UnlikeRoom(ctx context.Context, userID, roomID int64) (int, error) {
+	var likes int
+	err := r.db.QueryRowContext(ctx, `
+		WITH removed AS (
+			DELETE FROM room_likes WHERE room_id = $1 AND user_id = $2
+			RETURNING room_id
+		)
+		UPDATE user_rooms SET like_count = GREATEST(like_count - 1, 0)
+		WHERE id IN (SELECT room_id FROM removed)
+		RETURNING like_count
+	`, roomID, userID).Scan(&likes)
+	if err == sql.ErrNoRows {
+		return 0, ErrRoomNotLiked
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to unlike room: %w", err)
+	}
+	return likes, nil
+}
+
+// ListPopularRooms returns the public rooms with the most likes this week
+// (since Monday 00:00 UTC), leaving out users blocked either way
+func (r *ProgressRepository) ListPopularRooms(ctx context.Context, viewerID int64, limit int) ([]models.PopularRoom, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ur.id, ur.name, ur.user_id, COALESCE(u.name, ''), COALESCE(u.profile_image_url, ''),
+		       COUNT(*) AS weekly_likes, ur.like_count, ur.visit_count
+		FROM room_likes rl
+		JOIN user_rooms ur ON ur.id = rl.room_id
+		JOIN users u ON u.id = ur.user_id
+		WHERE rl.created_at >= $2
+		  AND ur.is_active AND ur.visibility = 'public'
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = ur.user_id)
+			   OR (b.blocker_id = ur.user_id AND b.blocked_id = $1)
+		  )
+		GROUP BY ur.id, u.id
+		ORDER BY weekly_likes DESC, ur.like_count DESC, ur.id
+		LIMIT $3
+	`, viewerID, models.WeekStart(time.Now()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query popular rooms: %w", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.PopularRoom{}
+	for rows.Next() {
+		var p models.PopularRoom
+		err := rows.Scan(&p.RoomID, &p.Name, &p.Owner.UserID, &p.Owner.Name, &p.Owner.ProfileImageURL,
+			&p.WeeklyLikes, &p.LikeCount, &p.VisitCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan popular room: %w", err)
+		}
+		rooms = append(rooms, p)
+	}
+	return rooms, rows.Err()
+}
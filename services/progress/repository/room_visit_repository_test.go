@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLikeRoomRewardsOncePerLiker(t *testing.T) {
+	ctx := context.Background()
+	book := newLemonBook(nil)
+	likes := map[int64]bool{}   // room_likes of room 7, by user
+	rewards := map[int64]bool{} // room_like_rewards of room 7, by liker
+	d := &dbtest.Driver{
+		Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+			if rows, ok := book.query(query, args); ok {
+				return rows, nil
+			}
+			likeCount := func() *dbtest.Rows {
+				return &dbtest.Rows{Columns: []string{"like_count"}, Values: [][]driver.Value{{int64(len(likes))}}}
+			}
+			switch {
+			case strings.Contains(query, "FROM user_rooms r WHERE r.id = $1 AND r.is_active"):
+				// Room 7 is user 1's public active room
+				return &dbtest.Rows{
+					Columns: []string{"id", "user_id", "name", "is_active", "visibility",
+						"furniture_count", "visit_count", "like_count", "created_at", "updated_at"},
+					Values: [][]driver.Value{{int64(7), int64(1), models.DefaultRoomName, true, models.RoomVisibilityPublic,
+						int64(0), int64(0), int64(len(likes)), time.Now(), time.Now()}},
+				}, nil
+			case strings.Contains(query, "FROM user_blocks"):
+				return &dbtest.Rows{Columns: []string{"exists", "blocked"}, Values: [][]driver.Value{{true, false}}}, nil
+			case strings.Contains(query, "SET like_count = like_count + 1"):
+				return likeCount(), nil
+			case strings.Contains(query, "DELETE FROM room_likes"):
+				if !likes[args[1].(int64)] {
+					return nil, nil
+				}
+				delete(likes, args[1].(int64))
+				return likeCount(), nil
+			case strings.Contains(query, "SUM(lemons), 0) FROM room_like_rewards"):
+				return &dbtest.Rows{Columns: []string{"sum"}, Values: [][]driver.Value{{book.wallets[1]}}}, nil
+			case strings.Contains(query, "EXISTS(SELECT 1 FROM room_like_rewards"):
+				return &dbtest.Rows{Columns: []string{"exists"}, Values: [][]driver.Value{{rewards[args[1].(int64)]}}}, nil
+			}
+			return nil, nil
+		},
+		Exec: func(query string, args []driver.Value) (int64, error) {
+			switch {
+			case strings.Contains(query, "INSERT INTO room_likes"):
+				if likes[args[1].(int64)] {
+					return 0, nil
+				}
+				likes[args[1].(int64)] = true
+			case strings.Contains(query, "INSERT INTO room_like_rewards"):
+				rewards[args[1].(int64)] = true
+			}
+			return 1, nil
+		},
+	}
+	repo := newTestRepository(t, d)
+
+	count, reward, err := repo.LikeRoom(ctx, 2, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, reward)
+
+	_, _, err = repo.LikeRoom(ctx, 2, 7)
+	assert.ErrorIs(t, err, ErrRoomAlreadyLiked)
+
+	// Unliking and liking again pays nothing
+	count, err = repo.UnlikeRoom(ctx, 2, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, reward, err = repo.LikeRoom(ctx, 2, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 0, reward)
+
+	_, reward, err = repo.LikeRoom(ctx, 3, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reward)
+	assert.Equal(t, map[int64]int64{1: 2}, book.wallets, "one reward per liker")
+
+	_, _, err = repo.LikeRoom(ctx, 1, 7)
+	assert.ErrorIs(t, err, ErrSelfRelation)
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// SHOP CATALOGUE ADMINISTRATION
+// ================================================================
+// Admins create, edit, deactivate and reorder character_items. Every
+// change is validated (see models.ValidateShopItem) and written together
+// with its shop_item_audit row in one transaction. Writes made elsewhere
+// (the admin panel) are audited by a trigger on character_items, which
+// these transactions switch off. Items are never deleted: users may own
+// them.
+// ================================================================
+
+// ErrShopOrderMismatch is returned when a reorder does not list exactly the
+// category's items
+var ErrShopOrderMismatch = errors.New("item order must list every item of the category once")
+
+// shopItemColumns selects a character_items row as models.ShopItem
+const shopItemColumns = `id, category, name, description, asset_key, COALESCE(asset_type, 'svg'),
+	COALESCE(is_bundled, false), COALESCE(render_order, 0), sort_order, COALESCE(price, 0),
+	COALESCE(rarity, 'common'), COALESCE(is_default, false), COALESCE(is_active, true),
+	COALESCE(metadata, '{}'), available_from, available_until, stock, created_at, updated_at`
+
+func scanShopItem(row interface{ Scan(...interface{}) error }) (*models.ShopItem, error) {
+	var item models.ShopItem
+	var metadata []byte
+	err := row.Scan(&item.ID, &item.Category, &item.Name, &item.Description, &item.AssetKey, &item.AssetType,
+		&item.IsBundled, &item.RenderOrder, &item.SortOrder, &item.Price,
+		&item.Rarity, &item.IsDefault, &item.IsActive,
+		&metadata, &item.AvailableFrom, &item.AvailableUntil, &item.Stock, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	item.Metadata = metadata
+	return &item, nil
+}
+
+// getShopItem returns a catalogue item, optionally locking it
+func getShopItem(ctx context.Context, q DBTX, itemID int64, forUpdate bool) (*models.ShopItem, error) {
+	query := `SELECT ` + shopItemColumns + ` FROM character_items WHERE id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	item, err := scanShopItem(q.QueryRowContext(ctx, query, itemID))
+	if err == sql.ErrNoRows {
+		return nil, ErrItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	return item, nil
+}
+
+// beginCatalogTx starts a catalogue transaction. It switches off the audit
+// trigger on character_items, since the service records its own audit rows.
+func (r *ProgressRepository) beginCatalogTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('shop.audited', 'on', true)`); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to mark catalogue transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// recordShopAudit writes one catalogue change
+func recordShopAudit(ctx context.Context, q DBTX, adminID, itemID int64, action string, changes map[string]models.AuditChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO shop_item_audit (item_id, admin_id, action, changes)
+		VALUES ($1, $2, $3, $4)
+	`, itemID, adminID, action, data)
+	if err != nil {
+		return fmt.Errorf("failed to record audit: %w", err)
+	}
+	return nil
+}
+
+// ListCatalogItems returns the whole catalogue, including inactive and
+// scheduled items. An empty category lists all categories.
+func (r *ProgressRepository) ListCatalogItems(ctx context.Context, category string) ([]models.ShopItem, error) {
+	query := `SELECT ` + shopItemColumns + ` FROM character_items`
+	args := []interface{}{}
+	if category != "" {
+		query += ` WHERE category = $1`
+		args = append(args, category)
+	}
+	query += ` ORDER BY category, sort_order, id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query catalogue: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.ShopItem{}
+	for rows.Next() {
+		item, err := scanShopItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// GetCatalogItem returns one catalogue item
+func (r *ProgressRepository) GetCatalogItem(ctx context.Context, itemID int64) (*models.ShopItem, error) {
+	return getShopItem(ctx, r.db, itemID, false)
+}
+
+// CreateCatalogItem adds an item to the catalogue
+func (r *ProgressRepository) CreateCatalogItem(ctx context.Context, adminID int64, item models.ShopItem) (*models.ShopItem, error) {
+	if err := models.ValidateShopItem(item); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.beginCatalogTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanShopItem(tx.QueryRowContext(ctx, `
+		INSERT INTO character_items (category, name, description, asset_key, asset_type,
+			is_bundled, render_order, sort_order, price, rarity, is_default, is_active,
+			metadata, available_from, available_until, stock)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING `+shopItemColumns,
+		item.Category, item.Name, item.Description, item.AssetKey, item.AssetType,
+		item.IsBundled, item.RenderOrder, item.SortOrder, item.Price, item.Rarity, item.IsDefault, item.IsActive,
+		[]byte(item.Metadata), item.AvailableFrom, item.AvailableUntil, item.Stock,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create item: %w", err)
+	}
+
+	if err := recordShopAudit(ctx, tx, adminID, created.ID, models.ShopAuditCreate,
+		models.DiffShopItems(models.ShopItem{}, *created)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit item: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateCatalogItem applies a partial update to an item. action is the
+// audit action (update or deactivate). An update that changes nothing is
+// not audited.
+func (r *ProgressRepository) UpdateCatalogItem(ctx context.Context, adminID, itemID int64, patch models.ShopItemPatch, action string) (*models.ShopItem, error) {
+	tx, err := r.beginCatalogTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getShopItem(ctx, tx, itemID, true)
+	if err != nil {
+		return nil, err
+	}
+	next := patch.Apply(*current)
+	if err := models.ValidateShopItemUpdate(*current, next); err != nil {
+		return nil, err
+	}
+
+	changes := models.DiffShopItems(*current, next)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	updated, err := scanShopItem(tx.QueryRowContext(ctx, `
+		UPDATE character_items SET
+			category = $2, name = $3, description = $4, asset_key = $5, asset_type = $6,
+			is_bundled = $7, render_order = $8, sort_order = $9, price = $10, rarity = $11,
+			is_default = $12, is_active = $13, metadata = $14,
+			available_from = $15, available_until = $16, stock = $17, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+shopItemColumns,
+		itemID, next.Category, next.Name, next.Description, next.AssetKey, next.AssetType,
+		next.IsBundled, next.RenderOrder, next.SortOrder, next.Price, next.Rarity,
+		next.IsDefault, next.IsActive, []byte(next.Metadata),
+		next.AvailableFrom, next.AvailableUntil, next.Stock,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update item: %w", err)
+	}
+
+	if err := recordShopAudit(ctx, tx, adminID, itemID, action, changes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit item: %w", err)
+	}
+	return updated, nil
+}
+
+// ReorderCatalogItems sets the shop order of a category: itemIDs must list
+// every item of the category once, in display order. Items whose position
+// changed get an audit row.
+func (r *ProgressRepository) ReorderCatalogItems(ctx context.Context, adminID int64, category string, itemIDs []int64) ([]models.ShopItem, error) {
+	tx, err := r.beginCatalogTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, sort_order FROM character_items WHERE category = $1 ORDER BY id FOR UPDATE
+	`, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock category: %w", err)
+	}
+	current := map[int64]int{}
+	for rows.Next() {
+		var id int64
+		var order int
+		if err := rows.Scan(&id, &order); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan item order: %w", err)
+		}
+		current[id] = order
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read item order: %w", err)
+	}
+
+	if len(itemIDs) != len(current) {
+		return nil, ErrShopOrderMismatch
+	}
+	seen := map[int64]bool{}
+	for _, id := range itemIDs {
+		if _, ok := current[id]; !ok || seen[id] {
+			return nil, ErrShopOrderMismatch
+		}
+		seen[id] = true
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE character_items ci SET sort_order = o.position - 1, updated_at = NOW()
+		FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+		WHERE ci.id = o.id AND ci.sort_order <> o.position - 1
+	`, pq.Array(itemIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reorder items: %w", err)
+	}
+
+	for position, id := range itemIDs {
+		if current[id] == position {
+			continue
+		}
+		changes := map[string]models.AuditChange{"sort_order": {Old: current[id], New: position}}
+		if err := recordShopAudit(ctx, tx, adminID, id, models.ShopAuditReorder, changes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit order: %w", err)
+	}
+	return r.ListCatalogItems(ctx, category)
+}
+
+// ListShopAudit returns catalogue changes, newest first. itemID 0 lists
+// changes to all items.
+func (r *ProgressRepository) ListShopAudit(ctx context.Context, itemID int64, limit int) ([]models.ShopAuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.id, a.item_id, COALESCE(ci.name, ''), a.admin_id, COALESCE(u.name, ''),
+		       a.action, a.changes, a.created_at
+		FROM shop_item_audit a
+		LEFT JOIN character_items ci ON ci.id = a.item_id
+		LEFT JOIN users u ON u.id = a.admin_id
+		WHERE $1 = 0 OR a.item_id = $1
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $2
+	`, itemID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shop audit: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.ShopAuditEntry{}
+	for rows.Next() {
+		var e models.ShopAuditEntry
+		var changes []byte
+		err := rows.Scan(&e.ID, &e.ItemID, &e.ItemName, &e.AdminID, &e.AdminName,
+			&e.Action, &changes, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shop audit: %w", err)
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode shop audit changes: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
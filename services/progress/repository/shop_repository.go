@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lemonkorean/progress/models"
+
+	"github.com/lib/pq"
+)
+
+// ================================================================
+// DAILY SHOP
+// ================================================================
+// The shop sells active items inside their availability window. Prices
+// come from the best running sale; purchases and item gifts go through
+// CheckoutShopItem, which prices the item and takes one from limited
+// stock in the buyer's transaction, so stock is only spent if the
+// purchase commits. The featured selection is computed, not stored: see
+// models.FeaturedItemIDs.
+// ================================================================
+
+var (
+	// ErrItemSoldOut is returned when a limited item has no stock left
+	ErrItemSoldOut = errors.New("item sold out")
+
+	// ErrSaleNotFound is returned for unknown or already ended sales
+	ErrSaleNotFound = errors.New("sale not found")
+)
+
+// getShopSettings loads the featured selection settings
+func getShopSettings(ctx context.Context, q DBTX) (models.ShopSettings, error) {
+	s := models.ShopSettings{FeaturedCount: 6, FeaturedScope: models.ShopFeaturedGlobal}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(shop_featured_count, 6), COALESCE(shop_featured_scope, 'global')
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&s.FeaturedCount, &s.FeaturedScope)
+	if err != nil && err != sql.ErrNoRows {
+		return s, fmt.Errorf("failed to get shop settings: %w", err)
+	}
+
+	return s, nil
+}
+
+// getActiveSales returns the biggest sale running at now for each item.
+// nil itemIDs means all items.
+func getActiveSales(ctx context.Context, q DBTX, itemIDs []int64, now time.Time) (map[int64]*models.ShopSale, error) {
+	query := `
+		SELECT DISTINCT ON (item_id) id, item_id, discount_percent, starts_at, ends_at, created_by, created_at
+		FROM shop_sales
+		WHERE starts_at <= $1 AND ends_at > $1`
+	args := []interface{}{now}
+	if itemIDs != nil {
+		query += ` AND item_id = ANY($2)`
+		args = append(args, pq.Array(itemIDs))
+	}
+	query += ` ORDER BY item_id, discount_percent DESC, ends_at`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sales: %w", err)
+	}
+	defer rows.Close()
+
+	sales := map[int64]*models.ShopSale{}
+	for rows.Next() {
+		var s models.ShopSale
+		err := rows.Scan(&s.ID, &s.ItemID, &s.DiscountPercent, &s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sale: %w", err)
+		}
+		sales[s.ItemID] = &s
+	}
+	return sales, rows.Err()
+}
+
+// ListShop returns the items on sale in category (empty for all) at their
+// current prices, and userID's featured selection for today
+func (r *ProgressRepository) ListShop(ctx context.Context, userID int64, category string) (*models.ShopListing, error) {
+	now := time.Now()
+
+	settings, err := getShopSettings(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+shopItemColumns+`
+		FROM character_items
+		WHERE is_active = true
+		ORDER BY category, sort_order, price, render_order
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shop items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.ShopItem{}
+	for rows.Next() {
+		item, err := scanShopItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shop item: %w", err)
+		}
+		if item.AvailableAt(now) {
+			items = append(items, *item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shop items: %w", err)
+	}
+
+	sales, err := getActiveSales(ctx, r.db, nil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &models.ShopListing{
+		Items:             []models.ShopOffer{},
+		Featured:          []models.ShopOffer{},
+		FeaturedScope:     settings.FeaturedScope,
+		FeaturedExpiresAt: models.ShopDay(now).AddDate(0, 0, 1),
+	}
+	offers := map[int64]models.ShopOffer{}
+	pool := []int64{}
+	for _, item := range items {
+		offer := models.NewShopOffer(item, sales[item.ID])
+		offers[item.ID] = offer
+		if offer.Featurable() {
+			pool = append(pool, item.ID)
+		}
+		if category == "" || item.Category == category {
+			listing.Items = append(listing.Items, offer)
+		}
+	}
+
+	for _, id := range models.FeaturedItemIDs(pool, now, settings.FeaturedSeed(userID), settings.FeaturedCount) {
+		listing.Featured = append(listing.Featured, offers[id])
+	}
+	return listing, nil
+}
+
+// CheckoutShopItem prices itemID for a purchase in q's transaction and
+// takes one from its stock. The conditional decrement locks the item row,
+// so concurrent buyers of a limited item are serialized until commit.
+func (r *ProgressRepository) CheckoutShopItem(ctx context.Context, q DBTX, itemID int64) (*models.ShopOffer, error) {
+	now := time.Now()
+
+	item, err := getShopItem(ctx, q, itemID, false)
+	if err != nil {
+		return nil, err
+	}
+	if !item.AvailableAt(now) {
+		return nil, ErrItemUnavailable
+	}
+
+	if item.Stock != nil {
+		err := q.QueryRowContext(ctx, `
+			UPDATE character_items SET stock = stock - 1
+			WHERE id = $1 AND stock > 0
+			RETURNING stock
+		`, itemID).Scan(&item.Stock)
+		if err == sql.ErrNoRows {
+			return nil, ErrItemSoldOut
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to take stock: %w", err)
+		}
+	}
+
+	sales, err := getActiveSales(ctx, q, []int64{itemID}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := models.NewShopOffer(*item, sales[itemID])
+	return &offer, nil
+}
+
+// ListShopSales returns sales that have not ended, or all recent sales
+// when includeEnded is set, newest first
+func (r *ProgressRepository) ListShopSales(ctx context.Context, includeEnded bool, limit int) ([]models.ShopSale, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT s.id, s.item_id, ci.name, s.discount_percent, s.starts_at, s.ends_at, s.created_by, s.created_at
+		FROM shop_sales s
+		JOIN character_items ci ON ci.id = s.item_id
+		WHERE $1 OR s.ends_at > NOW()
+		ORDER BY s.starts_at DESC, s.id DESC
+		LIMIT $2
+	`, includeEnded, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sales: %w", err)
+	}
+	defer rows.Close()
+
+	sales := []models.ShopSale{}
+	for rows.Next() {
+		var s models.ShopSale
+		err := rows.Scan(&s.ID, &s.ItemID, &s.ItemName, &s.DiscountPercent, &s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sale: %w", err)
+		}
+		sales = append(sales, s)
+	}
+	return sales, rows.Err()
+}
+
+// CreateShopSale schedules a discount on an item
+func (r *ProgressRepository) CreateShopSale(ctx context.Context, adminID int64, sale models.ShopSale) (*models.ShopSale, error) {
+	if err := models.ValidateShopSale(sale, time.Now()); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	item, err := getShopItem(ctx, tx, sale.ItemID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	created := sale
+	created.ItemName = item.Name
+	created.CreatedBy = &adminID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO shop_sales (item_id, discount_percent, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, sale.ItemID, sale.DiscountPercent, sale.StartsAt, sale.EndsAt, adminID).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sale: %w", err)
+	}
+
+	changes := map[string]models.AuditChange{"sale": {New: created}}
+	if err := recordShopAudit(ctx, tx, adminID, sale.ItemID, models.ShopAuditSaleCreate, changes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit sale: %w", err)
+	}
+	return &created, nil
+}
+
+// EndShopSale stops a sale now. A sale that has not started yet is
+// removed.
+func (r *ProgressRepository) EndShopSale(ctx context.Context, adminID, saleID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sale models.ShopSale
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, item_id, discount_percent, starts_at, ends_at, created_by, created_at
+		FROM shop_sales
+		WHERE id = $1 AND ends_at > NOW()
+		FOR UPDATE
+	`, saleID).Scan(&sale.ID, &sale.ItemID, &sale.DiscountPercent, &sale.StartsAt, &sale.EndsAt, &sale.CreatedBy, &sale.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrSaleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get sale: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM shop_sales WHERE id = $1 AND starts_at >= NOW()
+	`, saleID)
+	if err != nil {
+		return fmt.Errorf("failed to remove sale: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shop_sales SET ends_at = NOW() WHERE id = $1
+	`, saleID)
+	if err != nil {
+		return fmt.Errorf("failed to end sale: %w", err)
+	}
+
+	changes := map[string]models.AuditChange{"sale": {Old: sale}}
+	if err := recordShopAudit(ctx, tx, adminID, sale.ItemID, models.ShopAuditSaleEnd, changes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sale: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckoutShopItem(t *testing.T) {
+	ctx := context.Background()
+	stock := int64(1)
+	discount := int64(0)
+	d := &dbtest.Driver{Query: func(query string, args []driver.Value) (*dbtest.Rows, error) {
+		switch {
+		case strings.Contains(query, "FROM character_items WHERE id = $1"):
+			return &dbtest.Rows{Columns: shopItemRowColumns, Values: [][]driver.Value{shopItemRow(4, 100, stock)}}, nil
+		case strings.Contains(query, "SET stock = stock - 1"):
+			rows := &dbtest.Rows{Columns: []string{"stock"}}
+			if stock > 0 {
+				stock--
+				rows.Values = [][]driver.Value{{stock}}
+			}
+			return rows, nil
+		case strings.Contains(query, "FROM shop_sales") && discount > 0:
+			now := time.Now()
+			return &dbtest.Rows{
+				Columns: []string{"id", "item_id", "discount_percent", "starts_at", "ends_at", "created_by", "created_at"},
+				Values:  [][]driver.Value{{int64(1), int64(4), discount, now.Add(-time.Hour), now.Add(time.Hour), int64(9), now}},
+			}, nil
+		}
+		return nil, nil
+	}}
+	repo := newTestRepository(t, d)
+	db := dbtest.Open(t, d)
+
+	discount = 25
+	offer, err := repo.CheckoutShopItem(ctx, db, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 75, offer.Price)
+	assert.Equal(t, 100, offer.OriginalPrice)
+	require.NotNil(t, offer.Stock)
+	assert.Equal(t, 0, *offer.Stock)
+
+	_, err = repo.CheckoutShopItem(ctx, db, 4)
+	assert.ErrorIs(t, err, ErrItemSoldOut)
+
+	discount = 0
+	stock = 3
+	offer, err = repo.CheckoutShopItem(ctx, db, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 100, offer.Price, "full price without a sale")
+	assert.Equal(t, 2, *offer.Stock)
+}
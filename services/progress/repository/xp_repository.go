@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"lemonkorean/progress/models"
+	"lemonkorean/progress/outbox"
+)
+
+// ================================================================
+// XP AND LEVELS
+// ================================================================
+// XP is awarded in the transaction of the activity that earned it. Like
+// quests, it is not replayed when projections are rebuilt.
+// ================================================================
+
+// getXPSettings returns the XP amounts per activity
+func getXPSettings(ctx context.Context, q DBTX) (models.XPSettings, error) {
+	s := models.XPSettings{PerLesson: 20, PerReview: 2, PerHangul: 1, PerBossQuiz: 50}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(xp_per_lesson, 20), COALESCE(xp_per_review, 2),
+		       COALESCE(xp_per_hangul, 1), COALESCE(xp_per_boss_quiz, 50)
+		FROM gamification_settings
+		WHERE id = 1
+	`).Scan(&s.PerLesson, &s.PerReview, &s.PerHangul, &s.PerBossQuiz)
+	if err != nil && err != sql.ErrNoRows {
+		return s, fmt.Errorf("failed to get xp settings: %w", err)
+	}
+
+	return s, nil
+}
+
+// awardXPForEvent awards the XP earned by a learning event. A lesson earns
+// XP only the first time it is completed.
+func awardXPForEvent(ctx context.Context, q DBTX, event *models.LearningEvent) error {
+	source, count := "", 1
+	switch event.Type {
+	case models.LearningEventLessonCompleted:
+		first, err := isFirstLessonCompletion(ctx, q, event)
+		if err != nil || !first {
+			return err
+		}
+		source = models.XPSourceLesson
+	case models.LearningEventVocabularyAnswered:
+		source = models.XPSourceReview
+	case models.LearningEventVocabularyBatch:
+		var d models.VocabularyBatchData
+		if err := decodeLearningEvent(event, &d); err != nil {
+			return err
+		}
+		source, count = models.XPSourceReview, len(d.Results)
+	case models.LearningEventHangulPracticed:
+		source = models.XPSourceHangul
+	default:
+		return nil
+	}
+
+	return awardXP(ctx, q, event.UserID, source, count, event.OccurredAt)
+}
+
+// awardXP adds the XP for count activities from source to the user's total.
+// On a level-up the items unlocked by every level reached are added to the
+// inventory and a level_up event is written to the outbox. q must be a
+// transaction.
+func awardXP(ctx context.Context, q DBTX, userID int64, source string, count int, at time.Time) error {
+	settings, err := getXPSettings(ctx, q)
+	if err != nil {
+		return err
+	}
+	amount := settings.Amount(source) * count
+	if amount <= 0 {
+		return nil
+	}
+
+	var totalXP int64
+	var level int
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO user_xp (user_id, total_xp, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET total_xp = user_xp.total_xp + EXCLUDED.total_xp, updated_at = EXCLUDED.updated_at
+		RETURNING total_xp, level
+	`, userID, amount, at).Scan(&totalXP, &level)
+	if err != nil {
+		return fmt.Errorf("failed to award xp: %w", err)
+	}
+
+	var newLevel int
+	err = q.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(level), 1) FROM xp_levels WHERE xp_required <= $1`, totalXP,
+	).Scan(&newLevel)
+	if err != nil {
+		return fmt.Errorf("failed to get xp level: %w", err)
+	}
+	if newLevel <= level {
+		return nil
+	}
+
+	if _, err := q.ExecContext(ctx, `UPDATE user_xp SET level = $2 WHERE user_id = $1`, userID, newLevel); err != nil {
+		return fmt.Errorf("failed to update xp level: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		INSERT INTO user_inventory (user_id, item_id)
+		SELECT $1, unlock_item_id FROM xp_levels
+		WHERE level > $2 AND level <= $3 AND unlock_item_id IS NOT NULL
+		ON CONFLICT (user_id, item_id) DO NOTHING
+		RETURNING item_id
+	`, userID, level, newLevel)
+	if err != nil {
+		return fmt.Errorf("failed to unlock level items: %w", err)
+	}
+	unlocked := []int64{}
+	for rows.Next() {
+		var itemID int64
+		if err := rows.Scan(&itemID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan unlocked item: %w", err)
+		}
+		unlocked = append(unlocked, itemID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to unlock level items: %w", err)
+	}
+
+	payload, err := json.Marshal(&models.LevelUp{
+		FromLevel:       level,
+		ToLevel:         newLevel,
+		TotalXP:         totalXP,
+		Source:          source,
+		UnlockedItemIDs: unlocked,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode level up: %w", err)
+	}
+
+	err = outbox.Enqueue(ctx, q, &outbox.Message{
+		Type:       models.DomainEventLevelUp,
+		UserID:     userID,
+		Payload:    payload,
+		OccurredAt: at,
+	})
+	if err != nil {
+		return err
+	}
+
+	return recordActivity(ctx, q, userID, models.ActivityLevelUp, strconv.Itoa(newLevel), map[string]interface{}{
+		"level": newLevel,
+	})
+}
+
+// getLevelProgress returns the user's level and XP on the current curve
+func (r *ProgressRepository) getLevelProgress(ctx context.Context, userID int64) (models.LevelProgress, error) {
+	var totalXP int64
+	err := r.db.QueryRowContext(ctx, `SELECT total_xp FROM user_xp WHERE user_id = $1`, userID).Scan(&totalXP)
+	if err != nil && err != sql.ErrNoRows {
+		return models.LevelProgress{}, fmt.Errorf("failed to get user xp: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT level, xp_required, unlock_item_id FROM xp_levels ORDER BY level`)
+	if err != nil {
+		return models.LevelProgress{}, fmt.Errorf("failed to query xp levels: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []models.XPLevel
+	for rows.Next() {
+		var l models.XPLevel
+		if err := rows.Scan(&l.Level, &l.XPRequired, &l.UnlockItemID); err != nil {
+			return models.LevelProgress{}, fmt.Errorf("failed to scan xp level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return models.LevelProgress{}, fmt.Errorf("failed to query xp levels: %w", err)
+	}
+
+	return models.NewLevelProgress(levels, totalXP), nil
+}
+
+// withLevelProgress fills in the user's current level and XP. They are read
+// on every call rather than cached with the rest of the stats.
+func (r *ProgressRepository) withLevelProgress(ctx context.Context, stats *models.UserStats) (*models.UserStats, error) {
+	progress, err := r.getLevelProgress(ctx, stats.UserID)
+	if err != nil {
+		return nil, err
+	}
+	stats.LevelProgress = progress
+	return stats, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lemonkorean/progress/dbtest"
+	"lemonkorean/progress/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// learningEventLog answers the queries made while recording learning
+// events. Appended events are kept so repeat checks see earlier ones.
+type learningEventLog struct {
+	mu       sync.Mutex
+	lessons  []int64 // lesson_id of each appended event, by event ID - 1
+	xpAwards int
+}
+
+func (l *learningEventLog) query(query string, args []driver.Value) (*dbtest.Rows, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "INSERT INTO learning_events"):
+		var d models.LessonCompletedData
+		if err := json.Unmarshal(args[2].([]byte), &d); err != nil {
+			return nil, err
+		}
+		l.lessons = append(l.lessons, d.LessonID)
+		return &dbtest.Rows{
+			Columns: []string{"id", "occurred_at"},
+			Values:  [][]driver.Value{{int64(len(l.lessons)), time.Now()}},
+		}, nil
+
+	case strings.Contains(query, "FROM learning_events") && len(args) == 4:
+		repeated := false
+		for _, lessonID := range l.lessons[:args[1].(int64)-1] {
+			repeated = repeated || lessonID == args[3].(int64)
+		}
+		return &dbtest.Rows{Columns: []string{"exists"}, Values: [][]driver.Value{{repeated}}}, nil
+
+	case strings.Contains(query, "FROM user_quests WHERE"):
+		// Quests are already assigned for the period
+		return &dbtest.Rows{Columns: []string{"exists"}, Values: [][]driver.Value{{true}}}, nil
+
+	case strings.Contains(query, "INSERT INTO user_xp"):
+		l.xpAwards++
+		return &dbtest.Rows{
+			Columns: []string{"total_xp", "level"},
+			Values:  [][]driver.Value{{int64(20 * l.xpAwards), int64(1)}},
+		}, nil
+
+	case strings.Contains(query, "FROM xp_levels"):
+		return &dbtest.Rows{Columns: []string{"level"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	}
+	return nil, nil
+}
+
+func TestCompleteLessonAwardsXPOnce(t *testing.T) {
+	ctx := context.Background()
+	log := &learningEventLog{}
+	repo := newTestRepository(t, &dbtest.Driver{Query: log.query})
+
+	complete := func(lessonID int64) {
+		err := repo.CompleteLesson(ctx, &models.CompleteProgressRequest{UserID: 1, LessonID: lessonID, QuizScore: 90, TimeSpent: 5})
+		require.NoError(t, err)
+	}
+
+	complete(12)
+	complete(12)
+	assert.Equal(t, 1, log.xpAwards, "repeating a lesson earns no XP")
+
+	complete(13)
+	assert.Equal(t, 2, log.xpAwards)
+}